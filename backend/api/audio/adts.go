@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"bufio"
+	"io"
+)
+
+// adtsSampleRates lists the sample rates in Hz, indexed by the sampling frequency index of an ADTS header
+var adtsSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// adtsFrameSamples is the number of samples per channel in each raw data block of an AAC frame
+const adtsFrameSamples = 1024
+
+// ReadADTSInfo computes the duration and stream properties of a bare AAC file by walking its ADTS frame headers
+func ReadADTSInfo(r io.ReadSeeker) (*StreamInfo, error) {
+	start, end, err := audioRange(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(r)
+
+	var info *StreamInfo
+	var samples, size int64
+	header := make([]byte, 7)
+	for offset := start; offset+7 <= end; {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+
+		// A 12-bit sync word and layer 0, followed by the profile, sampling frequency index and channel configuration
+		if header[0] != 0xFF || header[1]&0xF6 != 0xF0 {
+			break // Trailing data that is not a frame
+		}
+		sampleRateIndex := int(header[2] >> 2 & 0xF)
+		channels := int(header[2]&0x1)<<2 | int(header[3]>>6)
+		length := int64(header[3]&0x3)<<11 | int64(header[4])<<3 | int64(header[5]>>5)
+		if sampleRateIndex >= len(adtsSampleRates) || length < 7 {
+			break
+		}
+		if info == nil {
+			if channels == 7 {
+				channels = 8 // Configuration 7 is 7.1 surround
+			}
+			info = &StreamInfo{
+				Container:   ContainerADTS,
+				Codec:       "aac",
+				SampleRate:  adtsSampleRates[sampleRateIndex],
+				Channels:    channels,
+				ChannelMode: channelMode(channels),
+			}
+		}
+
+		samples += int64(header[6]&0x3+1) * adtsFrameSamples
+		size += length
+		offset += length
+		if _, err := reader.Discard(int(length - 7)); err != nil {
+			break
+		}
+	}
+	if info == nil {
+		return nil, ErrInvalidStream
+	}
+
+	info.Duration = float64(samples) / float64(info.SampleRate)
+	info.Bitrate = averageBitrate(size, info.Duration)
+	return info, nil
+}
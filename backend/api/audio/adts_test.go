@@ -0,0 +1,87 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+)
+
+// adtsFrames builds count AAC frames of the given length with ADTS headers, each holding blocks raw data blocks
+func adtsFrames(sampleRateIndex, channels, length, blocks, count int) []byte {
+	frame := make([]byte, length)
+	frame[0], frame[1] = 0xFF, 0xF1 // Sync word, MPEG-4 and no CRC
+	frame[2] = 1<<6 | byte(sampleRateIndex)<<2 | byte(channels>>2)
+	frame[3] = byte(channels&0x3)<<6 | byte(length>>11)
+	frame[4] = byte(length >> 3)
+	frame[5] = byte(length&0x7)<<5 | 0x1F
+	frame[6] = 0xFC | byte(blocks-1)
+	return bytes.Repeat(frame, count)
+}
+
+func TestReadADTSInfo(t *testing.T) {
+	tag := id3v2Tag(4, 0, frame24("TIT2", 0, text(3, "Song")))
+
+	tests := []struct {
+		name string
+		data []byte
+		want StreamInfo
+	}{
+		{
+			name: "stereo at 48 kHz",
+			data: adtsFrames(3, 2, 256, 1, 375),
+			want: StreamInfo{Container: ContainerADTS, Codec: "aac", Duration: 8, Bitrate: 96, SampleRate: 48000, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+		{
+			name: "between tags and trailing data",
+			data: join(tag, adtsFrames(3, 2, 256, 1, 375), []byte("trailing data")),
+			want: StreamInfo{Container: ContainerADTS, Codec: "aac", Duration: 8, Bitrate: 96, SampleRate: 48000, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+		{
+			name: "mono with several raw data blocks per frame",
+			data: adtsFrames(8, 1, 400, 4, 50),
+			want: StreamInfo{Container: ContainerADTS, Codec: "aac", Duration: 12.8, Bitrate: 12, SampleRate: 16000, Channels: 1, ChannelMode: ChannelModeMono},
+		},
+		{
+			name: "7.1 surround",
+			data: adtsFrames(4, 7, 1000, 1, 441),
+			want: StreamInfo{Container: ContainerADTS, Codec: "aac", Duration: 441 * 1024 / 44100.0, Bitrate: 344, SampleRate: 44100, Channels: 8, ChannelMode: ChannelModeMultichannel},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ReadStreamInfo(bytes.NewReader(test.data), ContainerADTS)
+			if err != nil {
+				t.Fatalf("ReadStreamInfo: %v", err)
+			}
+			checkStreamInfo(t, info, test.want)
+		})
+	}
+}
+
+func TestReadADTSInfoInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":                      nil,
+		"MPEG frames":                mpegFrames(header128, 384, 10),
+		"reserved sample rate":       adtsFrames(13, 2, 256, 1, 10),
+		"length shorter than header": []byte("\xff\xf1\x4c\x80\x00\x1f\xfc"), // A declared length of zero
+		"truncated header":           adtsFrames(3, 2, 256, 1, 1)[:6],
+	} {
+		if _, err := ReadStreamInfo(bytes.NewReader(data), ContainerADTS); err != ErrInvalidStream {
+			t.Errorf("ReadStreamInfo(%s) error = %v, want ErrInvalidStream", name, err)
+		}
+	}
+}
+
+func TestReadADTSTags(t *testing.T) {
+	tag := id3v2Tag(3, 0, join(frame23("TIT2", text(0, "Song")), frame23("TCON", text(0, "(8)"))))
+
+	tags, err := ReadTags(bytes.NewReader(join(tag, adtsFrames(3, 2, 256, 1, 10))), ContainerADTS)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	checkTags(t, tags, "Song", "", "", "Jazz", 0)
+
+	if _, err := ReadTags(bytes.NewReader(adtsFrames(3, 2, 256, 1, 10)), ContainerADTS); err != ErrNoTags {
+		t.Errorf("ReadTags without tags error = %v, want ErrNoTags", err)
+	}
+}
@@ -0,0 +1,181 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// ErrUnsupportedCodec is returned when the audio stream of a file cannot be decoded without ffmpeg
+var ErrUnsupportedCodec = errors.New("audio codec cannot be decoded")
+
+// Decoder decodes an audio stream into interleaved samples in the range [-1, 1]
+type Decoder interface {
+	SampleRate() int // Sample rate in Hz
+	Channels() int   // Number of interleaved channels
+
+	// Read decodes up to len(samples) samples, returning io.EOF once the stream is over
+	Read(samples []float32) (int, error)
+}
+
+// NewDecoder returns a decoder of the audio stream of a file stored in the given container. MP3 files and WAV
+// files holding integer or float PCM samples are decoded natively; other streams need ffmpeg to decode them
+func NewDecoder(r io.ReadSeeker, container string) (Decoder, error) {
+	switch container {
+	case ContainerMPEG:
+		return newMP3Decoder(r)
+	case ContainerWAV:
+		return newWAVDecoder(r)
+	}
+	return nil, ErrUnsupportedCodec
+}
+
+// NewPCMDecoder returns a decoder of raw interleaved 32-bit little-endian float samples, as written by ffmpeg with -f f32le
+func NewPCMDecoder(r io.Reader, sampleRate, channels int) Decoder {
+	return &pcmDecoder{r: bufio.NewReader(r), sampleRate: sampleRate, channels: channels, sampleSize: 4, sample: float32Sample, remaining: -1}
+}
+
+// newMP3Decoder returns a decoder of an MP3 file, whose frames are decoded to 16-bit stereo samples
+func newMP3Decoder(r io.ReadSeeker) (Decoder, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, ErrInvalidStream
+	}
+	return &pcmDecoder{r: decoder, sampleRate: decoder.SampleRate(), channels: 2, sampleSize: 2, sample: int16Sample, remaining: -1}, nil
+}
+
+// newWAVDecoder returns a decoder of the samples of the data chunk of a WAV file
+func newWAVDecoder(r io.ReadSeeker) (Decoder, error) {
+	var format []byte
+	var dataOffset, dataSize int64
+	err := walkRIFFChunks(r, func(id string, size int64, read func() ([]byte, error)) error {
+		switch id {
+		case "fmt ":
+			var err error
+			format, err = read()
+			return err
+		case "data":
+			offset, err := r.Seek(0, io.SeekCurrent) // The body of the chunk starts right after its header
+			dataOffset, dataSize = offset, size
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(format) < 16 || dataSize == 0 {
+		return nil, ErrInvalidStream
+	}
+
+	code := binary.LittleEndian.Uint16(format[0:2])
+	channels := int(binary.LittleEndian.Uint16(format[2:4]))
+	sampleRate := int(binary.LittleEndian.Uint32(format[4:8]))
+	bitDepth := int(binary.LittleEndian.Uint16(format[14:16]))
+	if code == waveFormatExtensible && len(format) >= 26 {
+		code = binary.LittleEndian.Uint16(format[24:26]) // The sub-format GUID starts with the format code
+	}
+	if channels == 0 || sampleRate == 0 {
+		return nil, ErrInvalidStream
+	}
+
+	var sample func([]byte) float32
+	switch {
+	case code == waveFormatPCM && bitDepth == 8:
+		sample = uint8Sample
+	case code == waveFormatPCM && bitDepth == 16:
+		sample = int16Sample
+	case code == waveFormatPCM && bitDepth == 24:
+		sample = int24Sample
+	case code == waveFormatPCM && bitDepth == 32:
+		sample = int32Sample
+	case code == waveFormatIEEEFloat && bitDepth == 32:
+		sample = float32Sample
+	case code == waveFormatIEEEFloat && bitDepth == 64:
+		sample = float64Sample
+	default:
+		return nil, ErrUnsupportedCodec // A-law and μ-law samples, or unusual bit depths
+	}
+
+	if _, err := r.Seek(dataOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &pcmDecoder{r: bufio.NewReader(r), sampleRate: sampleRate, channels: channels, sampleSize: bitDepth / 8, sample: sample, remaining: dataSize}, nil
+}
+
+// pcmDecoder decodes a stream of interleaved fixed-size samples
+type pcmDecoder struct {
+	r          io.Reader
+	sampleRate int
+	channels   int
+	sampleSize int                  // Bytes per sample
+	sample     func([]byte) float32 // Converts the bytes of a sample to a value in [-1, 1]
+	remaining  int64                // Bytes left in the stream, or -1 when it runs to the end of the reader
+	buf        []byte
+}
+
+func (d *pcmDecoder) SampleRate() int { return d.sampleRate }
+func (d *pcmDecoder) Channels() int   { return d.channels }
+
+func (d *pcmDecoder) Read(samples []float32) (int, error) {
+	n := len(samples)
+	if d.remaining >= 0 {
+		n = min(n, int(min(d.remaining/int64(d.sampleSize), math.MaxInt32)))
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+
+	if cap(d.buf) < n*d.sampleSize {
+		d.buf = make([]byte, n*d.sampleSize)
+	}
+	buf := d.buf[:n*d.sampleSize]
+	read, err := io.ReadFull(d.r, buf)
+	count := read / d.sampleSize
+	for i := 0; i < count; i++ {
+		samples[i] = d.sample(buf[i*d.sampleSize:])
+	}
+	if d.remaining >= 0 {
+		d.remaining -= int64(read)
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		d.remaining = 0 // A truncated stream ends with its last whole sample
+		if count > 0 {
+			return count, nil
+		}
+		return 0, io.EOF
+	}
+	return count, err
+}
+
+// Conversions of the little-endian samples of PCM streams to values in [-1, 1]
+
+func uint8Sample(b []byte) float32 { return (float32(b[0]) - 128) / 128 }
+
+func int16Sample(b []byte) float32 {
+	return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+}
+
+func int24Sample(b []byte) float32 {
+	return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+}
+
+func int32Sample(b []byte) float32 {
+	return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+}
+
+func float32Sample(b []byte) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+func float64Sample(b []byte) float32 {
+	return float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+}
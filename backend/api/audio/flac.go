@@ -0,0 +1,124 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Types of FLAC metadata blocks
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockVorbisComment = 4
+	flacBlockPicture       = 6
+)
+
+// ReadFLACInfo computes the duration and stream properties of a FLAC file from its STREAMINFO block
+func ReadFLACInfo(r io.ReadSeeker) (*StreamInfo, error) {
+	info, _, err := readFLAC(r, false)
+	return info, err
+}
+
+// ReadFLACTags reads the Vorbis comment and the pictures stored in the metadata blocks of a FLAC file
+func ReadFLACTags(r io.ReadSeeker) (*Tags, error) {
+	_, tags, err := readFLAC(r, true)
+	if err != nil {
+		return nil, err
+	}
+	if tags.Format == "" && len(tags.Pictures) == 0 {
+		return nil, ErrNoTags
+	}
+	return tags, nil
+}
+
+// readFLAC walks the metadata blocks of a FLAC file, reading the stream properties and, if asked for, the tags
+func readFLAC(r io.ReadSeeker, withTags bool) (*StreamInfo, *Tags, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	marker := make([]byte, 4)
+	if _, err := io.ReadFull(r, marker); err != nil || string(marker) != "fLaC" {
+		return nil, nil, ErrInvalidStream
+	}
+
+	var info *StreamInfo
+	tags := &Tags{Frames: map[string]string{}, kind: tagKindVorbis}
+	offset := int64(4)
+	for last := false; !last; {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, nil, ErrInvalidStream
+		}
+		last = header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += 4 + length
+
+		// Only read the blocks that are needed, skipping padding, seek tables and the like
+		if blockType != flacBlockStreamInfo && (!withTags || blockType != flacBlockVorbisComment && blockType != flacBlockPicture) {
+			if _, err := r.Seek(offset, io.SeekStart); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, nil, ErrInvalidStream
+		}
+
+		switch blockType {
+		case flacBlockStreamInfo:
+			info = parseFLACStreamInfo(body)
+			if info == nil {
+				return nil, nil, ErrInvalidStream
+			}
+		case flacBlockVorbisComment:
+			if parseVorbisComment(body, tags) {
+				tags.Format = vorbisCommentFormat
+			}
+		case flacBlockPicture:
+			if picture, ok := parsePictureBlock(body); ok {
+				tags.Pictures = append(tags.Pictures, picture)
+			}
+		}
+	}
+	if info == nil {
+		return nil, nil, ErrInvalidStream
+	}
+
+	// The audio frames follow the last metadata block
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.Bitrate = averageBitrate(end-offset, info.Duration)
+
+	return info, tags, nil
+}
+
+// parseFLACStreamInfo parses a STREAMINFO block, returning nil if it is malformed or does not record the length of the stream
+func parseFLACStreamInfo(body []byte) *StreamInfo {
+	if len(body) < 18 {
+		return nil
+	}
+
+	// After the block and frame sizes, 20 bits of sample rate, 3 bits of channels minus one,
+	// 5 bits of bits per sample minus one and 36 bits of total samples
+	fields := binary.BigEndian.Uint64(body[10:18])
+	sampleRate := int(fields >> 44)
+	channels := int(fields>>41&0x7) + 1
+	bitDepth := int(fields>>36&0x1F) + 1
+	samples := int64(fields & 0xFFFFFFFFF)
+	if sampleRate == 0 || samples == 0 {
+		return nil
+	}
+
+	return &StreamInfo{
+		Container:   ContainerFLAC,
+		Codec:       "flac",
+		BitDepth:    bitDepth,
+		Duration:    float64(samples) / float64(sampleRate),
+		SampleRate:  sampleRate,
+		Channels:    channels,
+		ChannelMode: channelMode(channels),
+	}
+}
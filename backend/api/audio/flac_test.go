@@ -0,0 +1,118 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// flacBlock builds a metadata block of the type; flacFile marks the last one
+func flacBlock(blockType byte, body []byte) []byte {
+	return append([]byte{blockType, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+// streamInfoBlock builds a STREAMINFO block of a stream of the given properties
+func streamInfoBlock(sampleRate, channels, bitDepth int, samples uint64) []byte {
+	body := make([]byte, 34)
+	fields := uint64(sampleRate)<<44 | uint64(channels-1)<<41 | uint64(bitDepth-1)<<36 | samples
+	binary.BigEndian.PutUint64(body[10:18], fields)
+	return flacBlock(flacBlockStreamInfo, body)
+}
+
+// flacFile builds a FLAC file of the metadata blocks followed by audioSize bytes of frames
+func flacFile(audioSize int, blocks ...[]byte) []byte {
+	data := []byte("fLaC")
+	for i, block := range blocks {
+		if i == len(blocks)-1 {
+			block = append([]byte{block[0] | 0x80}, block[1:]...)
+		}
+		data = append(data, block...)
+	}
+	return append(data, make([]byte, audioSize)...)
+}
+
+func TestReadFLACInfo(t *testing.T) {
+	padding := flacBlock(1, make([]byte, 100))
+	seekTable := flacBlock(3, make([]byte, 18))
+
+	tests := []struct {
+		name string
+		data []byte
+		want StreamInfo
+	}{
+		{
+			name: "CD audio",
+			data: flacFile(700000, streamInfoBlock(44100, 2, 16, 441000)),
+			want: StreamInfo{Container: ContainerFLAC, Codec: "flac", BitDepth: 16, Duration: 10, Bitrate: 560, SampleRate: 44100, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+		{
+			name: "high resolution surround",
+			data: flacFile(3000000, streamInfoBlock(96000, 6, 24, 480000), seekTable, padding),
+			want: StreamInfo{Container: ContainerFLAC, Codec: "flac", BitDepth: 24, Duration: 5, Bitrate: 4800, SampleRate: 96000, Channels: 6, ChannelMode: ChannelModeMultichannel},
+		},
+		{
+			name: "mono after skipped blocks",
+			data: flacFile(50000, padding, streamInfoBlock(8000, 1, 8, 20000), padding),
+			want: StreamInfo{Container: ContainerFLAC, Codec: "flac", BitDepth: 8, Duration: 2.5, Bitrate: 160, SampleRate: 8000, Channels: 1, ChannelMode: ChannelModeMono},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ReadStreamInfo(bytes.NewReader(test.data), ContainerFLAC)
+			if err != nil {
+				t.Fatalf("ReadStreamInfo: %v", err)
+			}
+			checkStreamInfo(t, info, test.want)
+		})
+	}
+}
+
+func TestReadFLACInfoInvalid(t *testing.T) {
+	streamInfo := streamInfoBlock(44100, 2, 16, 441000)
+
+	for name, data := range map[string][]byte{
+		"empty":                     nil,
+		"another format":            mpegFrames(header128, 384, 10),
+		"no STREAMINFO":             flacFile(1000, flacBlock(1, make([]byte, 10))),
+		"unknown length":            flacFile(1000, streamInfoBlock(44100, 2, 16, 0)),
+		"no sample rate":            flacFile(1000, streamInfoBlock(0, 2, 16, 441000)),
+		"short STREAMINFO":          flacFile(1000, flacBlock(flacBlockStreamInfo, make([]byte, 12))),
+		"truncated STREAMINFO":      flacFile(0, streamInfo)[:20],
+		"no last block":             append([]byte("fLaC"), streamInfo...),
+		"block length past the end": flacFile(0, streamInfo, flacBlock(flacBlockVorbisComment, nil)[:2]),
+	} {
+		if _, err := ReadStreamInfo(bytes.NewReader(data), ContainerFLAC); err != ErrInvalidStream {
+			t.Errorf("ReadStreamInfo(%s) error = %v, want ErrInvalidStream", name, err)
+		}
+	}
+}
+
+func TestReadFLACTags(t *testing.T) {
+	streamInfo := streamInfoBlock(44100, 2, 16, 441000)
+	comment := flacBlock(flacBlockVorbisComment, vorbisComment("TITLE=Song", "ARTIST=Band", "ALBUM=Record", "GENRE=Jazz", "DATE=2001"))
+	back := flacBlock(flacBlockPicture, pictureBlock(4, "image/jpeg", "Back", []byte{0xFF, 0xD8}))
+	front := flacBlock(flacBlockPicture, pictureBlock(PictureTypeFrontCover, "image/png", "Front", []byte("\x89PNG")))
+
+	tags, err := ReadTags(bytes.NewReader(flacFile(100, streamInfo, comment, back, front)), ContainerFLAC)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	if tags.Format != vorbisCommentFormat {
+		t.Errorf("Format = %q, want %q", tags.Format, vorbisCommentFormat)
+	}
+	checkTags(t, tags, "Song", "Band", "Record", "Jazz", 2001)
+	if len(tags.Pictures) != 2 || tags.CoverArt().Description != "Front" {
+		t.Errorf("Pictures = %+v, want the back and front covers", tags.Pictures)
+	}
+
+	// Pictures alone are tags too
+	tags, err = ReadTags(bytes.NewReader(flacFile(100, streamInfo, front)), ContainerFLAC)
+	if err != nil || tags.Format != "" || len(tags.Pictures) != 1 {
+		t.Errorf("ReadTags of a picture = (%+v, %v), want the picture", tags, err)
+	}
+
+	if _, err := ReadTags(bytes.NewReader(flacFile(100, streamInfo)), ContainerFLAC); err != ErrNoTags {
+		t.Errorf("ReadTags without tags error = %v, want ErrNoTags", err)
+	}
+}
@@ -0,0 +1,87 @@
+package audio
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrInvalidStream is returned when a file holds no audio stream of its container that can be read
+var ErrInvalidStream = errors.New("no valid audio stream found")
+
+// Containers audio streams are stored in
+const (
+	ContainerMPEG = "mpeg" // Bare MPEG audio frames, e.g. an MP3 file
+	ContainerADTS = "adts" // Bare AAC frames with ADTS headers
+	ContainerMP4  = "mp4"  // MPEG-4 file, e.g. an M4A file holding AAC or ALAC
+	ContainerFLAC = "flac" // Native FLAC file
+	ContainerOgg  = "ogg"  // Ogg file holding Vorbis or Opus
+	ContainerWAV  = "wav"  // RIFF WAVE file holding PCM samples
+)
+
+// maxTagSize caps the size of a block of tags, or of a header holding them, read into memory
+const maxTagSize = 64 << 20
+
+// ReadStreamInfo computes the duration and stream properties of an audio file stored in the given container
+func ReadStreamInfo(r io.ReadSeeker, container string) (*StreamInfo, error) {
+	switch container {
+	case ContainerMPEG:
+		return ReadMPEGInfo(r)
+	case ContainerADTS:
+		return ReadADTSInfo(r)
+	case ContainerMP4:
+		return ReadMP4Info(r)
+	case ContainerFLAC:
+		return ReadFLACInfo(r)
+	case ContainerOgg:
+		return ReadOggInfo(r)
+	case ContainerWAV:
+		return ReadWAVInfo(r)
+	}
+	return nil, ErrInvalidStream
+}
+
+// ReadTags reads the tags of an audio file in the form native to its container: ID3 for bare MPEG and AAC
+// frames, Vorbis comments for FLAC and Ogg, iTunes metadata atoms for MP4 and INFO chunks or ID3 for WAV
+func ReadTags(r io.ReadSeeker, container string) (*Tags, error) {
+	switch container {
+	case ContainerMPEG, ContainerADTS:
+		return ReadID3(r)
+	case ContainerMP4:
+		return ReadMP4Tags(r)
+	case ContainerFLAC:
+		return ReadFLACTags(r)
+	case ContainerOgg:
+		return ReadOggTags(r)
+	case ContainerWAV:
+		return ReadWAVTags(r)
+	}
+	return nil, ErrNoTags
+}
+
+// channelMode returns the channel mode of a stream that only records its number of channels
+func channelMode(channels int) string {
+	switch channels {
+	case 1:
+		return ChannelModeMono
+	case 2:
+		return ChannelModeStereo
+	}
+	return ChannelModeMultichannel
+}
+
+// averageBitrate returns the average bitrate in kbit/s of a stream of the given size in bytes and duration in seconds
+func averageBitrate(size int64, duration float64) int {
+	if duration <= 0 {
+		return 0
+	}
+	return int(float64(size) * 8 / duration / 1000)
+}
+
+// IsLossless reports whether a codec compresses audio without loss, so its bitrate says nothing about its quality
+func IsLossless(codec string) bool {
+	switch codec {
+	case "flac", "alac", "pcm", "pcm_float":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// checkStreamInfo compares the stream properties read from a file with the expected ones, allowing for rounding
+// of the duration
+func checkStreamInfo(t *testing.T, info *StreamInfo, want StreamInfo) {
+	t.Helper()
+	if math.Abs(info.Duration-want.Duration) > 1e-9 {
+		t.Errorf("Duration = %v, want %v", info.Duration, want.Duration)
+	}
+	got := *info
+	got.Duration = want.Duration
+	if got != want {
+		t.Errorf("info = %+v, want %+v", got, want)
+	}
+}
+
+// checkTags compares the text fields of the tags with the expected title, artist, album, genre and year
+func checkTags(t *testing.T, tags *Tags, title, artist, album, genre string, year int) {
+	t.Helper()
+	if tags.Title() != title || tags.Artist() != artist || tags.Album() != album || tags.Genre() != genre || tags.Year() != year {
+		t.Errorf("tags = %q, %q, %q, %q, %d, want %q, %q, %q, %q, %d",
+			tags.Title(), tags.Artist(), tags.Album(), tags.Genre(), tags.Year(), title, artist, album, genre, year)
+	}
+}
+
+func TestReadStreamInfoUnknownContainer(t *testing.T) {
+	data := mpegFrames(header128, 384, 10)
+
+	if _, err := ReadStreamInfo(bytes.NewReader(data), "avi"); err != ErrInvalidStream {
+		t.Errorf("ReadStreamInfo error = %v, want ErrInvalidStream", err)
+	}
+	if _, err := ReadTags(bytes.NewReader(data), "avi"); err != ErrNoTags {
+		t.Errorf("ReadTags error = %v, want ErrNoTags", err)
+	}
+}
+
+func TestChannelMode(t *testing.T) {
+	for channels, mode := range map[int]string{1: ChannelModeMono, 2: ChannelModeStereo, 6: ChannelModeMultichannel} {
+		if got := channelMode(channels); got != mode {
+			t.Errorf("channelMode(%d) = %s, want %s", channels, got, mode)
+		}
+	}
+}
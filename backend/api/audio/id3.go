@@ -0,0 +1,424 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrNoTags is returned when a file carries no tags that can be read
+var ErrNoTags = errors.New("no tags found")
+
+// maxID3v2Size caps the size of an ID3v2 tag read into memory
+const maxID3v2Size = 64 << 20
+
+// id3v22Frames maps the three-character ID3v2.2 frame IDs to their ID3v2.3 equivalents
+var id3v22Frames = map[string]string{
+	"TT1": "TIT1", "TT2": "TIT2", "TT3": "TIT3",
+	"TP1": "TPE1", "TP2": "TPE2", "TP3": "TPE3", "TP4": "TPE4",
+	"TAL": "TALB", "TCO": "TCON", "TYE": "TYER", "TRK": "TRCK", "TPA": "TPOS",
+	"TCM": "TCOM", "TLE": "TLEN", "TBP": "TBPM", "TKE": "TKEY", "TLA": "TLAN",
+	"TPB": "TPUB", "TCR": "TCOP", "TEN": "TENC", "TSS": "TSSE", "TOR": "TORY",
+	"TXX": "TXXX", "COM": "COMM",
+}
+
+// ReadID3 reads the ID3v2 tag at the start and the ID3v1 tag at the end of an MP3 file;
+// ID3v2 frames take precedence and ID3v1 fills in the fields they lack
+func ReadID3(r io.ReadSeeker) (*Tags, error) {
+	tags := &Tags{Frames: map[string]string{}}
+
+	// Read the ID3v2 tag at the start of the file
+	version, body, unsynchronised, err := readID3v2(r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		tags.Format = fmt.Sprintf("ID3v2.%d", version)
+		parseID3v2Frames(version, body, unsynchronised, tags)
+	}
+
+	// Read the ID3v1 tag in the last 128 bytes of the file
+	v1Format, v1Frames, err := readID3v1(r)
+	if err != nil {
+		return nil, err
+	}
+	if tags.Format == "" {
+		tags.Format = v1Format
+	}
+	for id, value := range v1Frames {
+		if value != "" && tags.Frames[id] == "" {
+			tags.Frames[id] = value
+		}
+	}
+
+	if tags.Format == "" {
+		return nil, ErrNoTags
+	}
+	return tags, nil
+}
+
+// ID3v2Size returns the size of the ID3v2 tag at the start of the file, including its header and footer, or 0 if there is none
+func ID3v2Size(r io.ReadSeeker) (int64, error) {
+	header := make([]byte, 10)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil // Too short to hold a tag
+		}
+		return 0, err
+	}
+	if string(header[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	size := int64(syncsafe(header[6:10])) + 10
+	if header[3] == 4 && header[5]&0x10 != 0 {
+		size += 10 // ID3v2.4 tags may end with a footer
+	}
+	return size, nil
+}
+
+// readID3v2 reads the ID3v2 tag at the start of the file and returns its major version, the frame data
+// following the extended header and whether an ID3v2.4 tag marks all frames as unsynchronised;
+// the body is nil if the file has no supported ID3v2 tag
+func readID3v2(r io.ReadSeeker) (byte, []byte, bool, error) {
+	header := make([]byte, 10)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, nil, false, err
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, false, nil // Too short to hold a tag
+		}
+		return 0, nil, false, err
+	}
+	if string(header[:3]) != "ID3" {
+		return 0, nil, false, nil
+	}
+
+	version, flags, size := header[3], header[5], syncsafe(header[6:10])
+	if version < 2 || version > 4 || size > maxID3v2Size {
+		return 0, nil, false, nil // Unknown versions cannot be parsed
+	}
+	if version == 2 && flags&0x40 != 0 {
+		return 0, nil, false, nil // ID3v2.2 compression was never defined
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, false, nil // Truncated tags are ignored
+		}
+		return 0, nil, false, err
+	}
+
+	// Before ID3v2.4 unsynchronisation applies to the whole tag
+	if version < 4 && flags&0x80 != 0 {
+		body = unsynchronise(body)
+	}
+
+	// Skip the extended header
+	if version > 2 && flags&0x40 != 0 && len(body) >= 4 {
+		extended := int(binary.BigEndian.Uint32(body[:4])) + 4 // The ID3v2.3 size excludes the size field
+		if version == 4 {
+			extended = int(syncsafe(body[:4])) // The ID3v2.4 size includes it
+		}
+		if extended > len(body) {
+			return 0, nil, false, nil
+		}
+		body = body[extended:]
+	}
+
+	// In ID3v2.4 the tag flag marks every frame as unsynchronised
+	return version, body, version == 4 && flags&0x80 != 0, nil
+}
+
+// parseID3v2Frames decodes the text and picture frames of an ID3v2 tag body into the tags
+func parseID3v2Frames(version byte, body []byte, tagUnsynchronised bool, tags *Tags) {
+	headerSize := 10
+	if version == 2 {
+		headerSize = 6
+	}
+
+	for len(body) >= headerSize && body[0] != 0 { // Padding starts with a zero byte
+		var id string
+		var size int
+		var formatFlags byte
+		switch version {
+		case 2:
+			id, size = string(body[:3]), int(body[3])<<16|int(body[4])<<8|int(body[5])
+		case 3:
+			id, size, formatFlags = string(body[:4]), int(binary.BigEndian.Uint32(body[4:8])), body[9]
+		default:
+			id, size, formatFlags = string(body[:4]), int(syncsafe(body[4:8])), body[9]
+		}
+		if size > len(body)-headerSize {
+			return // The frame runs past the end of the tag
+		}
+		data := body[headerSize : headerSize+size]
+		body = body[headerSize+size:]
+
+		if version == 2 {
+			if mapped, ok := id3v22Frames[id]; ok {
+				id = mapped
+			}
+		}
+
+		data, ok := frameData(version, formatFlags, tagUnsynchronised, data)
+		if !ok {
+			continue
+		}
+
+		decodeID3v2Frame(id, data, tags)
+	}
+}
+
+// frameData strips the format-specific additions from a frame's data and reports whether the frame can be read
+func frameData(version, formatFlags byte, tagUnsynchronised bool, data []byte) ([]byte, bool) {
+	switch version {
+	case 3:
+		if formatFlags&0xC0 != 0 {
+			return nil, false // Compressed and encrypted frames are skipped
+		}
+		if formatFlags&0x20 != 0 {
+			if len(data) < 1 {
+				return nil, false
+			}
+			data = data[1:] // Skip the group identifier
+		}
+	case 4:
+		if formatFlags&0x0C != 0 {
+			return nil, false // Compressed and encrypted frames are skipped
+		}
+		if formatFlags&0x40 != 0 {
+			if len(data) < 1 {
+				return nil, false
+			}
+			data = data[1:] // Skip the group identifier
+		}
+		if formatFlags&0x01 != 0 {
+			if len(data) < 4 {
+				return nil, false
+			}
+			data = data[4:] // Skip the data length indicator
+		}
+		if formatFlags&0x02 != 0 || tagUnsynchronised {
+			data = unsynchronise(data)
+		}
+	}
+	return data, true
+}
+
+// decodeID3v2Frame stores the value of a text, comment, URL or picture frame; other frames are ignored
+func decodeID3v2Frame(id string, data []byte, tags *Tags) {
+	if len(data) == 0 {
+		return
+	}
+
+	frames := tags.Frames
+	switch {
+	case id == "APIC":
+		// Attached picture: encoding, MIME type, picture type, description, data
+		mimeType, rest := splitTerminated(0, data[1:])
+		if len(rest) < 1 {
+			return
+		}
+		description, picture := splitTerminated(data[0], rest[1:])
+		tags.Pictures = append(tags.Pictures, Picture{
+			MIMEType:    pictureMIMEType(decodeText(0, mimeType)),
+			Type:        rest[0],
+			Description: decodeText(data[0], description),
+			Data:        picture,
+		})
+	case id == "PIC":
+		// ID3v2.2 attached picture: encoding, three-character image format, picture type, description, data
+		if len(data) < 5 {
+			return
+		}
+		description, picture := splitTerminated(data[0], data[5:])
+		tags.Pictures = append(tags.Pictures, Picture{
+			MIMEType:    pictureMIMEType(string(data[1:4])),
+			Type:        data[4],
+			Description: decodeText(data[0], description),
+			Data:        picture,
+		})
+	case id == "TXXX":
+		// User-defined text: encoding, description, value
+		description, value := splitTerminated(data[0], data[1:])
+		key := frameKey("TXXX", decodeText(data[0], description))
+		if frames[key] == "" {
+			frames[key] = decodeText(data[0], value)
+		}
+	case id == "COMM":
+		// Comment: encoding, language, description, text; described comments such as iTunNORM are kept apart
+		if len(data) < 4 {
+			return
+		}
+		description, value := splitTerminated(data[0], data[4:])
+		key := frameKey("COMM", decodeText(data[0], description))
+		if frames[key] == "" {
+			frames[key] = decodeText(data[0], value)
+		}
+	case strings.HasPrefix(id, "T"):
+		// Text frame: encoding followed by one or more null-separated values
+		if frames[id] == "" {
+			frames[id] = decodeText(data[0], data[1:])
+		}
+	case strings.HasPrefix(id, "W") && id != "WXXX":
+		// URL frame: ISO-8859-1 text
+		if frames[id] == "" {
+			frames[id] = decodeText(0, data)
+		}
+	}
+}
+
+// pictureMIMEType normalises the MIME type or ID3v2.2 image format of a picture
+func pictureMIMEType(format string) string {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg", "image/jpg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	}
+	return strings.ToLower(format)
+}
+
+// frameKey returns the key a described frame is stored under, e.g. "TXXX:REPLAYGAIN_TRACK_GAIN";
+// characters MongoDB does not allow in field names are replaced
+func frameKey(id, description string) string {
+	if description == "" {
+		return id
+	}
+	return id + ":" + strings.NewReplacer(".", "_", "$", "_").Replace(description)
+}
+
+// readID3v1 reads the ID3v1 tag in the last 128 bytes of the file, mapping its fields to ID3v2 frame IDs
+func readID3v1(r io.ReadSeeker) (string, map[string]string, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", nil, err
+	}
+	if end < 128 {
+		return "", nil, nil // Too short to hold a tag
+	}
+
+	tag := make([]byte, 128)
+	if _, err := r.Seek(end-128, io.SeekStart); err != nil {
+		return "", nil, err
+	}
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return "", nil, err
+	}
+	if string(tag[:3]) != "TAG" {
+		return "", nil, nil
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimRight(decodeText(0, bytes.TrimRight(b, "\x00")), " ")
+	}
+
+	format := "ID3v1"
+	frames := map[string]string{
+		"TIT2": field(tag[3:33]),
+		"TPE1": field(tag[33:63]),
+		"TALB": field(tag[63:93]),
+		"TYER": field(tag[93:97]),
+		"COMM": field(tag[97:127]),
+	}
+
+	// ID3v1.1 stores the track number in the last byte of the comment
+	if tag[125] == 0 && tag[126] != 0 {
+		format = "ID3v1.1"
+		frames["COMM"] = field(tag[97:125])
+		frames["TRCK"] = fmt.Sprint(tag[126])
+	}
+
+	// 255 marks an unset genre
+	if int(tag[127]) < len(genres) {
+		frames["TCON"] = genres[tag[127]]
+	}
+
+	return format, frames, nil
+}
+
+// decodeText decodes ID3v2 text in the given encoding, joining multiple null-separated values with "/"
+func decodeText(encoding byte, data []byte) string {
+	var values []string
+	for len(data) > 0 {
+		var value []byte
+		value, data = splitTerminated(encoding, data)
+
+		var text string
+		switch encoding {
+		case 0: // ISO-8859-1
+			runes := make([]rune, len(value))
+			for i, b := range value {
+				runes[i] = rune(b)
+			}
+			text = string(runes)
+		case 1: // UTF-16 with a byte order mark
+			text = decodeUTF16(value, binary.BigEndian, true)
+		case 2: // UTF-16BE without a byte order mark
+			text = decodeUTF16(value, binary.BigEndian, false)
+		default: // UTF-8
+			text = string(value)
+		}
+
+		if text != "" {
+			values = append(values, text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(values, "/"))
+}
+
+// decodeUTF16 decodes UTF-16 text, honoring a leading byte order mark when allowed
+func decodeUTF16(data []byte, order binary.ByteOrder, bom bool) string {
+	if bom && len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			order, data = binary.LittleEndian, data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			order, data = binary.BigEndian, data[2:]
+		}
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// splitTerminated splits data at the first string terminator of the encoding, which is
+// one zero byte for single-byte encodings and two aligned zero bytes for UTF-16
+func splitTerminated(encoding byte, data []byte) ([]byte, []byte) {
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return data[:i], data[i+2:]
+			}
+		}
+		return data, nil
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return data[:i], data[i+1:]
+	}
+	return data, nil
+}
+
+// unsynchronise reverses the unsynchronisation scheme, which inserts a zero byte after every 0xFF
+func unsynchronise(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// syncsafe decodes a 28-bit integer stored in four bytes of seven bits each
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
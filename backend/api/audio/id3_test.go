@@ -0,0 +1,315 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// syncsafeBytes encodes n as a 28-bit integer of four seven-bit bytes
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// id3v2Tag builds an ID3v2 tag of the major version around the body
+func id3v2Tag(version, flags byte, body []byte) []byte {
+	tag := append([]byte{'I', 'D', '3', version, 0, flags}, syncsafeBytes(len(body))...)
+	return append(tag, body...)
+}
+
+// frame22 builds an ID3v2.2 frame
+func frame22(id string, data []byte) []byte {
+	frame := append([]byte(id), byte(len(data)>>16), byte(len(data)>>8), byte(len(data)))
+	return append(frame, data...)
+}
+
+// frame23 builds an ID3v2.3 frame without flags
+func frame23(id string, data []byte) []byte {
+	frame := binary.BigEndian.AppendUint32([]byte(id), uint32(len(data)))
+	return append(append(frame, 0, 0), data...)
+}
+
+// frame24 builds an ID3v2.4 frame with the format flags
+func frame24(id string, formatFlags byte, data []byte) []byte {
+	frame := append([]byte(id), syncsafeBytes(len(data))...)
+	return append(append(frame, 0, formatFlags), data...)
+}
+
+// text prefixes the text with its encoding byte
+func text(encoding byte, value string) []byte {
+	return append([]byte{encoding}, value...)
+}
+
+// utf16Text encodes the text as UTF-16 with a byte order mark of the given order, prefixed with encoding 1
+func utf16Text(value string, order binary.AppendByteOrder) []byte {
+	data := []byte{1}
+	data = order.AppendUint16(data, 0xFEFF)
+	for _, r := range value {
+		data = order.AppendUint16(data, uint16(r))
+	}
+	return data
+}
+
+// unsynchronised applies the unsynchronisation scheme, inserting a zero byte after every 0xFF
+func unsynchronised(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xFF}, []byte{0xFF, 0x00})
+}
+
+// id3v1Tag builds an ID3v1.1 tag, or an ID3v1 tag if track is 0
+func id3v1Tag(title, artist, album, year, comment string, track, genre byte) []byte {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:33], title)
+	copy(tag[33:63], artist)
+	copy(tag[63:93], album)
+	copy(tag[93:97], year)
+	copy(tag[97:127], comment)
+	if track != 0 {
+		tag[125], tag[126] = 0, track
+	}
+	tag[127] = genre
+	return tag
+}
+
+// join concatenates byte slices
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestReadID3(t *testing.T) {
+	longTitle := string(bytes.Repeat([]byte("a"), 300)) // Needs more than seven bits in each size
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		frames map[string]string // Expected frames; other frames may be present
+	}{
+		{
+			name:   "ID3v1",
+			data:   join([]byte("audio"), id3v1Tag("Title", "Artist", "Album", "1999", "A comment that fills the field", 0, 17)),
+			format: "ID3v1",
+			frames: map[string]string{
+				"TIT2": "Title", "TPE1": "Artist", "TALB": "Album", "TYER": "1999",
+				"COMM": "A comment that fills the field", "TCON": "Rock",
+			},
+		},
+		{
+			name:   "ID3v1.1 track number and unset genre",
+			data:   id3v1Tag("Title", "Artist", "", "", "Comment", 7, 255),
+			format: "ID3v1.1",
+			frames: map[string]string{"TIT2": "Title", "COMM": "Comment", "TRCK": "7", "TCON": ""},
+		},
+		{
+			name: "ID3v2.2 frame IDs",
+			data: id3v2Tag(2, 0, join(
+				frame22("TT2", text(0, "Title")),
+				frame22("TP1", text(0, "Artist")),
+				frame22("TAL", text(0, "Album")),
+				frame22("TCO", text(0, "(9)")),
+				frame22("TYE", text(0, "1987")),
+			)),
+			format: "ID3v2.2",
+			frames: map[string]string{"TIT2": "Title", "TPE1": "Artist", "TALB": "Album", "TCON": "(9)", "TYER": "1987"},
+		},
+		{
+			name: "ID3v2.3 ISO-8859-1 and UTF-16",
+			data: id3v2Tag(3, 0, join(
+				frame23("TIT2", text(0, "Caf\xe9")),
+				frame23("TPE1", utf16Text("Björk", binary.LittleEndian)),
+				frame23("TALB", utf16Text("Homogénic", binary.BigEndian)),
+				frame23("TXXX", text(0, "REPLAYGAIN_TRACK_GAIN\x00-6.50 dB")),
+				frame23("COMM", text(0, "eng\x00Liner notes")),
+			)),
+			format: "ID3v2.3",
+			frames: map[string]string{
+				"TIT2": "Café", "TPE1": "Björk", "TALB": "Homogénic",
+				"TXXX:REPLAYGAIN_TRACK_GAIN": "-6.50 dB", "COMM": "Liner notes",
+			},
+		},
+		{
+			name: "ID3v2.3 unsynchronised tag",
+			data: id3v2Tag(3, 0x80, unsynchronised(join(
+				frame23("TIT2", text(0, "\xff\xfeTitle")),
+				frame23("TPE1", text(0, "Artist")),
+			))),
+			format: "ID3v2.3",
+			frames: map[string]string{"TIT2": "ÿþTitle", "TPE1": "Artist"},
+		},
+		{
+			name: "ID3v2.3 extended header",
+			data: id3v2Tag(3, 0x40, join(
+				[]byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 0}, // Size excluding itself, flags, padding size
+				frame23("TIT2", text(0, "Title")),
+			)),
+			format: "ID3v2.3",
+			frames: map[string]string{"TIT2": "Title"},
+		},
+		{
+			name: "ID3v2.4 UTF-8, UTF-16BE and multiple values",
+			data: id3v2Tag(4, 0, join(
+				frame24("TIT2", 0, text(3, "Über")),
+				frame24("TPE1", 0, text(3, "First\x00Second")),
+				frame24("TALB", 0, append([]byte{2}, 0, 'A', 0, 'l', 0, 'b')),
+				frame24("TDRC", 0, text(3, "2004-05-06")),
+				frame24("TLEN", 0, text(3, "215500")),
+			)),
+			format: "ID3v2.4",
+			frames: map[string]string{"TIT2": "Über", "TPE1": "First/Second", "TALB": "Alb", "TDRC": "2004-05-06", "TLEN": "215500"},
+		},
+		{
+			name: "ID3v2.4 sync-safe sizes",
+			data: id3v2Tag(4, 0, join(
+				frame24("TIT2", 0, text(3, longTitle)),
+				frame24("TPE1", 0, text(3, "Artist")),
+			)),
+			format: "ID3v2.4",
+			frames: map[string]string{"TIT2": longTitle, "TPE1": "Artist"},
+		},
+		{
+			name: "ID3v2.4 unsynchronised frame with data length indicator",
+			data: id3v2Tag(4, 0, join(
+				frame24("TIT2", 0x03, join(syncsafeBytes(8), unsynchronised(text(0, "\xff\xe0Title")))),
+				frame24("TPE1", 0, text(3, "Artist")),
+			)),
+			format: "ID3v2.4",
+			frames: map[string]string{"TIT2": "ÿàTitle", "TPE1": "Artist"},
+		},
+		{
+			name: "ID3v2.4 unsynchronised tag",
+			data: id3v2Tag(4, 0x80, join(
+				frame24("TIT2", 0, unsynchronised(text(0, "\xff\xe0Title"))),
+			)),
+			format: "ID3v2.4",
+			frames: map[string]string{"TIT2": "ÿàTitle"},
+		},
+		{
+			name: "ID3v2.4 extended header",
+			data: id3v2Tag(4, 0x40, join(
+				[]byte{0, 0, 0, 6, 1, 0}, // Size including itself, number of flag bytes, flags
+				frame24("TIT2", 0, text(3, "Title")),
+			)),
+			format: "ID3v2.4",
+			frames: map[string]string{"TIT2": "Title"},
+		},
+		{
+			name: "ID3v2 takes precedence over ID3v1",
+			data: join(
+				id3v2Tag(3, 0, frame23("TIT2", text(0, "Long title beyond thirty characters"))),
+				[]byte("audio"),
+				id3v1Tag("Long title beyond thirty chara", "Artist", "", "", "", 0, 0),
+			),
+			format: "ID3v2.3",
+			frames: map[string]string{"TIT2": "Long title beyond thirty characters", "TPE1": "Artist", "TCON": "Blues"},
+		},
+		{
+			name: "padding and frames running past the tag",
+			data: id3v2Tag(3, 0, join(
+				frame23("TIT2", text(0, "Title")),
+				[]byte{0, 0, 0, 0},
+				frame23("TPE1", text(0, "Hidden by the padding")),
+			)),
+			format: "ID3v2.3",
+			frames: map[string]string{"TIT2": "Title", "TPE1": ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := ReadID3(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatalf("ReadID3: %v", err)
+			}
+			if tags.Format != test.format {
+				t.Errorf("Format = %q, want %q", tags.Format, test.format)
+			}
+			for id, want := range test.frames {
+				if got := tags.Frames[id]; got != want {
+					t.Errorf("Frames[%s] = %q, want %q", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadID3WithoutTags(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		[]byte("\xff\xfb\x90\x00"),
+		bytes.Repeat([]byte{0}, 200),
+		id3v2Tag(5, 0, frame23("TIT2", text(0, "Unknown version"))),
+	} {
+		if _, err := ReadID3(bytes.NewReader(data)); err != ErrNoTags {
+			t.Errorf("ReadID3(%q) error = %v, want ErrNoTags", data, err)
+		}
+	}
+}
+
+func TestID3v2Size(t *testing.T) {
+	body := bytes.Repeat([]byte{0}, 300)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int64
+	}{
+		{"no tag", []byte("\xff\xfb\x90\x00"), 0},
+		{"too short", []byte("ID3"), 0},
+		{"sync-safe size", id3v2Tag(3, 0, body), 310},
+		{"ID3v2.4 footer", id3v2Tag(4, 0x10, body), 320},
+	}
+
+	for _, test := range tests {
+		got, err := ID3v2Size(bytes.NewReader(test.data))
+		if err != nil {
+			t.Fatalf("%s: ID3v2Size: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: ID3v2Size = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTagsGenre(t *testing.T) {
+	tests := map[string]string{
+		"Rock":             "Rock",
+		"17":               "Rock",
+		"(17)":             "Rock",
+		"(9)":              "Metal",
+		"(17)Rockabilly":   "Rockabilly",
+		"(RX)":             "Remix",
+		"(CR)":             "Cover",
+		"((Parenthesised)": "(Parenthesised)",
+		"191":              "Psybient",
+		"192":              "",
+		"8/9":              "Jazz",
+		" Jazz ":           "Jazz",
+		"":                 "",
+	}
+
+	for value, want := range tests {
+		tags := &Tags{Frames: map[string]string{"TCON": value}}
+		if got := tags.Genre(); got != want {
+			t.Errorf("Genre(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestTagsYear(t *testing.T) {
+	tests := []struct {
+		frames map[string]string
+		year   int
+	}{
+		{map[string]string{"TDRC": "2004-05-06", "TYER": "1999"}, 2004},
+		{map[string]string{"TYER": "1999"}, 1999},
+		{map[string]string{"TDRC": "n/a", "TORY": "1970"}, 1970},
+		{map[string]string{"TYER": "0000"}, 0},
+	}
+
+	for _, test := range tests {
+		tags := &Tags{Frames: test.frames}
+		if got := tags.Year(); got != test.year {
+			t.Errorf("Year(%v) = %d, want %d", test.frames, got, test.year)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package audio
+
+import (
+	"io"
+	"math"
+)
+
+// ReplayGainReference is the loudness in LUFS that ReplayGain 2.0 gains bring audio to
+const ReplayGainReference = -18.0
+
+// Gates of the integrated loudness, per ITU-R BS.1770-4
+const (
+	loudnessAbsoluteGate = -70.0 // Blocks quieter than this in LUFS are ignored
+	loudnessRelativeGate = -10.0 // Blocks quieter than this in LU below the loudness of the blocks passing the absolute gate are ignored
+)
+
+// Loudness is the loudness of an audio stream measured per ITU-R BS.1770-4 and EBU R128. Silent streams are
+// reported at the absolute gate
+type Loudness struct {
+	Integrated  float64 // Integrated loudness in LUFS
+	TruePeak    float64 // True peak in dBTP
+	GatedBlocks int     // Number of 400 ms blocks passing both gates, weighting the stream in album loudness
+}
+
+// Gain returns the ReplayGain 2.0 gain in dB bringing audio of the given loudness to the reference loudness
+func Gain(loudness float64) float64 {
+	return ReplayGainReference - loudness
+}
+
+// MeasureLoudness decodes a whole stream and measures its integrated loudness and true peak
+func MeasureLoudness(decoder Decoder) (*Loudness, error) {
+	meter := newLoudnessMeter(decoder.SampleRate(), decoder.Channels())
+	samples := make([]float32, 4096*meter.channels)
+	for {
+		n, err := decoder.Read(samples)
+		meter.add(samples[:n])
+		if err == io.EOF {
+			return meter.loudness(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// AlbumLoudness returns the integrated loudness of several streams played as a whole, such as the tracks of an
+// album. The energy of the streams is averaged over their gated blocks, each stream keeping its own relative gate
+func AlbumLoudness(tracks []Loudness) float64 {
+	var energy float64
+	var blocks int
+	for _, track := range tracks {
+		energy += float64(track.GatedBlocks) * loudnessEnergy(track.Integrated)
+		blocks += track.GatedBlocks
+	}
+	if blocks == 0 {
+		return loudnessAbsoluteGate
+	}
+	return energyLoudness(energy / float64(blocks))
+}
+
+// loudnessMeter measures the loudness of interleaved samples through K-weighting filters, in 400 ms blocks
+// overlapping by 75%, and their true peak by oversampling
+type loudnessMeter struct {
+	channels       int
+	weights        []float64      // Weight of each channel in the sum of their energies
+	filters        []kWeighting   // K-weighting filter of each channel
+	oversamplers   []*oversampler // True peak interpolator of each channel, nil when the sample rate needs none
+	subBlockFrames int            // Frames per 100 ms sub-block
+	frames         int            // Frames in the current sub-block
+	sums           []float64      // Sum of the squared K-weighted samples of each channel in the current sub-block
+	subBlocks      [4]float64     // Energy of the last four sub-blocks, which make up a block
+	subBlockCount  int            // Number of completed sub-blocks
+	blocks         []float64      // Energy of every completed block
+	peak           float64        // Highest absolute sample value, interpolated or not
+	channel        int            // Channel of the next sample within its frame
+}
+
+// newLoudnessMeter returns a meter of a stream with the given sample rate and number of channels
+func newLoudnessMeter(sampleRate, channels int) *loudnessMeter {
+	channels = max(channels, 1)
+	m := &loudnessMeter{
+		channels:       channels,
+		weights:        make([]float64, channels),
+		filters:        make([]kWeighting, channels),
+		oversamplers:   make([]*oversampler, channels),
+		subBlockFrames: max(1, int(math.Round(float64(sampleRate)/10))),
+		sums:           make([]float64, channels),
+	}
+	for i := range m.weights {
+		m.weights[i] = 1
+		m.filters[i] = newKWeighting(float64(sampleRate))
+		m.oversamplers[i] = newOversampler(sampleRate)
+	}
+	if channels == 6 {
+		// 5.1 audio: the LFE channel is ignored and the surround channels are weighted up
+		m.weights[3], m.weights[4], m.weights[5] = 0, 1.41, 1.41
+	}
+	return m
+}
+
+// add measures interleaved samples, which may end in the middle of a frame
+func (m *loudnessMeter) add(samples []float32) {
+	for _, sample := range samples {
+		x := float64(sample)
+		m.peak = max(m.peak, math.Abs(x))
+		if o := m.oversamplers[m.channel]; o != nil {
+			m.peak = max(m.peak, o.peak(x))
+		}
+		y := m.filters[m.channel].filter(x)
+		m.sums[m.channel] += y * y
+
+		m.channel++
+		if m.channel < m.channels {
+			continue
+		}
+		m.channel = 0
+		m.frames++
+		if m.frames == m.subBlockFrames {
+			m.completeSubBlock()
+		}
+	}
+}
+
+// completeSubBlock records the energy of the current sub-block, and that of the block it completes
+func (m *loudnessMeter) completeSubBlock() {
+	var energy float64
+	for i, sum := range m.sums {
+		energy += m.weights[i] * sum / float64(m.frames)
+		m.sums[i] = 0
+	}
+	m.frames = 0
+
+	m.subBlocks[m.subBlockCount%len(m.subBlocks)] = energy
+	m.subBlockCount++
+	if m.subBlockCount >= len(m.subBlocks) {
+		var block float64
+		for _, e := range m.subBlocks {
+			block += e
+		}
+		m.blocks = append(m.blocks, block/float64(len(m.subBlocks)))
+	}
+}
+
+// loudness gates the blocks and returns the measured loudness
+func (m *loudnessMeter) loudness() *Loudness {
+	result := &Loudness{Integrated: loudnessAbsoluteGate, TruePeak: loudnessAbsoluteGate}
+	if m.peak > 0 {
+		result.TruePeak = max(loudnessAbsoluteGate, 20*math.Log10(m.peak))
+	}
+
+	threshold := loudnessEnergy(loudnessAbsoluteGate)
+	energy, count := gatedEnergy(m.blocks, threshold)
+	if count == 0 {
+		return result
+	}
+	threshold = max(threshold, loudnessEnergy(energyLoudness(energy)+loudnessRelativeGate))
+	energy, count = gatedEnergy(m.blocks, threshold)
+
+	result.Integrated = energyLoudness(energy)
+	result.GatedBlocks = count
+	return result
+}
+
+// gatedEnergy returns the mean energy of the blocks above the threshold, and their number
+func gatedEnergy(blocks []float64, threshold float64) (float64, int) {
+	var sum float64
+	var count int
+	for _, block := range blocks {
+		if block > threshold {
+			sum += block
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return sum / float64(count), count
+}
+
+// energyLoudness converts the mean square of K-weighted samples to a loudness in LUFS
+func energyLoudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// loudnessEnergy converts a loudness in LUFS to the mean square of K-weighted samples
+func loudnessEnergy(loudness float64) float64 {
+	return math.Pow(10, (loudness+0.691)/10)
+}
+
+// biquad is a second order IIR filter in direct form I
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x1, f.x2 = x, f.x1
+	f.y1, f.y2 = y, f.y1
+	return y
+}
+
+// kWeighting is the K-weighting filter of BS.1770: a high shelf modeling the head, followed by a high pass
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+// newKWeighting derives the coefficients of the K-weighting filter at any sample rate from the analog prototypes
+// of the filters BS.1770 specifies at 48 kHz
+func newKWeighting(sampleRate float64) kWeighting {
+	// High shelf of about +4 dB above 1.5 kHz
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / sampleRate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// High pass at about 38 Hz
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / sampleRate)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return kWeighting{shelf: shelf, highPass: highPass}
+}
+
+func (f *kWeighting) filter(x float64) float64 {
+	return f.highPass.filter(f.shelf.filter(x))
+}
+
+// oversamplerTaps is the number of taps of each phase of the true peak interpolator
+const oversamplerTaps = 12
+
+// oversampler interpolates samples between the samples of a channel with a polyphase windowed-sinc filter,
+// as BS.1770 does to estimate the true peak of a stream
+type oversampler struct {
+	phases  [][]float64 // Taps of each interpolated phase
+	history []float64   // Last samples of the channel, newest first
+}
+
+// newOversampler returns an interpolator oversampling a stream four times below 96 kHz and twice below 192 kHz,
+// or nil when the sample rate is higher
+func newOversampler(sampleRate int) *oversampler {
+	factor := 1
+	switch {
+	case sampleRate < 96000:
+		factor = 4
+	case sampleRate < 192000:
+		factor = 2
+	default:
+		return nil
+	}
+
+	size := factor * oversamplerTaps
+	o := &oversampler{phases: make([][]float64, factor), history: make([]float64, oversamplerTaps)}
+	for p := range o.phases {
+		o.phases[p] = make([]float64, oversamplerTaps)
+		var sum float64
+		for j := range o.phases[p] {
+			n := float64(p + j*factor)
+			x := (n - float64(size-1)/2) / float64(factor)
+			window := 0.5 - 0.5*math.Cos(2*math.Pi*(n+0.5)/float64(size)) // Hann window
+			tap := window
+			if x != 0 {
+				tap *= math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			o.phases[p][j] = tap
+			sum += tap
+		}
+		for j := range o.phases[p] {
+			o.phases[p][j] /= sum // Pass every phase at unity gain
+		}
+	}
+	return o
+}
+
+// peak adds a sample and returns the highest absolute value interpolated before it
+func (o *oversampler) peak(x float64) float64 {
+	copy(o.history[1:], o.history)
+	o.history[0] = x
+
+	var peak float64
+	for _, taps := range o.phases {
+		var y float64
+		for j, tap := range taps {
+			y += tap * o.history[j]
+		}
+		peak = max(peak, math.Abs(y))
+	}
+	return peak
+}
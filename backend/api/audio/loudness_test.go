@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureLoudness(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate int
+		channels   int
+		level      float64 // Peak level of the sine in dBFS
+		integrated float64 // Expected loudness in LUFS
+	}{
+		{"stereo -23 dBFS", 48000, 2, -23, -23}, // EBU Tech 3341 case 1
+		{"stereo -33 dBFS", 48000, 2, -33, -33}, // EBU Tech 3341 case 2
+		{"mono 0 dBFS", 48000, 1, 0, -3.01},     // ITU-R BS.1770: a full-scale sine on one channel
+		{"stereo -23 dBFS 44.1k", 44100, 2, -23, -23},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amplitude := math.Pow(10, test.level/20)
+			loudness, err := MeasureLoudness(sineDecoder(test.sampleRate, test.channels, 1000, amplitude, 20))
+			if err != nil {
+				t.Fatalf("MeasureLoudness: %v", err)
+			}
+			if math.Abs(loudness.Integrated-test.integrated) > 0.1 {
+				t.Errorf("integrated = %.2f LUFS, want %.2f", loudness.Integrated, test.integrated)
+			}
+			if math.Abs(loudness.TruePeak-test.level) > 0.5 {
+				t.Errorf("true peak = %.2f dBTP, want %.2f", loudness.TruePeak, test.level)
+			}
+			if loudness.GatedBlocks == 0 {
+				t.Error("no gated blocks")
+			}
+		})
+	}
+}
+
+func TestMeasureLoudnessSilence(t *testing.T) {
+	loudness, err := MeasureLoudness(sineDecoder(48000, 2, 1000, 0, 5))
+	if err != nil {
+		t.Fatalf("MeasureLoudness: %v", err)
+	}
+	if loudness.Integrated != loudnessAbsoluteGate || loudness.GatedBlocks != 0 {
+		t.Errorf("silence = %.2f LUFS over %d blocks, want the absolute gate over none", loudness.Integrated, loudness.GatedBlocks)
+	}
+}
+
+func TestAlbumLoudness(t *testing.T) {
+	// Two tracks at the same loudness make an album at that loudness, whatever their lengths
+	album := AlbumLoudness([]Loudness{{Integrated: -20, GatedBlocks: 10}, {Integrated: -20, GatedBlocks: 500}})
+	if math.Abs(album+20) > 1e-9 {
+		t.Errorf("album = %.2f LUFS, want -20", album)
+	}
+
+	// Otherwise the longer track weighs more
+	album = AlbumLoudness([]Loudness{{Integrated: -10, GatedBlocks: 10}, {Integrated: -30, GatedBlocks: 1000}})
+	if album >= -20 || album <= -30 {
+		t.Errorf("album = %.2f LUFS, want between -30 and -20", album)
+	}
+
+	if album := AlbumLoudness(nil); album != loudnessAbsoluteGate {
+		t.Errorf("empty album = %.2f LUFS, want the absolute gate", album)
+	}
+}
+
+func TestGain(t *testing.T) {
+	if gain := Gain(-23); gain != 5 {
+		t.Errorf("Gain(-23) = %.2f, want 5", gain)
+	}
+}
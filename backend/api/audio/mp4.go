@@ -0,0 +1,374 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Well-known types of the values of iTunes metadata items
+const (
+	mp4DataImplicit = 0
+	mp4DataUTF8     = 1
+	mp4DataUTF16    = 2
+	mp4DataJPEG     = 13
+	mp4DataPNG      = 14
+	mp4DataSigned   = 21
+	mp4DataUnsigned = 22
+	mp4DataBMP      = 27
+)
+
+// mp4PictureTypes maps the types of picture values to their MIME types
+var mp4PictureTypes = map[uint32]string{
+	mp4DataJPEG: "image/jpeg",
+	mp4DataPNG:  "image/png",
+	mp4DataBMP:  "image/bmp",
+}
+
+// mp4TagFormat is the tag format of the iTunes metadata items of MP4 files
+const mp4TagFormat = "MP4"
+
+// mp4Codecs maps the sample entry types of MP4 audio tracks to their codec names; "mp4a" is resolved by its
+// elementary stream descriptor
+var mp4Codecs = map[string]string{
+	"mp4a": "aac",
+	"alac": "alac",
+	"fLaC": "flac",
+	"Opus": "opus",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+}
+
+// ReadMP4Info computes the duration and stream properties of an MP4 file from the boxes describing its first audio track
+func ReadMP4Info(r io.ReadSeeker) (*StreamInfo, error) {
+	moov, mediaSize, err := readMoov(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the first track handling sound
+	var mdia []byte
+	walkBoxes(moov, func(boxType string, body []byte) {
+		if boxType != "trak" || mdia != nil {
+			return
+		}
+		media := findBox(body, "mdia")
+		if handler := findBox(media, "hdlr"); len(handler) >= 12 && string(handler[8:12]) == "soun" {
+			mdia = media
+		}
+	})
+	if mdia == nil {
+		return nil, ErrInvalidStream
+	}
+
+	// The media header records the duration in units of the track's timescale
+	var timescale, duration uint64
+	header := findBox(mdia, "mdhd")
+	switch {
+	case len(header) >= 20 && header[0] == 0:
+		timescale = uint64(binary.BigEndian.Uint32(header[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	case len(header) >= 32 && header[0] == 1:
+		timescale = uint64(binary.BigEndian.Uint32(header[20:24]))
+		duration = binary.BigEndian.Uint64(header[24:32])
+	}
+	if timescale == 0 || duration == 0 {
+		return nil, ErrInvalidStream
+	}
+
+	// The sample description holds a sample entry naming the codec, followed by the audio sample entry fields
+	descriptions := findBox(mdia, "minf", "stbl", "stsd")
+	if len(descriptions) < 8 {
+		return nil, ErrInvalidStream
+	}
+	var entryType string
+	var entry []byte
+	walkBoxes(descriptions[8:], func(boxType string, body []byte) {
+		if entry == nil {
+			entryType, entry = boxType, body
+		}
+	})
+	codec, ok := mp4Codecs[entryType]
+	if !ok || len(entry) < 28 {
+		return nil, ErrInvalidStream
+	}
+	channels := int(binary.BigEndian.Uint16(entry[16:18]))
+	sampleSize := int(binary.BigEndian.Uint16(entry[18:20]))
+	sampleRate := int(binary.BigEndian.Uint32(entry[24:28]) >> 16) // A 16.16 fixed-point number
+	if sampleRate == 0 {
+		sampleRate = int(timescale) // Rates above 65535 Hz only fit the timescale
+	}
+
+	info := &StreamInfo{
+		Container:   ContainerMP4,
+		Codec:       codec,
+		Duration:    float64(duration) / float64(timescale),
+		SampleRate:  sampleRate,
+		Channels:    channels,
+		ChannelMode: channelMode(channels),
+	}
+	switch entryType {
+	case "mp4a":
+		children := entry[28:]
+		if binary.BigEndian.Uint16(entry[8:10]) == 1 && len(children) >= 16 {
+			children = children[16:] // Version 1 entries of QuickTime files have four more fields
+		}
+		info.Codec = mp4AudioCodec(findBox(children, "esds"))
+	case "alac", "fLaC":
+		info.BitDepth = sampleSize // Only lossless codecs have a meaningful sample size
+	}
+	info.Bitrate = averageBitrate(mediaSize, info.Duration)
+
+	return info, nil
+}
+
+// ReadMP4Tags reads the iTunes metadata items of an MP4 file. Items are keyed by their atom type, e.g. "©nam" for
+// the title, and freeform items by "----:" followed by their name, e.g. "----:REPLAYGAIN_TRACK_GAIN"
+func ReadMP4Tags(r io.ReadSeeker) (*Tags, error) {
+	moov, _, err := readMoov(r)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := findBox(moov, "udta", "meta")
+	if len(meta) < 4 {
+		return nil, ErrNoTags
+	}
+	items := findBox(meta[4:], "ilst") // The meta box starts with a version and flags
+	if items == nil {
+		return nil, ErrNoTags
+	}
+
+	tags := &Tags{Format: mp4TagFormat, Frames: map[string]string{}, kind: tagKindMP4}
+	walkBoxes(items, func(itemType string, item []byte) {
+		key := latin1(itemType)
+		walkBoxes(item, func(boxType string, body []byte) {
+			switch {
+			case boxType == "name" && itemType == "----" && len(body) >= 4:
+				key = "----:" + string(body[4:])
+			case boxType == "data" && len(body) >= 8:
+				decodeMP4Data(key, binary.BigEndian.Uint32(body[0:4])&0xFFFFFF, body[8:], tags)
+			}
+		})
+	})
+
+	if len(tags.Frames) == 0 && len(tags.Pictures) == 0 {
+		return nil, ErrNoTags
+	}
+	return tags, nil
+}
+
+// decodeMP4Data adds the value of a metadata item to the tags according to its well-known type
+func decodeMP4Data(key string, dataType uint32, value []byte, tags *Tags) {
+	switch dataType {
+	case mp4DataUTF8:
+		tags.Frames[key] = string(value)
+	case mp4DataUTF16:
+		units := make([]uint16, len(value)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(value[2*i:])
+		}
+		tags.Frames[key] = string(utf16.Decode(units))
+	case mp4DataJPEG, mp4DataPNG, mp4DataBMP:
+		tags.Pictures = append(tags.Pictures, Picture{MIMEType: mp4PictureTypes[dataType], Type: PictureTypeFrontCover, Data: value})
+	case mp4DataSigned:
+		var number int64
+		for i, b := range value {
+			if i == 0 {
+				number = int64(int8(b)) // Sign-extend from the most significant byte
+			} else {
+				number = number<<8 | int64(b)
+			}
+		}
+		tags.Frames[key] = strconv.FormatInt(number, 10)
+	case mp4DataUnsigned:
+		var number uint64
+		for _, b := range value {
+			number = number<<8 | uint64(b)
+		}
+		tags.Frames[key] = strconv.FormatUint(number, 10)
+	case mp4DataImplicit:
+		switch {
+		case (key == "trkn" || key == "disk") && len(value) >= 6:
+			// The number and the total, as 16-bit integers following two reserved bytes
+			number, total := binary.BigEndian.Uint16(value[2:4]), binary.BigEndian.Uint16(value[4:6])
+			tags.Frames[key] = strconv.Itoa(int(number))
+			if total > 0 {
+				tags.Frames[key] += "/" + strconv.Itoa(int(total))
+			}
+		case key == "gnre" && len(value) >= 2:
+			// The ID3v1 genre number plus one, stored as a bare genre number as ID3v2 frames do
+			if genre := int(binary.BigEndian.Uint16(value)); genre > 0 {
+				tags.Frames[key] = strconv.Itoa(genre - 1)
+			}
+		}
+	}
+}
+
+// mp4AudioCodec returns the codec of an "mp4a" sample entry from the object type of its elementary stream descriptor
+func mp4AudioCodec(esds []byte) string {
+	if len(esds) < 4 {
+		return "aac"
+	}
+	data := esds[4:] // The descriptor box starts with a version and flags
+
+	// The ES descriptor (tag 3) contains the decoder config descriptor (tag 4) starting with the object type
+	tag, body := readDescriptor(data)
+	if tag != 3 || len(body) < 3 {
+		return "aac"
+	}
+	flags := body[2]
+	body = body[3:]
+	if flags&0x80 != 0 && len(body) >= 2 {
+		body = body[2:] // Depended-on stream ID
+	}
+	if flags&0x40 != 0 && len(body) >= 1 && len(body) >= 1+int(body[0]) {
+		body = body[1+int(body[0]):] // URL
+	}
+	if flags&0x20 != 0 && len(body) >= 2 {
+		body = body[2:] // OCR stream ID
+	}
+	tag, config := readDescriptor(body)
+	if tag != 4 || len(config) < 1 {
+		return "aac"
+	}
+
+	switch config[0] {
+	case 0x69, 0x6B:
+		return "mp3" // MPEG-2 and MPEG-1 audio
+	case 0xA5:
+		return "ac3"
+	}
+	return "aac"
+}
+
+// readDescriptor reads the tag and body of an MPEG-4 descriptor, whose length is encoded in up to four bytes
+// of seven bits each
+func readDescriptor(data []byte) (byte, []byte) {
+	if len(data) < 2 {
+		return 0, nil
+	}
+	tag := data[0]
+	length, i := 0, 1
+	for ; i < len(data) && i <= 4; i++ {
+		length = length<<7 | int(data[i]&0x7F)
+		if data[i]&0x80 == 0 {
+			i++
+			break
+		}
+	}
+	if length > len(data)-i {
+		return 0, nil
+	}
+	return tag, data[i : i+length]
+}
+
+// readMoov reads the movie box of an MP4 file into memory and returns its body along with the total size of its
+// media data boxes; the movie box may follow the media data
+func readMoov(r io.ReadSeeker) ([]byte, int64, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var moov []byte
+	var mediaSize int64
+	header := make([]byte, 16)
+	for offset := int64(0); offset+8 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, 0, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return nil, 0, ErrInvalidStream
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = end - offset // The box extends to the end of the file
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return nil, 0, ErrInvalidStream
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize || size > end-offset {
+			return nil, 0, ErrInvalidStream
+		}
+		if offset == 0 && boxType != "ftyp" {
+			return nil, 0, ErrInvalidStream
+		}
+
+		switch boxType {
+		case "moov":
+			if size-headerSize > maxTagSize {
+				return nil, 0, ErrInvalidStream
+			}
+			moov = make([]byte, size-headerSize)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, 0, ErrInvalidStream
+			}
+		case "mdat":
+			mediaSize += size - headerSize
+		}
+		offset += size
+	}
+
+	if moov == nil {
+		return nil, 0, ErrInvalidStream
+	}
+	return moov, mediaSize, nil
+}
+
+// walkBoxes calls visit with the type and body of every box in the data, stopping at the first malformed box
+func walkBoxes(data []byte, visit func(boxType string, body []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return
+		}
+		visit(string(data[4:8]), data[headerSize:size])
+		data = data[size:]
+	}
+}
+
+// findBox returns the body of the first box found by descending the path of box types, or nil if there is none
+func findBox(data []byte, path ...string) []byte {
+	for _, boxType := range path {
+		var found []byte
+		walkBoxes(data, func(t string, body []byte) {
+			if t == boxType && found == nil {
+				found = body
+			}
+		})
+		if found == nil {
+			return nil
+		}
+		data = found
+	}
+	return data
+}
+
+// latin1 decodes an atom type, in which the copyright sign starts the types of the standard text items
+func latin1(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(rune(s[i]))
+	}
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// box builds an MP4 box of the type around the concatenated children
+func box(boxType string, children ...[]byte) []byte {
+	body := join(children...)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(body))), append([]byte(boxType), body...)...)
+}
+
+// mediaHeader builds the body of a version 0 media header box
+func mediaHeader(timescale, duration uint32) []byte {
+	header := make([]byte, 12) // Version, flags, creation and modification times
+	header = binary.BigEndian.AppendUint32(header, timescale)
+	header = binary.BigEndian.AppendUint32(header, duration)
+	return append(header, 0, 0, 0, 0) // Language and quality
+}
+
+// audioSampleEntry builds a sample entry of the type for an audio track, followed by the child boxes
+func audioSampleEntry(entryType string, channels, sampleSize uint16, sampleRate uint32, children ...[]byte) []byte {
+	entry := make([]byte, 16) // Reserved, data reference index, version, revision and vendor
+	entry = binary.BigEndian.AppendUint16(entry, channels)
+	entry = binary.BigEndian.AppendUint16(entry, sampleSize)
+	entry = append(entry, 0, 0, 0, 0) // Compression ID and packet size
+	entry = binary.BigEndian.AppendUint32(entry, sampleRate<<16)
+	return box(entryType, append([][]byte{entry}, children...)...)
+}
+
+// esds builds an elementary stream descriptor box declaring the object type
+func esds(objectType byte) []byte {
+	config := []byte{0x04, 13, objectType, 0x15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	descriptor := append([]byte{0x03, 0x80, 0x80, byte(3 + len(config)), 0, 1, 0}, config...) // A length padded to three bytes
+	return box("esds", []byte{0, 0, 0, 0}, descriptor)
+}
+
+// track builds a track box of the handler type with a media header and a sample description holding the entry
+func track(handler string, header, entry []byte) []byte {
+	return box("trak", box("mdia",
+		box("mdhd", header),
+		box("hdlr", []byte{0, 0, 0, 0, 0, 0, 0, 0}, []byte(handler), make([]byte, 13)),
+		box("minf", box("stbl", box("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, entry))),
+	))
+}
+
+// mp4File builds an M4A file of the movie box following mediaSize bytes of media data
+func mp4File(mediaSize int, moov []byte) []byte {
+	return join(box("ftyp", []byte("M4A \x00\x00\x00\x00M4A isom")), box("mdat", make([]byte, mediaSize)), moov)
+}
+
+// dataBox builds the data box of a metadata item holding a value of the type
+func dataBox(dataType uint32, value []byte) []byte {
+	return box("data", binary.BigEndian.AppendUint32(nil, dataType), []byte{0, 0, 0, 0}, value)
+}
+
+func TestReadMP4Info(t *testing.T) {
+	aac := track("soun", mediaHeader(44100, 441000), audioSampleEntry("mp4a", 2, 16, 44100, esds(0x40)))
+	video := track("vide", mediaHeader(600, 6000), box("avc1", make([]byte, 80)))
+	longHeader := append([]byte{1, 0, 0, 0}, make([]byte, 16)...) // Version 1 with 64-bit times and duration
+	longHeader = binary.BigEndian.AppendUint32(longHeader, 48000)
+	longHeader = binary.BigEndian.AppendUint64(longHeader, 48000*90)
+
+	tests := []struct {
+		name string
+		data []byte
+		want StreamInfo
+	}{
+		{
+			name: "AAC",
+			data: mp4File(160000, box("moov", box("mvhd", make([]byte, 100)), aac)),
+			want: StreamInfo{Container: ContainerMP4, Codec: "aac", Duration: 10, Bitrate: 128, SampleRate: 44100, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+		{
+			name: "MP3 after a video track",
+			data: mp4File(40000, box("moov", video, track("soun", mediaHeader(32000, 64000), audioSampleEntry("mp4a", 1, 16, 32000, esds(0x6B))))),
+			want: StreamInfo{Container: ContainerMP4, Codec: "mp3", Duration: 2, Bitrate: 160, SampleRate: 32000, Channels: 1, ChannelMode: ChannelModeMono},
+		},
+		{
+			name: "ALAC above 65535 Hz with a 64-bit duration",
+			data: mp4File(9000000, box("moov", track("soun", longHeader, audioSampleEntry("alac", 6, 24, 0)))),
+			want: StreamInfo{Container: ContainerMP4, Codec: "alac", BitDepth: 24, Duration: 90, Bitrate: 800, SampleRate: 48000, Channels: 6, ChannelMode: ChannelModeMultichannel},
+		},
+		{
+			name: "AAC without a descriptor",
+			data: mp4File(0, box("moov", track("soun", mediaHeader(1000, 500), audioSampleEntry("mp4a", 2, 16, 22050)))),
+			want: StreamInfo{Container: ContainerMP4, Codec: "aac", Duration: 0.5, SampleRate: 22050, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ReadStreamInfo(bytes.NewReader(test.data), ContainerMP4)
+			if err != nil {
+				t.Fatalf("ReadStreamInfo: %v", err)
+			}
+			checkStreamInfo(t, info, test.want)
+		})
+	}
+}
+
+func TestReadMP4InfoInvalid(t *testing.T) {
+	entry := audioSampleEntry("mp4a", 2, 16, 44100, esds(0x40))
+	moov := box("moov", track("soun", mediaHeader(44100, 441000), entry))
+
+	for name, data := range map[string][]byte{
+		"empty":                   nil,
+		"no file type first":      join(box("mdat", make([]byte, 10)), moov),
+		"no movie box":            mp4File(100, nil),
+		"no sound track":          mp4File(100, box("moov", track("vide", mediaHeader(600, 6000), box("avc1", make([]byte, 80))))),
+		"no duration":             mp4File(100, box("moov", track("soun", mediaHeader(44100, 0), entry))),
+		"unknown codec":           mp4File(100, box("moov", track("soun", mediaHeader(44100, 441000), audioSampleEntry("samr", 1, 16, 8000)))),
+		"box size past the end":   mp4File(100, moov)[:len(mp4File(100, moov))-1],
+		"box smaller than header": append(box("ftyp", []byte("M4A ")), 0, 0, 0, 4, 'f', 'r', 'e', 'e'),
+	} {
+		if _, err := ReadStreamInfo(bytes.NewReader(data), ContainerMP4); err != ErrInvalidStream {
+			t.Errorf("ReadStreamInfo(%s) error = %v, want ErrInvalidStream", name, err)
+		}
+	}
+}
+
+func TestReadMP4Tags(t *testing.T) {
+	utf16Title := []byte{0, 'S', 0, 'o', 0, 'n', 0, 'g'}
+	items := box("ilst",
+		box("\xa9nam", dataBox(mp4DataUTF16, utf16Title)),
+		box("aART", dataBox(mp4DataUTF8, []byte("Band"))),
+		box("\xa9alb", dataBox(mp4DataUTF8, []byte("Record"))),
+		box("gnre", dataBox(mp4DataImplicit, []byte{0, 18})), // ID3v1 genre 17 plus one
+		box("\xa9day", dataBox(mp4DataUTF8, []byte("2012-03-04T00:00:00Z"))),
+		box("trkn", dataBox(mp4DataImplicit, []byte{0, 0, 0, 3, 0, 12, 0, 0})),
+		box("tmpo", dataBox(mp4DataUnsigned, []byte{0, 120})),
+		box("shwm", dataBox(mp4DataSigned, []byte{0xFF})),
+		box("----", box("mean", []byte{0, 0, 0, 0}, []byte("com.apple.iTunes")), box("name", []byte{0, 0, 0, 0}, []byte("REPLAYGAIN_TRACK_GAIN")), dataBox(mp4DataUTF8, []byte("-6.5 dB"))),
+		box("covr", dataBox(mp4DataPNG, []byte("\x89PNG")), dataBox(mp4DataJPEG, []byte{0xFF, 0xD8})),
+	)
+	moov := box("moov",
+		track("soun", mediaHeader(44100, 441000), audioSampleEntry("mp4a", 2, 16, 44100, esds(0x40))),
+		box("udta", box("meta", []byte{0, 0, 0, 0}, box("hdlr", make([]byte, 25)), items)),
+	)
+
+	tags, err := ReadTags(bytes.NewReader(mp4File(1000, moov)), ContainerMP4)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	if tags.Format != mp4TagFormat {
+		t.Errorf("Format = %q, want %q", tags.Format, mp4TagFormat)
+	}
+	checkTags(t, tags, "Song", "Band", "Record", "Rock", 2012)
+	for key, value := range map[string]string{"trkn": "3/12", "tmpo": "120", "shwm": "-1", "----:REPLAYGAIN_TRACK_GAIN": "-6.5 dB"} {
+		if tags.Frames[key] != value {
+			t.Errorf("Frames[%q] = %q, want %q", key, tags.Frames[key], value)
+		}
+	}
+	if len(tags.Pictures) != 2 || tags.Pictures[0].MIMEType != "image/png" || tags.Pictures[1].MIMEType != "image/jpeg" {
+		t.Errorf("Pictures = %+v, want the PNG and JPEG covers", tags.Pictures)
+	}
+
+	// A named genre is preferred to a genre number
+	items = box("ilst", box("gnre", dataBox(mp4DataImplicit, []byte{0, 18})), box("\xa9gen", dataBox(mp4DataUTF8, []byte("Shoegaze"))))
+	moov = box("moov", box("udta", box("meta", []byte{0, 0, 0, 0}, items)))
+	if tags, err := ReadTags(bytes.NewReader(mp4File(0, moov)), ContainerMP4); err != nil || tags.Genre() != "Shoegaze" {
+		t.Errorf("ReadTags = (%+v, %v), want the named genre", tags, err)
+	}
+
+	for name, moov := range map[string][]byte{
+		"no user data":   box("moov"),
+		"no item list":   box("moov", box("udta", box("meta", []byte{0, 0, 0, 0}))),
+		"no known items": box("moov", box("udta", box("meta", []byte{0, 0, 0, 0}, box("ilst", box("free"))))),
+	} {
+		if _, err := ReadTags(bytes.NewReader(mp4File(0, moov)), ContainerMP4); err != ErrNoTags {
+			t.Errorf("ReadTags(%s) error = %v, want ErrNoTags", name, err)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrNotMPEG is returned when no MPEG audio frames are found in a file
+var ErrNotMPEG = errors.New("no MPEG audio frames found")
+
+// Channel modes of an audio stream
+const (
+	ChannelModeStereo       = "stereo"
+	ChannelModeJointStereo  = "joint_stereo"
+	ChannelModeDualChannel  = "dual_channel"
+	ChannelModeMono         = "mono"
+	ChannelModeMultichannel = "multichannel"
+)
+
+// maxSyncSearch is how far past the tags the first frame is searched for
+const maxSyncSearch = 64 << 10
+
+// StreamInfo describes the audio stream of a file
+type StreamInfo struct {
+	Container   string  // One of the containers
+	Codec       string  // Codec of the stream, e.g. "mp3" or "flac"
+	BitDepth    int     // Bits per sample of lossless and PCM streams, 0 for lossy ones
+	Duration    float64 // Playing time in seconds
+	Bitrate     int     // Average bitrate in kbit/s
+	SampleRate  int     // Sample rate in Hz
+	Channels    int     // Number of channels
+	ChannelMode string  // One of the channel modes
+	VBR         bool    // Whether the bitrate varies between frames
+}
+
+// mpegFrame holds the fields of an MPEG audio frame header
+type mpegFrame struct {
+	version     int // 1 for MPEG-1, 2 for MPEG-2 and 25 for MPEG-2.5
+	layer       int // 1, 2 or 3
+	bitrate     int // kbit/s
+	sampleRate  int // Hz
+	channelMode string
+	length      int // Frame length in bytes, including the header
+	samples     int // Samples per channel in the frame
+}
+
+// bitrates lists the bitrates in kbit/s by version and layer, indexed by the header's bitrate index
+var bitrates = map[[2]int][16]int{
+	{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+	{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+// sampleRates lists the sample rates in Hz by version, indexed by the header's sample rate index
+var sampleRates = map[int][3]int{
+	1:  {44100, 48000, 32000},
+	2:  {22050, 24000, 16000},
+	25: {11025, 12000, 8000},
+}
+
+// ReadMPEGInfo computes the duration and stream properties of an MP3 file. It honors Xing, Info and
+// VBRI headers, including the LAME encoder delay and padding, and otherwise walks every frame header
+func ReadMPEGInfo(r io.ReadSeeker) (*StreamInfo, error) {
+	start, end, err := audioRange(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the first frame, confirmed by the header of the frame that follows it
+	offset, first, err := findFirstFrame(r, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &StreamInfo{
+		Container:   ContainerMPEG,
+		Codec:       codecName(first.layer),
+		SampleRate:  first.sampleRate,
+		Channels:    2,
+		ChannelMode: first.channelMode,
+	}
+	if first.channelMode == ChannelModeMono {
+		info.Channels = 1
+	}
+
+	// Prefer the frame count recorded by the encoder in the first frame
+	frame := make([]byte, first.length)
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, ErrNotMPEG
+	}
+	if frames, size, delay, vbr, ok := readVBRHeader(frame, first); ok && frames > 0 {
+		samples := frames*int64(first.samples) - int64(delay)
+		if samples <= 0 {
+			samples = frames * int64(first.samples)
+		}
+		info.Duration = float64(samples) / float64(first.sampleRate)
+		if size <= 0 {
+			size = end - offset - int64(first.length) // The header frame holds no audio
+		}
+		info.Bitrate = averageBitrate(size, info.Duration)
+		info.VBR = vbr
+		return info, nil
+	}
+
+	// Otherwise walk every frame header
+	frames, size, vbr, err := walkFrames(r, offset, end)
+	if err != nil {
+		return nil, err
+	}
+	info.Duration = float64(frames) * float64(first.samples) / float64(first.sampleRate)
+	info.Bitrate = averageBitrate(size, info.Duration)
+	info.VBR = vbr
+	return info, nil
+}
+
+// audioRange returns the offsets between which the audio frames lie, excluding the ID3v2 tag at the start
+// and the ID3v1 and APEv2 tags at the end
+func audioRange(r io.ReadSeeker) (int64, int64, error) {
+	start, err := ID3v2Size(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// A tag whose declared size runs past the end of the file leaves no audio
+	if start > end {
+		start = end
+	}
+
+	// An ID3v1 tag takes the last 128 bytes
+	trailer := make([]byte, 128)
+	if end-start >= 128 {
+		if _, err := r.Seek(end-128, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, trailer); err != nil {
+			return 0, 0, err
+		}
+		if string(trailer[:3]) == "TAG" {
+			end -= 128
+		}
+	}
+
+	// An APEv2 tag ends with a 32-byte footer whose size excludes the header
+	footer := make([]byte, 32)
+	if end-start >= 32 {
+		if _, err := r.Seek(end-32, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		if _, err := io.ReadFull(r, footer); err != nil {
+			return 0, 0, err
+		}
+		if string(footer[:8]) == "APETAGEX" {
+			size := int64(binary.LittleEndian.Uint32(footer[12:16]))
+			if footer[23]&0x80 != 0 {
+				size += 32 // The tag also has a header
+			}
+			if size <= end-start {
+				end -= size
+			}
+		}
+	}
+
+	return start, end, nil
+}
+
+// findFirstFrame searches for the first frame header that is followed by another valid header
+func findFirstFrame(r io.ReadSeeker, start, end int64) (int64, *mpegFrame, error) {
+	searchEnd := start + maxSyncSearch
+	if searchEnd > end {
+		searchEnd = end
+	}
+
+	buffer := make([]byte, searchEnd-start+4)
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, nil, err
+	}
+	n, err := io.ReadFull(r, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, nil, err
+	}
+	buffer = buffer[:n]
+
+	for i := 0; i+4 <= len(buffer); i++ {
+		frame, ok := parseFrameHeader(buffer[i:])
+		if !ok {
+			continue
+		}
+
+		// Confirm the sync by checking the next header, unless the frame ends the stream
+		next := start + int64(i) + int64(frame.length)
+		if next == end {
+			return start + int64(i), frame, nil
+		}
+		header := make([]byte, 4)
+		if _, err := r.Seek(next, io.SeekStart); err != nil {
+			return 0, nil, err
+		}
+		if _, err := io.ReadFull(r, header); err != nil {
+			continue
+		}
+		if following, ok := parseFrameHeader(header); ok && following.version == frame.version && following.layer == frame.layer && following.sampleRate == frame.sampleRate {
+			return start + int64(i), frame, nil
+		}
+	}
+
+	return 0, nil, ErrNotMPEG
+}
+
+// walkFrames counts the frames between the offsets, resynchronising past damaged data,
+// and returns the frame count, the audio size in bytes and whether the bitrate varies
+func walkFrames(r io.ReadSeeker, offset, end int64) (int64, int64, bool, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, false, err
+	}
+	reader := bufio.NewReaderSize(r, 64<<10)
+
+	var frames, size int64
+	var firstBitrate int
+	vbr := false
+	position := offset
+	for position+4 <= end {
+		header, err := reader.Peek(4)
+		if err != nil {
+			break
+		}
+
+		frame, ok := parseFrameHeader(header)
+		if !ok || position+int64(frame.length) > end {
+			// Skip a byte and look for the next frame header
+			if _, err := reader.Discard(1); err != nil {
+				break
+			}
+			position++
+			continue
+		}
+
+		if firstBitrate == 0 {
+			firstBitrate = frame.bitrate
+		} else if frame.bitrate != firstBitrate {
+			vbr = true
+		}
+		frames++
+		size += int64(frame.length)
+
+		if _, err := reader.Discard(frame.length); err != nil {
+			break
+		}
+		position += int64(frame.length)
+	}
+
+	if frames == 0 {
+		return 0, 0, false, ErrNotMPEG
+	}
+	return frames, size, vbr, nil
+}
+
+// parseFrameHeader decodes a four-byte MPEG audio frame header
+func parseFrameHeader(b []byte) (*mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return nil, false // No frame sync
+	}
+
+	frame := &mpegFrame{}
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		frame.version = 25
+	case 2:
+		frame.version = 2
+	case 3:
+		frame.version = 1
+	default:
+		return nil, false // Reserved version
+	}
+
+	frame.layer = 4 - int((b[1]>>1)&0x03)
+	if frame.layer == 4 {
+		return nil, false // Reserved layer
+	}
+
+	table := frame.version
+	if table == 25 {
+		table = 2 // MPEG-2.5 shares the MPEG-2 bitrates
+	}
+	bitrateIndex, sampleRateIndex := b[2]>>4, (b[2]>>2)&0x03
+	if bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return nil, false // Free format and reserved values are not supported
+	}
+	frame.bitrate = bitrates[[2]int{table, frame.layer}][bitrateIndex]
+	frame.sampleRate = sampleRates[frame.version][sampleRateIndex]
+	padding := int((b[2] >> 1) & 0x01)
+
+	switch b[3] >> 6 {
+	case 0:
+		frame.channelMode = ChannelModeStereo
+	case 1:
+		frame.channelMode = ChannelModeJointStereo
+	case 2:
+		frame.channelMode = ChannelModeDualChannel
+	default:
+		frame.channelMode = ChannelModeMono
+	}
+
+	switch {
+	case frame.layer == 1:
+		frame.samples = 384
+		frame.length = (12*frame.bitrate*1000/frame.sampleRate + padding) * 4
+	case frame.layer == 3 && frame.version != 1:
+		frame.samples = 576
+		frame.length = 72*frame.bitrate*1000/frame.sampleRate + padding
+	default:
+		frame.samples = 1152
+		frame.length = 144*frame.bitrate*1000/frame.sampleRate + padding
+	}
+
+	return frame, frame.length > 4
+}
+
+// readVBRHeader reads the Xing, Info or VBRI header an encoder may store in the first frame and returns
+// the frame count, the audio size in bytes, the encoder delay and padding in samples and whether the file is VBR
+func readVBRHeader(frame []byte, header *mpegFrame) (int64, int64, int, bool, bool) {
+	// The Xing and Info headers follow the side information of layer III frames
+	sideInfo := 32
+	switch {
+	case header.version == 1 && header.channelMode == ChannelModeMono:
+		sideInfo = 17
+	case header.version != 1 && header.channelMode != ChannelModeMono:
+		sideInfo = 17
+	case header.version != 1:
+		sideInfo = 9
+	}
+
+	if xing := 4 + sideInfo; len(frame) >= xing+8 {
+		tag := string(frame[xing : xing+4])
+		if tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(frame[xing+4:])
+			position := xing + 8
+
+			var frames, size int64
+			if flags&0x01 != 0 && len(frame) >= position+4 {
+				frames = int64(binary.BigEndian.Uint32(frame[position:]))
+				position += 4
+			}
+			if flags&0x02 != 0 && len(frame) >= position+4 {
+				size = int64(binary.BigEndian.Uint32(frame[position:]))
+				position += 4
+			}
+			if flags&0x04 != 0 {
+				position += 100 // Seek table
+			}
+			if flags&0x08 != 0 {
+				position += 4 // Quality indicator
+			}
+
+			// The LAME tag records the encoder delay and padding in 12 bits each, 21 bytes into the tag
+			delay := 0
+			if len(frame) >= position+24 {
+				encoder := string(frame[position : position+4])
+				if encoder == "LAME" || encoder == "Lavf" || encoder == "Lavc" {
+					gapless := frame[position+21:]
+					encoderDelay := int(gapless[0])<<4 | int(gapless[1])>>4
+					padding := int(gapless[1]&0x0F)<<8 | int(gapless[2])
+					delay = encoderDelay + padding
+				}
+			}
+
+			return frames, size, delay, tag == "Xing", true
+		}
+	}
+
+	// The VBRI header sits 32 bytes after the frame header
+	if vbri := 4 + 32; len(frame) >= vbri+18 && string(frame[vbri:vbri+4]) == "VBRI" {
+		size := int64(binary.BigEndian.Uint32(frame[vbri+10:]))
+		frames := int64(binary.BigEndian.Uint32(frame[vbri+14:]))
+		return frames, size, 0, true, true
+	}
+
+	return 0, 0, 0, false, false
+}
+
+// codecName returns the codec name of an MPEG audio layer
+func codecName(layer int) string {
+	switch layer {
+	case 1:
+		return "mp1"
+	case 2:
+		return "mp2"
+	}
+	return "mp3"
+}
@@ -0,0 +1,211 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// MPEG-1 layer III frame headers at 48 kHz: 384-byte frames at 128 kbit/s and 480-byte frames at 160 kbit/s
+var (
+	header128 = []byte{0xFF, 0xFB, 0x94, 0x00}
+	header160 = []byte{0xFF, 0xFB, 0xA4, 0x00}
+)
+
+// containers lists every container the parsers read
+var containers = []string{ContainerMPEG, ContainerADTS, ContainerMP4, ContainerFLAC, ContainerOgg, ContainerWAV}
+
+// mpegFrames builds count frames of the given length starting with the header, with silent payloads
+func mpegFrames(header []byte, length, count int) []byte {
+	frame := make([]byte, length)
+	copy(frame, header)
+	return bytes.Repeat(frame, count)
+}
+
+// vbrHeaderFrame builds a 128 kbit/s stereo frame holding an encoder header at the Xing position
+func vbrHeaderFrame(header []byte) []byte {
+	frame := mpegFrames(header128, 384, 1)
+	copy(frame[4+32:], header) // The Xing header follows 32 bytes of stereo MPEG-1 side information
+	return frame
+}
+
+// xingHeader builds a Xing or Info header with frame and byte counts and a LAME tag recording the encoder delay and padding
+func xingHeader(tag string, frames, size uint32, delay, padding int) []byte {
+	header := []byte(tag)
+	header = binary.BigEndian.AppendUint32(header, 0x03) // Frame and byte counts present
+	header = binary.BigEndian.AppendUint32(header, frames)
+	header = binary.BigEndian.AppendUint32(header, size)
+
+	lame := make([]byte, 24)
+	copy(lame, "LAME3.100")
+	lame[21], lame[22], lame[23] = byte(delay>>4), byte(delay<<4)|byte(padding>>8), byte(padding)
+	return append(header, lame...)
+}
+
+// vbriHeader builds a Fraunhofer VBRI header with byte and frame counts
+func vbriHeader(size, frames uint32) []byte {
+	header := []byte("VBRI\x00\x01\x00\x00\x00\x4b")
+	header = binary.BigEndian.AppendUint32(header, size)
+	return binary.BigEndian.AppendUint32(header, frames)
+}
+
+func TestReadMPEGInfo(t *testing.T) {
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		codec    string
+		duration float64
+		bitrate  int
+		rate     int
+		channels int
+		mode     string
+		vbr      bool
+	}{
+		{
+			name:     "CBR",
+			data:     mpegFrames(header128, 384, 250),
+			codec:    "mp3",
+			duration: 250 * 1152 / 48000.0,
+			bitrate:  128,
+			rate:     48000,
+			channels: 2,
+			mode:     ChannelModeStereo,
+		},
+		{
+			name:     "CBR between ID3v2 and ID3v1 tags",
+			data:     bytes.Join([][]byte{[]byte("ID3\x03\x00\x00\x00\x00\x00\x0a"), make([]byte, 10), mpegFrames(header128, 384, 250), id3v1}, nil),
+			codec:    "mp3",
+			duration: 250 * 1152 / 48000.0,
+			bitrate:  128,
+			rate:     48000,
+			channels: 2,
+			mode:     ChannelModeStereo,
+		},
+		{
+			name:     "CBR MPEG-2 mono",
+			data:     mpegFrames([]byte{0xFF, 0xF3, 0x84, 0xC0}, 192, 100), // 64 kbit/s at 24 kHz
+			codec:    "mp3",
+			duration: 100 * 576 / 24000.0,
+			bitrate:  64,
+			rate:     24000,
+			channels: 1,
+			mode:     ChannelModeMono,
+		},
+		{
+			name:     "VBR without a header",
+			data:     append(mpegFrames(header128, 384, 100), mpegFrames(header160, 480, 100)...),
+			codec:    "mp3",
+			duration: 200 * 1152 / 48000.0,
+			bitrate:  144,
+			rate:     48000,
+			channels: 2,
+			mode:     ChannelModeStereo,
+			vbr:      true,
+		},
+		{
+			name:     "Xing VBR with encoder delay and padding",
+			data:     append(vbrHeaderFrame(xingHeader("Xing", 1000, 400000, 576, 1000)), mpegFrames(header160, 480, 1)...),
+			codec:    "mp3",
+			duration: (1000*1152 - 576 - 1000) / 48000.0,
+			bitrate:  133,
+			rate:     48000,
+			channels: 2,
+			mode:     ChannelModeStereo,
+			vbr:      true,
+		},
+		{
+			name:     "Info CBR",
+			data:     append(vbrHeaderFrame(xingHeader("Info", 250, 96000, 0, 0)), mpegFrames(header128, 384, 1)...),
+			codec:    "mp3",
+			duration: 250 * 1152 / 48000.0,
+			bitrate:  128,
+			rate:     48000,
+			channels: 2,
+			mode:     ChannelModeStereo,
+		},
+		{
+			name:     "VBRI VBR",
+			data:     append(vbrHeaderFrame(vbriHeader(200000, 500)), mpegFrames(header160, 480, 1)...),
+			codec:    "mp3",
+			duration: 500 * 1152 / 48000.0,
+			bitrate:  133,
+			rate:     48000,
+			channels: 2,
+			mode:     ChannelModeStereo,
+			vbr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ReadMPEGInfo(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatalf("ReadMPEGInfo: %v", err)
+			}
+			if math.Abs(info.Duration-test.duration) > 1e-9 {
+				t.Errorf("Duration = %v, want %v", info.Duration, test.duration)
+			}
+			if info.Codec != test.codec || info.Bitrate != test.bitrate || info.SampleRate != test.rate ||
+				info.Channels != test.channels || info.ChannelMode != test.mode || info.VBR != test.vbr {
+				t.Errorf("info = %+v, want %s at %d kbit/s, %d Hz, %d channels, %s, VBR %v",
+					info, test.codec, test.bitrate, test.rate, test.channels, test.mode, test.vbr)
+			}
+		})
+	}
+}
+
+func TestReadMPEGInfoWithoutFrames(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		make([]byte, 1000),
+		[]byte("\xff\xfb\x94\x00"), // A lone header without a following frame
+	} {
+		if _, err := ReadMPEGInfo(bytes.NewReader(data)); err != ErrNotMPEG {
+			t.Errorf("ReadMPEGInfo(%d bytes) error = %v, want ErrNotMPEG", len(data), err)
+		}
+	}
+}
+
+func TestReadMPEGInfoOversizedID3v2(t *testing.T) {
+	// An ID3v2 header declaring a 128-byte tag in a 14-byte file, followed by the start of a frame header
+	data := []byte("ID3\x03\x00\x00\x00\x00\x01\x00\xff\xfb\x90\x00")
+
+	for _, container := range []string{ContainerMPEG, ContainerADTS} {
+		if _, err := ReadStreamInfo(bytes.NewReader(data), container); err == nil {
+			t.Errorf("ReadStreamInfo(%s) succeeded on a file without audio", container)
+		}
+	}
+}
+
+func TestAudioRangeClampsStart(t *testing.T) {
+	data := []byte("ID3\x03\x00\x00\x00\x00\x01\x00\xff\xfb\x90\x00")
+
+	start, end, err := audioRange(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("audioRange: %v", err)
+	}
+	if start > end {
+		t.Errorf("audioRange = (%d, %d), start past end", start, end)
+	}
+}
+
+func FuzzReadStreamInfo(f *testing.F) {
+	f.Add([]byte("ID3\x03\x00\x00\x00\x00\x01\x00\xff\xfb\x90\x00"))
+	f.Add([]byte("\xff\xfb\x90\x00\xff\xfb\x90\x00"))
+	f.Add(append(vbrHeaderFrame(vbriHeader(200000, 500)), header160...))
+	f.Add([]byte("\xff\xf1\x50\x80\x01\x1f\xfc"))
+	f.Add([]byte("fLaC\x80\x00\x00\x22"))
+	f.Add([]byte("OggS\x00\x02"))
+	f.Add([]byte("RIFF\x24\x00\x00\x00WAVEfmt "))
+	f.Add([]byte("\x00\x00\x00\x18ftypM4A "))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, container := range containers {
+			ReadStreamInfo(bytes.NewReader(data), container)
+			ReadTags(bytes.NewReader(data), container)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// opusSampleRate is the rate in Hz Opus streams are always decoded at and their granule positions count samples in
+const opusSampleRate = 48000
+
+// maxOggPageSize is the largest size of an Ogg page, searched for at the end of a file to find the last granule position
+const maxOggPageSize = 27 + 255 + 255*255
+
+// Magic bytes starting the identification and comment headers of the codecs stored in Ogg files
+var (
+	vorbisIDHeader      = []byte("\x01vorbis")
+	vorbisCommentHeader = []byte("\x03vorbis")
+	opusIDHeader        = []byte("OpusHead")
+	opusCommentHeader   = []byte("OpusTags")
+)
+
+// ReadOggInfo computes the duration and stream properties of an Ogg Vorbis or Opus file from the identification
+// header of its first logical stream and the granule position of its last page
+func ReadOggInfo(r io.ReadSeeker) (*StreamInfo, error) {
+	info, _, err := readOgg(r, false)
+	return info, err
+}
+
+// ReadOggTags reads the Vorbis comment in the comment header of an Ogg Vorbis or Opus file
+func ReadOggTags(r io.ReadSeeker) (*Tags, error) {
+	_, tags, err := readOgg(r, true)
+	if err != nil {
+		return nil, err
+	}
+	if tags.Format == "" {
+		return nil, ErrNoTags
+	}
+	return tags, nil
+}
+
+// readOgg reads the stream properties from the headers of an Ogg file and, if asked for, the tags
+func readOgg(r io.ReadSeeker, withTags bool) (*StreamInfo, *Tags, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	packets := &oggPacketReader{r: bufio.NewReader(r)}
+
+	// The first packet identifies the codec
+	header, err := packets.next()
+	if err != nil {
+		return nil, nil, err
+	}
+	info := &StreamInfo{Container: ContainerOgg}
+	var preSkip int64
+	var commentHeader []byte
+	switch {
+	case bytes.HasPrefix(header, vorbisIDHeader) && len(header) >= 30:
+		info.Codec = "vorbis"
+		info.Channels = int(header[11])
+		info.SampleRate = int(binary.LittleEndian.Uint32(header[12:16]))
+		commentHeader = vorbisCommentHeader
+	case bytes.HasPrefix(header, opusIDHeader) && len(header) >= 19:
+		info.Codec = "opus"
+		info.Channels = int(header[9])
+		info.SampleRate = opusSampleRate
+		preSkip = int64(binary.LittleEndian.Uint16(header[10:12])) // Samples the decoder discards at the start
+		commentHeader = opusCommentHeader
+	default:
+		return nil, nil, ErrInvalidStream
+	}
+	if info.Channels == 0 || info.SampleRate == 0 {
+		return nil, nil, ErrInvalidStream
+	}
+	info.ChannelMode = channelMode(info.Channels)
+
+	// The second packet holds the Vorbis comment
+	tags := &Tags{Frames: map[string]string{}, kind: tagKindVorbis}
+	if withTags {
+		comment, err := packets.next()
+		if err == nil && bytes.HasPrefix(comment, commentHeader) && parseVorbisComment(comment[len(commentHeader):], tags) {
+			tags.Format = vorbisCommentFormat
+		}
+	}
+
+	// The granule position of the last page counts the samples of the whole stream
+	granule, end, err := lastGranulePosition(r, packets.serial)
+	if err != nil {
+		return nil, nil, err
+	}
+	samples := granule - preSkip
+	if samples <= 0 {
+		return nil, nil, ErrInvalidStream
+	}
+	info.Duration = float64(samples) / float64(info.SampleRate)
+	info.Bitrate = averageBitrate(end, info.Duration)
+
+	return info, tags, nil
+}
+
+// lastGranulePosition searches the end of an Ogg file for the last page of the logical stream with the given serial
+// number and returns its granule position along with the size of the file
+func lastGranulePosition(r io.ReadSeeker, serial uint32) (int64, int64, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	start := max(0, end-maxOggPageSize)
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	tail := make([]byte, end-start)
+	if _, err := io.ReadFull(r, tail); err != nil {
+		return 0, 0, err
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		page := tail[i:]
+		if len(page) < 27 || page[4] != 0 || binary.LittleEndian.Uint32(page[14:18]) != serial {
+			continue
+		}
+		granule := int64(binary.LittleEndian.Uint64(page[6:14]))
+		if granule >= 0 { // Pages on which no packet ends have a granule position of -1
+			return granule, end, nil
+		}
+	}
+
+	return 0, 0, ErrInvalidStream
+}
+
+// oggPacketReader reassembles the packets of the first logical stream of an Ogg file from its pages
+type oggPacketReader struct {
+	r       *bufio.Reader
+	serial  uint32   // Serial number of the first logical stream
+	started bool     // Whether the first page was read, setting the serial number
+	packet  []byte   // Packet continued on the next page
+	packets [][]byte // Complete packets not returned yet
+}
+
+// next returns the next packet of the stream
+func (o *oggPacketReader) next() ([]byte, error) {
+	for len(o.packets) == 0 {
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+	packet := o.packets[0]
+	o.packets = o.packets[1:]
+	return packet, nil
+}
+
+// readPage reads the next page, splitting its segments into packets; pages of other logical streams are skipped
+func (o *oggPacketReader) readPage() error {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(o.r, header); err != nil || string(header[:4]) != "OggS" {
+		return ErrInvalidStream
+	}
+	lacing := make([]byte, header[26])
+	if _, err := io.ReadFull(o.r, lacing); err != nil {
+		return ErrInvalidStream
+	}
+	size := 0
+	for _, length := range lacing {
+		size += int(length)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(o.r, body); err != nil {
+		return ErrInvalidStream
+	}
+
+	serial := binary.LittleEndian.Uint32(header[14:18])
+	if !o.started {
+		o.serial, o.started = serial, true
+	}
+	if serial != o.serial {
+		return nil
+	}
+
+	// A segment shorter than 255 bytes ends a packet
+	for _, length := range lacing {
+		o.packet = append(o.packet, body[:length]...)
+		body = body[length:]
+		if len(o.packet) > maxTagSize {
+			return ErrInvalidStream
+		}
+		if length < 255 {
+			o.packets = append(o.packets, o.packet)
+			o.packet = nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// oggPage builds a page of the logical stream holding the packets; if open, the last packet, whose length must be
+// a multiple of 255, continues on the next page
+func oggPage(serial uint32, granule int64, open bool, packets ...[]byte) []byte {
+	var lacing, body []byte
+	for i, packet := range packets {
+		n := len(packet)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+		if !open || i < len(packets)-1 {
+			lacing = append(lacing, byte(n)) // A segment shorter than 255 bytes ends the packet
+		}
+		body = append(body, packet...)
+	}
+
+	page := []byte("OggS\x00\x00")
+	page = binary.LittleEndian.AppendUint64(page, uint64(granule))
+	page = binary.LittleEndian.AppendUint32(page, serial)
+	page = append(page, 0, 0, 0, 0, 0, 0, 0, 0) // Sequence number and checksum
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+	return append(page, body...)
+}
+
+// vorbisIdentification builds the identification header of a Vorbis stream
+func vorbisIdentification(channels byte, sampleRate uint32) []byte {
+	header := append([]byte("\x01vorbis\x00\x00\x00\x00"), channels)
+	header = binary.LittleEndian.AppendUint32(header, sampleRate)
+	return append(header, make([]byte, 14)...) // Bitrates, block sizes and framing bit
+}
+
+// opusIdentification builds the identification header of an Opus stream
+func opusIdentification(channels byte, preSkip uint16) []byte {
+	header := append([]byte("OpusHead\x01"), channels)
+	header = binary.LittleEndian.AppendUint16(header, preSkip)
+	return append(header, 0x44, 0xAC, 0, 0, 0, 0, 0) // Input sample rate, output gain and channel mapping family
+}
+
+func TestReadOggInfo(t *testing.T) {
+	vorbisComments := append([]byte("\x03vorbis"), vorbisComment()...)
+	audio := make([]byte, 4000)
+	vorbis := join(
+		oggPage(1, 0, false, vorbisIdentification(2, 44100)),
+		oggPage(1, 0, false, vorbisComments, []byte("\x05vorbis")),
+		oggPage(1, 441000, false, audio),
+	)
+	opus := join(
+		oggPage(7, 0, false, opusIdentification(1, 312)),
+		oggPage(7, 0, false, append([]byte("OpusTags"), vorbisComment()...)),
+		oggPage(7, 240312, false, audio),
+	)
+	interleaved := join(vorbis, oggPage(2, 999999, false, audio)) // Ends with a page of another logical stream
+	unfinished := join(vorbis, oggPage(1, -1, true, make([]byte, 255)))
+
+	tests := []struct {
+		name string
+		data []byte
+		want StreamInfo
+	}{
+		{
+			name: "Vorbis",
+			data: vorbis,
+			want: StreamInfo{Container: ContainerOgg, Codec: "vorbis", Duration: 10, Bitrate: len(vorbis) * 8 / 10 / 1000, SampleRate: 44100, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+		{
+			name: "Opus discarding its pre-skip",
+			data: opus,
+			want: StreamInfo{Container: ContainerOgg, Codec: "opus", Duration: 5, Bitrate: len(opus) * 8 / 5 / 1000, SampleRate: 48000, Channels: 1, ChannelMode: ChannelModeMono},
+		},
+		{
+			name: "pages of another logical stream are skipped",
+			data: interleaved,
+			want: StreamInfo{Container: ContainerOgg, Codec: "vorbis", Duration: 10, Bitrate: len(interleaved) * 8 / 10 / 1000, SampleRate: 44100, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+		{
+			name: "a last page on which no packet ends is skipped",
+			data: unfinished,
+			want: StreamInfo{Container: ContainerOgg, Codec: "vorbis", Duration: 10, Bitrate: len(unfinished) * 8 / 10 / 1000, SampleRate: 44100, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ReadStreamInfo(bytes.NewReader(test.data), ContainerOgg)
+			if err != nil {
+				t.Fatalf("ReadStreamInfo: %v", err)
+			}
+			checkStreamInfo(t, info, test.want)
+		})
+	}
+}
+
+func TestReadOggInfoInvalid(t *testing.T) {
+	audio := oggPage(1, 441000, false, make([]byte, 100))
+
+	for name, data := range map[string][]byte{
+		"empty":                    nil,
+		"another format":           []byte("fLaC\x00\x00\x00\x22"),
+		"another codec":            join(oggPage(1, 0, false, []byte("\x80theora"+string(make([]byte, 40)))), audio),
+		"short Vorbis header":      join(oggPage(1, 0, false, vorbisIdentification(2, 44100)[:20]), audio),
+		"no channels":              join(oggPage(1, 0, false, vorbisIdentification(0, 44100)), audio),
+		"no sample rate":           join(oggPage(1, 0, false, vorbisIdentification(2, 0)), audio),
+		"no samples":               oggPage(1, 0, false, vorbisIdentification(2, 44100)),
+		"no samples past the skip": join(oggPage(1, 0, false, opusIdentification(2, 312)), oggPage(1, 312, false, make([]byte, 10))),
+		"truncated page":           oggPage(1, 0, false, vorbisIdentification(2, 44100))[:40],
+	} {
+		if _, err := ReadStreamInfo(bytes.NewReader(data), ContainerOgg); err != ErrInvalidStream {
+			t.Errorf("ReadStreamInfo(%s) error = %v, want ErrInvalidStream", name, err)
+		}
+	}
+}
+
+func TestReadOggTags(t *testing.T) {
+	comments := vorbisComment("TITLE=Song", "ARTIST=Band", "ALBUM=Record", "GENRE=Ambient", "DATE=1999-12-31")
+	audio := oggPage(1, 48000, false, make([]byte, 100))
+
+	// Pad the comment header to span pages, as headers holding pictures do
+	long := append(append([]byte("\x03vorbis"), comments...), make([]byte, 510-7-len(comments))...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Vorbis", join(oggPage(1, 0, false, vorbisIdentification(2, 48000)), oggPage(1, 0, false, append([]byte("\x03vorbis"), comments...)), audio)},
+		{"Opus", join(oggPage(1, 0, false, opusIdentification(2, 0)), oggPage(1, 0, false, append([]byte("OpusTags"), comments...)), audio)},
+		{"comment header spanning pages", join(
+			oggPage(1, 0, false, vorbisIdentification(2, 48000)),
+			oggPage(1, -1, true, long[:255]),
+			oggPage(2, 0, false, []byte("another stream")),
+			oggPage(1, 0, false, long[255:]),
+			audio,
+		)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := ReadTags(bytes.NewReader(test.data), ContainerOgg)
+			if err != nil {
+				t.Fatalf("ReadTags: %v", err)
+			}
+			if tags.Format != vorbisCommentFormat {
+				t.Errorf("Format = %q, want %q", tags.Format, vorbisCommentFormat)
+			}
+			checkTags(t, tags, "Song", "Band", "Record", "Ambient", 1999)
+		})
+	}
+
+	// A comment header of another codec is not read
+	data := join(oggPage(1, 0, false, opusIdentification(2, 0)), oggPage(1, 0, false, append([]byte("\x03vorbis"), comments...)), audio)
+	if _, err := ReadTags(bytes.NewReader(data), ContainerOgg); err != ErrNoTags {
+		t.Errorf("ReadTags of a mismatched comment header error = %v, want ErrNoTags", err)
+	}
+}
@@ -0,0 +1,174 @@
+package audio
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Picture types of embedded pictures
+const (
+	PictureTypeOther      = 0x00 // Any other picture
+	PictureTypeFrontCover = 0x03 // Front cover of the album
+)
+
+// Kinds of tags, which name the same fields differently
+const (
+	tagKindID3 = iota
+	tagKindVorbis
+	tagKindMP4
+	tagKindRIFF
+)
+
+// Fields of the track read from the tags
+const (
+	fieldTitle = iota
+	fieldArtist
+	fieldAlbumArtist
+	fieldAlbum
+	fieldGenre
+	fieldYear
+)
+
+// fieldKeys lists by kind of tags the keys a field may be stored under, in order of preference
+var fieldKeys = map[int]map[int][]string{
+	tagKindID3: {
+		fieldTitle:       {"TIT2"},
+		fieldArtist:      {"TPE1"},
+		fieldAlbumArtist: {"TPE2"},
+		fieldAlbum:       {"TALB"},
+		fieldGenre:       {"TCON"},
+		fieldYear:        {"TDRC", "TYER", "TDOR", "TORY"}, // ID3v2.4 records the recording time, ID3v2.3 and ID3v1 the year
+	},
+	tagKindVorbis: {
+		fieldTitle:       {"TITLE"},
+		fieldArtist:      {"ARTIST"},
+		fieldAlbumArtist: {"ALBUMARTIST", "ALBUM ARTIST"},
+		fieldAlbum:       {"ALBUM"},
+		fieldGenre:       {"GENRE"},
+		fieldYear:        {"DATE", "YEAR", "ORIGINALDATE"},
+	},
+	tagKindMP4: {
+		fieldTitle:       {"©nam"},
+		fieldArtist:      {"©ART"},
+		fieldAlbumArtist: {"aART"},
+		fieldAlbum:       {"©alb"},
+		fieldGenre:       {"©gen", "gnre"},
+		fieldYear:        {"©day"},
+	},
+	tagKindRIFF: {
+		fieldTitle:  {"INAM"},
+		fieldArtist: {"IART"},
+		fieldAlbum:  {"IPRD"},
+		fieldGenre:  {"IGNR"},
+		fieldYear:   {"ICRD"},
+	},
+}
+
+// Tags holds the metadata read from the tags of an audio file
+type Tags struct {
+	Format   string            // Tag format and version, e.g. "ID3v2.3", "Vorbis comment" or "MP4"
+	Frames   map[string]string // Text frames keyed by frame ID, comment field name or atom type, e.g. "TIT2", "TITLE" or "©nam"
+	Pictures []Picture         // Embedded pictures, in the order they appear
+	kind     int               // Kind of the tags, selecting the keys fields are stored under
+}
+
+// Picture is a picture embedded in the tags of an audio file
+type Picture struct {
+	MIMEType    string // MIME type of the picture, e.g. "image/jpeg"
+	Type        byte   // One of the picture types
+	Description string // Description of the picture
+	Data        []byte // Encoded picture data
+}
+
+// CoverArt returns the embedded front cover, or the first embedded picture if there is no front cover
+func (t *Tags) CoverArt() *Picture {
+	for i := range t.Pictures {
+		if t.Pictures[i].Type == PictureTypeFrontCover {
+			return &t.Pictures[i]
+		}
+	}
+	if len(t.Pictures) > 0 {
+		return &t.Pictures[0]
+	}
+	return nil
+}
+
+// Title returns the title of the track
+func (t *Tags) Title() string {
+	return t.field(fieldTitle)
+}
+
+// Artist returns the artist of the track, falling back to the album artist
+func (t *Tags) Artist() string {
+	if artist := t.field(fieldArtist); artist != "" {
+		return artist
+	}
+	return t.field(fieldAlbumArtist)
+}
+
+// Album returns the album of the track
+func (t *Tags) Album() string {
+	return t.field(fieldAlbum)
+}
+
+// Genre returns the genre of the track, resolving numeric ID3v1 genre references to their names
+func (t *Tags) Genre() string {
+	return parseGenre(t.field(fieldGenre))
+}
+
+// Year returns the release year of the track, or 0 if it is not tagged
+func (t *Tags) Year() int {
+	for _, key := range fieldKeys[t.kind][fieldYear] {
+		value := t.Frames[key]
+		if len(value) < 4 {
+			continue
+		}
+		if year, err := strconv.Atoi(value[:4]); err == nil && year > 0 {
+			return year
+		}
+	}
+	return 0
+}
+
+// field returns the first non-empty value of a field under the keys of the kind of tags
+func (t *Tags) field(field int) string {
+	for _, key := range fieldKeys[t.kind][field] {
+		if value := t.Frames[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseGenre resolves the forms a genre can be written in: "Rock", "17", "(17)" and "(17)Rock"
+func parseGenre(value string) string {
+	value = strings.TrimSpace(value)
+
+	// Resolve a leading "(n)" reference, preferring the refinement text that follows it
+	if strings.HasPrefix(value, "(") && !strings.HasPrefix(value, "((") {
+		if end := strings.Index(value, ")"); end > 0 {
+			reference, refinement := value[1:end], strings.TrimSpace(value[end+1:])
+			if refinement != "" {
+				return refinement
+			}
+			switch reference {
+			case "RX":
+				return "Remix"
+			case "CR":
+				return "Cover"
+			}
+			value = reference
+		}
+	}
+
+	// Resolve a bare genre number, including the first of several numbers
+	number, _, _ := strings.Cut(value, "/")
+	if index, err := strconv.Atoi(number); err == nil {
+		if index >= 0 && index < len(genres) {
+			return genres[index]
+		}
+		return ""
+	}
+
+	return strings.TrimPrefix(value, "(") // An escaped "((" starts a genre name with a parenthesis
+}
@@ -0,0 +1,94 @@
+package audio
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// vorbisCommentFormat is the tag format of Vorbis comments, used by FLAC, Ogg Vorbis and Opus files
+const vorbisCommentFormat = "Vorbis comment"
+
+// parseVorbisComment adds the fields of a Vorbis comment block to the tags. Field names are case-insensitive and
+// stored upper-cased; repeated fields are joined with a semicolon, and pictures stored base64-encoded in
+// METADATA_BLOCK_PICTURE fields are added to the pictures. It returns false if the block is malformed
+func parseVorbisComment(data []byte, tags *Tags) bool {
+	_, data, ok := readLengthPrefixed(data, binary.LittleEndian) // Skip the vendor string naming the encoder
+	if !ok || len(data) < 4 {
+		return false
+	}
+
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	for i := uint32(0); i < count; i++ {
+		var comment []byte
+		comment, data, ok = readLengthPrefixed(data, binary.LittleEndian)
+		if !ok {
+			return false
+		}
+
+		name, value, found := strings.Cut(string(comment), "=")
+		if !found || name == "" {
+			continue
+		}
+		name = strings.ToUpper(name)
+
+		if name == "METADATA_BLOCK_PICTURE" {
+			block, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				continue
+			}
+			if picture, ok := parsePictureBlock(block); ok {
+				tags.Pictures = append(tags.Pictures, picture)
+			}
+			continue
+		}
+
+		if previous := tags.Frames[name]; previous != "" {
+			value = previous + "; " + value
+		}
+		tags.Frames[name] = value
+	}
+
+	return true
+}
+
+// parsePictureBlock parses a FLAC picture block, as stored in FLAC files and base64-encoded in Vorbis comments
+func parsePictureBlock(data []byte) (Picture, bool) {
+	if len(data) < 4 {
+		return Picture{}, false
+	}
+	pictureType := binary.BigEndian.Uint32(data)
+
+	mimeType, data, ok := readLengthPrefixed(data[4:], binary.BigEndian)
+	if !ok {
+		return Picture{}, false
+	}
+	description, data, ok := readLengthPrefixed(data, binary.BigEndian)
+	if !ok || len(data) < 16 {
+		return Picture{}, false
+	}
+	picture, _, ok := readLengthPrefixed(data[16:], binary.BigEndian) // Skip the width, height, color depth and palette size
+	if !ok {
+		return Picture{}, false
+	}
+
+	return Picture{
+		MIMEType:    string(mimeType),
+		Type:        byte(pictureType),
+		Description: string(description),
+		Data:        picture,
+	}, true
+}
+
+// readLengthPrefixed reads a byte string preceded by its 32-bit length and returns it with the data following it
+func readLengthPrefixed(data []byte, order binary.ByteOrder) ([]byte, []byte, bool) {
+	if len(data) < 4 {
+		return nil, nil, false
+	}
+	length := order.Uint32(data)
+	if uint64(length) > uint64(len(data)-4) {
+		return nil, nil, false
+	}
+	return data[4 : 4+length], data[4+length:], true
+}
@@ -0,0 +1,125 @@
+package audio
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// vorbisComment builds a Vorbis comment block holding the "NAME=value" comments
+func vorbisComment(comments ...string) []byte {
+	block := binary.LittleEndian.AppendUint32(nil, 4)
+	block = append(block, "test"...) // Vendor string
+	block = binary.LittleEndian.AppendUint32(block, uint32(len(comments)))
+	for _, comment := range comments {
+		block = binary.LittleEndian.AppendUint32(block, uint32(len(comment)))
+		block = append(block, comment...)
+	}
+	return block
+}
+
+// pictureBlock builds a FLAC picture block of a 1x1 picture
+func pictureBlock(pictureType uint32, mimeType, description string, data []byte) []byte {
+	block := binary.BigEndian.AppendUint32(nil, pictureType)
+	block = binary.BigEndian.AppendUint32(block, uint32(len(mimeType)))
+	block = append(block, mimeType...)
+	block = binary.BigEndian.AppendUint32(block, uint32(len(description)))
+	block = append(block, description...)
+	block = append(block, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 24, 0, 0, 0, 0) // Width, height, color depth and palette size
+	block = binary.BigEndian.AppendUint32(block, uint32(len(data)))
+	return append(block, data...)
+}
+
+func TestParseVorbisComment(t *testing.T) {
+	cover := pictureBlock(PictureTypeFrontCover, "image/png", "Front", []byte("\x89PNG"))
+
+	tests := []struct {
+		name     string
+		block    []byte
+		ok       bool
+		frames   map[string]string
+		pictures []Picture
+	}{
+		{
+			name:   "names are case-insensitive",
+			block:  vorbisComment("title=Song", "Artist=Band", "ALBUM=Record"),
+			ok:     true,
+			frames: map[string]string{"TITLE": "Song", "ARTIST": "Band", "ALBUM": "Record"},
+		},
+		{
+			name:   "repeated fields are joined",
+			block:  vorbisComment("ARTIST=One", "ARTIST=Two"),
+			ok:     true,
+			frames: map[string]string{"ARTIST": "One; Two"},
+		},
+		{
+			name:   "values may hold an equals sign",
+			block:  vorbisComment("TITLE=1 + 1 = 2"),
+			ok:     true,
+			frames: map[string]string{"TITLE": "1 + 1 = 2"},
+		},
+		{
+			name:   "comments without a name are skipped",
+			block:  vorbisComment("no separator", "=value", "GENRE=Jazz"),
+			ok:     true,
+			frames: map[string]string{"GENRE": "Jazz"},
+		},
+		{
+			name:     "pictures are decoded",
+			block:    vorbisComment("METADATA_BLOCK_PICTURE="+base64.StdEncoding.EncodeToString(cover), "METADATA_BLOCK_PICTURE=not base64"),
+			ok:       true,
+			frames:   map[string]string{},
+			pictures: []Picture{{MIMEType: "image/png", Type: PictureTypeFrontCover, Description: "Front", Data: []byte("\x89PNG")}},
+		},
+		{
+			name:   "a count past the comments is malformed",
+			block:  vorbisComment("TITLE=Song")[:18],
+			frames: map[string]string{},
+		},
+		{
+			name:   "a truncated vendor string is malformed",
+			block:  []byte{0xFF, 0, 0, 0, 't'},
+			frames: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags := &Tags{Frames: map[string]string{}, kind: tagKindVorbis}
+			if ok := parseVorbisComment(test.block, tags); ok != test.ok {
+				t.Fatalf("parseVorbisComment = %v, want %v", ok, test.ok)
+			}
+			if !test.ok {
+				return
+			}
+			if !reflect.DeepEqual(tags.Frames, test.frames) {
+				t.Errorf("Frames = %v, want %v", tags.Frames, test.frames)
+			}
+			if !reflect.DeepEqual(tags.Pictures, test.pictures) {
+				t.Errorf("Pictures = %+v, want %+v", tags.Pictures, test.pictures)
+			}
+		})
+	}
+}
+
+func TestVorbisCommentFields(t *testing.T) {
+	tags := &Tags{Frames: map[string]string{}, kind: tagKindVorbis}
+	parseVorbisComment(vorbisComment("TITLE=Song", "ALBUM ARTIST=Band", "ALBUM=Record", "GENRE=(17)", "DATE=2019-05-01"), tags)
+
+	checkTags(t, tags, "Song", "Band", "Record", "Rock", 2019)
+}
+
+func TestParsePictureBlock(t *testing.T) {
+	block := pictureBlock(PictureTypeOther, "image/jpeg", "", []byte{0xFF, 0xD8})
+
+	picture, ok := parsePictureBlock(block)
+	if !ok || picture.MIMEType != "image/jpeg" || picture.Type != PictureTypeOther || string(picture.Data) != "\xff\xd8" {
+		t.Errorf("parsePictureBlock = (%+v, %v), want the JPEG picture", picture, ok)
+	}
+	for _, size := range []int{0, 3, 10, len(block) - 1} {
+		if _, ok := parsePictureBlock(block[:size]); ok {
+			t.Errorf("parsePictureBlock of %d bytes succeeded", size)
+		}
+	}
+}
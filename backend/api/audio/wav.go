@@ -0,0 +1,171 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// Format codes of WAV files
+const (
+	waveFormatPCM        = 0x0001
+	waveFormatIEEEFloat  = 0x0003
+	waveFormatALaw       = 0x0006
+	waveFormatMuLaw      = 0x0007
+	waveFormatExtensible = 0xFFFE
+)
+
+// waveCodecs maps the supported format codes of WAV files to their codec names
+var waveCodecs = map[uint16]string{
+	waveFormatPCM:       "pcm",
+	waveFormatIEEEFloat: "pcm_float",
+	waveFormatALaw:      "alaw",
+	waveFormatMuLaw:     "mulaw",
+}
+
+// riffInfoFormat is the tag format of the INFO list of RIFF files
+const riffInfoFormat = "RIFF INFO"
+
+// ReadWAVInfo computes the duration and stream properties of a WAV file from its fmt and data chunks
+func ReadWAVInfo(r io.ReadSeeker) (*StreamInfo, error) {
+	var format []byte
+	var dataSize int64
+	err := walkRIFFChunks(r, func(id string, size int64, read func() ([]byte, error)) error {
+		switch id {
+		case "fmt ":
+			var err error
+			format, err = read()
+			return err
+		case "data":
+			dataSize = size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(format) < 16 || dataSize == 0 {
+		return nil, ErrInvalidStream
+	}
+
+	code := binary.LittleEndian.Uint16(format[0:2])
+	channels := int(binary.LittleEndian.Uint16(format[2:4]))
+	sampleRate := int(binary.LittleEndian.Uint32(format[4:8]))
+	byteRate := int64(binary.LittleEndian.Uint32(format[8:12]))
+	bitDepth := int(binary.LittleEndian.Uint16(format[14:16]))
+	if code == waveFormatExtensible && len(format) >= 26 {
+		code = binary.LittleEndian.Uint16(format[24:26]) // The sub-format GUID starts with the format code
+	}
+	codec, ok := waveCodecs[code]
+	if !ok || channels == 0 || sampleRate == 0 || byteRate == 0 {
+		return nil, ErrInvalidStream
+	}
+
+	return &StreamInfo{
+		Container:   ContainerWAV,
+		Codec:       codec,
+		BitDepth:    bitDepth,
+		Duration:    float64(dataSize) / float64(byteRate),
+		Bitrate:     int(byteRate * 8 / 1000),
+		SampleRate:  sampleRate,
+		Channels:    channels,
+		ChannelMode: channelMode(channels),
+	}, nil
+}
+
+// ReadWAVTags reads the tags of a WAV file, preferring an ID3v2 tag stored in an id3 chunk to the INFO list
+func ReadWAVTags(r io.ReadSeeker) (*Tags, error) {
+	var id3, info []byte
+	err := walkRIFFChunks(r, func(id string, size int64, read func() ([]byte, error)) error {
+		var err error
+		switch {
+		case strings.EqualFold(id, "id3 ") && id3 == nil:
+			id3, err = read()
+		case id == "LIST" && info == nil && size <= maxTagSize:
+			var list []byte
+			list, err = read()
+			if len(list) >= 4 && string(list[:4]) == "INFO" {
+				info = list[4:]
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if id3 != nil {
+		if tags, err := ReadID3(bytes.NewReader(id3)); err == nil {
+			return tags, nil
+		}
+	}
+	if info == nil {
+		return nil, ErrNoTags
+	}
+
+	// The INFO list holds a chunk of null-terminated text per field
+	tags := &Tags{Format: riffInfoFormat, Frames: map[string]string{}, kind: tagKindRIFF}
+	for len(info) >= 8 {
+		id := string(info[:4])
+		size := int(binary.LittleEndian.Uint32(info[4:8]))
+		if size > len(info)-8 {
+			break
+		}
+		if value := strings.TrimSpace(strings.TrimRight(string(info[8:8+size]), "\x00")); value != "" {
+			tags.Frames[id] = value
+		}
+		info = info[min(len(info), 8+size+size%2):] // Chunks are padded to an even size
+	}
+	if len(tags.Frames) == 0 {
+		return nil, ErrNoTags
+	}
+	return tags, nil
+}
+
+// walkRIFFChunks calls visit with the ID and size of every top-level chunk of a RIFF WAVE file, along with a
+// function reading its body. Chunks running past the end of the file, like the data chunk of a file written
+// while streaming, are cut to the file
+func walkRIFFChunks(r io.ReadSeeker, visit func(id string, size int64, read func() ([]byte, error)) error) error {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return ErrInvalidStream
+	}
+
+	for offset := int64(12); offset+8 <= end; {
+		chunk := make([]byte, 8)
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return ErrInvalidStream
+		}
+		id := string(chunk[:4])
+		size := min(int64(binary.LittleEndian.Uint32(chunk[4:8])), end-offset-8)
+
+		read := func() ([]byte, error) {
+			if size > maxTagSize {
+				return nil, ErrInvalidStream
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, ErrInvalidStream
+			}
+			return body, nil
+		}
+		if err := visit(id, size, read); err != nil {
+			return err
+		}
+
+		offset += 8 + size + size%2 // Chunks are padded to an even size
+	}
+
+	return nil
+}
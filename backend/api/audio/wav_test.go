@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// riffChunk builds a RIFF chunk, padded to an even size
+func riffChunk(id string, body []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(body)))
+	chunk = append(chunk, body...)
+	if len(body)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// wavFile builds a RIFF WAVE file of the chunks
+func wavFile(chunks ...[]byte) []byte {
+	body := join(chunks...)
+	return join([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(body))), []byte("WAVE"), body)
+}
+
+// fmtChunk builds the fmt chunk of a stream of interleaved samples in the format
+func fmtChunk(code, channels uint16, sampleRate uint32, bitDepth uint16) []byte {
+	blockAlign := channels * bitDepth / 8
+	format := binary.LittleEndian.AppendUint16(nil, code)
+	format = binary.LittleEndian.AppendUint16(format, channels)
+	format = binary.LittleEndian.AppendUint32(format, sampleRate)
+	format = binary.LittleEndian.AppendUint32(format, sampleRate*uint32(blockAlign))
+	format = binary.LittleEndian.AppendUint16(format, blockAlign)
+	format = binary.LittleEndian.AppendUint16(format, bitDepth)
+	if code == waveFormatExtensible {
+		format = append(format, 22, 0, byte(bitDepth), 0, 0x3F, 0, 0, 0) // Valid bits and the 5.1 channel mask
+		format = append(format, waveFormatPCM, 0)                        // Sub-format GUID starting with the format code
+		format = append(format, make([]byte, 14)...)
+	}
+	return riffChunk("fmt ", format)
+}
+
+func TestReadWAVInfo(t *testing.T) {
+	cd := fmtChunk(waveFormatPCM, 2, 44100, 16)
+
+	tests := []struct {
+		name string
+		data []byte
+		want StreamInfo
+	}{
+		{
+			name: "CD audio",
+			data: wavFile(cd, riffChunk("data", make([]byte, 1764000))),
+			want: StreamInfo{Container: ContainerWAV, Codec: "pcm", BitDepth: 16, Duration: 10, Bitrate: 1411, SampleRate: 44100, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+		{
+			name: "float mono after an odd-sized chunk",
+			data: wavFile(riffChunk("junk", []byte("odd")), fmtChunk(waveFormatIEEEFloat, 1, 48000, 32), riffChunk("data", make([]byte, 96000))),
+			want: StreamInfo{Container: ContainerWAV, Codec: "pcm_float", BitDepth: 32, Duration: 0.5, Bitrate: 1536, SampleRate: 48000, Channels: 1, ChannelMode: ChannelModeMono},
+		},
+		{
+			name: "extensible surround",
+			data: wavFile(fmtChunk(waveFormatExtensible, 6, 48000, 24), riffChunk("data", make([]byte, 864000))),
+			want: StreamInfo{Container: ContainerWAV, Codec: "pcm", BitDepth: 24, Duration: 1, Bitrate: 6912, SampleRate: 48000, Channels: 6, ChannelMode: ChannelModeMultichannel},
+		},
+		{
+			name: "data chunk of a streamed file cut to the file",
+			data: join(wavFile(cd), []byte("data\xff\xff\xff\xff"), make([]byte, 176400)),
+			want: StreamInfo{Container: ContainerWAV, Codec: "pcm", BitDepth: 16, Duration: 1, Bitrate: 1411, SampleRate: 44100, Channels: 2, ChannelMode: ChannelModeStereo},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := ReadStreamInfo(bytes.NewReader(test.data), ContainerWAV)
+			if err != nil {
+				t.Fatalf("ReadStreamInfo: %v", err)
+			}
+			checkStreamInfo(t, info, test.want)
+		})
+	}
+}
+
+func TestReadWAVInfoInvalid(t *testing.T) {
+	data := riffChunk("data", make([]byte, 100))
+
+	for name, file := range map[string][]byte{
+		"empty":            nil,
+		"another RIFF":     join([]byte("RIFF\x04\x00\x00\x00WEBP"), data),
+		"no fmt chunk":     wavFile(data),
+		"short fmt chunk":  wavFile(riffChunk("fmt ", make([]byte, 14)), data),
+		"no data chunk":    wavFile(fmtChunk(waveFormatPCM, 2, 44100, 16)),
+		"compressed audio": wavFile(fmtChunk(0x0055, 2, 44100, 16), data),
+		"no channels":      wavFile(fmtChunk(waveFormatPCM, 0, 44100, 16), data),
+	} {
+		if _, err := ReadStreamInfo(bytes.NewReader(file), ContainerWAV); err != ErrInvalidStream {
+			t.Errorf("ReadStreamInfo(%s) error = %v, want ErrInvalidStream", name, err)
+		}
+	}
+}
+
+func TestReadWAVTags(t *testing.T) {
+	format := fmtChunk(waveFormatPCM, 2, 44100, 16)
+	data := riffChunk("data", make([]byte, 1000))
+	info := riffChunk("LIST", join(
+		[]byte("INFO"),
+		riffChunk("INAM", []byte("Song\x00")),
+		riffChunk("IART", []byte("Band\x00")),
+		riffChunk("IPRD", []byte("Record\x00\x00")),
+		riffChunk("IGNR", []byte("Folk")),
+		riffChunk("ICRD", []byte("1987-06-05\x00")),
+		riffChunk("ICMT", []byte(" \x00")),
+	))
+	id3 := riffChunk("id3 ", id3v2Tag(3, 0, join(frame23("TIT2", text(0, "ID3 Song")), frame23("TPE1", text(0, "ID3 Band")))))
+
+	tags, err := ReadTags(bytes.NewReader(wavFile(format, info, data)), ContainerWAV)
+	if err != nil {
+		t.Fatalf("ReadTags: %v", err)
+	}
+	if tags.Format != riffInfoFormat {
+		t.Errorf("Format = %q, want %q", tags.Format, riffInfoFormat)
+	}
+	checkTags(t, tags, "Song", "Band", "Record", "Folk", 1987)
+	if _, ok := tags.Frames["ICMT"]; ok {
+		t.Error("blank ICMT chunk was read")
+	}
+
+	// An ID3v2 tag is preferred to the INFO list
+	tags, err = ReadTags(bytes.NewReader(wavFile(format, info, data, id3)), ContainerWAV)
+	if err != nil || tags.Title() != "ID3 Song" || tags.Artist() != "ID3 Band" {
+		t.Errorf("ReadTags with an id3 chunk = (%+v, %v), want the ID3 tags", tags, err)
+	}
+
+	for name, file := range map[string][]byte{
+		"no tags":    wavFile(format, data),
+		"empty INFO": wavFile(format, riffChunk("LIST", []byte("INFO")), data),
+		"other list": wavFile(format, riffChunk("LIST", join([]byte("adtl"), riffChunk("labl", []byte("cue\x00")))), data),
+	} {
+		if _, err := ReadTags(bytes.NewReader(file), ContainerWAV); err != ErrNoTags {
+			t.Errorf("ReadTags(%s) error = %v, want ErrNoTags", name, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package audio
+
+import (
+	"io"
+	"math"
+)
+
+// peakBlockSize is the number of frames summarized by each of the finest min/max pairs kept by Peaks
+const peakBlockSize = 64
+
+// Peaks records the minimum and maximum sample of every block of frames of a stream mixed down to mono,
+// from which waveforms of any lower resolution are derived
+type Peaks struct {
+	sampleRate int
+	channels   int
+	mins, maxs []float32 // Peaks of the completed blocks
+	blockMin   float32   // Peaks of the current block
+	blockMax   float32
+	blockSize  int     // Frames in the current block
+	frame      float32 // Sum of the samples of the current frame
+	channel    int     // Channel of the next sample within its frame
+}
+
+// Waveform is the peak data of a stream at one resolution, each pixel holding the minimum and maximum sample
+// over samplesPerPixel frames as 8-bit values, as in the waveform data format of audiowaveform
+type Waveform struct {
+	SampleRate      int    // Sample rate of the stream in Hz
+	SamplesPerPixel int    // Frames summarized by each pixel
+	Data            []int8 // Minimum and maximum of every pixel, in order
+}
+
+// Length returns the number of pixels of the waveform
+func (w *Waveform) Length() int {
+	return len(w.Data) / 2
+}
+
+// NewPeaks returns empty peaks of a stream with the given sample rate and number of channels
+func NewPeaks(sampleRate, channels int) *Peaks {
+	return &Peaks{sampleRate: sampleRate, channels: max(channels, 1)}
+}
+
+// ReadPeaks decodes a whole stream and records its peaks
+func ReadPeaks(decoder Decoder) (*Peaks, error) {
+	peaks := NewPeaks(decoder.SampleRate(), decoder.Channels())
+	samples := make([]float32, 4096*peaks.channels)
+	for {
+		n, err := decoder.Read(samples)
+		peaks.Add(samples[:n])
+		if err == io.EOF {
+			return peaks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Add records interleaved samples, which may end in the middle of a frame
+func (p *Peaks) Add(samples []float32) {
+	for _, sample := range samples {
+		p.frame += sample
+		p.channel++
+		if p.channel < p.channels {
+			continue
+		}
+
+		mono := p.frame / float32(p.channels)
+		p.frame, p.channel = 0, 0
+		if p.blockSize == 0 || mono < p.blockMin {
+			p.blockMin = mono
+		}
+		if p.blockSize == 0 || mono > p.blockMax {
+			p.blockMax = mono
+		}
+		p.blockSize++
+		if p.blockSize == peakBlockSize {
+			p.flush()
+		}
+	}
+}
+
+// Waveform returns the waveform with the fewest frames per pixel that still spans the recorded frames in at
+// most the given number of pixels. The last pixel may summarize fewer frames than the others
+func (p *Peaks) Waveform(pixels int) *Waveform {
+	p.flush() // Include the last, partial block
+
+	blocksPerPixel := max(1, (len(p.mins)+pixels-1)/max(pixels, 1))
+	waveform := &Waveform{
+		SampleRate:      p.sampleRate,
+		SamplesPerPixel: blocksPerPixel * peakBlockSize,
+		Data:            make([]int8, 0, 2*((len(p.mins)+blocksPerPixel-1)/blocksPerPixel)),
+	}
+	for start := 0; start < len(p.mins); start += blocksPerPixel {
+		end := min(start+blocksPerPixel, len(p.mins))
+		low, high := p.mins[start], p.maxs[start]
+		for i := start + 1; i < end; i++ {
+			low, high = min(low, p.mins[i]), max(high, p.maxs[i])
+		}
+		waveform.Data = append(waveform.Data, quantizeSample(low), quantizeSample(high))
+	}
+
+	return waveform
+}
+
+// flush completes the current block
+func (p *Peaks) flush() {
+	if p.blockSize == 0 {
+		return
+	}
+	p.mins = append(p.mins, p.blockMin)
+	p.maxs = append(p.maxs, p.blockMax)
+	p.blockSize = 0
+}
+
+// quantizeSample converts a sample in [-1, 1] to an 8-bit value
+func quantizeSample(sample float32) int8 {
+	return int8(max(-128, min(127, math.Floor(float64(sample)*128))))
+}
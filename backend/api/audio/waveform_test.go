@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// sineDecoder returns a decoder of a sine wave of the given frequency and peak amplitude, the same on every channel
+func sineDecoder(sampleRate, channels int, frequency, amplitude, seconds float64) Decoder {
+	frames := int(seconds * float64(sampleRate))
+	var buf bytes.Buffer
+	for i := 0; i < frames; i++ {
+		sample := float32(amplitude * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+		for c := 0; c < channels; c++ {
+			binary.Write(&buf, binary.LittleEndian, sample)
+		}
+	}
+	return NewPCMDecoder(&buf, sampleRate, channels)
+}
+
+func TestReadPeaks(t *testing.T) {
+	// One second of a full-scale stereo sine
+	peaks, err := ReadPeaks(sineDecoder(44100, 2, 441, 1, 1))
+	if err != nil {
+		t.Fatalf("ReadPeaks: %v", err)
+	}
+
+	waveform := peaks.Waveform(100)
+	if waveform.SampleRate != 44100 {
+		t.Errorf("sample rate = %d, want 44100", waveform.SampleRate)
+	}
+	if waveform.Length() > 100 || waveform.Length() < 50 {
+		t.Errorf("length = %d pixels, want at most 100 and at least half of them", waveform.Length())
+	}
+	if waveform.SamplesPerPixel%peakBlockSize != 0 {
+		t.Errorf("samples per pixel = %d, want a multiple of %d", waveform.SamplesPerPixel, peakBlockSize)
+	}
+
+	// Every pixel spans at least one period, so it reaches both peaks
+	for i := 0; i < waveform.Length()-1; i++ {
+		low, high := waveform.Data[2*i], waveform.Data[2*i+1]
+		if low > -126 || high < 126 {
+			t.Errorf("pixel %d = [%d, %d], want the full range", i, low, high)
+		}
+	}
+}
+
+func TestPeaksWaveformResolution(t *testing.T) {
+	peaks := NewPeaks(8000, 1)
+	peaks.Add(make([]float32, 10*peakBlockSize+1)) // Ten blocks and a partial one
+
+	tests := []struct {
+		pixels          int
+		length          int
+		samplesPerPixel int
+	}{
+		{pixels: 100, length: 11, samplesPerPixel: peakBlockSize},
+		{pixels: 11, length: 11, samplesPerPixel: peakBlockSize},
+		{pixels: 10, length: 6, samplesPerPixel: 2 * peakBlockSize},
+		{pixels: 1, length: 1, samplesPerPixel: 11 * peakBlockSize},
+	}
+	for _, test := range tests {
+		waveform := peaks.Waveform(test.pixels)
+		if waveform.Length() != test.length || waveform.SamplesPerPixel != test.samplesPerPixel {
+			t.Errorf("Waveform(%d) = %d pixels of %d samples, want %d of %d", test.pixels, waveform.Length(), waveform.SamplesPerPixel, test.length, test.samplesPerPixel)
+		}
+	}
+}
+
+func TestPeaksMixesChannelsDown(t *testing.T) {
+	// Opposite channels cancel out, and frames may be split across calls
+	peaks := NewPeaks(8000, 2)
+	peaks.Add([]float32{1, -1, 0.5})
+	peaks.Add([]float32{0.5})
+
+	waveform := peaks.Waveform(1)
+	if waveform.Length() != 1 || waveform.Data[0] != 0 || waveform.Data[1] != 64 {
+		t.Errorf("waveform = %v, want [0 64]", waveform.Data)
+	}
+}
+
+func TestQuantizeSample(t *testing.T) {
+	for sample, want := range map[float32]int8{-1: -128, 0: 0, 0.5: 64, 1: 127, 2: 127, -2: -128} {
+		if got := quantizeSample(sample); got != want {
+			t.Errorf("quantizeSample(%v) = %d, want %d", sample, got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"music-library-management/api/models"
+	"music-library-management/api/services"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyController handles HTTP requests for API keys
+type APIKeyController struct {
+	apiKeyService *services.APIKeyService // A reference to the API key service
+}
+
+// NewAPIKeyController creates a new APIKeyController
+func NewAPIKeyController(apiKeyService *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{
+		apiKeyService: apiKeyService, // Initialize the API key service
+	}
+}
+
+// CreateAPIKeyInput represents the input data for creating an API key
+type CreateAPIKeyInput struct {
+	Name          string   `json:"name" binding:"required"`         // The label of the key, required field
+	Scopes        []string `json:"scopes" binding:"required,min=1"` // The permissions granted to the key, required field
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0"` // The lifetime of the key in days, the configured default if omitted
+}
+
+// APIKeyOutput represents the output data for an API key
+type APIKeyOutput struct {
+	ID         string     `json:"id"`            // The ID of the key
+	Name       string     `json:"name"`          // The label of the key
+	Prefix     string     `json:"prefix"`        // The first characters of the key
+	Scopes     []string   `json:"scopes"`        // The permissions granted to the key
+	ExpiresAt  time.Time  `json:"expires_at"`    // When the key expires
+	LastUsedAt *time.Time `json:"last_used_at"`  // When the key was last used
+	CreatedAt  time.Time  `json:"created_at"`    // When the key was created
+	Key        string     `json:"key,omitempty"` // The plain-text key, only returned once on creation
+}
+
+// CreateAPIKey handles creating a new API key for the authenticated user
+func (kc *APIKeyController) CreateAPIKey(c *gin.Context) {
+	var input CreateAPIKeyInput
+
+	// Bind JSON input to the CreateAPIKeyInput struct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Call service to create the key
+	ttl := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	key, plainKey, err := kc.apiKeyService.CreateAPIKey(utils.GetUserID(c), input.Name, input.Scopes, ttl)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	// Prepare output data, including the plain-text key which cannot be retrieved again
+	output := newAPIKeyOutput(key)
+	output.Key = plainKey
+
+	// Respond with success message and created key
+	response := utils.NewSuccessResponse("API key created successfully", output)
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListAPIKeys handles listing the authenticated user's API keys
+func (kc *APIKeyController) ListAPIKeys(c *gin.Context) {
+	// Call service to list the keys
+	keys, err := kc.apiKeyService.ListAPIKeys(utils.GetUserID(c))
+	if err != nil {
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Prepare output data
+	output := make([]APIKeyOutput, len(keys))
+	for i, key := range keys {
+		output[i] = newAPIKeyOutput(key)
+	}
+
+	// Respond with success message and list of keys
+	response := utils.NewSuccessResponse("API keys retrieved successfully", output)
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAPIKey handles revoking one of the authenticated user's API keys
+func (kc *APIKeyController) RevokeAPIKey(c *gin.Context) {
+	keyId := c.Param("keyId") // Get the key ID from the URL parameter
+
+	// Call service to revoke the key
+	err := kc.apiKeyService.RevokeAPIKey(utils.GetUserID(c), keyId)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message
+	response := utils.NewSuccessResponse("API key revoked successfully", nil)
+	c.JSON(http.StatusOK, response)
+}
+
+// newAPIKeyOutput converts an API key model to its output representation
+func newAPIKeyOutput(key *models.APIKey) APIKeyOutput {
+	return APIKeyOutput{
+		ID:         key.ID.Hex(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
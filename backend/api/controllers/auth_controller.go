@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"music-library-management/api/models"
+	"music-library-management/api/services"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthController handles HTTP requests for authentication
+type AuthController struct {
+	authService *services.AuthService // A reference to the auth service
+	userService *services.UserService // A reference to the user service
+}
+
+// NewAuthController creates a new AuthController
+func NewAuthController(authService *services.AuthService, userService *services.UserService) *AuthController {
+	return &AuthController{
+		authService: authService, // Initialize the auth service
+		userService: userService, // Initialize the user service
+	}
+}
+
+// RegisterInput represents the input data for registering a new user
+type RegisterInput struct {
+	Email    string `json:"email" binding:"required,email"`    // The email of the user, required field
+	Password string `json:"password" binding:"required,min=8"` // The password of the user, at least 8 characters
+	Name     string `json:"name"`                              // The display name of the user
+}
+
+// LoginInput represents the input data for logging in
+type LoginInput struct {
+	Email    string `json:"email" binding:"required"`    // The email of the user, required field
+	Password string `json:"password" binding:"required"` // The password of the user, required field
+}
+
+// RefreshInput represents the input data for refreshing tokens
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"` // The refresh token issued at login, required field
+}
+
+// UserOutput represents the output data for a user
+type UserOutput struct {
+	ID    string `json:"id"`    // The ID of the user
+	Email string `json:"email"` // The email of the user
+	Name  string `json:"name"`  // The display name of the user
+	Role  string `json:"role"`  // The role of the user
+}
+
+// AuthOutput represents the output data for a successful authentication
+type AuthOutput struct {
+	User                  UserOutput `json:"user"`                     // The authenticated user
+	AccessToken           string     `json:"access_token"`             // The access token to send as a bearer token
+	AccessTokenExpiresAt  time.Time  `json:"access_token_expires_at"`  // When the access token expires
+	RefreshToken          string     `json:"refresh_token"`            // The refresh token used to obtain new tokens
+	RefreshTokenExpiresAt time.Time  `json:"refresh_token_expires_at"` // When the refresh token expires
+	TokenType             string     `json:"token_type"`               // Always "Bearer"
+}
+
+// Register handles creating a new user account
+func (ac *AuthController) Register(c *gin.Context) {
+	var input RegisterInput
+	var user models.User
+
+	// Bind JSON input to the RegisterInput struct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Copy input data to user model
+	user.Email = input.Email
+	user.Name = input.Name
+
+	// Call service to register the user
+	createdUser, tokens, err := ac.authService.Register(&user, input.Password)
+	if err != nil {
+		if err == errors.ErrEmailAlreadyExists {
+			errors.HandleError(c, http.StatusConflict, err) // Handle duplicate registrations
+			return
+		}
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message and issued tokens
+	response := utils.NewSuccessResponse("User registered successfully", newAuthOutput(createdUser, tokens))
+	c.JSON(http.StatusCreated, response)
+}
+
+// Login handles exchanging credentials for tokens
+func (ac *AuthController) Login(c *gin.Context) {
+	var input LoginInput
+
+	// Bind JSON input to the LoginInput struct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Call service to log the user in
+	user, tokens, err := ac.authService.Login(input.Email, input.Password)
+	if err != nil {
+		if err == errors.ErrInvalidCredentials {
+			errors.HandleError(c, http.StatusUnauthorized, err) // Handle wrong credentials
+			return
+		}
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message and issued tokens
+	response := utils.NewSuccessResponse("Logged in successfully", newAuthOutput(user, tokens))
+	c.JSON(http.StatusOK, response)
+}
+
+// Refresh handles exchanging a refresh token for a new token pair
+func (ac *AuthController) Refresh(c *gin.Context) {
+	var input RefreshInput
+
+	// Bind JSON input to the RefreshInput struct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Call service to refresh the tokens
+	user, tokens, err := ac.authService.Refresh(input.RefreshToken)
+	if err != nil {
+		if err == errors.ErrInvalidToken {
+			errors.HandleError(c, http.StatusUnauthorized, err) // Handle invalid refresh tokens
+			return
+		}
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message and issued tokens
+	response := utils.NewSuccessResponse("Tokens refreshed successfully", newAuthOutput(user, tokens))
+	c.JSON(http.StatusOK, response)
+}
+
+// Me handles retrieving the authenticated user
+func (ac *AuthController) Me(c *gin.Context) {
+	// Call service to get the authenticated user
+	user, err := ac.userService.GetUser(utils.GetUserID(c))
+	if err != nil {
+		errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the user is not found
+		return
+	}
+
+	// Respond with success message and the user
+	response := utils.NewSuccessResponse("User retrieved successfully", newUserOutput(user))
+	c.JSON(http.StatusOK, response)
+}
+
+// newUserOutput converts a user model to its output representation
+func newUserOutput(user *models.User) UserOutput {
+	return UserOutput{
+		ID:    user.ID.Hex(),
+		Email: user.Email,
+		Name:  user.Name,
+		Role:  user.EffectiveRole(),
+	}
+}
+
+// newAuthOutput combines a user and its issued tokens into an AuthOutput
+func newAuthOutput(user *models.User, tokens *services.TokenPair) AuthOutput {
+	return AuthOutput{
+		User:                  newUserOutput(user),
+		AccessToken:           tokens.AccessToken,
+		AccessTokenExpiresAt:  tokens.AccessTokenExpiresAt,
+		RefreshToken:          tokens.RefreshToken,
+		RefreshTokenExpiresAt: tokens.RefreshTokenExpiresAt,
+		TokenType:             "Bearer",
+	}
+}
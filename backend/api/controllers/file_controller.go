@@ -1,38 +1,87 @@
 package controllers
 
 import (
+	"music-library-management/api/models"
 	"music-library-management/api/services"
 	"music-library-management/api/utils"
 	"music-library-management/errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // FileController handles file-related HTTP requests
 type FileController struct {
-	fileService *services.FileService // A reference to the file service
+	fileService   *services.FileService   // A reference to the file service
+	fileGCService *services.FileGCService // A reference to the file garbage collection service
 }
 
 // NewFileController creates a new FileController
-func NewFileController(fileService *services.FileService) *FileController {
+func NewFileController(fileService *services.FileService, fileGCService *services.FileGCService) *FileController {
 	return &FileController{
-		fileService: fileService, // Initialize the file service
+		fileService:   fileService,   // Initialize the file service
+		fileGCService: fileGCService, // Initialize the file garbage collection service
 	}
 }
 
 // ListFilesInput represents the input data for listing files
 type ListFilesInput struct {
-	Page  int `form:"page"`  // The page number for pagination
-	Limit int `form:"limit"` // The number of items per page for pagination
+	Page      int    `form:"page"`       // The page number for pagination
+	Limit     int    `form:"limit"`      // The number of items per page for pagination
+	OwnerType string `form:"owner_type"` // Only list files owned by entities of this type
+	OwnerID   string `form:"owner_id"`   // Only list files owned by this entity
+	Role      string `form:"role"`       // Only list files playing this role for their owner
+	Type      string `form:"type"`       // Only list files of this MIME type or top-level type
+}
+
+// CollectGarbageInput represents the input data for collecting unused files
+type CollectGarbageInput struct {
+	DryRun bool `form:"dry_run"` // Only report what would be collected
 }
 
 // FileOutput represents the output data for a file
 type FileOutput struct {
-	ID       string `json:"id"`       // The ID of the file
-	Filename string `json:"filename"` // The filename of the file
-	Filepath string `json:"filepath"` // The filepath of the file
-	FileUrl  string `json:"file_url"` // The file url of the file
+	ID        string            `json:"id"`                   // The ID of the file
+	Filename  string            `json:"filename"`             // The filename of the file
+	Filepath  string            `json:"filepath"`             // The filepath of the file
+	FileUrl   string            `json:"file_url"`             // The file url of the file
+	Hash      string            `json:"hash,omitempty"`       // The SHA-256 hash of the file's content
+	Size      int64             `json:"size"`                 // The size of the file in bytes
+	MimeType  string            `json:"mime_type"`            // The MIME type of the file
+	Owners    []FileOwnerOutput `json:"owners"`               // The entities using the file
+	RefCount  int64             `json:"ref_count"`            // The number of owners sharing the file
+	ParentID  string            `json:"parent_id,omitempty"`  // The ID of the file this file was derived from
+	Width     int               `json:"width,omitempty"`      // The width of an image in pixels
+	Height    int               `json:"height,omitempty"`     // The height of an image in pixels
+	TagFormat string            `json:"tag_format,omitempty"` // The format of the tags read from the file
+	Tags      map[string]string `json:"tags,omitempty"`       // The raw text frames read from the file's tags
+}
+
+// FileOwnerOutput represents the output data for an entity using a file
+type FileOwnerOutput struct {
+	OwnerType string `json:"owner_type"` // The type of the owning entity
+	OwnerID   string `json:"owner_id"`   // The ID of the owning entity
+	Role      string `json:"role"`       // The role of the file for the owner
+}
+
+// GCEntryOutput represents the output data for a file or stored object handled by a garbage collection
+type GCEntryOutput struct {
+	FileID   string `json:"file_id,omitempty"` // The ID of the file record, omitted for stored content without a record
+	Filename string `json:"filename"`          // The name the content is stored under
+	Size     int64  `json:"size"`              // The size of the content in bytes
+}
+
+// GCReportOutput represents the output data for a garbage collection
+type GCReportOutput struct {
+	DryRun         bool            `json:"dry_run"`         // Whether nothing was changed
+	StartedAt      time.Time       `json:"started_at"`      // When the collection started
+	Cutoff         time.Time       `json:"cutoff"`          // Files unused since before this time were collected
+	Restored       []GCEntryOutput `json:"restored"`        // Soft-deleted files that are used again and were restored
+	SoftDeleted    []GCEntryOutput `json:"soft_deleted"`    // Unused files that were soft-deleted
+	Purged         []GCEntryOutput `json:"purged"`          // Soft-deleted files that were removed with their content
+	StrayObjects   []GCEntryOutput `json:"stray_objects"`   // Stored content without a file record that was removed
+	ReclaimedBytes int64           `json:"reclaimed_bytes"` // The bytes of content removed from the storage
 }
 
 // PaginatedFilesOutput represents the output data for paginated files
@@ -62,21 +111,21 @@ func (fc *FileController) ListFiles(c *gin.Context) {
 	}
 
 	// Retrieve files and total count from the file service
-	files, total, err := fc.fileService.ListFiles(input.Page, input.Limit)
+	files, total, err := fc.fileService.ListFiles(services.FileFilter{
+		OwnerType: input.OwnerType,
+		OwnerID:   input.OwnerID,
+		Role:      input.Role,
+		Type:      input.Type,
+	}, input.Page, input.Limit)
 	if err != nil {
-		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
 		return
 	}
 
 	// Prepare the output data
 	fileOutputs := make([]FileOutput, len(files))
-	for i, file := range files {
-		fileOutputs[i] = FileOutput{
-			ID:       file.ID.Hex(),
-			Filename: file.Filename,
-			Filepath: file.Filepath,
-			FileUrl:  file.FileUrl,
-		}
+	for i := range files {
+		fileOutputs[i] = newFileOutput(&files[i])
 	}
 
 	// Create a success response with the paginated files
@@ -90,3 +139,77 @@ func (fc *FileController) ListFiles(c *gin.Context) {
 	// Send the response as JSON
 	c.JSON(http.StatusOK, response)
 }
+
+// newFileOutput converts a file model to its output representation
+func newFileOutput(file *models.File) FileOutput {
+	owners := make([]FileOwnerOutput, len(file.Owners))
+	for i, owner := range file.Owners {
+		owners[i] = FileOwnerOutput{
+			OwnerType: owner.Type,
+			OwnerID:   owner.ID.Hex(),
+			Role:      owner.Role,
+		}
+	}
+
+	return FileOutput{
+		ID:        file.ID.Hex(),
+		Filename:  file.Filename,
+		Filepath:  file.Filepath,
+		FileUrl:   file.FileUrl,
+		Hash:      file.Hash,
+		Size:      file.Size,
+		MimeType:  file.MimeType,
+		Owners:    owners,
+		RefCount:  file.RefCount,
+		ParentID:  fileIDOutput(file.ParentID),
+		Width:     file.Width,
+		Height:    file.Height,
+		TagFormat: file.TagFormat,
+		Tags:      file.Tags,
+	}
+}
+
+// CollectGarbage handles collecting the files no live track uses and the stored content without a file record
+func (fc *FileController) CollectGarbage(c *gin.Context) {
+	var input CollectGarbageInput // Declare a variable to hold the input data
+
+	// Bind query parameters to input struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if binding fails
+		return
+	}
+
+	report, err := fc.fileGCService.CollectGarbage(input.DryRun) // Call service to collect the garbage
+	if err != nil {
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Prepare the output data
+	output := GCReportOutput{
+		DryRun:         report.DryRun,
+		StartedAt:      report.StartedAt,
+		Cutoff:         report.Cutoff,
+		Restored:       newGCEntryOutputs(report.Restored),
+		SoftDeleted:    newGCEntryOutputs(report.SoftDeleted),
+		Purged:         newGCEntryOutputs(report.Purged),
+		StrayObjects:   newGCEntryOutputs(report.StrayObjects),
+		ReclaimedBytes: report.ReclaimedBytes,
+	}
+
+	response := utils.NewSuccessResponse("Garbage collected successfully", output) // Create a success response
+	c.JSON(http.StatusOK, response)                                                // Send the response
+}
+
+// newGCEntryOutputs converts garbage collection entries to their output representation
+func newGCEntryOutputs(entries []services.GCEntry) []GCEntryOutput {
+	outputs := make([]GCEntryOutput, len(entries))
+	for i, entry := range entries {
+		outputs[i] = GCEntryOutput{
+			FileID:   entry.FileID,
+			Filename: entry.Filename,
+			Size:     entry.Size,
+		}
+	}
+	return outputs
+}
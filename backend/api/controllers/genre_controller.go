@@ -67,7 +67,7 @@ func (gc *GenreController) AddGenre(c *gin.Context) {
 	genre.Name = input.Name
 
 	// Call service to add the genre
-	createdGenre, err := gc.genreService.AddGenre(&genre)
+	createdGenre, err := gc.genreService.AddGenre(utils.GetUserID(c), &genre)
 	if err != nil {
 		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
 		return
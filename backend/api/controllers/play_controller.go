@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"music-library-management/api/services"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PlayController handles HTTP requests for the play history
+type PlayController struct {
+	playService  *services.PlayService  // A reference to the play service
+	trackService *services.TrackService // A reference to the track service
+}
+
+// NewPlayController creates a new PlayController
+func NewPlayController(playService *services.PlayService, trackService *services.TrackService) *PlayController {
+	return &PlayController{
+		playService:  playService,  // Initialize the play service
+		trackService: trackService, // Initialize the track service
+	}
+}
+
+// ListHistoryInput represents the input data for listing the play history
+type ListHistoryInput struct {
+	Page  int       `form:"page"`  // The page number for pagination
+	Limit int       `form:"limit"` // The number of items per page for pagination
+	From  time.Time `form:"from"`  // Only plays started at or after this time
+	To    time.Time `form:"to"`    // Only plays started before this time
+}
+
+// PlayOutput represents the output data for a play
+type PlayOutput struct {
+	ID               string       `json:"id"`                // The ID of the play
+	TrackID          string       `json:"track_id"`          // The ID of the played track
+	DeviceID         string       `json:"device_id"`         // The device the track was played on
+	StartedAt        time.Time    `json:"started_at"`        // When the track started playing
+	ListenedDuration float64      `json:"listened_duration"` // The seconds actually spent playing
+	Completed        bool         `json:"completed"`         // Whether playback reached the end of the track
+	Track            *TrackOutput `json:"track"`             // The played track, null if it has been deleted
+}
+
+// PaginatedPlaysOutput represents the output data for paginated plays
+type PaginatedPlaysOutput struct {
+	Page       int          `json:"page"`        // The current page number
+	Limit      int          `json:"limit"`       // The number of items per page
+	TotalCount int64        `json:"total_count"` // The total number of plays
+	Plays      []PlayOutput `json:"plays"`       // The list of plays
+}
+
+// ListHistory handles listing the play history of the authenticated user with pagination
+func (pc *PlayController) ListHistory(c *gin.Context) {
+	var input ListHistoryInput
+
+	// Bind query parameters to ListHistoryInput struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Set default pagination values if not provided
+	if input.Page == 0 {
+		input.Page = 1
+	}
+	if input.Limit == 0 {
+		input.Limit = 10
+	}
+
+	// Call service to list the plays
+	plays, totalCount, err := pc.playService.ListHistory(utils.GetUserID(c), input.From, input.To, input.Page, input.Limit)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	// Retrieve the played tracks in a single query
+	trackIDs := make([]primitive.ObjectID, len(plays))
+	for i, play := range plays {
+		trackIDs[i] = play.TrackID
+	}
+	tracks, err := pc.trackService.GetTracksByIDs(trackIDs)
+	if err != nil {
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Prepare output data
+	output := PaginatedPlaysOutput{
+		Page:       input.Page,
+		Limit:      input.Limit,
+		TotalCount: totalCount,
+		Plays:      make([]PlayOutput, len(plays)), // Initialize the plays slice with the appropriate length
+	}
+
+	// Populate the output plays
+	for i, play := range plays {
+		output.Plays[i] = PlayOutput{
+			ID:               play.ID.Hex(),
+			TrackID:          play.TrackID.Hex(),
+			DeviceID:         play.DeviceID,
+			StartedAt:        play.StartedAt,
+			ListenedDuration: play.ListenedDuration,
+			Completed:        play.Completed,
+		}
+		if track, ok := tracks[play.TrackID]; ok {
+			trackOutput := newTrackOutput(c, track)
+			output.Plays[i].Track = &trackOutput
+		}
+	}
+
+	// Respond with success message and list of plays
+	response := utils.NewSuccessResponse("History retrieved successfully", output)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"music-library-management/api/models"
+	"music-library-management/api/services"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaybackController handles HTTP requests for playback sessions
+type PlaybackController struct {
+	playbackService *services.PlaybackService // A reference to the playback service
+}
+
+// NewPlaybackController creates a new PlaybackController
+func NewPlaybackController(playbackService *services.PlaybackService) *PlaybackController {
+	return &PlaybackController{
+		playbackService: playbackService, // Initialize the playback service
+	}
+}
+
+// GetPlaybackInput represents the input data for reading the playback state
+type GetPlaybackInput struct {
+	DeviceID string `form:"device_id"` // The device to read; the most recently used device if empty
+}
+
+// PlaybackOutput represents the output data for a playback session
+type PlaybackOutput struct {
+	DeviceID   string       `json:"device_id"`   // The device the session is played on
+	State      string       `json:"state"`       // The playback state: playing, paused or stopped
+	Position   float64      `json:"position"`    // The current position in seconds
+	Queue      []string     `json:"queue"`       // The track IDs of the queue
+	QueueIndex int          `json:"queue_index"` // The index of the current track in the queue
+	StartedAt  *time.Time   `json:"started_at"`  // When the current track started playing
+	UpdatedAt  time.Time    `json:"updated_at"`  // When the state last changed
+	Track      *TrackOutput `json:"track"`       // The current track, null if it has been deleted
+}
+
+// GetPlayback handles reading the playback state of the user's device
+func (pc *PlaybackController) GetPlayback(c *gin.Context) {
+	var input GetPlaybackInput
+
+	// Bind query parameters to GetPlaybackInput struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Call service to get the playback session
+	session, track, err := pc.playbackService.GetPlayback(utils.GetUserID(c), input.DeviceID)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message and the playback state
+	response := utils.NewSuccessResponse("Playback retrieved successfully", newPlaybackOutput(c, session, track))
+	c.JSON(http.StatusOK, response)
+}
+
+// newPlaybackOutput converts a playback session and its track to the output representation
+func newPlaybackOutput(c *gin.Context, session *models.PlaybackSession, track *models.Track) PlaybackOutput {
+	output := PlaybackOutput{
+		DeviceID:   session.DeviceID,
+		State:      session.State,
+		Position:   session.CurrentPosition(time.Now(), 0),
+		Queue:      make([]string, len(session.Queue)), // Initialize the queue slice with the appropriate length
+		QueueIndex: session.QueueIndex,
+		StartedAt:  session.StartedAt,
+		UpdatedAt:  session.UpdatedAt,
+	}
+
+	// Populate the queue track IDs
+	for i, id := range session.Queue {
+		output.Queue[i] = id.Hex()
+	}
+
+	// Include the current track and cap the position at its duration
+	if track != nil {
+		trackOutput := newTrackOutput(c, track)
+		output.Track = &trackOutput
+		output.Position = session.CurrentPosition(time.Now(), track.Duration)
+	}
+
+	return output
+}
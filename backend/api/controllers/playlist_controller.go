@@ -24,12 +24,60 @@ func NewPlaylistController(playlistService *services.PlaylistService) *PlaylistC
 
 // AddPlaylistInput represents the input data for adding a new playlist
 type AddPlaylistInput struct {
-	Name string `json:"name" binding:"required"` // The name of the playlist, required field
+	Name            string `json:"name" binding:"required"`                                      // The name of the playlist, required field
+	Visibility      string `json:"visibility" binding:"omitempty,oneof=private unlisted public"` // The visibility of the playlist, private by default
+	AllowDuplicates bool   `json:"allow_duplicates"`                                             // Whether a track may be added more than once
+
+	Rules *SmartPlaylistRules `json:"rules"` // The rules defining the tracks of a smart playlist, a playlist of added tracks if omitted
 }
 
 // UpdatePlaylistInput represents the input data for updating a playlist
 type UpdatePlaylistInput struct {
-	Name string `json:"name" binding:"required"` // The updated name of the playlist, required field
+	Name            string `json:"name"`                                                         // The updated name of the playlist
+	Visibility      string `json:"visibility" binding:"omitempty,oneof=private unlisted public"` // The updated visibility of the playlist
+	AllowDuplicates *bool  `json:"allow_duplicates"`                                             // Whether a track may be added more than once, unchanged if omitted
+
+	Rules *SmartPlaylistRules `json:"rules"` // The updated rules of a smart playlist, unchanged if omitted
+}
+
+// SmartPlaylistRules represents the rules defining the tracks of a smart playlist in requests and responses
+type SmartPlaylistRules struct {
+	Match     *SmartPlaylistRule `json:"match"`                                         // The rule tree the tracks must match, every track if omitted
+	SortBy    string             `json:"sort_by"`                                       // The track field the tracks are sorted by, the date they were added if omitted
+	SortOrder string             `json:"sort_order" binding:"omitempty,oneof=asc desc"` // The sort order, ascending if omitted
+	Limit     int                `json:"limit" binding:"min=0"`                         // The maximum number of tracks, unlimited if omitted
+}
+
+// SmartPlaylistRule represents a node of the rule tree of a smart playlist: a group of rules or a condition
+type SmartPlaylistRule struct {
+	Operator string              `json:"operator"`        // "and" or "or" for groups, the comparison for conditions
+	Rules    []SmartPlaylistRule `json:"rules,omitempty"` // The sub-rules of a group
+	Field    string              `json:"field,omitempty"` // The track field a condition compares
+	Value    interface{}         `json:"value,omitempty"` // The value a condition compares the field with
+}
+
+// PlaylistPositionInput represents the input data for adding or removing a track at a position of a playlist
+type PlaylistPositionInput struct {
+	Position *int `form:"position" binding:"omitempty,min=0"` // The zero-based position in the playlist; the end, or every occurrence, if omitted
+}
+
+// MoveTrackInput represents the input data for moving a track within a playlist
+type MoveTrackInput struct {
+	From    *int   `json:"from" binding:"required,min=0"` // The zero-based position of the track to move
+	To      *int   `json:"to" binding:"required,min=0"`   // The zero-based position to move the track to
+	Version *int64 `json:"version"`                       // The version of the playlist the move is based on, not checked if omitted
+}
+
+// ReorderPlaylistInput represents the input data for reordering the tracks of a playlist
+type ReorderPlaylistInput struct {
+	Tracks  []string `json:"tracks" binding:"required"`  // The IDs of the playlist's tracks in their new order
+	Version *int64   `json:"version" binding:"required"` // The version of the playlist the order is based on
+}
+
+// GetPlaylistInput represents the input data for viewing a playlist and a page of its tracks
+type GetPlaylistInput struct {
+	Page  int `form:"page" binding:"min=0"`  // The page number of the tracks
+	Limit int `form:"limit" binding:"min=0"` // The number of tracks per page
 }
 
 // ListPlaylistsInput represents the input data for listing playlists
@@ -40,8 +88,39 @@ type ListPlaylistsInput struct {
 
 // PlaylistOutput represents the output data for a playlist
 type PlaylistOutput struct {
-	ID   string `json:"id"`   // The ID of the playlist
-	Name string `json:"name"` // The name of the playlist
+	ID              string `json:"id"`               // The ID of the playlist
+	Name            string `json:"name"`             // The name of the playlist
+	OwnerID         string `json:"owner_id"`         // The ID of the user who owns the playlist
+	Visibility      string `json:"visibility"`       // The visibility of the playlist
+	AllowDuplicates bool   `json:"allow_duplicates"` // Whether a track may be added more than once
+	Version         int64  `json:"version"`          // The version of the playlist's tracks, incremented on every change
+
+	Rules *SmartPlaylistRules `json:"rules,omitempty"` // The rules defining the tracks of a smart playlist
+}
+
+// PlaylistTracksOutput represents the output data for the order of the tracks of a playlist
+type PlaylistTracksOutput struct {
+	ID       string   `json:"id"`        // The ID of the playlist
+	Version  int64    `json:"version"`   // The version of the playlist's tracks after the change
+	TrackIDs []string `json:"track_ids"` // The IDs of the playlist's tracks in order
+}
+
+// PlaylistDetailOutput represents the output data for a playlist along with a page of its tracks
+type PlaylistDetailOutput struct {
+	PlaylistOutput
+	TrackCount    int                   `json:"track_count"`    // The number of tracks in the playlist, including unavailable ones
+	TotalDuration int                   `json:"total_duration"` // The duration in seconds of the available tracks of the playlist
+	Page          int                   `json:"page"`           // The current page number
+	Limit         int                   `json:"limit"`          // The number of tracks per page
+	Tracks        []PlaylistTrackOutput `json:"tracks"`         // The tracks of the page in playlist order
+}
+
+// PlaylistTrackOutput represents the output data for a track at a position of a playlist
+type PlaylistTrackOutput struct {
+	Position  int          `json:"position"`  // The zero-based position of the track in the playlist
+	TrackID   string       `json:"track_id"`  // The ID of the track
+	Available bool         `json:"available"` // Whether the track still exists and has not been deleted
+	Track     *TrackOutput `json:"track"`     // The track, null if it no longer exists
 }
 
 // PaginatedPlaylistsOutput represents the output data for paginated playlists
@@ -65,19 +144,21 @@ func (pc *PlaylistController) AddPlaylist(c *gin.Context) {
 
 	// Copy input data to playlist model
 	playlist.Name = input.Name
+	playlist.Visibility = input.Visibility
+	playlist.AllowDuplicates = input.AllowDuplicates
+	if input.Rules != nil {
+		playlist.Rules = toPlaylistRules(input.Rules)
+	}
 
 	// Call service to add the playlist
-	createdPlaylist, err := pc.playlistService.AddPlaylist(&playlist)
+	createdPlaylist, err := pc.playlistService.AddPlaylist(utils.GetUserID(c), &playlist)
 	if err != nil {
-		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
 		return
 	}
 
 	// Prepare output data
-	output := PlaylistOutput{
-		ID:   createdPlaylist.ID.Hex(),
-		Name: createdPlaylist.Name,
-	}
+	output := newPlaylistOutput(createdPlaylist)
 
 	// Respond with success message and created playlist
 	response := utils.NewSuccessResponse("Playlist added successfully", output)
@@ -87,18 +168,50 @@ func (pc *PlaylistController) AddPlaylist(c *gin.Context) {
 // GetPlaylist handles retrieving a playlist by ID
 func (pc *PlaylistController) GetPlaylist(c *gin.Context) {
 	playlistId := c.Param("playlistId") // Get the playlist ID from the URL parameter
+	var input GetPlaylistInput
+
+	// Bind query parameters to GetPlaylistInput struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
 
-	// Call service to get the playlist
-	playlist, err := pc.playlistService.GetPlaylist(playlistId)
+	// Set default pagination values if not provided
+	if input.Page == 0 {
+		input.Page = 1
+	}
+	if input.Limit == 0 {
+		input.Limit = 10
+	}
+
+	// Call service to get the playlist with a page of its tracks
+	detail, err := pc.playlistService.GetPlaylistDetail(utils.GetUserID(c), playlistId, input.Page, input.Limit)
 	if err != nil {
-		errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the playlist is not found
+		errors.HandleError(c, errors.StatusCode(err, http.StatusNotFound), err) // Handle errors if the playlist is not found
 		return
 	}
 
 	// Prepare output data
-	output := PlaylistOutput{
-		ID:   playlist.ID.Hex(),
-		Name: playlist.Name,
+	output := PlaylistDetailOutput{
+		PlaylistOutput: newPlaylistOutput(&detail.Playlist),
+		TrackCount:     detail.TrackCount,
+		TotalDuration:  detail.TotalDuration,
+		Page:           input.Page,
+		Limit:          input.Limit,
+		Tracks:         make([]PlaylistTrackOutput, len(detail.Entries)), // Initialize the tracks slice with the appropriate length
+	}
+
+	// Populate the output tracks, keeping unavailable ones in place
+	for i, entry := range detail.Entries {
+		output.Tracks[i] = PlaylistTrackOutput{
+			Position:  entry.Position,
+			TrackID:   entry.TrackID.Hex(),
+			Available: entry.IsAvailable(),
+		}
+		if entry.Track != nil {
+			track := newTrackOutput(c, entry.Track)
+			output.Tracks[i].Track = &track
+		}
 	}
 
 	// Respond with success message and retrieved playlist
@@ -111,9 +224,9 @@ func (pc *PlaylistController) UpdatePlaylist(c *gin.Context) {
 	playlistId := c.Param("playlistId") // Get the playlist ID from the URL parameter
 
 	// Check if the playlist exists
-	_, err := pc.playlistService.GetPlaylist(playlistId)
+	existingPlaylist, err := pc.playlistService.GetPlaylist(utils.GetUserID(c), playlistId)
 	if err != nil {
-		errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the playlist is not found
+		errors.HandleError(c, errors.StatusCode(err, http.StatusNotFound), err) // Handle errors if the playlist is not found
 		return
 	}
 
@@ -128,19 +241,24 @@ func (pc *PlaylistController) UpdatePlaylist(c *gin.Context) {
 
 	// Copy input data to updatedPlaylist model
 	updatedPlaylist.Name = input.Name
+	updatedPlaylist.Visibility = input.Visibility
+	updatedPlaylist.AllowDuplicates = existingPlaylist.AllowDuplicates
+	if input.AllowDuplicates != nil {
+		updatedPlaylist.AllowDuplicates = *input.AllowDuplicates
+	}
+	if input.Rules != nil {
+		updatedPlaylist.Rules = toPlaylistRules(input.Rules)
+	}
 
 	// Call service to update the playlist
-	playlist, err := pc.playlistService.UpdatePlaylist(playlistId, &updatedPlaylist)
+	playlist, err := pc.playlistService.UpdatePlaylist(utils.GetUserID(c), playlistId, &updatedPlaylist)
 	if err != nil {
-		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
 		return
 	}
 
 	// Prepare output data
-	output := PlaylistOutput{
-		ID:   playlist.ID.Hex(),
-		Name: playlist.Name,
-	}
+	output := newPlaylistOutput(playlist)
 
 	// Respond with success message and updated playlist
 	response := utils.NewSuccessResponse("Playlist updated successfully", output)
@@ -152,9 +270,9 @@ func (pc *PlaylistController) DeletePlaylist(c *gin.Context) {
 	playlistId := c.Param("playlistId") // Get the playlist ID from the URL parameter
 
 	// Call service to delete the playlist
-	err := pc.playlistService.DeletePlaylist(playlistId)
+	err := pc.playlistService.DeletePlaylist(utils.GetUserID(c), playlistId)
 	if err != nil {
-		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
 		return
 	}
 
@@ -182,9 +300,9 @@ func (pc *PlaylistController) ListPlaylists(c *gin.Context) {
 	}
 
 	// Call service to list playlists
-	playlists, totalCount, err := pc.playlistService.ListPlaylists(input.Page, input.Limit)
+	playlists, totalCount, err := pc.playlistService.ListPlaylists(utils.GetUserID(c), input.Page, input.Limit)
 	if err != nil {
-		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
 		return
 	}
 
@@ -198,10 +316,7 @@ func (pc *PlaylistController) ListPlaylists(c *gin.Context) {
 
 	// Populate the output playlists
 	for i, playlist := range playlists {
-		output.Playlists[i] = PlaylistOutput{
-			ID:   playlist.ID.Hex(),
-			Name: playlist.Name,
-		}
+		output.Playlists[i] = newPlaylistOutput(playlist)
 	}
 
 	// Respond with success message and list of playlists
@@ -209,36 +324,176 @@ func (pc *PlaylistController) ListPlaylists(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// AddTrackToPlaylist handles adding a track to a playlist
+// AddTrackToPlaylist handles inserting a track into a playlist at a position, or appending it
 func (pc *PlaylistController) AddTrackToPlaylist(c *gin.Context) {
 	playlistId := c.Param("playlistId") // Get the playlist ID from the URL parameter
 	trackId := c.Param("trackId")       // Get the track ID from the URL parameter
+	var input PlaylistPositionInput     // Declare a variable to hold the input data
+
+	// Bind query parameters to input struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
 
 	// Call service to add the track to the playlist
-	err := pc.playlistService.AddTrackToPlaylist(playlistId, trackId)
+	playlist, err := pc.playlistService.AddTrackToPlaylist(utils.GetUserID(c), playlistId, trackId, input.Position)
 	if err != nil {
-		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
 		return
 	}
 
-	// Respond with success message
-	response := utils.NewSuccessResponse("Track added to playlist successfully", nil)
+	// Respond with success message and the new order of the tracks
+	response := utils.NewSuccessResponse("Track added to playlist successfully", newPlaylistTracksOutput(playlist))
 	c.JSON(http.StatusOK, response)
 }
 
-// RemoveTrackFromPlaylist handles removing a track from a playlist
+// RemoveTrackFromPlaylist handles removing a track from a playlist, at a position or everywhere it occurs
 func (pc *PlaylistController) RemoveTrackFromPlaylist(c *gin.Context) {
 	playlistId := c.Param("playlistId") // Get the playlist ID from the URL parameter
 	trackId := c.Param("trackId")       // Get the track ID from the URL parameter
+	var input PlaylistPositionInput     // Declare a variable to hold the input data
+
+	// Bind query parameters to input struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
 
 	// Call service to remove the track from the playlist
-	err := pc.playlistService.RemoveTrackFromPlaylist(playlistId, trackId)
+	playlist, err := pc.playlistService.RemoveTrackFromPlaylist(utils.GetUserID(c), playlistId, trackId, input.Position)
 	if err != nil {
-		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
 		return
 	}
 
-	// Respond with success message
-	response := utils.NewSuccessResponse("Track removed from playlist successfully", nil)
+	// Respond with success message and the new order of the tracks
+	response := utils.NewSuccessResponse("Track removed from playlist successfully", newPlaylistTracksOutput(playlist))
+	c.JSON(http.StatusOK, response)
+}
+
+// MoveTrackInPlaylist handles moving a track from one position of a playlist to another
+func (pc *PlaylistController) MoveTrackInPlaylist(c *gin.Context) {
+	playlistId := c.Param("playlistId") // Get the playlist ID from the URL parameter
+	var input MoveTrackInput
+
+	// Bind JSON input to the MoveTrackInput struct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Call service to move the track
+	playlist, err := pc.playlistService.MoveTrackInPlaylist(utils.GetUserID(c), playlistId, *input.From, *input.To, input.Version)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message and the new order of the tracks
+	response := utils.NewSuccessResponse("Track moved successfully", newPlaylistTracksOutput(playlist))
 	c.JSON(http.StatusOK, response)
 }
+
+// ReorderPlaylistTracks handles replacing the order of the tracks of a playlist
+func (pc *PlaylistController) ReorderPlaylistTracks(c *gin.Context) {
+	playlistId := c.Param("playlistId") // Get the playlist ID from the URL parameter
+	var input ReorderPlaylistInput
+
+	// Bind JSON input to the ReorderPlaylistInput struct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Call service to reorder the tracks
+	playlist, err := pc.playlistService.ReorderPlaylistTracks(utils.GetUserID(c), playlistId, input.Tracks, *input.Version)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message and the new order of the tracks
+	response := utils.NewSuccessResponse("Playlist reordered successfully", newPlaylistTracksOutput(playlist))
+	c.JSON(http.StatusOK, response)
+}
+
+// newPlaylistOutput converts a playlist model to its output representation
+func newPlaylistOutput(playlist *models.Playlist) PlaylistOutput {
+	output := PlaylistOutput{
+		ID:              playlist.ID.Hex(),
+		Name:            playlist.Name,
+		Visibility:      playlist.Visibility,
+		AllowDuplicates: playlist.AllowDuplicates,
+		Version:         playlist.Version,
+	}
+	if !playlist.OwnerID.IsZero() {
+		output.OwnerID = playlist.OwnerID.Hex() // Legacy playlists have no owner
+	}
+	if output.Visibility == "" {
+		output.Visibility = models.PlaylistVisibilityPublic // Legacy playlists have no setting and stay public
+	}
+	if playlist.IsSmart() {
+		output.Rules = newSmartPlaylistRules(playlist.Rules)
+	}
+	return output
+}
+
+// newSmartPlaylistRules converts the rules of a smart playlist to their representation in responses
+func newSmartPlaylistRules(rules *models.PlaylistRules) *SmartPlaylistRules {
+	output := &SmartPlaylistRules{
+		SortBy:    rules.SortBy,
+		SortOrder: rules.SortOrder,
+		Limit:     rules.Limit,
+	}
+	if rules.Match != nil {
+		match := newSmartPlaylistRule(rules.Match)
+		output.Match = &match
+	}
+	return output
+}
+
+// newSmartPlaylistRule converts a node of the rule tree of a smart playlist to its representation in responses
+func newSmartPlaylistRule(rule *models.PlaylistRule) SmartPlaylistRule {
+	output := SmartPlaylistRule{Operator: rule.Operator, Field: rule.Field, Value: rule.Value}
+	for i := range rule.Rules {
+		output.Rules = append(output.Rules, newSmartPlaylistRule(&rule.Rules[i]))
+	}
+	return output
+}
+
+// toPlaylistRules converts the rules of a smart playlist given in a request to their model
+func toPlaylistRules(r *SmartPlaylistRules) *models.PlaylistRules {
+	rules := &models.PlaylistRules{
+		SortBy:    r.SortBy,
+		SortOrder: r.SortOrder,
+		Limit:     r.Limit,
+	}
+	if r.Match != nil {
+		match := toPlaylistRule(r.Match)
+		rules.Match = &match
+	}
+	return rules
+}
+
+// toPlaylistRule converts a node of the rule tree of a smart playlist given in a request to its model
+func toPlaylistRule(r *SmartPlaylistRule) models.PlaylistRule {
+	rule := models.PlaylistRule{Operator: r.Operator, Field: r.Field, Value: r.Value}
+	for i := range r.Rules {
+		rule.Rules = append(rule.Rules, toPlaylistRule(&r.Rules[i]))
+	}
+	return rule
+}
+
+// newPlaylistTracksOutput converts the tracks of a playlist model to the output representation of their order
+func newPlaylistTracksOutput(playlist *models.Playlist) PlaylistTracksOutput {
+	output := PlaylistTracksOutput{
+		ID:       playlist.ID.Hex(),
+		Version:  playlist.Version,
+		TrackIDs: make([]string, len(playlist.Tracks)),
+	}
+	for i, trackID := range playlist.Tracks {
+		output.TrackIDs[i] = trackID.Hex()
+	}
+	return output
+}
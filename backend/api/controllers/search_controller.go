@@ -87,17 +87,7 @@ func (sc *SearchController) SearchTracks(c *gin.Context) {
 
 	// Populate the output tracks
 	for i, track := range tracks {
-		output.Tracks[i] = TrackOutput{
-			ID:            track.ID.Hex(),
-			Title:         track.Title,
-			Artist:        track.Artist,
-			Album:         track.Album,
-			Genre:         track.Genre,
-			ReleaseYear:   track.ReleaseYear,
-			Duration:      track.Duration,
-			CoverImageUrl: track.CoverImageUrl,
-			Mp3FileUrl:    track.Mp3FileUrl,
-		}
+		output.Tracks[i] = newTrackOutput(c, track)
 	}
 
 	// Create a success response
@@ -125,7 +115,7 @@ func (sc *SearchController) SearchPlaylists(c *gin.Context) {
 	}
 
 	// Call the search service to search playlists
-	playlists, total, err := sc.searchService.SearchPlaylists(input.Query, input.Page, input.Limit)
+	playlists, total, err := sc.searchService.SearchPlaylists(utils.GetUserID(c), input.Query, input.Page, input.Limit)
 	if err != nil {
 		// Handle any errors that occur during the search
 		errors.HandleError(c, http.StatusInternalServerError, err)
@@ -142,10 +132,7 @@ func (sc *SearchController) SearchPlaylists(c *gin.Context) {
 
 	// Populate the output playlists
 	for i, playlist := range playlists {
-		output.Playlists[i] = PlaylistOutput{
-			ID:   playlist.ID.Hex(),
-			Name: playlist.Name,
-		}
+		output.Playlists[i] = newPlaylistOutput(playlist)
 	}
 
 	// Create a success response
@@ -1,50 +1,73 @@
 package controllers
 
 import (
+	"bytes"
+	"log"
+	"music-library-management/api/audio"
+	"music-library-management/api/hls"
 	"music-library-management/api/models"
 	"music-library-management/api/services"
 	"music-library-management/api/utils"
 	"music-library-management/errors"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // TrackController handles HTTP requests for tracks
 type TrackController struct {
-	trackService *services.TrackService // A reference to the track service
-	fileService  *services.FileService  // A reference to the file service
+	trackService     *services.TrackService     // A reference to the track service
+	fileService      *services.FileService      // A reference to the file service
+	uploadService    *services.UploadService    // A reference to the upload service
+	thumbnailService *services.ThumbnailService // A reference to the thumbnail service
+	playbackService  *services.PlaybackService  // A reference to the playback service
+	transcodeService *services.TranscodeService // A reference to the transcode service
+	authService      *services.AuthService      // A reference to the auth service
 }
 
 // NewTrackController creates a new TrackController
-func NewTrackController(trackService *services.TrackService, fileService *services.FileService) *TrackController {
+func NewTrackController(trackService *services.TrackService, fileService *services.FileService, uploadService *services.UploadService, thumbnailService *services.ThumbnailService, playbackService *services.PlaybackService, transcodeService *services.TranscodeService, authService *services.AuthService) *TrackController {
 	return &TrackController{
-		trackService: trackService, // Initialize the track service
-		fileService:  fileService,  // Initialize the file service
+		trackService:     trackService,     // Initialize the track service
+		fileService:      fileService,      // Initialize the file service
+		uploadService:    uploadService,    // Initialize the upload service
+		thumbnailService: thumbnailService, // Initialize the thumbnail service
+		playbackService:  playbackService,  // Initialize the playback service
+		transcodeService: transcodeService, // Initialize the transcode service
+		authService:      authService,      // Initialize the auth service
 	}
 }
 
-// AddTrackInput represents the input data for adding a new track
+// AddTrackInput represents the input data for adding a new track; fields left empty are prefilled from the audio file's tags
 type AddTrackInput struct {
-	Title       string `form:"title" binding:"required"`    // The title of the track, required field
-	Artist      string `form:"artist" binding:"required"`   // The artist of the track, required field
-	Album       string `form:"album"`                       // The album of the track
-	Genre       string `form:"genre"`                       // The genre of the track
-	ReleaseYear int    `form:"release_year"`                // The release year of the track
-	Duration    int    `form:"duration" binding:"required"` // The duration of the track, required field
+	Title         string `form:"title"`           // The title of the track, required unless tagged
+	Artist        string `form:"artist"`          // The artist of the track, required unless tagged
+	Album         string `form:"album"`           // The album of the track
+	Genre         string `form:"genre"`           // The genre of the track
+	ReleaseYear   int    `form:"release_year"`    // The release year of the track
+	Duration      int    `form:"duration"`        // The expected duration of the track, checked against the audio file
+	AudioUploadID string `form:"audio_upload_id"` // A finished resumable upload to use instead of the audio_file field
+	Mp3UploadID   string `form:"mp3_upload_id"`   // The former name of audio_upload_id, still accepted
+	CoverUploadID string `form:"cover_upload_id"` // A finished resumable upload to use instead of the cover_image field
 }
 
 // UpdateTrackInput represents the input data for updating a track
 type UpdateTrackInput struct {
-	Title       string `form:"title"`        // The updated title of the track
-	Artist      string `form:"artist"`       // The updated artist of the track
-	Album       string `form:"album"`        // The updated album of the track
-	Genre       string `form:"genre"`        // The updated genre of the track
-	ReleaseYear int    `form:"release_year"` // The updated release year of the track
-	Duration    int    `form:"duration"`     // The updated duration of the track
+	Title         string `form:"title"`           // The updated title of the track
+	Artist        string `form:"artist"`          // The updated artist of the track
+	Album         string `form:"album"`           // The updated album of the track
+	Genre         string `form:"genre"`           // The updated genre of the track
+	ReleaseYear   int    `form:"release_year"`    // The updated release year of the track
+	Duration      int    `form:"duration"`        // The expected duration of the track, checked against the audio file
+	AudioUploadID string `form:"audio_upload_id"` // A finished resumable upload to use instead of the audio_file field
+	Mp3UploadID   string `form:"mp3_upload_id"`   // The former name of audio_upload_id, still accepted
+	CoverUploadID string `form:"cover_upload_id"` // A finished resumable upload to use instead of the cover_image field
 }
 
 // ListTracksInput represents the input data for listing tracks
@@ -53,22 +76,136 @@ type ListTracksInput struct {
 	Limit int `form:"limit"` // The number of items per page for pagination
 }
 
-// PlayPauseTrackInput represents the input data for playing or pausing a track
+// GetCoverImageInput represents the input data for serving a cover image
+type GetCoverImageInput struct {
+	Size int `form:"size"` // The size in pixels of the square the image must fit in, the original image if zero
+}
+
+// StreamTrackInput represents the input data for streaming a track
+type StreamTrackInput struct {
+	Quality string `form:"quality"` // The name of a rendition, or "original"; picked from the Accept header if empty
+}
+
+// GetWaveformInput represents the input data for serving the waveform of a track
+type GetWaveformInput struct {
+	Resolution int `form:"resolution" binding:"min=0"` // The maximum number of pixels of the waveform, 1024 if zero
+}
+
+// AnalyzeLoudnessInput represents the input data for re-analyzing the loudness of tracks
+type AnalyzeLoudnessInput struct {
+	All bool `form:"all"` // Re-analyze every track rather than only the tracks never analyzed
+}
+
+// PlayPauseTrackInput represents the input data for a playback action on a track
 type PlayPauseTrackInput struct {
-	Action string `json:"action" binding:"required,oneof=play pause"` // The action to perform, required field with validation
+	Action   string   `json:"action" binding:"required,oneof=play pause seek stop next previous"` // The action to perform, required field with validation
+	DeviceID string   `json:"device_id"`                                                          // The device the action is performed on
+	Position *float64 `json:"position" binding:"omitempty,min=0"`                                 // The position in seconds to play or seek to
+	Queue    []string `json:"queue"`                                                              // The track IDs to play through with next and previous
 }
 
 // TrackOutput represents the output data for a track
 type TrackOutput struct {
-	ID            string `json:"id"`              // The ID of the track
-	Title         string `json:"title"`           // The title of the track
-	Artist        string `json:"artist"`          // The artist of the track
-	Album         string `json:"album"`           // The album of the track
-	Genre         string `json:"genre"`           // The genre of the track
-	ReleaseYear   int    `json:"release_year"`    // The release year of the track
-	Duration      int    `json:"duration"`        // The duration of the track
-	CoverImageUrl string `json:"cover_image_url"` // The URL of the cover image
-	Mp3FileUrl    string `json:"mp3_file_url"`    // The URL of the MP3 file
+	ID            string            `json:"id"`              // The ID of the track
+	Title         string            `json:"title"`           // The title of the track
+	Artist        string            `json:"artist"`          // The artist of the track
+	Album         string            `json:"album"`           // The album of the track
+	Genre         string            `json:"genre"`           // The genre of the track
+	ReleaseYear   int               `json:"release_year"`    // The release year of the track
+	Duration      int               `json:"duration"`        // The duration of the track
+	CoverImageUrl string            `json:"cover_image_url"` // The URL of the cover image endpoint
+	AudioFileUrl  string            `json:"audio_file_url"`  // The URL of the stream endpoint
+	Mp3FileUrl    string            `json:"mp3_file_url"`    // The URL of the stream endpoint, kept for clients predating audio_file_url
+	HLSUrl        string            `json:"hls_url"`         // The URL of the HLS master playlist, available once the audio is packaged
+	WaveformUrl   string            `json:"waveform_url"`    // The URL of the waveform peaks, available once they are computed
+	CoverFileID   string            `json:"cover_file_id"`   // The ID of the cover image file, empty for tracks added before files were linked
+	Thumbnails    []ThumbnailOutput `json:"thumbnails"`      // The thumbnails of the cover image
+	AudioFileID   string            `json:"audio_file_id"`   // The ID of the audio file, empty for tracks added before files were linked
+	Audio         AudioOutput       `json:"audio"`           // The format and stream properties of the audio file
+	Renditions    []RenditionOutput `json:"renditions"`      // The versions of the audio transcoded for streaming
+	Loudness      *LoudnessOutput   `json:"loudness"`        // The loudness of the audio and its ReplayGain gains, null until it is analyzed
+	PlayCount     int64             `json:"play_count"`      // The number of times the track was played
+	LastPlayedAt  *time.Time        `json:"last_played_at"`  // When the track was last played
+}
+
+// AudioOutput represents the output data for the format and stream properties of a track's audio file
+type AudioOutput struct {
+	MimeType    string `json:"mime_type"`    // The MIME type of the audio file
+	Container   string `json:"container"`    // The container of the stream, e.g. "mpeg", "mp4" or "ogg"
+	Codec       string `json:"codec"`        // The codec of the stream, e.g. "mp3", "aac" or "flac"
+	BitDepth    int    `json:"bit_depth"`    // The bits per sample of lossless and PCM streams, 0 for lossy ones
+	Bitrate     int    `json:"bitrate"`      // The average bitrate in kbit/s
+	SampleRate  int    `json:"sample_rate"`  // The sample rate in Hz
+	Channels    int    `json:"channels"`     // The number of channels
+	ChannelMode string `json:"channel_mode"` // The channel mode of the stream
+}
+
+// RenditionOutput represents the output data for a version of a track's audio transcoded for streaming
+type RenditionOutput struct {
+	Name     string `json:"name"`      // The name of the rendition, e.g. "mp3-128k"
+	Codec    string `json:"codec"`     // The codec of the rendition
+	Bitrate  int    `json:"bitrate"`   // The target bitrate in kbit/s
+	MimeType string `json:"mime_type"` // The MIME type of the rendition file
+	FileID   string `json:"file_id"`   // The ID of the rendition file
+	Url      string `json:"url"`       // The URL of the stream endpoint serving the rendition
+}
+
+// TranscodeJobOutput represents the output data for a transcoding job
+type TranscodeJobOutput struct {
+	ID         string     `json:"id"`          // The ID of the job
+	Kind       string     `json:"kind"`        // "rendition", "hls" or "waveform"
+	Rendition  string     `json:"rendition"`   // The name of the rendition transcoded, "hls" or "waveform" for the other kinds
+	Codec      string     `json:"codec"`       // The codec transcoded to
+	Bitrate    int        `json:"bitrate"`     // The target bitrate in kbit/s
+	Status     string     `json:"status"`      // One of "queued", "running", "done", "failed" and "canceled"
+	Attempts   int        `json:"attempts"`    // The number of times a worker started the job
+	Error      string     `json:"error"`       // Why the last attempt failed
+	FileID     string     `json:"file_id"`     // The ID of the rendition file once the job is done
+	CreatedAt  time.Time  `json:"created_at"`  // When the job was queued
+	StartedAt  *time.Time `json:"started_at"`  // When the last attempt started
+	FinishedAt *time.Time `json:"finished_at"` // When the job finished, failed or was canceled
+}
+
+// LoudnessOutput represents the output data for the loudness of a track's audio
+type LoudnessOutput struct {
+	Integrated float64   `json:"integrated"`  // The integrated loudness in LUFS
+	TruePeak   float64   `json:"true_peak"`   // The true peak in dBTP
+	TrackGain  float64   `json:"track_gain"`  // The ReplayGain 2.0 gain in dB bringing the track to -18 LUFS
+	AlbumGain  *float64  `json:"album_gain"`  // The ReplayGain 2.0 gain in dB bringing the album as a whole to -18 LUFS, null without an album
+	AlbumPeak  *float64  `json:"album_peak"`  // The highest true peak of the album's tracks in dBTP
+	AnalyzedAt time.Time `json:"analyzed_at"` // When the audio was analyzed
+}
+
+// AnalyzeLoudnessOutput represents the output data for a re-analysis of the loudness of tracks
+type AnalyzeLoudnessOutput struct {
+	Queued int `json:"queued"` // The number of tracks queued for analysis
+}
+
+// WaveformOutput represents the output data for the peaks of a track's audio, in the JSON format of audiowaveform
+type WaveformOutput struct {
+	Version         int    `json:"version"`           // The version of the format, always 2
+	Channels        int    `json:"channels"`          // The number of channels, always 1 as the audio is mixed down to mono
+	SampleRate      int    `json:"sample_rate"`       // The sample rate of the audio in Hz
+	SamplesPerPixel int    `json:"samples_per_pixel"` // The number of frames summarized by each pixel
+	Bits            int    `json:"bits"`              // The resolution of the data, always 8
+	Length          int    `json:"length"`            // The number of pixels
+	Data            []int8 `json:"data"`              // The minimum and maximum of every pixel
+}
+
+// ThumbnailOutput represents the output data for a thumbnail of a cover image
+type ThumbnailOutput struct {
+	Size   int    `json:"size"`    // The size in pixels of the square the thumbnail fits in
+	FileID string `json:"file_id"` // The ID of the thumbnail file
+	Url    string `json:"url"`     // The URL of the cover endpoint serving the thumbnail
+}
+
+// MediaTokenOutput represents the output data for a media token and the media URLs carrying it
+type MediaTokenOutput struct {
+	MediaToken    string    `json:"media_token"`     // The token to pass as the media_token query parameter
+	ExpiresAt     time.Time `json:"expires_at"`      // When the token expires
+	AudioFileUrl  string    `json:"audio_file_url"`  // The URL of the stream endpoint, carrying the token
+	CoverImageUrl string    `json:"cover_image_url"` // The URL of the cover image endpoint, carrying the token
+	HLSUrl        string    `json:"hls_url"`         // The URL of the HLS master playlist, carrying the token
 }
 
 // PaginatedTracksOutput represents the output data for paginated tracks
@@ -84,9 +221,9 @@ func (tc *TrackController) AddTrack(c *gin.Context) {
 	var input AddTrackInput // Declare a variable to hold the input data
 	var track models.Track  // Declare a variable to hold the track model
 
-	// Parse multipart form data
+	// Parse multipart form data; files uploaded beforehand may be referenced from a URL-encoded form
 	err := c.Request.ParseMultipartForm(10 << 20) // Limit to 10 MB
-	if err != nil {
+	if err != nil && err != http.ErrNotMultipart {
 		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if form data is invalid
 		return
 	}
@@ -105,77 +242,105 @@ func (tc *TrackController) AddTrack(c *gin.Context) {
 	track.ReleaseYear = input.ReleaseYear
 	track.Duration = input.Duration
 
-	// Handle cover image upload
-	coverImage, err := c.FormFile("cover_image") // Get the cover image file
+	// Assign the track's ID up front so the uploaded files can be linked to it
+	track.ID = primitive.NewObjectID()
+	coverOwner := models.FileOwner{Type: models.FileOwnerTrack, ID: track.ID, Role: models.FileRoleCover}
+	audioOwner := models.FileOwner{Type: models.FileOwnerTrack, ID: track.ID, Role: models.FileRoleAudio}
+
+	// Handle cover image upload; without one the cover is taken from the audio file below
+	coverRecord, coverUpload, err := tc.saveTrackFile(c, "cover_image", input.CoverUploadID, coverOwner) // Store the cover image file or claim its upload
 	if err != nil {
-		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if the cover image is not provided
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if saving fails
 		return
 	}
-	coverImageExt := filepath.Ext(coverImage.Filename)                      // Get the file extension
-	coverImageName := uuid.New().String() + coverImageExt                   // Generate a unique file name
-	coverImagePath := tc.fileService.GetUploadPath() + "/" + coverImageName // Generate a unique file path
-	if err := c.SaveUploadedFile(coverImage, coverImagePath); err != nil {  // Save the cover image file
-		errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer) // Handle errors if saving fails
+	if coverRecord != nil {
+		track.CoverImageUrl = coverRecord.FileUrl // Set the cover image URL
+		track.CoverFileID = coverRecord.ID        // Link the cover image file
+	}
+
+	// Handle audio file upload
+	audioRecord, audioUpload, err := tc.saveAudioFile(c, input.AudioUploadID, input.Mp3UploadID, audioOwner) // Store the audio file or claim its upload
+	if err == nil && audioRecord == nil {
+		err = errors.ErrInvalidInput // The audio file is required
+	}
+	if err != nil {
+		tc.fileService.RemoveFile(coverRecord, coverOwner)                                 // Remove the uploaded cover image file
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the audio file is missing or saving fails
 		return
 	}
-	track.CoverImageUrl = utils.GetScheme(c) + "://" + c.Request.Host + "/" + coverImagePath // Set the cover image URL
+	track.Audio = newAudioAsset(audioRecord) // Link the audio file
 
-	// Save cover image metadata
-	_, err = tc.fileService.SaveFileMetadata(c, coverImageName)
+	// Read the audio file's tags and prefill the fields the form left empty
+	tags, err := tc.fileService.ExtractTags(audioRecord)
 	if err != nil {
-		os.Remove(coverImagePath) // Remove the uploaded cover image file
-		errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer)
+		tc.fileService.RemoveFile(coverRecord, coverOwner)         // Remove the uploaded cover image file
+		tc.fileService.RemoveFile(audioRecord, audioOwner)         // Remove the uploaded audio file
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors if the tags cannot be read
 		return
 	}
+	if tags != nil {
+		prefillTrack(&track, tags)
+	}
 
-	// Handle MP3 file upload
-	mp3File, err := c.FormFile("mp3_file") // Get the MP3 file
+	// Compute the duration and stream properties of the audio file and check the supplied duration
+	err = tc.applyStreamInfo(&track, audioRecord, input.Duration)
 	if err != nil {
-		os.Remove(coverImagePath)                                            // Remove the uploaded cover image file
-		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if the MP3 file is not provided
+		tc.fileService.RemoveFile(coverRecord, coverOwner)                                 // Remove the uploaded cover image file
+		tc.fileService.RemoveFile(audioRecord, audioOwner)                                 // Remove the uploaded audio file
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle invalid files and duration mismatches
 		return
 	}
-	mp3FileExt := filepath.Ext(mp3File.Filename)                      // Get the file extension
-	mp3FileName := uuid.New().String() + mp3FileExt                   // Generate a unique file name
-	mp3FilePath := tc.fileService.GetUploadPath() + "/" + mp3FileName // Generate a unique file path
-	if err := c.SaveUploadedFile(mp3File, mp3FilePath); err != nil {  // Save the MP3 file
-		os.Remove(coverImagePath)                                                       // Remove the uploaded cover image file
-		errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer) // Handle errors if saving fails
+
+	// Title and artist are required from the form or the tags
+	if track.Title == "" || track.Artist == "" {
+		tc.fileService.RemoveFile(coverRecord, coverOwner)                   // Remove the uploaded cover image file
+		tc.fileService.RemoveFile(audioRecord, audioOwner)                   // Remove the uploaded audio file
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if required fields are missing
 		return
 	}
-	track.Mp3FileUrl = utils.GetScheme(c) + "://" + c.Request.Host + "/" + mp3FilePath // Set the MP3 file URL
 
-	// Save MP3 file metadata
-	_, err = tc.fileService.SaveFileMetadata(c, mp3FileName)
+	// Without an uploaded cover image, use the art embedded in the audio file or a generated placeholder
+	if coverRecord == nil {
+		coverRecord, err = tc.saveCoverArt(c, &track, tags, coverOwner)
+		if err != nil {
+			tc.fileService.RemoveFile(audioRecord, audioOwner)         // Remove the uploaded audio file
+			errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors if saving fails
+			return
+		}
+	}
+
+	// Scale the cover image down for list views
+	track.Thumbnails, err = tc.thumbnailService.GenerateThumbnails(c, coverRecord)
 	if err != nil {
-		os.Remove(coverImagePath) // Remove the uploaded cover image file
-		os.Remove(mp3FilePath)    // Remove the uploaded MP3 file
-		errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer)
+		tc.fileService.RemoveFile(coverRecord, coverOwner)                                 // Remove the uploaded cover image file and its thumbnails
+		tc.fileService.RemoveFile(audioRecord, audioOwner)                                 // Remove the uploaded audio file
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the cover image cannot be scaled
 		return
 	}
 
 	// Add track to the database
-	createdTrack, err := tc.trackService.AddTrack(&track) // Call service to add the track
+	createdTrack, err := tc.trackService.AddTrack(utils.GetUserID(c), &track) // Call service to add the track
 	if err != nil {
-		os.Remove(coverImagePath)                                  // Remove the uploaded cover image file
-		os.Remove(mp3FilePath)                                     // Remove the uploaded MP3 file
+		tc.fileService.RemoveFile(coverRecord, coverOwner)         // Remove the uploaded cover image file
+		tc.fileService.RemoveFile(audioRecord, audioOwner)         // Remove the uploaded audio file
 		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
 		return
 	}
 
-	// Prepare output data
-	output := TrackOutput{
-		ID:            createdTrack.ID.Hex(),
-		Title:         createdTrack.Title,
-		Artist:        createdTrack.Artist,
-		Album:         createdTrack.Album,
-		Genre:         createdTrack.Genre,
-		ReleaseYear:   createdTrack.ReleaseYear,
-		Duration:      createdTrack.Duration,
-		CoverImageUrl: createdTrack.CoverImageUrl,
-		Mp3FileUrl:    createdTrack.Mp3FileUrl,
+	// The track now owns the files of the resumable uploads it was created from
+	tc.uploadService.ReleaseUpload(coverUpload)
+	tc.uploadService.ReleaseUpload(audioUpload)
+
+	// Transcode the audio file into the renditions streamed to clients in the background
+	if err := tc.transcodeService.EnqueueTranscodes(createdTrack); err != nil {
+		// The track is saved and streamed in its original format meanwhile, so a failure is not reported to a
+		// client that would retry and add the track twice
+		log.Printf("Error queuing the transcoding jobs of track %s: %v", createdTrack.ID.Hex(), err)
 	}
 
+	// Prepare output data
+	output := newTrackOutput(c, createdTrack)
+
 	response := utils.NewSuccessResponse("Track added successfully", output) // Create a success response
 	c.JSON(http.StatusCreated, response)                                     // Send the response
 }
@@ -191,17 +356,7 @@ func (tc *TrackController) GetTrack(c *gin.Context) {
 	}
 
 	// Prepare output data
-	output := TrackOutput{
-		ID:            track.ID.Hex(),
-		Title:         track.Title,
-		Artist:        track.Artist,
-		Album:         track.Album,
-		Genre:         track.Genre,
-		ReleaseYear:   track.ReleaseYear,
-		Duration:      track.Duration,
-		CoverImageUrl: track.CoverImageUrl,
-		Mp3FileUrl:    track.Mp3FileUrl,
-	}
+	output := newTrackOutput(c, track)
 
 	response := utils.NewSuccessResponse("Track retrieved successfully", output) // Create a success response
 	c.JSON(http.StatusOK, response)                                              // Send the response
@@ -212,22 +367,23 @@ func (tc *TrackController) UpdateTrack(c *gin.Context) {
 	trackId := c.Param("trackId") // Get the track ID from the URL parameter
 
 	// Check if the track exists
-	_, err := tc.trackService.GetTrack(trackId) // Call service to check if the track exists
+	existingTrack, err := tc.trackService.GetTrack(trackId) // Call service to check if the track exists
 	if err != nil {
 		errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the track is not found
 		return
 	}
 
-	// Parse multipart form data
+	// Parse multipart form data; files uploaded beforehand may be referenced from a URL-encoded form
 	err = c.Request.ParseMultipartForm(10 << 20) // Limit to 10 MB
-	if err != nil {
+	if err != nil && err != http.ErrNotMultipart {
 		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if form data is invalid
 		return
 	}
 
-	var input UpdateTrackInput             // Declare a variable to hold the input data
-	var updatedTrack models.Track          // Declare a variable to hold the updated track model
-	var coverImagePath, mp3FilePath string // Variables to hold the file paths
+	var input UpdateTrackInput    // Declare a variable to hold the input data
+	var updatedTrack models.Track // Declare a variable to hold the updated track model
+	coverOwner := models.FileOwner{Type: models.FileOwnerTrack, ID: existingTrack.ID, Role: models.FileRoleCover}
+	audioOwner := models.FileOwner{Type: models.FileOwnerTrack, ID: existingTrack.ID, Role: models.FileRoleAudio}
 
 	// Bind form data to input struct
 	if err := c.ShouldBind(&input); err != nil {
@@ -244,45 +400,68 @@ func (tc *TrackController) UpdateTrack(c *gin.Context) {
 	updatedTrack.Duration = input.Duration
 
 	// Handle cover image upload
-	coverImage, err := c.FormFile("cover_image") // Get the cover image file
-	if err == nil {
-		coverImageExt := filepath.Ext(coverImage.Filename)                     // Get the file extension
-		coverImageName := uuid.New().String() + coverImageExt                  // Generate a unique file name
-		coverImagePath = tc.fileService.GetUploadPath() + "/" + coverImageName // Generate a unique file path
-		if err := c.SaveUploadedFile(coverImage, coverImagePath); err != nil { // Save the cover image file
-			errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer) // Handle errors if saving fails
-			return
+	coverRecord, coverUpload, err := tc.saveTrackFile(c, "cover_image", input.CoverUploadID, coverOwner) // Store the cover image file or claim its upload
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if saving fails
+		return
+	}
+	if coverRecord != nil {
+		updatedTrack.CoverImageUrl = coverRecord.FileUrl // Set the cover image URL
+		updatedTrack.CoverFileID = coverRecord.ID        // Link the cover image file
+		if coverRecord.ID == existingTrack.CoverFileID {
+			coverRecord = nil // The track already uses identical content, so there is nothing to undo or release
 		}
-		updatedTrack.CoverImageUrl = utils.GetScheme(c) + "://" + c.Request.Host + "/" + coverImagePath // Set the cover image URL
+	}
+
+	// Handle audio file upload
+	audioRecord, audioUpload, err := tc.saveAudioFile(c, input.AudioUploadID, input.Mp3UploadID, audioOwner) // Store the audio file or claim its upload
+	if err != nil {
+		tc.fileService.RemoveFile(coverRecord, coverOwner)                                 // Remove the uploaded cover image file
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if saving fails
+		return
+	}
+	if audioRecord != nil && audioRecord.ID == existingTrack.Audio.FileID {
+		audioRecord = nil // The track already uses identical content, which was analysed when it was uploaded
+	}
+
+	// Analyse a new audio file, otherwise check a supplied duration against the current one
+	if audioRecord != nil {
+		updatedTrack.Audio = newAudioAsset(audioRecord) // Link the audio file
 
-		// Save cover image metadata
-		_, err = tc.fileService.SaveFileMetadata(c, coverImageName)
+		// Record the new audio file's tags; the track keeps its current values for fields left empty
+		_, err = tc.fileService.ExtractTags(audioRecord)
 		if err != nil {
-			os.Remove(coverImagePath) // Remove the uploaded cover image file
-			errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer)
+			tc.fileService.RemoveFile(coverRecord, coverOwner)         // Remove the uploaded cover image file
+			tc.fileService.RemoveFile(audioRecord, audioOwner)         // Remove the uploaded audio file
+			errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors if the tags cannot be read
 			return
 		}
-	}
 
-	// Handle MP3 file upload
-	mp3File, err := c.FormFile("mp3_file") // Get the MP3 file
-	if err == nil {
-		mp3FileExt := filepath.Ext(mp3File.Filename)                     // Get the file extension
-		mp3FileName := uuid.New().String() + mp3FileExt                  // Generate a unique file name
-		mp3FilePath = tc.fileService.GetUploadPath() + "/" + mp3FileName // Generate a unique file path
-		if err := c.SaveUploadedFile(mp3File, mp3FilePath); err != nil { // Save the MP3 file
-			os.Remove(coverImagePath)                                                       // Remove the uploaded cover image file
-			errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer) // Handle errors if saving fails
+		// Compute the duration and stream properties of the new audio file and check the supplied duration
+		err = tc.applyStreamInfo(&updatedTrack, audioRecord, input.Duration)
+		if err != nil {
+			tc.fileService.RemoveFile(coverRecord, coverOwner)                                 // Remove the uploaded cover image file
+			tc.fileService.RemoveFile(audioRecord, audioOwner)                                 // Remove the uploaded audio file
+			errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle invalid files and duration mismatches
+			return
+		}
+	} else if input.Duration != 0 && existingTrack.Audio.Codec != "" {
+		// The duration of an analysed audio file is known, so a supplied duration is only checked against it
+		updatedTrack.Duration, err = tc.trackService.CheckDuration(existingTrack.Duration, input.Duration)
+		if err != nil {
+			tc.fileService.RemoveFile(coverRecord, coverOwner)                                 // Remove the uploaded cover image file
+			errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle duration mismatches
 			return
 		}
-		updatedTrack.Mp3FileUrl = utils.GetScheme(c) + "://" + c.Request.Host + "/" + mp3FilePath // Set the MP3 file URL
+	}
 
-		// Save MP3 file metadata
-		_, err = tc.fileService.SaveFileMetadata(c, mp3FileName)
+	// Scale a new cover image down for list views
+	if coverRecord != nil {
+		updatedTrack.Thumbnails, err = tc.thumbnailService.GenerateThumbnails(c, coverRecord)
 		if err != nil {
-			os.Remove(coverImagePath) // Remove the uploaded cover image file
-			os.Remove(mp3FilePath)    // Remove the uploaded MP3 file
-			errors.HandleError(c, http.StatusInternalServerError, errors.ErrInternalServer)
+			tc.fileService.RemoveFile(coverRecord, coverOwner)                                 // Remove the uploaded cover image file and its thumbnails
+			tc.fileService.RemoveFile(audioRecord, audioOwner)                                 // Remove the uploaded audio file
+			errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the cover image cannot be scaled
 			return
 		}
 	}
@@ -290,24 +469,32 @@ func (tc *TrackController) UpdateTrack(c *gin.Context) {
 	// Update track in the database
 	track, err := tc.trackService.UpdateTrack(trackId, &updatedTrack) // Call service to update the track
 	if err != nil {
-		os.Remove(coverImagePath)                                  // Remove the uploaded cover image file
-		os.Remove(mp3FilePath)                                     // Remove the uploaded MP3 file
+		tc.fileService.RemoveFile(coverRecord, coverOwner)         // Remove the uploaded cover image file
+		tc.fileService.RemoveFile(audioRecord, audioOwner)         // Remove the uploaded audio file
 		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
 		return
 	}
 
-	// Prepare output data
-	output := TrackOutput{
-		ID:            track.ID.Hex(),
-		Title:         track.Title,
-		Artist:        track.Artist,
-		Album:         track.Album,
-		Genre:         track.Genre,
-		ReleaseYear:   track.ReleaseYear,
-		Duration:      track.Duration,
-		CoverImageUrl: track.CoverImageUrl,
-		Mp3FileUrl:    track.Mp3FileUrl,
+	// Release the files the track no longer uses and the resumable uploads it was updated from
+	if coverRecord != nil {
+		tc.releaseFile(existingTrack.CoverFileID, existingTrack.CoverImageUrl, coverOwner)
 	}
+	if audioRecord != nil {
+		tc.releaseFile(existingTrack.Audio.FileID, existingTrack.Audio.FileUrl, audioOwner)
+	}
+	tc.uploadService.ReleaseUpload(coverUpload)
+	tc.uploadService.ReleaseUpload(audioUpload)
+
+	// Replace the renditions of the previous audio file with ones of the new audio file
+	if audioRecord != nil {
+		if err := tc.transcodeService.EnqueueTranscodes(track); err != nil {
+			// The update is saved and the track streamed in its original format meanwhile
+			log.Printf("Error queuing the transcoding jobs of track %s: %v", track.ID.Hex(), err)
+		}
+	}
+
+	// Prepare output data
+	output := newTrackOutput(c, track)
 
 	response := utils.NewSuccessResponse("Track updated successfully", output) // Create a success response
 	c.JSON(http.StatusOK, response)                                            // Send the response
@@ -329,6 +516,21 @@ func (tc *TrackController) DeleteTrack(c *gin.Context) {
 
 // ListTracks handles listing all tracks with pagination
 func (tc *TrackController) ListTracks(c *gin.Context) {
+	tc.listTracks(c, tc.trackService.ListTracks) // List tracks, newest first
+}
+
+// ListMostPlayedTracks handles listing the most played tracks with pagination
+func (tc *TrackController) ListMostPlayedTracks(c *gin.Context) {
+	tc.listTracks(c, tc.trackService.ListMostPlayedTracks) // List played tracks by play count
+}
+
+// ListRecentlyPlayedTracks handles listing the most recently played tracks with pagination
+func (tc *TrackController) ListRecentlyPlayedTracks(c *gin.Context) {
+	tc.listTracks(c, tc.trackService.ListRecentlyPlayedTracks) // List played tracks by last play
+}
+
+// listTracks binds the pagination input, lists a page of tracks with the given service method and writes the response
+func (tc *TrackController) listTracks(c *gin.Context, list func(page, limit int) ([]*models.Track, int64, error)) {
 	var input ListTracksInput // Declare a variable to hold the input data
 
 	// Bind query parameters to input struct
@@ -345,7 +547,7 @@ func (tc *TrackController) ListTracks(c *gin.Context) {
 	}
 
 	// Call service to list tracks
-	tracks, totalCount, err := tc.trackService.ListTracks(input.Page, input.Limit) // Call service to list tracks
+	tracks, totalCount, err := list(input.Page, input.Limit) // Call service to list tracks
 	if err != nil {
 		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
 		return
@@ -361,24 +563,14 @@ func (tc *TrackController) ListTracks(c *gin.Context) {
 
 	// Populate the output tracks
 	for i, track := range tracks {
-		output.Tracks[i] = TrackOutput{
-			ID:            track.ID.Hex(),
-			Title:         track.Title,
-			Artist:        track.Artist,
-			Album:         track.Album,
-			Genre:         track.Genre,
-			ReleaseYear:   track.ReleaseYear,
-			Duration:      track.Duration,
-			CoverImageUrl: track.CoverImageUrl,
-			Mp3FileUrl:    track.Mp3FileUrl,
-		}
+		output.Tracks[i] = newTrackOutput(c, track)
 	}
 
 	response := utils.NewSuccessResponse("Tracks retrieved successfully", output) // Create a success response
 	c.JSON(http.StatusOK, response)                                               // Send the response
 }
 
-// PlayPauseTrack handles playing or pausing a track
+// PlayPauseTrack handles playback actions on a track for the user's device
 func (tc *TrackController) PlayPauseTrack(c *gin.Context) {
 	trackId := c.Param("trackId") // Get the track ID from the URL parameter
 	var input PlayPauseTrackInput // Declare a variable to hold the input data
@@ -389,12 +581,731 @@ func (tc *TrackController) PlayPauseTrack(c *gin.Context) {
 		return
 	}
 
-	err := tc.trackService.PlayPauseTrack(trackId, input.Action) // Call service to play or pause the track
+	// Call service to perform the playback action
+	session, track, err := tc.playbackService.PlayPauseTrack(utils.GetUserID(c), trackId, services.PlaybackCommand{
+		DeviceID: input.DeviceID,
+		Action:   input.Action,
+		Position: input.Position,
+		Queue:    input.Queue,
+	})
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	response := utils.NewSuccessResponse("Track action performed successfully", newPlaybackOutput(c, session, track)) // Create a success response
+	c.JSON(http.StatusOK, response)                                                                                   // Send the response
+}
+
+// ListTrackFiles handles listing the files owned by a track
+func (tc *TrackController) ListTrackFiles(c *gin.Context) {
+	trackId := c.Param("trackId") // Get the track ID from the URL parameter
+
+	_, err := tc.trackService.GetTrack(trackId) // Call service to check if the track exists
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusNotFound), err) // Handle errors if the track is not found
+		return
+	}
+
+	files, err := tc.fileService.GetFilesByOwner(models.FileOwnerTrack, trackId) // Call service to list the track's files
+	if err != nil {
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Prepare output data
+	output := make([]FileOutput, len(files))
+	for i := range files {
+		output[i] = newFileOutput(&files[i])
+	}
+
+	response := utils.NewSuccessResponse("Track files retrieved successfully", output) // Create a success response
+	c.JSON(http.StatusOK, response)                                                    // Send the response
+}
+
+// StreamTrack handles streaming the audio file of a track, or one of its renditions, with support for HTTP range
+// requests. The rendition is named by the quality parameter or, without one, negotiated from the Accept header
+func (tc *TrackController) StreamTrack(c *gin.Context) {
+	trackId := c.Param("trackId") // Get the track ID from the URL parameter
+	var input StreamTrackInput    // Declare a variable to hold the input data
+
+	// Bind query parameters to input struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if binding fails
+		return
+	}
+
+	track, err := tc.trackService.GetTrack(trackId) // Call service to get the track; deleted tracks are not found
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusNotFound), err) // Handle errors if the track is not found
+		return
+	}
+
+	// Pick the rendition to stream; nil streams the original audio file
+	var rendition *models.Rendition
+	if input.Quality != "" {
+		rendition, err = findRendition(track, input.Quality)
+		if err != nil {
+			errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the track has no such rendition
+			return
+		}
+	} else {
+		c.Header("Vary", "Accept") // The response depends on the formats the client accepts
+		rendition = negotiateRendition(track, c.GetHeader("Accept"))
+	}
+
+	var file *models.File
+	if rendition != nil {
+		file, err = tc.fileService.GetFile(rendition.FileID.Hex()) // Retrieve the rendition file
+	} else {
+		file, err = tc.resolveFile(track.Audio.FileID, track.Audio.FileUrl) // Resolve the audio file
+	}
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the file is not found
+		return
+	}
+
+	tc.serveFile(c, file) // Serve the audio file or its rendition
+}
+
+// GetHLSMasterPlaylist handles serving the HLS master playlist of a track, listing the variants its audio is packaged in
+func (tc *TrackController) GetHLSMasterPlaylist(c *gin.Context) {
+	hlsPackage, err := tc.getHLSPackage(c) // Retrieve the package of the track's audio
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the track or package is not found
+		return
+	}
+
+	variants := make([]hls.Variant, len(hlsPackage.Variants))
+	for i, variant := range hlsPackage.Variants {
+		variants[i] = hls.Variant{
+			URI:              variant.Name + "/index.m3u8" + hlsQuery(c),
+			Bandwidth:        variant.Bandwidth,
+			AverageBandwidth: variant.AverageBandwidth,
+			Codecs:           variant.Codecs,
+		}
+	}
+
+	var playlist bytes.Buffer
+	hls.WriteMasterPlaylist(&playlist, variants)
+	writePlaylist(c, playlist.Bytes()) // Send the playlist
+}
+
+// GetHLSMediaPlaylist handles serving the HLS media playlist of a variant of a track, listing its segments
+func (tc *TrackController) GetHLSMediaPlaylist(c *gin.Context) {
+	hlsPackage, err := tc.getHLSPackage(c) // Retrieve the package of the track's audio
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the track or package is not found
+		return
+	}
+
+	variant, err := findHLSVariant(hlsPackage, c.Param("variant"))
+	if err != nil {
+		errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the variant is not found
+		return
+	}
+
+	segments := make([]hls.Segment, len(variant.Segments))
+	for i, segment := range variant.Segments {
+		segments[i] = hls.Segment{URI: strconv.Itoa(i) + ".ts" + hlsQuery(c), Duration: segment.Duration}
+	}
+
+	var playlist bytes.Buffer
+	hls.WriteMediaPlaylist(&playlist, segments)
+	writePlaylist(c, playlist.Bytes()) // Send the playlist
+}
+
+// GetHLSSegment handles serving a segment of an HLS variant of a track
+func (tc *TrackController) GetHLSSegment(c *gin.Context) {
+	hlsPackage, err := tc.getHLSPackage(c) // Retrieve the package of the track's audio
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the track or package is not found
+		return
+	}
+
+	variant, err := findHLSVariant(hlsPackage, c.Param("variant"))
+	if err != nil {
+		errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the variant is not found
+		return
+	}
+
+	// Segments are named after their index in the variant, e.g. "3.ts"
+	index, err := strconv.Atoi(strings.TrimSuffix(c.Param("segment"), ".ts"))
+	if err != nil || index < 0 || index >= len(variant.Segments) || !strings.HasSuffix(c.Param("segment"), ".ts") {
+		errors.HandleError(c, http.StatusNotFound, errors.ErrFileNotFound) // Handle errors if the segment is not found
+		return
+	}
+
+	file, err := tc.fileService.GetFile(variant.Segments[index].FileID.Hex()) // Retrieve the segment file
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the file is not found
+		return
+	}
+
+	tc.serveFile(c, file) // Serve the segment
+}
+
+// getHLSPackage retrieves the HLS package of the audio of the track in the URL; deleted tracks are not found
+func (tc *TrackController) getHLSPackage(c *gin.Context) (*models.HLSPackage, error) {
+	track, err := tc.trackService.GetTrack(c.Param("trackId")) // Call service to get the track
+	if err != nil {
+		return nil, err
+	}
+
+	return tc.transcodeService.GetHLSPackage(track.Audio.FileID) // Call service to get the package of the track's audio
+}
+
+// IssueMediaToken handles issuing a token that authenticates the stream, cover and HLS endpoints of a track
+// from their URLs, for media elements and players that cannot send headers
+func (tc *TrackController) IssueMediaToken(c *gin.Context) {
+	trackId := c.Param("trackId") // Get the track ID from the URL parameter
+
+	track, err := tc.trackService.GetTrack(trackId) // Make sure the track exists
+	if err != nil {
+		errors.HandleError(c, http.StatusNotFound, err) // Handle errors if the track is not found
+		return
+	}
+
+	token, expiresAt, err := tc.authService.IssueMediaToken(utils.GetUserID(c), track.ID.Hex()) // Sign a token for the track
+	if err != nil {
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Prepare output data
+	trackUrl := utils.GetScheme(c) + "://" + c.Request.Host + "/api/tracks/" + track.ID.Hex() // Base URL of the track endpoints
+	query := "?media_token=" + url.QueryEscape(token)
+	output := MediaTokenOutput{
+		MediaToken:    token,
+		ExpiresAt:     expiresAt,
+		AudioFileUrl:  trackUrl + "/stream" + query,
+		CoverImageUrl: trackUrl + "/cover" + query,
+		HLSUrl:        trackUrl + "/hls/master.m3u8" + query,
+	}
+
+	response := utils.NewSuccessResponse("Media token issued successfully", output) // Create a success response
+	c.JSON(http.StatusCreated, response)                                            // Send the response
+}
+
+// GetWaveform handles serving the waveform of a track at the stored resolution closest to the requested one,
+// as a bare audiowaveform JSON document that waveform display libraries load as is
+func (tc *TrackController) GetWaveform(c *gin.Context) {
+	trackId := c.Param("trackId") // Get the track ID from the URL parameter
+	var input GetWaveformInput    // Declare a variable to hold the input data
+
+	// Bind query parameters to input struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if binding fails
+		return
+	}
+	if input.Resolution == 0 {
+		input.Resolution = 1024 // Default resolution
+	}
+
+	track, err := tc.trackService.GetTrack(trackId) // Call service to get the track; deleted tracks are not found
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusNotFound), err) // Handle errors if the track is not found
+		return
+	}
+
+	waveform, err := tc.transcodeService.GetWaveform(track.Audio.FileID) // Call service to get the waveform of the track's audio
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the waveform is not found
+		return
+	}
+
+	// Pick the lowest resolution with enough pixels, or the highest one
+	level := waveform.Levels[len(waveform.Levels)-1]
+	for _, l := range waveform.Levels {
+		if l.Resolution >= input.Resolution {
+			level = l
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, WaveformOutput{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      waveform.SampleRate,
+		SamplesPerPixel: level.SamplesPerPixel,
+		Bits:            8,
+		Length:          level.Length,
+		Data:            level.Data,
+	}) // Send the waveform
+}
+
+// ListTranscodeJobs handles listing the transcoding jobs of a track's audio file
+func (tc *TrackController) ListTranscodeJobs(c *gin.Context) {
+	trackId := c.Param("trackId") // Get the track ID from the URL parameter
+
+	track, err := tc.trackService.GetTrack(trackId) // Call service to get the track
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusNotFound), err) // Handle errors if the track is not found
+		return
+	}
+
+	jobs, err := tc.transcodeService.ListTranscodeJobs(track) // Call service to list the track's jobs
 	if err != nil {
 		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
 		return
 	}
 
-	response := utils.NewSuccessResponse("Track action performed successfully", nil) // Create a success response
-	c.JSON(http.StatusOK, response)                                                  // Send the response
+	// Prepare output data
+	output := make([]TranscodeJobOutput, len(jobs))
+	for i := range jobs {
+		output[i] = newTranscodeJobOutput(&jobs[i])
+	}
+
+	response := utils.NewSuccessResponse("Transcoding jobs retrieved successfully", output) // Create a success response
+	c.JSON(http.StatusOK, response)                                                         // Send the response
+}
+
+// AnalyzeLoudness handles queuing the loudness analysis of the tracks that were never analyzed, such as the tracks
+// added before loudness was measured, or of every track
+func (tc *TrackController) AnalyzeLoudness(c *gin.Context) {
+	var input AnalyzeLoudnessInput // Declare a variable to hold the input data
+
+	// Bind query parameters to input struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if binding fails
+		return
+	}
+
+	queued, err := tc.transcodeService.EnqueueLoudnessAnalysis(input.All) // Call service to queue the analysis
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	output := AnalyzeLoudnessOutput{Queued: queued} // Prepare output data
+
+	response := utils.NewSuccessResponse("Loudness analysis queued successfully", output) // Create a success response
+	c.JSON(http.StatusOK, response)                                                       // Send the response
+}
+
+// GetCoverImage handles serving the cover image of a track, or a thumbnail of it when a size is requested;
+// thumbnails of sizes other than the standard ones are generated on first request and stored
+func (tc *TrackController) GetCoverImage(c *gin.Context) {
+	trackId := c.Param("trackId") // Get the track ID from the URL parameter
+	var input GetCoverImageInput  // Declare a variable to hold the input data
+
+	// Bind query parameters to input struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if binding fails
+		return
+	}
+
+	size := 0
+	if input.Size != 0 {
+		var err error
+		size, err = services.ThumbnailSize(input.Size) // Validate the size and round it up
+		if err != nil {
+			errors.HandleError(c, http.StatusBadRequest, err) // Handle errors if the size is out of bounds
+			return
+		}
+	}
+
+	track, err := tc.trackService.GetTrack(trackId) // Call service to get the track; deleted tracks are not found
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusNotFound), err) // Handle errors if the track is not found
+		return
+	}
+
+	file, err := tc.resolveFile(track.CoverFileID, track.CoverImageUrl) // Resolve the cover image file
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the file is not found
+		return
+	}
+
+	if size != 0 {
+		file, err = tc.thumbnailService.GetThumbnail(c, file, size) // Retrieve or generate the thumbnail
+		if err != nil {
+			errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the cover image cannot be scaled
+			return
+		}
+	}
+
+	tc.serveFile(c, file) // Serve the cover image or its thumbnail
+}
+
+// serveFile writes the content of a file, answering range and conditional requests with 206 and 304 responses
+func (tc *TrackController) serveFile(c *gin.Context, file *models.File) {
+	// Send clients to the storage directly when it issues signed URLs
+	redirectUrl, err := tc.fileService.RedirectURL(file)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the URL cannot be signed
+		return
+	}
+	if redirectUrl != "" {
+		c.Redirect(http.StatusFound, redirectUrl)
+		return
+	}
+
+	content, info, err := tc.fileService.OpenFile(file) // Open the file content
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors if the file cannot be opened
+		return
+	}
+	defer content.Close()
+
+	// Describe the content so clients can cache it and resume or seek with range requests; the type sniffed on
+	// upload wins over the one derived from the stored filename, and browsers must not sniff another one
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = info.ContentType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", `"`+file.ID.Hex()+"-"+strconv.FormatInt(info.Size, 16)+"-"+strconv.FormatInt(info.ModTime.Unix(), 16)+`"`)
+	c.Header("Cache-Control", "private, max-age=3600")
+
+	// ServeContent sets Accept-Ranges and Last-Modified and handles Range, If-Range, If-None-Match and If-Modified-Since
+	http.ServeContent(c.Writer, c.Request, file.Filename, info.ModTime, content)
+}
+
+// saveTrackFile stores the file uploaded in the form field for the owner, or adds the owner to the file of the
+// finished resumable upload with the given ID, which the caller releases once the track is saved.
+// It returns a nil file if neither was given
+func (tc *TrackController) saveTrackFile(c *gin.Context, field, uploadId string, owner models.FileOwner) (*models.File, *models.Upload, error) {
+	if uploadId != "" {
+		return tc.uploadService.ClaimUpload(utils.GetUserID(c), uploadId, owner) // Claim the file of the upload
+	}
+
+	header, err := c.FormFile(field) // Get the uploaded file
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	file, err := tc.fileService.SaveUploadedFile(c, utils.GetUserID(c), header, owner) // Store the file and its metadata
+	return file, nil, err
+}
+
+// saveAudioFile stores the audio file uploaded in the audio_file form field, or in the mp3_file field it was formerly
+// named, or claims the finished resumable upload given by either of its IDs
+func (tc *TrackController) saveAudioFile(c *gin.Context, uploadId, mp3UploadId string, owner models.FileOwner) (*models.File, *models.Upload, error) {
+	if uploadId == "" {
+		uploadId = mp3UploadId
+	}
+	field := "audio_file"
+	if _, err := c.FormFile(field); err != nil {
+		field = "mp3_file"
+	}
+	return tc.saveTrackFile(c, field, uploadId, owner)
+}
+
+// releaseFile removes the track as an owner of a file it no longer uses
+func (tc *TrackController) releaseFile(fileID primitive.ObjectID, fileUrl string, owner models.FileOwner) {
+	file, err := tc.resolveFile(fileID, fileUrl)
+	if err != nil {
+		return // Nothing to release
+	}
+	tc.fileService.RemoveFile(file, owner)
+}
+
+// resolveFile retrieves the record of a file of a track by its ID, or by its stored URL for tracks added
+// before file IDs were stored on tracks
+func (tc *TrackController) resolveFile(fileID primitive.ObjectID, fileUrl string) (*models.File, error) {
+	if !fileID.IsZero() {
+		return tc.fileService.GetFile(fileID.Hex())
+	}
+	return tc.fileService.GetFileByFilename(path.Base(fileUrl))
+}
+
+// saveCoverArt saves the front cover embedded in the tags, or a placeholder generated from the track's album
+// when there is none, as the track's cover image and returns the stored file
+func (tc *TrackController) saveCoverArt(c *gin.Context, track *models.Track, tags *audio.Tags, owner models.FileOwner) (*models.File, error) {
+	var data []byte
+	if tags != nil {
+		if picture := tags.CoverArt(); picture != nil {
+			data = picture.Data
+		}
+	}
+
+	// Only keep embedded pictures in an image format accepted for covers
+	if !utils.IsImageType(utils.SniffContentType(data)) {
+		placeholder, err := utils.GeneratePlaceholderCover(track.Artist + "\x00" + track.Album) // Generate a placeholder shared by the album
+		if err != nil {
+			return nil, errors.ErrInternalServer
+		}
+		data = placeholder
+	}
+
+	coverRecord, err := tc.fileService.StoreFile(c, utils.GetUserID(c), bytes.NewReader(data), owner) // Store the cover image file and its metadata
+	if err != nil {
+		return nil, err
+	}
+	track.CoverImageUrl = coverRecord.FileUrl // Set the cover image URL
+	track.CoverFileID = coverRecord.ID        // Link the cover image file
+
+	return coverRecord, nil
+}
+
+// applyStreamInfo computes the duration and stream properties of a stored audio file and sets them on the track
+func (tc *TrackController) applyStreamInfo(track *models.Track, audioRecord *models.File, clientDuration int) error {
+	info, err := tc.fileService.ReadStreamInfo(audioRecord) // Read the stream properties from the container
+	if err != nil {
+		return err
+	}
+
+	return tc.trackService.ApplyStreamInfo(track, info, clientDuration) // Apply the duration policy
+}
+
+// newAudioAsset links a stored audio file as the audio of a track; its stream properties are set by applyStreamInfo
+func newAudioAsset(audioRecord *models.File) models.AudioAsset {
+	return models.AudioAsset{
+		FileID:   audioRecord.ID,
+		FileUrl:  audioRecord.FileUrl,
+		MimeType: audioRecord.MimeType,
+	}
+}
+
+// findRendition returns the rendition of a track with the given name, or nil for the "original" quality
+func findRendition(track *models.Track, quality string) (*models.Rendition, error) {
+	if quality == "original" {
+		return nil, nil
+	}
+	for i := range track.Renditions {
+		if track.Renditions[i].Name == quality {
+			return &track.Renditions[i], nil
+		}
+	}
+	return nil, errors.ErrRenditionNotFound
+}
+
+// mimeTypeAliases lists the other media types clients accept a type of audio file by
+var mimeTypeAliases = map[string][]string{
+	utils.ContentTypeMP3:  {"audio/mp3"},
+	utils.ContentTypeOpus: {utils.ContentTypeOgg},
+}
+
+// negotiateRendition picks the rendition of a track whose type the Accept header prefers, or nil for the original
+// audio file. Ties go to the original, then to the rendition of the highest bitrate; without an Accept header or
+// an acceptable type, the original is streamed
+func negotiateRendition(track *models.Track, accept string) *models.Rendition {
+	if accept == "" {
+		return nil
+	}
+	ranges := parseAccept(accept)
+
+	originalType := track.Audio.MimeType
+	if originalType == "" {
+		originalType = utils.ContentTypeMP3 // Tracks added before types were detected only hold MP3 files
+	}
+
+	var best *models.Rendition
+	bestQuality := acceptQuality(ranges, originalType)
+	for i := range track.Renditions {
+		rendition := &track.Renditions[i]
+		quality := acceptQuality(ranges, rendition.MimeType)
+		if quality > bestQuality || quality == bestQuality && quality > 0 && best != nil && rendition.Bitrate > best.Bitrate {
+			best, bestQuality = rendition, quality
+		}
+	}
+	return best
+}
+
+// parseAccept parses an Accept header into the quality value of each media range it lists
+func parseAccept(accept string) map[string]float64 {
+	ranges := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaRange == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		ranges[mediaRange] = quality
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality value parsed Accept ranges give a media type: that of the type itself or one
+// of its aliases, otherwise that of its top-level wildcard, otherwise that of */*, and 0 if none is listed
+func acceptQuality(ranges map[string]float64, mimeType string) float64 {
+	found := false
+	quality := 0.0
+	for _, name := range append([]string{mimeType}, mimeTypeAliases[mimeType]...) {
+		if q, ok := ranges[name]; ok {
+			found, quality = true, max(quality, q)
+		}
+	}
+	if found {
+		return quality
+	}
+
+	topLevel, _, _ := strings.Cut(mimeType, "/")
+	if q, ok := ranges[topLevel+"/*"]; ok {
+		return q
+	}
+	return ranges["*/*"]
+}
+
+// findHLSVariant returns the variant of an HLS package with the given name
+func findHLSVariant(hlsPackage *models.HLSPackage, name string) (*models.HLSVariant, error) {
+	for i := range hlsPackage.Variants {
+		if hlsPackage.Variants[i].Name == name {
+			return &hlsPackage.Variants[i], nil
+		}
+	}
+	return nil, errors.ErrHLSPackageNotFound
+}
+
+// hlsQuery returns the query string the URIs in HLS playlists carry so players that cannot send headers, which
+// authenticate with a media_token parameter, keep authenticating for the playlists and segments they load
+func hlsQuery(c *gin.Context) string {
+	token := c.Query("media_token")
+	if token == "" {
+		return ""
+	}
+	return "?media_token=" + url.QueryEscape(token)
+}
+
+// writePlaylist sends an HLS playlist; playlists change when the track's audio file is replaced, so they are
+// only cached briefly
+func writePlaylist(c *gin.Context, playlist []byte) {
+	c.Header("Cache-Control", "private, max-age=60")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", playlist)
+}
+
+// prefillTrack fills the track fields that are still empty with the values read from the tags
+func prefillTrack(track *models.Track, tags *audio.Tags) {
+	if track.Title == "" {
+		track.Title = tags.Title()
+	}
+	if track.Artist == "" {
+		track.Artist = tags.Artist()
+	}
+	if track.Album == "" {
+		track.Album = tags.Album()
+	}
+	if track.Genre == "" {
+		track.Genre = tags.Genre()
+	}
+	if track.ReleaseYear == 0 {
+		track.ReleaseYear = tags.Year()
+	}
+}
+
+// newTrackOutput converts a track model to its output representation, pointing the file URLs at the track endpoints
+func newTrackOutput(c *gin.Context, track *models.Track) TrackOutput {
+	trackUrl := utils.GetScheme(c) + "://" + c.Request.Host + "/api/tracks/" + track.ID.Hex() // Base URL of the track endpoints
+
+	return TrackOutput{
+		ID:            track.ID.Hex(),
+		Title:         track.Title,
+		Artist:        track.Artist,
+		Album:         track.Album,
+		Genre:         track.Genre,
+		ReleaseYear:   track.ReleaseYear,
+		Duration:      track.Duration,
+		CoverImageUrl: trackUrl + "/cover",
+		AudioFileUrl:  trackUrl + "/stream",
+		Mp3FileUrl:    trackUrl + "/stream",
+		HLSUrl:        trackUrl + "/hls/master.m3u8",
+		WaveformUrl:   trackUrl + "/waveform",
+		CoverFileID:   fileIDOutput(track.CoverFileID),
+		Thumbnails:    newThumbnailOutputs(trackUrl, track.Thumbnails),
+		AudioFileID:   fileIDOutput(track.Audio.FileID),
+		Audio:         newAudioOutput(track.Audio),
+		Renditions:    newRenditionOutputs(trackUrl, track.Renditions),
+		Loudness:      newLoudnessOutput(track.Loudness),
+		PlayCount:     track.PlayCount,
+		LastPlayedAt:  track.LastPlayedAt,
+	}
+}
+
+// newAudioOutput converts the audio asset of a track to the output representation of its format
+func newAudioOutput(audio models.AudioAsset) AudioOutput {
+	return AudioOutput{
+		MimeType:    audio.MimeType,
+		Container:   audio.Container,
+		Codec:       audio.Codec,
+		BitDepth:    audio.BitDepth,
+		Bitrate:     audio.Bitrate,
+		SampleRate:  audio.SampleRate,
+		Channels:    audio.Channels,
+		ChannelMode: audio.ChannelMode,
+	}
+}
+
+// newThumbnailOutputs converts the thumbnails of a track's cover image to their output representation
+func newThumbnailOutputs(trackUrl string, thumbnails []models.Thumbnail) []ThumbnailOutput {
+	output := make([]ThumbnailOutput, len(thumbnails))
+	for i, thumbnail := range thumbnails {
+		output[i] = ThumbnailOutput{
+			Size:   thumbnail.Size,
+			FileID: thumbnail.FileID.Hex(),
+			Url:    trackUrl + "/cover?size=" + strconv.Itoa(thumbnail.Size),
+		}
+	}
+	return output
+}
+
+// newRenditionOutputs converts the renditions of a track's audio to their output representation
+func newRenditionOutputs(trackUrl string, renditions []models.Rendition) []RenditionOutput {
+	output := make([]RenditionOutput, len(renditions))
+	for i, rendition := range renditions {
+		output[i] = RenditionOutput{
+			Name:     rendition.Name,
+			Codec:    rendition.Codec,
+			Bitrate:  rendition.Bitrate,
+			MimeType: rendition.MimeType,
+			FileID:   rendition.FileID.Hex(),
+			Url:      trackUrl + "/stream?quality=" + url.QueryEscape(rendition.Name),
+		}
+	}
+	return output
+}
+
+// newLoudnessOutput converts the loudness of a track to its output representation, nil if it is not analyzed
+func newLoudnessOutput(loudness *models.Loudness) *LoudnessOutput {
+	if loudness == nil {
+		return nil
+	}
+	return &LoudnessOutput{
+		Integrated: loudness.Integrated,
+		TruePeak:   loudness.TruePeak,
+		TrackGain:  loudness.TrackGain,
+		AlbumGain:  loudness.AlbumGain,
+		AlbumPeak:  loudness.AlbumPeak,
+		AnalyzedAt: loudness.AnalyzedAt,
+	}
+}
+
+// newTranscodeJobOutput converts a transcoding job to its output representation
+func newTranscodeJobOutput(job *models.TranscodeJob) TranscodeJobOutput {
+	return TranscodeJobOutput{
+		ID:         job.ID.Hex(),
+		Kind:       job.Kind,
+		Rendition:  job.Rendition,
+		Codec:      job.Codec,
+		Bitrate:    job.Bitrate,
+		Status:     job.Status,
+		Attempts:   job.Attempts,
+		Error:      job.Error,
+		FileID:     fileIDOutput(job.FileID),
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+}
+
+// fileIDOutput converts the ID of a linked file to its output representation, empty if no file is linked
+func fileIDOutput(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
 }
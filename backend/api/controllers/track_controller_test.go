@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+)
+
+func TestParseAccept(t *testing.T) {
+	ranges := parseAccept("Audio/Ogg;q=0.8, audio/mpeg , audio/*; q=0.5;level=1,,*/*;q=bad")
+	want := map[string]float64{"audio/ogg": 0.8, "audio/mpeg": 1, "audio/*": 0.5, "*/*": 1}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("ranges = %v, want %v", ranges, want)
+	}
+}
+
+func TestAcceptQuality(t *testing.T) {
+	tests := []struct {
+		accept   string
+		mimeType string
+		quality  float64
+	}{
+		{"audio/mpeg;q=0.7", utils.ContentTypeMP3, 0.7},
+		{"audio/mp3;q=0.6", utils.ContentTypeMP3, 0.6},                   // Alias of MP3
+		{"audio/mpeg;q=0.2, audio/mp3;q=0.9", utils.ContentTypeMP3, 0.9}, // The best of the type and its aliases
+		{"audio/ogg", utils.ContentTypeOpus, 1},                          // Opus is served in Ogg
+		{"audio/*;q=0.4", utils.ContentTypeFLAC, 0.4},
+		{"audio/flac;q=0, audio/*", utils.ContentTypeFLAC, 0}, // The type itself wins over its wildcard
+		{"*/*;q=0.3", utils.ContentTypeAAC, 0.3},
+		{"video/*", utils.ContentTypeAAC, 0},
+	}
+	for _, test := range tests {
+		if quality := acceptQuality(parseAccept(test.accept), test.mimeType); quality != test.quality {
+			t.Errorf("acceptQuality(%q, %q) = %v, want %v", test.accept, test.mimeType, quality, test.quality)
+		}
+	}
+}
+
+func TestNegotiateRendition(t *testing.T) {
+	track := &models.Track{
+		Audio: models.AudioAsset{MimeType: utils.ContentTypeFLAC},
+		Renditions: []models.Rendition{
+			{Name: "mp3-128k", Bitrate: 128, MimeType: utils.ContentTypeMP3},
+			{Name: "mp3-320k", Bitrate: 320, MimeType: utils.ContentTypeMP3},
+			{Name: "opus-96k", Bitrate: 96, MimeType: utils.ContentTypeOpus},
+		},
+	}
+
+	tests := []struct {
+		accept    string
+		rendition string // Empty for the original
+	}{
+		{"", ""},
+		{"*/*", ""},                    // Ties go to the original
+		{"audio/flac, audio/mpeg", ""}, // Ties go to the original
+		{"audio/mpeg", "mp3-320k"},     // Then to the highest bitrate
+		{"audio/mp3", "mp3-320k"},      // Aliases count as the type
+		{"audio/ogg, audio/mpeg;q=0.5", "opus-96k"},
+		{"audio/flac;q=0.1, audio/*;q=0.5", "mp3-320k"},
+		{"video/mp4", ""}, // Nothing acceptable, so the original
+		{"audio/flac;q=0, audio/opus", "opus-96k"},
+	}
+	for _, test := range tests {
+		rendition := negotiateRendition(track, test.accept)
+		name := ""
+		if rendition != nil {
+			name = rendition.Name
+		}
+		if name != test.rendition {
+			t.Errorf("negotiateRendition(%q) = %q, want %q", test.accept, name, test.rendition)
+		}
+	}
+}
+
+func TestNegotiateRenditionLegacyTrack(t *testing.T) {
+	// Tracks added before types were detected only hold MP3 files
+	track := &models.Track{Renditions: []models.Rendition{{Name: "opus-96k", Bitrate: 96, MimeType: utils.ContentTypeOpus}}}
+	if rendition := negotiateRendition(track, "audio/mpeg, audio/ogg;q=0.9"); rendition != nil {
+		t.Errorf("negotiateRendition = %q, want the original", rendition.Name)
+	}
+}
+
+func TestFindRendition(t *testing.T) {
+	track := &models.Track{Renditions: []models.Rendition{{Name: "mp3-128k"}}}
+	if rendition, err := findRendition(track, "original"); rendition != nil || err != nil {
+		t.Errorf("findRendition(original) = %v, %v, want the original", rendition, err)
+	}
+	if rendition, err := findRendition(track, "mp3-128k"); err != nil || rendition.Name != "mp3-128k" {
+		t.Errorf("findRendition(mp3-128k) = %v, %v", rendition, err)
+	}
+	if _, err := findRendition(track, "opus-96k"); err == nil {
+		t.Error("findRendition of a missing rendition succeeded")
+	}
+}
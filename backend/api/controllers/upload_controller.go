@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"music-library-management/api/services"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusVersion is the version of the tus resumable upload protocol the upload endpoints implement
+const tusVersion = "1.0.0"
+
+// UploadController handles resumable uploads following the tus protocol
+type UploadController struct {
+	uploadService *services.UploadService // A reference to the upload service
+}
+
+// NewUploadController creates a new UploadController
+func NewUploadController(uploadService *services.UploadService) *UploadController {
+	return &UploadController{
+		uploadService: uploadService, // Initialize the upload service
+	}
+}
+
+// RequireTusResumable rejects requests that do not speak the supported tus version and marks every response with it
+func (uc *UploadController) RequireTusResumable(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		errors.HandleError(c, http.StatusPreconditionFailed, errors.ErrInvalidInput) // Handle unsupported protocol versions
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+// GetOptions handles the discovery of the supported tus version, extensions and maximum upload size
+func (uc *UploadController) GetOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,termination,expiration")
+	c.Header("Tus-Max-Size", strconv.FormatInt(uc.uploadService.MaxSize(), 10))
+	c.Status(http.StatusNoContent) // Send the response
+}
+
+// CreateUpload handles creating a resumable upload of the length given in the Upload-Length header
+func (uc *UploadController) CreateUpload(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64) // Parse the declared length
+	if err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if the length is missing or invalid
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata")) // Decode the upload metadata
+	if err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if the metadata is invalid
+		return
+	}
+
+	upload, err := uc.uploadService.CreateUpload(utils.GetUserID(c), length, metadata) // Call service to create the upload
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	c.Header("Location", "/api/uploads/"+upload.ID.Hex())
+	c.Header("Upload-Expires", uploadExpiry(upload.ExpiresAt))
+	c.Status(http.StatusCreated) // Send the response
+}
+
+// GetUploadOffset handles reporting how many bytes of an upload were received, so a client can resume it
+func (uc *UploadController) GetUploadOffset(c *gin.Context) {
+	uploadId := c.Param("uploadId") // Get the upload ID from the URL parameter
+
+	upload, err := uc.uploadService.GetUpload(utils.GetUserID(c), uploadId) // Call service to get the upload
+	if err != nil {
+		c.Status(errors.StatusCode(err, http.StatusInternalServerError)) // HEAD responses carry no body
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", uploadExpiry(upload.ExpiresAt))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK) // Send the response
+}
+
+// PatchUpload handles receiving a chunk of an upload at the offset given in the Upload-Offset header;
+// the upload is finished into a file once its last chunk was received
+func (uc *UploadController) PatchUpload(c *gin.Context) {
+	uploadId := c.Param("uploadId") // Get the upload ID from the URL parameter
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		errors.HandleError(c, http.StatusUnsupportedMediaType, errors.ErrInvalidInput) // Handle errors if the body is not a chunk
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64) // Parse the offset of the chunk
+	if err != nil || offset < 0 {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle errors if the offset is missing or invalid
+		return
+	}
+
+	upload, err := uc.uploadService.WriteChunk(c, utils.GetUserID(c), uploadId, offset, c.Request.Body) // Call service to store the chunk
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", uploadExpiry(upload.ExpiresAt))
+	c.Status(http.StatusNoContent) // Send the response
+}
+
+// DeleteUpload handles terminating an upload, discarding what was received
+func (uc *UploadController) DeleteUpload(c *gin.Context) {
+	uploadId := c.Param("uploadId") // Get the upload ID from the URL parameter
+
+	err := uc.uploadService.DeleteUpload(utils.GetUserID(c), uploadId) // Call service to delete the upload
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	c.Status(http.StatusNoContent) // Send the response
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated keys, each followed by a space and its
+// base64-encoded value, which may be omitted
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, errors.ErrInvalidInput
+		}
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, errors.ErrInvalidInput
+			}
+			value = string(decoded)
+		}
+		metadata[parts[0]] = value
+	}
+
+	return metadata, nil
+}
+
+// uploadExpiry formats the expiry of an upload for the Upload-Expires header
+func uploadExpiry(expiresAt time.Time) string {
+	return expiresAt.UTC().Format(http.TimeFormat)
+}
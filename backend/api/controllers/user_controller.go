@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"music-library-management/api/services"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserController handles HTTP requests for user administration
+type UserController struct {
+	userService *services.UserService // A reference to the user service
+}
+
+// NewUserController creates a new UserController
+func NewUserController(userService *services.UserService) *UserController {
+	return &UserController{
+		userService: userService, // Initialize the user service
+	}
+}
+
+// ListUsersInput represents the input data for listing users
+type ListUsersInput struct {
+	Page  int `form:"page"`  // The page number for pagination
+	Limit int `form:"limit"` // The number of items per page for pagination
+}
+
+// UpdateUserRoleInput represents the input data for assigning a role
+type UpdateUserRoleInput struct {
+	Role string `json:"role" binding:"required,oneof=admin curator listener"` // The role to assign, required field
+}
+
+// PaginatedUsersOutput represents the output data for paginated users
+type PaginatedUsersOutput struct {
+	Page       int          `json:"page"`        // The current page number
+	Limit      int          `json:"limit"`       // The number of items per page
+	TotalCount int64        `json:"total_count"` // The total number of users
+	Users      []UserOutput `json:"users"`       // The list of users
+}
+
+// ListUsers handles listing all users with pagination
+func (uc *UserController) ListUsers(c *gin.Context) {
+	var input ListUsersInput
+
+	// Bind query parameters to ListUsersInput struct
+	if err := c.ShouldBindQuery(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Set default pagination values if not provided
+	if input.Page == 0 {
+		input.Page = 1
+	}
+	if input.Limit == 0 {
+		input.Limit = 10
+	}
+
+	// Call service to list users
+	users, totalCount, err := uc.userService.ListUsers(input.Page, input.Limit)
+	if err != nil {
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+		return
+	}
+
+	// Prepare output data
+	output := PaginatedUsersOutput{
+		Page:       input.Page,
+		Limit:      input.Limit,
+		TotalCount: totalCount,
+		Users:      make([]UserOutput, len(users)), // Initialize the users slice with the appropriate length
+	}
+
+	// Populate the output users
+	for i, user := range users {
+		output.Users[i] = newUserOutput(user)
+	}
+
+	// Respond with success message and list of users
+	response := utils.NewSuccessResponse("Users retrieved successfully", output)
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateUserRole handles assigning a role to a user
+func (uc *UserController) UpdateUserRole(c *gin.Context) {
+	userId := c.Param("userId") // Get the user ID from the URL parameter
+	var input UpdateUserRoleInput
+
+	// Bind JSON input to the UpdateUserRoleInput struct
+	if err := c.ShouldBindJSON(&input); err != nil {
+		errors.HandleError(c, http.StatusBadRequest, errors.ErrInvalidInput) // Handle binding errors
+		return
+	}
+
+	// Call service to assign the role
+	user, err := uc.userService.UpdateUserRole(utils.GetUserID(c), userId, input.Role)
+	if err != nil {
+		errors.HandleError(c, errors.StatusCode(err, http.StatusInternalServerError), err) // Handle errors from the service
+		return
+	}
+
+	// Respond with success message and updated user
+	response := utils.NewSuccessResponse("User role updated successfully", newUserOutput(user))
+	c.JSON(http.StatusOK, response)
+}
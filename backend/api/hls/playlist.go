@@ -0,0 +1,96 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPlaylist is returned when a media playlist cannot be parsed
+var ErrInvalidPlaylist = errors.New("invalid HLS playlist")
+
+// CodecAACLC is the RFC 6381 codec string of AAC-LC audio
+const CodecAACLC = "mp4a.40.2"
+
+// Variant is a stream listed in a master playlist
+type Variant struct {
+	URI              string // URI of the media playlist of the variant
+	Bandwidth        int    // Peak bitrate in bit/s
+	AverageBandwidth int    // Average bitrate in bit/s
+	Codecs           string // RFC 6381 codec strings of the variant
+}
+
+// Segment is a media segment listed in a media playlist
+type Segment struct {
+	URI      string  // URI of the segment
+	Duration float64 // Duration of the segment in seconds
+}
+
+// WriteMasterPlaylist writes a master playlist listing the variants of a stream
+func WriteMasterPlaylist(w io.Writer, variants []Variant) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	for _, variant := range variants {
+		fmt.Fprintf(bw, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,CODECS=%q\n%s\n",
+			variant.Bandwidth, variant.AverageBandwidth, variant.Codecs, variant.URI)
+	}
+	return bw.Flush()
+}
+
+// WriteMediaPlaylist writes the media playlist of a video on demand stream cut into the segments
+func WriteMediaPlaylist(w io.Writer, segments []Segment) error {
+	// The target duration is the longest segment duration rounded to the nearest integer
+	targetDuration := 0
+	for _, segment := range segments {
+		targetDuration = max(targetDuration, int(math.Round(segment.Duration)))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n", targetDuration)
+	for _, segment := range segments {
+		fmt.Fprintf(bw, "#EXTINF:%.6f,\n%s\n", segment.Duration, segment.URI)
+	}
+	fmt.Fprint(bw, "#EXT-X-ENDLIST\n")
+	return bw.Flush()
+}
+
+// ReadMediaPlaylist reads the segments listed in a media playlist, such as the ones written by ffmpeg
+func ReadMediaPlaylist(r io.Reader) ([]Segment, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "#EXTM3U" {
+		return nil, ErrInvalidPlaylist
+	}
+
+	var segments []Segment
+	duration := -1.0 // Duration of the next segment, negative until its #EXTINF tag is read
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			d, err := strconv.ParseFloat(value, 64)
+			if err != nil || d < 0 {
+				return nil, ErrInvalidPlaylist
+			}
+			duration = d
+		case strings.HasPrefix(line, "#"):
+			// Other tags describe the whole playlist
+		default:
+			if duration < 0 {
+				return nil, ErrInvalidPlaylist // Every segment must be preceded by its duration
+			}
+			segments = append(segments, Segment{URI: line, Duration: duration})
+			duration = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
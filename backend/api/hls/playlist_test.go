@@ -0,0 +1,131 @@
+package hls
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteMasterPlaylist(t *testing.T) {
+	variants := []Variant{
+		{URI: "aac-64k/index.m3u8", Bandwidth: 70400, AverageBandwidth: 64000, Codecs: CodecAACLC},
+		{URI: "aac-128k/index.m3u8", Bandwidth: 140800, AverageBandwidth: 128000, Codecs: CodecAACLC},
+	}
+	want := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=70400,AVERAGE-BANDWIDTH=64000,CODECS="mp4a.40.2"
+aac-64k/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=140800,AVERAGE-BANDWIDTH=128000,CODECS="mp4a.40.2"
+aac-128k/index.m3u8
+`
+
+	var b bytes.Buffer
+	if err := WriteMasterPlaylist(&b, variants); err != nil {
+		t.Fatalf("WriteMasterPlaylist: %v", err)
+	}
+	if b.String() != want {
+		t.Errorf("playlist =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteMediaPlaylist(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+		want     string
+	}{
+		{
+			name: "target duration rounds the longest segment up",
+			segments: []Segment{
+				{URI: "segment0.ts", Duration: 6.016},
+				{URI: "segment1.ts", Duration: 6.5},
+				{URI: "segment2.ts", Duration: 2.25},
+			},
+			want: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:7
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:6.016000,
+segment0.ts
+#EXTINF:6.500000,
+segment1.ts
+#EXTINF:2.250000,
+segment2.ts
+#EXT-X-ENDLIST
+`,
+		},
+		{
+			name:     "target duration rounds the longest segment down",
+			segments: []Segment{{URI: "segment0.ts", Duration: 5.9}, {URI: "segment1.ts", Duration: 6.4999}},
+			want: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:5.900000,
+segment0.ts
+#EXTINF:6.499900,
+segment1.ts
+#EXT-X-ENDLIST
+`,
+		},
+		{
+			name: "no segments",
+			want: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:0
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-ENDLIST
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := WriteMediaPlaylist(&b, test.segments); err != nil {
+				t.Fatalf("WriteMediaPlaylist: %v", err)
+			}
+			if b.String() != test.want {
+				t.Errorf("playlist =\n%s\nwant\n%s", b.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestReadMediaPlaylist(t *testing.T) {
+	// A playlist as written by ffmpeg, with carriage returns and a title after the duration
+	playlist := "#EXTM3U\r\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:7\n\n#EXTINF:6.016,\nsegment0.ts\n#EXTINF:2.25,title\r\nsegment1.ts\n#EXT-X-ENDLIST\n"
+	want := []Segment{{URI: "segment0.ts", Duration: 6.016}, {URI: "segment1.ts", Duration: 2.25}}
+
+	segments, err := ReadMediaPlaylist(strings.NewReader(playlist))
+	if err != nil {
+		t.Fatalf("ReadMediaPlaylist: %v", err)
+	}
+	if !reflect.DeepEqual(segments, want) {
+		t.Errorf("segments = %+v, want %+v", segments, want)
+	}
+
+	// A written playlist reads back as its segments
+	var b bytes.Buffer
+	WriteMediaPlaylist(&b, want)
+	if segments, err := ReadMediaPlaylist(&b); err != nil || !reflect.DeepEqual(segments, want) {
+		t.Errorf("ReadMediaPlaylist of a written playlist = (%+v, %v), want %+v", segments, err, want)
+	}
+
+	for name, playlist := range map[string]string{
+		"empty":                        "",
+		"no header":                    "#EXTINF:6,\nsegment0.ts\n",
+		"segment without its duration": "#EXTM3U\nsegment0.ts\n",
+		"invalid duration":             "#EXTM3U\n#EXTINF:six,\nsegment0.ts\n",
+		"negative duration":            "#EXTM3U\n#EXTINF:-1,\nsegment0.ts\n",
+	} {
+		if _, err := ReadMediaPlaylist(strings.NewReader(playlist)); err != ErrInvalidPlaylist {
+			t.Errorf("ReadMediaPlaylist(%s) error = %v, want ErrInvalidPlaylist", name, err)
+		}
+	}
+}
@@ -2,10 +2,148 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"music-library-management/api/models"
+	"music-library-management/api/services"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
 )
 
+// AuthMiddleware requires either a valid bearer access token or an X-API-Key header and stores the authenticated user in the context
+func AuthMiddleware(authService *services.AuthService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Machine clients authenticate with an API key limited to its scopes
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			key, user, err := apiKeyService.Authenticate(apiKey)
+			if err != nil {
+				abortUnauthenticated(c, err)
+				return
+			}
+
+			// Make the user, role and key scopes available to the permission checks and controllers
+			c.Set(utils.ContextUserIDKey, user.ID.Hex())
+			c.Set(utils.ContextRoleKey, user.EffectiveRole())
+			c.Set(utils.ContextScopesKey, key.Scopes)
+
+			// Proceed to the next middleware or handler
+			c.Next()
+			return
+		}
+
+		// Extract the token from the "Authorization: Bearer <token>" header
+		header := c.GetHeader("Authorization")
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			errors.HandleError(c, http.StatusUnauthorized, errors.ErrUnauthorized) // Reject requests without credentials
+			c.Abort()
+			return
+		}
+
+		// Validate the token and load the user it belongs to
+		user, err := authService.Authenticate(token)
+		if err != nil {
+			abortUnauthenticated(c, err)
+			return
+		}
+
+		// Make the user ID and role available to the permission checks and controllers
+		c.Set(utils.ContextUserIDKey, user.ID.Hex())
+		c.Set(utils.ContextRoleKey, user.EffectiveRole())
+
+		// Proceed to the next middleware or handler
+		c.Next()
+	}
+}
+
+// MediaAuthMiddleware guards the stream, cover and HLS endpoints of a track. Media elements such as <audio> cannot
+// send headers, so besides the credentials the auth middleware accepts, these endpoints take a media token issued
+// for the track as the media_token query parameter. Access tokens are never accepted in URLs, where they would end
+// up in access logs, caches and Referer headers
+func MediaAuthMiddleware(authService *services.AuthService, authMiddleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mediaToken := c.Query("media_token")
+		if mediaToken == "" {
+			authMiddleware(c) // Authenticate with a header as on the other routes
+			return
+		}
+
+		// Validate the token against the track in the URL and load the user it was issued to
+		user, err := authService.AuthenticateMedia(mediaToken, c.Param("trackId"))
+		if err != nil {
+			abortUnauthenticated(c, err)
+			return
+		}
+
+		// Make the user and role available, limiting the token to reading tracks like an API key scope
+		c.Set(utils.ContextUserIDKey, user.ID.Hex())
+		c.Set(utils.ContextRoleKey, user.EffectiveRole())
+		c.Set(utils.ContextScopesKey, []string{models.PermissionTracksRead})
+
+		// Proceed to the next middleware or handler
+		c.Next()
+	}
+}
+
+// abortUnauthenticated aborts the request with the status matching an authentication error
+func abortUnauthenticated(c *gin.Context, err error) {
+	if err == errors.ErrInvalidToken {
+		errors.HandleError(c, http.StatusUnauthorized, err) // Reject invalid or expired credentials
+	} else {
+		errors.HandleError(c, http.StatusInternalServerError, err) // Handle errors from the service
+	}
+	c.Abort()
+}
+
+// RequirePermission allows the request only if the authenticated user's role grants the permission
+// and, for API key requests, the key was granted the permission as a scope
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Check the role set by the auth middleware against the permission matrix
+		allowed := models.HasPermission(utils.GetRole(c), permission)
+
+		// API keys are further limited to their scopes
+		if scopes, ok := utils.GetScopes(c); ok {
+			allowed = allowed && containsScope(scopes, permission)
+		}
+
+		if !allowed {
+			errors.HandleError(c, http.StatusForbidden, errors.ErrForbidden) // Reject requests the role or key does not allow
+			c.Abort()
+			return
+		}
+
+		// Proceed to the next middleware or handler
+		c.Next()
+	}
+}
+
+// RequireUserSession rejects requests authenticated with an API key, so keys cannot manage other keys
+func RequireUserSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := utils.GetScopes(c); ok {
+			errors.HandleError(c, http.StatusForbidden, errors.ErrForbidden) // Reject API key requests
+			c.Abort()
+			return
+		}
+
+		// Proceed to the next middleware or handler
+		c.Next()
+	}
+}
+
+// containsScope reports whether the scope list includes the permission
+func containsScope(scopes []string, permission string) bool {
+	for _, scope := range scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
 // CORSMiddleware handles CORS-related headers and ensures the client is allowed to access the resource
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
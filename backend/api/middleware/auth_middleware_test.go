@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+)
+
+// serveWithPermission runs a request as a user of the role, and of an API key limited to the scopes unless they
+// are nil, through RequirePermission, returning the response status
+func serveWithPermission(role string, scopes []string, permission string) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Set(utils.ContextRoleKey, role)
+		if scopes != nil {
+			c.Set(utils.ContextScopesKey, scopes)
+		}
+	}, RequirePermission(permission), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	return recorder.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		scopes     []string
+		permission string
+		status     int
+	}{
+		{"role grants", models.RoleListener, nil, models.PermissionTracksRead, http.StatusOK},
+		{"role denies", models.RoleListener, nil, models.PermissionTracksWrite, http.StatusForbidden},
+		{"admin grants", models.RoleAdmin, nil, models.PermissionUsersManage, http.StatusOK},
+		{"no role", "", nil, models.PermissionTracksRead, http.StatusForbidden},
+		{"key scope and role grant", models.RoleCurator, []string{models.PermissionTracksWrite}, models.PermissionTracksWrite, http.StatusOK},
+		{"key scope missing", models.RoleCurator, []string{models.PermissionTracksRead}, models.PermissionTracksWrite, http.StatusForbidden},
+		{"key without scopes", models.RoleAdmin, []string{}, models.PermissionTracksRead, http.StatusForbidden},
+		{"key scope beyond role", models.RoleListener, []string{models.PermissionTracksWrite}, models.PermissionTracksWrite, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := serveWithPermission(test.role, test.scopes, test.permission); status != test.status {
+				t.Errorf("status = %d, want %d", status, test.status)
+			}
+		})
+	}
+}
+
+func TestRequireUserSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, test := range []struct {
+		scopes []string
+		status int
+	}{
+		{nil, http.StatusOK},
+		{[]string{models.PermissionTracksRead}, http.StatusForbidden},
+	} {
+		router := gin.New()
+		router.GET("/", func(c *gin.Context) {
+			if test.scopes != nil {
+				c.Set(utils.ContextScopesKey, test.scopes)
+			}
+		}, RequireUserSession(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != test.status {
+			t.Errorf("scopes %v: status = %d, want %d", test.scopes, recorder.Code, test.status)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyUsageInterval is how often the last use of an API key is recorded at most
+const APIKeyUsageInterval = time.Minute
+
+// APIKey represents a long-lived credential used by machine clients
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`           // User the key acts on behalf of
+	Name       string             `bson:"name" json:"name"`                 // Human-readable label, e.g. "ingest script"
+	Prefix     string             `bson:"prefix" json:"prefix"`             // First characters of the key, shown to identify it
+	KeyHash    string             `bson:"key_hash" json:"-"`                // SHA-256 hash of the key, never serialized
+	Scopes     []string           `bson:"scopes" json:"scopes"`             // Permissions the key is limited to
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`     // When the key stops working
+	LastUsedAt *time.Time         `bson:"last_used_at" json:"last_used_at"` // When the key was last used
+	IsDeleted  bool               `bson:"is_deleted" json:"is_deleted"`     // Soft delete flag, set when the key is revoked
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`     // Creation timestamp
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`     // Last update timestamp
+	DeletedAt  *time.Time         `bson:"deleted_at" json:"deleted_at"`     // Revocation timestamp
+}
+
+// BeforeCreate sets the CreatedAt and UpdatedAt fields before creating a new API key
+func (k *APIKey) BeforeCreate() {
+	now := time.Now()
+	k.ID = primitive.NewObjectID()
+	k.CreatedAt = now
+	k.UpdatedAt = now
+	k.DeletedAt = nil
+	k.LastUsedAt = nil
+	k.IsDeleted = false
+}
+
+// BeforeUpdate sets the UpdatedAt field before updating an existing API key
+func (k *APIKey) BeforeUpdate() {
+	k.UpdatedAt = time.Now()
+}
+
+// SoftDelete sets the DeletedAt and IsDeleted fields to mark the API key as revoked
+func (k *APIKey) SoftDelete() {
+	now := time.Now()
+	k.UpdatedAt = now
+	k.DeletedAt = &now
+	k.IsDeleted = true
+}
+
+// IsExpired reports whether the API key has passed its expiry time
+func (k *APIKey) IsExpired() bool {
+	return time.Now().After(k.ExpiresAt)
+}
+
+// NeedsUsageRecorded reports whether the use of the API key at the given time should be recorded,
+// which is the case when its last recorded use is older than APIKeyUsageInterval
+func (k *APIKey) NeedsUsageRecorded(now time.Time) bool {
+	return k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= APIKeyUsageInterval
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeedsUsageRecorded(t *testing.T) {
+	now := time.Now()
+	at := func(ago time.Duration) *time.Time {
+		t := now.Add(-ago)
+		return &t
+	}
+
+	tests := []struct {
+		name       string
+		lastUsedAt *time.Time
+		want       bool
+	}{
+		{"never used", nil, true},
+		{"used just now", at(0), false},
+		{"used within the interval", at(APIKeyUsageInterval - time.Second), false},
+		{"used an interval ago", at(APIKeyUsageInterval), true},
+		{"used long ago", at(24 * time.Hour), true},
+	}
+
+	for _, test := range tests {
+		key := APIKey{LastUsedAt: test.lastUsedAt}
+		if got := key.NeedsUsageRecorded(now); got != test.want {
+			t.Errorf("%s: NeedsUsageRecorded = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
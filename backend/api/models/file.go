@@ -1,21 +1,78 @@
 package models
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Types of the entities owning files
+const (
+	FileOwnerTrack  = "track"  // A music track
+	FileOwnerUpload = "upload" // A finished resumable upload not used by a track yet
+	FileOwnerFile   = "file"   // Another file the owned file was derived from, e.g. the cover image of a thumbnail
+)
+
+// Roles a file plays for its owner
+const (
+	FileRoleCover   = "cover"   // Cover image
+	FileRoleAudio   = "audio"   // Audio file
+	FileRoleContent = "content" // Content of a resumable upload
+)
+
+// ThumbnailRole returns the role of a thumbnail of an image scaled down to fit the given size in pixels
+func ThumbnailRole(size int) string {
+	return "thumbnail_" + strconv.Itoa(size)
+}
+
+// RenditionRole returns the role of a rendition of a track's audio transcoded for streaming
+func RenditionRole(name string) string {
+	return "rendition_" + name
+}
+
+// hlsSegmentRolePrefix starts the roles of the HLS segments packaged from an audio file
+const hlsSegmentRolePrefix = "hls_"
+
+// HLSSegmentRole returns the role of a segment of an HLS variant packaged from an audio file, by its index in the variant
+func HLSSegmentRole(variant string, index int) string {
+	return hlsSegmentRolePrefix + variant + "_" + strconv.Itoa(index)
+}
+
+// IsHLSSegmentRole reports whether a role is that of an HLS segment
+func IsHLSSegmentRole(role string) bool {
+	return strings.HasPrefix(role, hlsSegmentRolePrefix)
+}
+
+// FileOwner links a file to an entity using it
+type FileOwner struct {
+	Type string             `bson:"owner_type" json:"owner_type"` // Type of the owning entity, e.g. "track"
+	ID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`     // ID of the owning entity
+	Role string             `bson:"role" json:"role"`             // Role of the file for the owner, e.g. "cover"
+}
+
 // File represents the metadata for an uploaded file
 type File struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Filename  string             `bson:"filename" json:"filename"`
-	Filepath  string             `bson:"filepath" json:"filepath"`
-	FileUrl   string             `bson:"file_url" json:"file_url"`
-	IsDeleted bool               `bson:"is_deleted" json:"is_deleted"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
-	DeletedAt *time.Time         `bson:"deleted_at" json:"deleted_at"`
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Filename   string             `bson:"filename" json:"filename"`
+	Filepath   string             `bson:"filepath" json:"filepath"`
+	FileUrl    string             `bson:"file_url" json:"file_url"`
+	Hash       string             `bson:"hash,omitempty" json:"hash,omitempty"`               // Hex SHA-256 of the content, which also names the stored object
+	Size       int64              `bson:"size" json:"size"`                                   // Size of the content in bytes
+	MimeType   string             `bson:"mime_type,omitempty" json:"mime_type,omitempty"`     // MIME type of the content
+	Owners     []FileOwner        `bson:"owners,omitempty" json:"owners,omitempty"`           // Entities using the file
+	ParentID   primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"`     // File this file was first derived from, e.g. the cover image of a thumbnail
+	Width      int                `bson:"width,omitempty" json:"width,omitempty"`             // Width of an image in pixels
+	Height     int                `bson:"height,omitempty" json:"height,omitempty"`           // Height of an image in pixels
+	RefCount   int64              `bson:"ref_count" json:"ref_count"`                         // Number of owners sharing the content
+	UploadedBy primitive.ObjectID `bson:"uploaded_by,omitempty" json:"uploaded_by,omitempty"` // User who first uploaded the file
+	TagFormat  string             `bson:"tag_format,omitempty" json:"tag_format,omitempty"`   // Format of the tags read from the file, e.g. "ID3v2.3"
+	Tags       map[string]string  `bson:"tags,omitempty" json:"tags,omitempty"`               // Raw text frames read from the file's tags, keyed by frame ID
+	IsDeleted  bool               `bson:"is_deleted" json:"is_deleted"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	DeletedAt  *time.Time         `bson:"deleted_at" json:"deleted_at"`
 }
 
 // BeforeCreate sets default values before creating a new file record
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HLSPackage is the packaging of an audio file for HLS streaming: AAC variants at several bitrates, each cut into
+// MPEG-TS segments stored as files derived from the audio file. Tracks sharing identical audio share its package
+type HLSPackage struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SourceFileID primitive.ObjectID `bson:"source_file_id" json:"source_file_id"` // Audio file packaged
+	Variants     []HLSVariant       `bson:"variants" json:"variants"`             // Variants, lowest bitrate first
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`         // When the audio file was packaged
+}
+
+// HLSVariant is a version of the packaged audio at one bitrate
+type HLSVariant struct {
+	Name             string       `bson:"name" json:"name"`                           // Name of the variant, e.g. "aac-128k"
+	Bitrate          int          `bson:"bitrate" json:"bitrate"`                     // Target bitrate in kbit/s
+	Bandwidth        int          `bson:"bandwidth" json:"bandwidth"`                 // Peak bitrate of the segments in bit/s
+	AverageBandwidth int          `bson:"average_bandwidth" json:"average_bandwidth"` // Average bitrate of the segments in bit/s
+	Codecs           string       `bson:"codecs" json:"codecs"`                       // RFC 6381 codec strings, e.g. "mp4a.40.2"
+	Segments         []HLSSegment `bson:"segments" json:"segments"`                   // Segments in playback order
+}
+
+// HLSSegment is a segment of an HLS variant
+type HLSSegment struct {
+	Duration float64            `bson:"duration" json:"duration"` // Duration of the segment in seconds
+	Size     int64              `bson:"size" json:"size"`         // Size of the segment file in bytes
+	FileID   primitive.ObjectID `bson:"file_id" json:"file_id"`   // File holding the segment
+}
+
+// BeforeCreate sets default values before creating a new HLS package
+func (p *HLSPackage) BeforeCreate() {
+	p.ID = primitive.NewObjectID()
+	p.CreatedAt = time.Now()
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Play represents one listen of a track, recorded from playback
+type Play struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`                     // User who played the track
+	TrackID          primitive.ObjectID `bson:"track_id" json:"track_id"`                   // Track that was played
+	DeviceID         string             `bson:"device_id" json:"device_id"`                 // Device the track was played on
+	StartedAt        time.Time          `bson:"started_at" json:"started_at"`               // When the track started playing
+	ListenedDuration float64            `bson:"listened_duration" json:"listened_duration"` // Seconds actually spent playing
+	Completed        bool               `bson:"completed" json:"completed"`                 // Whether playback reached the end of the track
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`               // Creation timestamp
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`               // Last update timestamp
+}
+
+// BeforeCreate sets the CreatedAt and UpdatedAt fields before creating a new play
+func (p *Play) BeforeCreate() {
+	now := time.Now()
+	p.ID = primitive.NewObjectID()
+	p.CreatedAt = now
+	p.UpdatedAt = now
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Playback states of a session
+const (
+	PlaybackStatePlaying = "playing" // The current track is playing
+	PlaybackStatePaused  = "paused"  // The current track is paused at the recorded position
+	PlaybackStateStopped = "stopped" // Nothing is playing; the position is reset
+)
+
+// Playback actions accepted by the play endpoint
+const (
+	PlaybackActionPlay     = "play"     // Start or resume a track
+	PlaybackActionPause    = "pause"    // Pause the current track
+	PlaybackActionSeek     = "seek"     // Move to a position in the current track
+	PlaybackActionStop     = "stop"     // Stop playback
+	PlaybackActionNext     = "next"     // Skip to the next track of the queue
+	PlaybackActionPrevious = "previous" // Go back to the previous track of the queue
+)
+
+// PlaybackSession represents the playback state of a user on one device
+type PlaybackSession struct {
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     primitive.ObjectID   `bson:"user_id" json:"user_id"`                     // User the session belongs to
+	DeviceID   string               `bson:"device_id" json:"device_id"`                 // Device the session is played on
+	TrackID    primitive.ObjectID   `bson:"track_id" json:"track_id"`                   // Track currently loaded
+	Queue      []primitive.ObjectID `bson:"queue" json:"queue"`                         // Tracks that next and previous move through
+	QueueIndex int                  `bson:"queue_index" json:"queue_index"`             // Index of the current track in the queue
+	State      string               `bson:"state" json:"state"`                         // One of the playback states
+	Position   float64              `bson:"position" json:"position"`                   // Position in seconds when the state last changed
+	StartedAt  *time.Time           `bson:"started_at" json:"started_at"`               // When the current track started playing
+	PlayID     primitive.ObjectID   `bson:"play_id,omitempty" json:"play_id,omitempty"` // Play recorded for the current track
+	Listened   float64              `bson:"listened" json:"listened"`                   // Seconds the current track has played so far
+	CreatedAt  time.Time            `bson:"created_at" json:"created_at"`               // Creation timestamp
+	UpdatedAt  time.Time            `bson:"updated_at" json:"updated_at"`               // When the state last changed
+}
+
+// BeforeCreate sets the CreatedAt and UpdatedAt fields before creating a new session
+func (s *PlaybackSession) BeforeCreate() {
+	now := time.Now()
+	s.ID = primitive.NewObjectID()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+}
+
+// BeforeUpdate sets the UpdatedAt field before updating an existing session
+func (s *PlaybackSession) BeforeUpdate() {
+	s.UpdatedAt = time.Now()
+}
+
+// CurrentPosition returns the position at the given time, advancing it while the track is playing
+// and capping it at the track duration in seconds
+func (s *PlaybackSession) CurrentPosition(now time.Time, duration int) float64 {
+	position := s.Position
+	if s.State == PlaybackStatePlaying {
+		position += now.Sub(s.UpdatedAt).Seconds() // Add the time played since the state last changed
+	}
+	if duration > 0 && position > float64(duration) {
+		position = float64(duration)
+	}
+	return position
+}
+
+// IsValidPlaybackAction reports whether the action is accepted by the play endpoint
+func IsValidPlaybackAction(action string) bool {
+	switch action {
+	case PlaybackActionPlay, PlaybackActionPause, PlaybackActionSeek, PlaybackActionStop, PlaybackActionNext, PlaybackActionPrevious:
+		return true
+	}
+	return false
+}
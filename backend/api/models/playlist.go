@@ -6,15 +6,73 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Playlist visibility settings
+const (
+	PlaylistVisibilityPrivate  = "private"  // Only the owner can see the playlist
+	PlaylistVisibilityUnlisted = "unlisted" // Anyone with the ID can see the playlist, but it is not listed or searchable
+	PlaylistVisibilityPublic   = "public"   // Everyone can see, list and search the playlist
+)
+
+// Operators of the rules of smart playlists
+const (
+	PlaylistRuleAnd        = "and"         // Group matching the tracks every sub-rule matches
+	PlaylistRuleOr         = "or"          // Group matching the tracks any sub-rule matches
+	PlaylistRuleEq         = "eq"          // Field equals the value
+	PlaylistRuleNe         = "ne"          // Field differs from the value
+	PlaylistRuleGt         = "gt"          // Field is greater than, or after, the value
+	PlaylistRuleGte        = "gte"         // Field is greater than or equal to the value
+	PlaylistRuleLt         = "lt"          // Field is less than, or before, the value
+	PlaylistRuleLte        = "lte"         // Field is less than or equal to the value
+	PlaylistRuleBetween    = "between"     // Field lies within a list of two inclusive bounds
+	PlaylistRuleIn         = "in"          // Field equals any value of a list
+	PlaylistRuleNotIn      = "not_in"      // Field equals no value of a list
+	PlaylistRuleContains   = "contains"    // Text field contains the value, ignoring case
+	PlaylistRuleStartsWith = "starts_with" // Text field starts with the value, ignoring case
+	PlaylistRuleInLast     = "in_last"     // Date field lies within the given number of days before now
+)
+
+// Sort orders of smart playlists
+const (
+	PlaylistSortAsc  = "asc"  // Lowest values, or earliest dates, first
+	PlaylistSortDesc = "desc" // Highest values, or latest dates, first
+)
+
+// PlaylistRules defines the tracks of a smart playlist, which are resolved from the catalog whenever the playlist
+// is read instead of being stored
+type PlaylistRules struct {
+	Match     *PlaylistRule `bson:"match" json:"match"`           // Rule tree the tracks must match, every track if nil
+	SortBy    string        `bson:"sort_by" json:"sort_by"`       // Track field the tracks are sorted by, the date they were added if empty
+	SortOrder string        `bson:"sort_order" json:"sort_order"` // One of the PlaylistSort values, ascending if empty
+	Limit     int           `bson:"limit" json:"limit"`           // Maximum number of tracks, unlimited if 0
+}
+
+// PlaylistRule is a node of the rule tree of a smart playlist: either a group combining its sub-rules with "and"
+// or "or", or a condition comparing a track field with a value
+type PlaylistRule struct {
+	Operator string         `bson:"operator" json:"operator"`               // One of the PlaylistRule operators
+	Rules    []PlaylistRule `bson:"rules,omitempty" json:"rules,omitempty"` // Sub-rules of a group
+	Field    string         `bson:"field,omitempty" json:"field,omitempty"` // Track field a condition compares, by its JSON name
+	Value    interface{}    `bson:"value" json:"value,omitempty"`           // Value a condition compares the field with
+}
+
 // Playlist represents a playlist in the library
 type Playlist struct {
-	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
-	Name      string               `bson:"name" json:"name" binding:"required"`
-	Tracks    []primitive.ObjectID `bson:"tracks" json:"tracks"`         // Tracks in the playlist
-	IsDeleted bool                 `bson:"is_deleted" json:"is_deleted"` // Soft delete flag
-	CreatedAt time.Time            `bson:"created_at" json:"created_at"` // Creation timestamp
-	UpdatedAt time.Time            `bson:"updated_at" json:"updated_at"` // Last update timestamp
-	DeletedAt *time.Time           `bson:"deleted_at" json:"deleted_at"` // Deletion timestamp
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	Name       string               `bson:"name" json:"name" binding:"required"`
+	Tracks     []primitive.ObjectID `bson:"tracks" json:"tracks"`         // Tracks in the playlist
+	OwnerID    primitive.ObjectID   `bson:"owner_id" json:"owner_id"`     // User who owns the playlist
+	Visibility string               `bson:"visibility" json:"visibility"` // One of the PlaylistVisibility values
+	IsDeleted  bool                 `bson:"is_deleted" json:"is_deleted"` // Soft delete flag
+	CreatedAt  time.Time            `bson:"created_at" json:"created_at"` // Creation timestamp
+	UpdatedAt  time.Time            `bson:"updated_at" json:"updated_at"` // Last update timestamp
+	DeletedAt  *time.Time           `bson:"deleted_at" json:"deleted_at"` // Deletion timestamp
+
+	// Ordering of the tracks
+	AllowDuplicates bool  `bson:"allow_duplicates" json:"allow_duplicates"` // Whether a track may appear more than once, e.g. in a DJ set
+	Version         int64 `bson:"version" json:"version"`                   // Incremented on every change of the tracks, to detect concurrent edits
+
+	// Rules of smart playlists, whose tracks follow the catalog and cannot be edited
+	Rules *PlaylistRules `bson:"rules,omitempty" json:"rules,omitempty"`
 }
 
 // BeforeCreate sets the CreatedAt, UpdatedAt fields and initializes Tracks before creating a new playlist
@@ -26,6 +84,47 @@ func (p *Playlist) BeforeCreate() {
 	p.DeletedAt = nil
 	p.IsDeleted = false
 	p.Tracks = []primitive.ObjectID{} // Initialize Tracks as an empty array
+	if p.Visibility == "" {
+		p.Visibility = PlaylistVisibilityPrivate // Playlists are private unless requested otherwise
+	}
+}
+
+// IsOwnedBy reports whether the given user owns the playlist
+func (p *Playlist) IsOwnedBy(userID primitive.ObjectID) bool {
+	return !p.OwnerID.IsZero() && p.OwnerID == userID
+}
+
+// IsSmart reports whether the tracks of the playlist are defined by rules rather than stored
+func (p *Playlist) IsSmart() bool {
+	return p.Rules != nil
+}
+
+// TrackIndex returns the index of the first occurrence of a track in the playlist, or -1 if it is not in it
+func (p *Playlist) TrackIndex(trackID primitive.ObjectID) int {
+	for i, id := range p.Tracks {
+		if id == trackID {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasDuplicateTracks reports whether a track appears more than once in the playlist
+func (p *Playlist) HasDuplicateTracks() bool {
+	seen := make(map[primitive.ObjectID]bool, len(p.Tracks))
+	for _, id := range p.Tracks {
+		if seen[id] {
+			return true
+		}
+		seen[id] = true
+	}
+	return false
+}
+
+// IsVisibleTo reports whether the given user may view the playlist
+func (p *Playlist) IsVisibleTo(userID primitive.ObjectID) bool {
+	// Playlists created before visibility existed have no setting and stay public
+	return p.IsOwnedBy(userID) || p.Visibility != PlaylistVisibilityPrivate
 }
 
 // BeforeUpdate sets the UpdatedAt field before updating an existing playlist
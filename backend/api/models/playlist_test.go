@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHasDuplicateTracks(t *testing.T) {
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		tracks     []primitive.ObjectID
+		duplicates bool
+	}{
+		{nil, false},
+		{[]primitive.ObjectID{a}, false},
+		{[]primitive.ObjectID{a, b}, false},
+		{[]primitive.ObjectID{a, b, a}, true},
+		{[]primitive.ObjectID{b, b}, true},
+	}
+	for _, test := range tests {
+		playlist := &Playlist{Tracks: test.tracks}
+		if got := playlist.HasDuplicateTracks(); got != test.duplicates {
+			t.Errorf("HasDuplicateTracks(%v) = %v, want %v", test.tracks, got, test.duplicates)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+// Roles a user can be assigned
+const (
+	RoleAdmin    = "admin"    // Full access, including user management
+	RoleCurator  = "curator"  // Manages the catalog of tracks and genres
+	RoleListener = "listener" // Reads the catalog and manages their own playlists
+)
+
+// Permissions checked by the route groups
+const (
+	PermissionTracksRead     = "tracks:read"     // View, list, search and play tracks
+	PermissionTracksWrite    = "tracks:write"    // Add, update and delete tracks
+	PermissionTracksManage   = "tracks:manage"   // Re-analyze the audio of tracks
+	PermissionGenresRead     = "genres:read"     // View and list genres
+	PermissionGenresWrite    = "genres:write"    // Add, update and delete genres
+	PermissionPlaylistsRead  = "playlists:read"  // View, list and search playlists
+	PermissionPlaylistsWrite = "playlists:write" // Create playlists and manage their own playlists
+	PermissionFilesRead      = "files:read"      // List uploaded files
+	PermissionFilesManage    = "files:manage"    // Collect unused files
+	PermissionUsersManage    = "users:manage"    // List users and assign roles
+)
+
+// rolePermissions is the permission matrix granting each role its permissions
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionTracksRead, PermissionTracksWrite, PermissionTracksManage,
+		PermissionGenresRead, PermissionGenresWrite,
+		PermissionPlaylistsRead, PermissionPlaylistsWrite,
+		PermissionFilesRead, PermissionFilesManage,
+		PermissionUsersManage,
+	},
+	RoleCurator: {
+		PermissionTracksRead, PermissionTracksWrite,
+		PermissionGenresRead, PermissionGenresWrite,
+		PermissionPlaylistsRead, PermissionPlaylistsWrite,
+		PermissionFilesRead,
+	},
+	RoleListener: {
+		PermissionTracksRead,
+		PermissionGenresRead,
+		PermissionPlaylistsRead, PermissionPlaylistsWrite,
+	},
+}
+
+// IsValidRole reports whether the role exists in the permission matrix
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// IsValidPermission reports whether the permission exists in the permission matrix
+func IsValidPermission(permission string) bool {
+	return HasPermission(RoleAdmin, permission) // Admins are granted every permission
+}
+
+// HasPermission reports whether the role is granted the permission
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions returns the permissions granted to the role
+func RolePermissions(role string) []string {
+	permissions := make([]string, len(rolePermissions[role]))
+	copy(permissions, rolePermissions[role]) // Copy so callers cannot modify the matrix
+	return permissions
+}
@@ -0,0 +1,70 @@
+package models
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	permissions := []string{
+		PermissionTracksRead, PermissionTracksWrite, PermissionTracksManage,
+		PermissionGenresRead, PermissionGenresWrite,
+		PermissionPlaylistsRead, PermissionPlaylistsWrite,
+		PermissionFilesRead, PermissionFilesManage,
+		PermissionUsersManage,
+	}
+	granted := map[string]map[string]bool{
+		RoleAdmin: {
+			PermissionTracksRead: true, PermissionTracksWrite: true, PermissionTracksManage: true,
+			PermissionGenresRead: true, PermissionGenresWrite: true,
+			PermissionPlaylistsRead: true, PermissionPlaylistsWrite: true,
+			PermissionFilesRead: true, PermissionFilesManage: true,
+			PermissionUsersManage: true,
+		},
+		RoleCurator: {
+			PermissionTracksRead: true, PermissionTracksWrite: true,
+			PermissionGenresRead: true, PermissionGenresWrite: true,
+			PermissionPlaylistsRead: true, PermissionPlaylistsWrite: true,
+			PermissionFilesRead: true,
+		},
+		RoleListener: {
+			PermissionTracksRead:    true,
+			PermissionGenresRead:    true,
+			PermissionPlaylistsRead: true, PermissionPlaylistsWrite: true,
+		},
+		"":        {}, // Requests without a role
+		"unknown": {},
+	}
+
+	for role, allowed := range granted {
+		for _, permission := range permissions {
+			if got := HasPermission(role, permission); got != allowed[permission] {
+				t.Errorf("HasPermission(%q, %q) = %v, want %v", role, permission, got, allowed[permission])
+			}
+		}
+		if HasPermission(role, "tracks:unknown") {
+			t.Errorf("HasPermission(%q, unknown permission) = true", role)
+		}
+	}
+}
+
+func TestIsValidRole(t *testing.T) {
+	for role, want := range map[string]bool{RoleAdmin: true, RoleCurator: true, RoleListener: true, "": false, "Admin": false} {
+		if got := IsValidRole(role); got != want {
+			t.Errorf("IsValidRole(%q) = %v, want %v", role, got, want)
+		}
+	}
+}
+
+func TestIsValidPermission(t *testing.T) {
+	for permission, want := range map[string]bool{PermissionTracksRead: true, PermissionUsersManage: true, "": false, "tracks": false} {
+		if got := IsValidPermission(permission); got != want {
+			t.Errorf("IsValidPermission(%q) = %v, want %v", permission, got, want)
+		}
+	}
+}
+
+func TestRolePermissionsIsACopy(t *testing.T) {
+	permissions := RolePermissions(RoleListener)
+	permissions[0] = PermissionUsersManage
+	if HasPermission(RoleListener, PermissionUsersManage) {
+		t.Error("modifying the returned permissions changed the matrix")
+	}
+}
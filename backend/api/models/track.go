@@ -6,6 +6,50 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ThumbnailSizes are the sizes in pixels of the thumbnails generated for every cover image
+var ThumbnailSizes = []int{64, 256, 1024}
+
+// Thumbnail is a rendition of a track's cover image scaled down to fit a square
+type Thumbnail struct {
+	Size   int                `bson:"size" json:"size"`       // Size in pixels of the square the thumbnail fits in
+	FileID primitive.ObjectID `bson:"file_id" json:"file_id"` // File record of the thumbnail
+}
+
+// Rendition is a version of a track's audio transcoded for streaming, owned by the track
+type Rendition struct {
+	Name     string             `bson:"name" json:"name"`           // Name of the configured rendition, e.g. "mp3-128k"
+	Codec    string             `bson:"codec" json:"codec"`         // Codec of the rendition, e.g. "opus"
+	Bitrate  int                `bson:"bitrate" json:"bitrate"`     // Target bitrate in kbit/s
+	MimeType string             `bson:"mime_type" json:"mime_type"` // MIME type of the rendition, e.g. "audio/opus"
+	FileID   primitive.ObjectID `bson:"file_id" json:"file_id"`     // File record of the rendition
+}
+
+// AudioAsset is the audio file of a track along with the properties of its stream, whatever its format
+type AudioAsset struct {
+	FileID      primitive.ObjectID `bson:"file_id,omitempty" json:"file_id,omitempty"`           // File record of the audio file
+	FileUrl     string             `bson:"file_url" json:"file_url"`                             // Stored URL of the audio file
+	MimeType    string             `bson:"mime_type,omitempty" json:"mime_type,omitempty"`       // MIME type detected from the content, e.g. "audio/flac"
+	Container   string             `bson:"container,omitempty" json:"container,omitempty"`       // Container of the stream, e.g. "mpeg", "mp4" or "ogg"
+	Codec       string             `bson:"codec,omitempty" json:"codec,omitempty"`               // Codec of the stream, e.g. "mp3", "aac" or "flac"
+	BitDepth    int                `bson:"bit_depth,omitempty" json:"bit_depth,omitempty"`       // Bits per sample of lossless and PCM streams
+	Bitrate     int                `bson:"bitrate,omitempty" json:"bitrate,omitempty"`           // Average bitrate in kbit/s
+	SampleRate  int                `bson:"sample_rate,omitempty" json:"sample_rate,omitempty"`   // Sample rate in Hz
+	Channels    int                `bson:"channels,omitempty" json:"channels,omitempty"`         // Number of channels
+	ChannelMode string             `bson:"channel_mode,omitempty" json:"channel_mode,omitempty"` // Channel mode, e.g. "joint_stereo"
+}
+
+// Loudness is the loudness of a track's audio measured per EBU R128, along with the ReplayGain 2.0 gains bringing
+// the track, or the album it belongs to, to the reference loudness of -18 LUFS
+type Loudness struct {
+	Integrated  float64   `bson:"integrated" json:"integrated"`                     // Integrated loudness in LUFS
+	TruePeak    float64   `bson:"true_peak" json:"true_peak"`                       // True peak in dBTP
+	GatedBlocks int       `bson:"gated_blocks" json:"gated_blocks"`                 // Number of 400 ms blocks measured, weighting the track in its album
+	TrackGain   float64   `bson:"track_gain" json:"track_gain"`                     // Gain in dB normalizing the track
+	AlbumGain   *float64  `bson:"album_gain,omitempty" json:"album_gain,omitempty"` // Gain in dB normalizing the album as a whole, nil for tracks without an album
+	AlbumPeak   *float64  `bson:"album_peak,omitempty" json:"album_peak,omitempty"` // Highest true peak of the album's tracks in dBTP
+	AnalyzedAt  time.Time `bson:"analyzed_at" json:"analyzed_at"`                   // When the audio file was analyzed
+}
+
 // Track represents a music track in the library
 type Track struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
@@ -15,18 +59,27 @@ type Track struct {
 	Album         string             `bson:"album" json:"album"`
 	Genre         string             `bson:"genre" json:"genre"`
 	ReleaseYear   int                `bson:"release_year" json:"release_year"`
-	Duration      int                `bson:"duration" json:"duration" binding:"required"` // Duration in seconds
-	Mp3FileUrl    string             `bson:"mp3_file_url" json:"mp3_file_url"`
-	IsDeleted     bool               `bson:"is_deleted" json:"is_deleted"` // Soft delete flag
-	CreatedAt     time.Time          `bson:"created_at" json:"created_at"` // Creation timestamp
-	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"` // Last update timestamp
-	DeletedAt     *time.Time         `bson:"deleted_at" json:"deleted_at"` // Deletion timestamp
+	Duration      int                `bson:"duration" json:"duration" binding:"required"`            // Duration in seconds
+	CoverFileID   primitive.ObjectID `bson:"cover_file_id,omitempty" json:"cover_file_id,omitempty"` // File record of the cover image
+	Thumbnails    []Thumbnail        `bson:"thumbnails,omitempty" json:"thumbnails,omitempty"`       // Renditions of the cover image in ThumbnailSizes
+	Audio         AudioAsset         `bson:"audio" json:"audio"`                                     // Audio file of the track, in any supported format
+	Renditions    []Rendition        `bson:"renditions,omitempty" json:"renditions,omitempty"`       // Versions of the audio transcoded for streaming
+	Loudness      *Loudness          `bson:"loudness,omitempty" json:"loudness,omitempty"`           // Loudness of the audio, nil until it is analyzed
+	CreatedBy     primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`       // User who added the track
+	PlayCount     int64              `bson:"play_count" json:"play_count"`                           // Number of times the track was played
+	LastPlayedAt  *time.Time         `bson:"last_played_at" json:"last_played_at"`                   // When the track was last played
+	IsDeleted     bool               `bson:"is_deleted" json:"is_deleted"`                           // Soft delete flag
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`                           // Creation timestamp
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`                           // Last update timestamp
+	DeletedAt     *time.Time         `bson:"deleted_at" json:"deleted_at"`                           // Deletion timestamp
 }
 
 // BeforeCreate sets the CreatedAt and UpdatedAt fields before creating a new track
 func (t *Track) BeforeCreate() {
 	now := time.Now()
-	t.ID = primitive.NewObjectID()
+	if t.ID.IsZero() {
+		t.ID = primitive.NewObjectID() // Keep an ID assigned in advance, e.g. to link uploaded files to the track
+	}
 	t.CreatedAt = now
 	t.UpdatedAt = now
 	t.DeletedAt = nil
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a transcoding job
+const (
+	TranscodeStatusQueued   = "queued"   // Waiting for a worker
+	TranscodeStatusRunning  = "running"  // Being transcoded by a worker
+	TranscodeStatusDone     = "done"     // The rendition is stored and linked to the track
+	TranscodeStatusFailed   = "failed"   // Transcoding failed on every attempt
+	TranscodeStatusCanceled = "canceled" // The track's audio changed or the track was deleted before the rendition was linked
+)
+
+// Kinds of transcoding jobs
+const (
+	TranscodeKindRendition = "rendition" // Transcodes a rendition streamed as a single file
+	TranscodeKindHLS       = "hls"       // Packages the audio in segmented variants for HLS streaming
+	TranscodeKindWaveform  = "waveform"  // Computes the peaks of the audio drawn as its waveform
+	TranscodeKindLoudness  = "loudness"  // Measures the loudness of the audio and the ReplayGain gains of the track and its album
+)
+
+// TranscodeJob represents the transcoding of a track's audio file into one of the configured renditions, its
+// packaging for HLS, or the computation of its waveform or loudness
+type TranscodeJob struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TrackID      primitive.ObjectID `bson:"track_id" json:"track_id"`                   // Track the rendition is linked to
+	SourceFileID primitive.ObjectID `bson:"source_file_id" json:"source_file_id"`       // Audio file transcoded
+	Kind         string             `bson:"kind" json:"kind"`                           // One of the kinds of transcoding jobs
+	Rendition    string             `bson:"rendition" json:"rendition"`                 // Name of the configured rendition, e.g. "mp3-128k"
+	Codec        string             `bson:"codec" json:"codec"`                         // Codec transcoded to
+	Bitrate      int                `bson:"bitrate" json:"bitrate"`                     // Target bitrate in kbit/s
+	Status       string             `bson:"status" json:"status"`                       // One of the transcoding statuses
+	Attempts     int                `bson:"attempts" json:"attempts"`                   // Number of times a worker started the job
+	Error        string             `bson:"error,omitempty" json:"error,omitempty"`     // Why the last attempt failed
+	FileID       primitive.ObjectID `bson:"file_id,omitempty" json:"file_id,omitempty"` // File the rendition was stored as
+	StartedAt    *time.Time         `bson:"started_at,omitempty" json:"started_at"`     // When the last attempt started
+	FinishedAt   *time.Time         `bson:"finished_at,omitempty" json:"finished_at"`   // When the job finished, failed or was canceled
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`               // When the job was queued
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`               // Last update timestamp
+}
+
+// BeforeCreate sets default values before creating a new transcoding job
+func (j *TranscodeJob) BeforeCreate() {
+	now := time.Now()
+	j.ID = primitive.NewObjectID()
+	j.Status = TranscodeStatusQueued
+	j.CreatedAt = now
+	j.UpdatedAt = now
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upload represents a resumable upload, received in chunks and finished into a file
+type Upload struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`                     // User creating the upload
+	Length    int64              `bson:"length" json:"length"`                       // Declared size of the content in bytes
+	Offset    int64              `bson:"offset" json:"offset"`                       // Bytes received so far
+	Filename  string             `bson:"filename,omitempty" json:"filename"`         // Original filename, from the upload metadata
+	MimeType  string             `bson:"mime_type,omitempty" json:"mime_type"`       // MIME type declared in the upload metadata
+	Metadata  map[string]string  `bson:"metadata,omitempty" json:"metadata"`         // Decoded upload metadata
+	Chunks    []UploadChunk      `bson:"chunks,omitempty" json:"-"`                  // Stored chunks, in order
+	HashState []byte             `bson:"hash_state,omitempty" json:"-"`              // SHA-256 state after the received bytes
+	FileID    primitive.ObjectID `bson:"file_id,omitempty" json:"file_id,omitempty"` // File the finished upload was stored as
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`               // When an unfinished or unused upload is discarded
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// UploadChunk is a chunk of a resumable upload, stored as its own object until the upload is finished
+type UploadChunk struct {
+	Key    string `bson:"key"`    // Storage key of the chunk
+	Offset int64  `bson:"offset"` // Offset of the chunk in the content
+	Size   int64  `bson:"size"`   // Size of the chunk in bytes
+}
+
+// BeforeCreate sets default values before creating a new upload
+func (u *Upload) BeforeCreate() {
+	now := time.Now()
+	u.ID = primitive.NewObjectID()
+	u.CreatedAt = now
+	u.UpdatedAt = now
+}
+
+// BeforeUpdate sets the updated_at field before updating an upload
+func (u *Upload) BeforeUpdate() {
+	u.UpdatedAt = time.Now()
+}
+
+// IsFinished reports whether all of the content was received and stored as a file
+func (u *Upload) IsFinished() bool {
+	return !u.FileID.IsZero()
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User represents an account that can authenticate against the API
+type User struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Email        string             `bson:"email" json:"email" binding:"required"`
+	Name         string             `bson:"name" json:"name"`
+	PasswordHash string             `bson:"password_hash" json:"-"`       // Bcrypt hash of the password, never serialized
+	Role         string             `bson:"role" json:"role"`             // One of the Role values
+	IsDeleted    bool               `bson:"is_deleted" json:"is_deleted"` // Soft delete flag
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"` // Creation timestamp
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"` // Last update timestamp
+	DeletedAt    *time.Time         `bson:"deleted_at" json:"deleted_at"` // Deletion timestamp
+}
+
+// BeforeCreate sets the CreatedAt and UpdatedAt fields before creating a new user
+func (u *User) BeforeCreate() {
+	now := time.Now()
+	u.ID = primitive.NewObjectID()
+	u.CreatedAt = now
+	u.UpdatedAt = now
+	u.DeletedAt = nil
+	u.IsDeleted = false
+	if u.Role == "" {
+		u.Role = RoleListener // New users are listeners unless an admin assigns another role
+	}
+}
+
+// EffectiveRole returns the user's role, treating users created before roles existed as listeners
+func (u *User) EffectiveRole() string {
+	if u.Role == "" {
+		return RoleListener
+	}
+	return u.Role
+}
+
+// BeforeUpdate sets the UpdatedAt field before updating an existing user
+func (u *User) BeforeUpdate() {
+	u.UpdatedAt = time.Now()
+}
+
+// SoftDelete sets the DeletedAt and IsDeleted fields to mark the user as deleted
+func (u *User) SoftDelete() {
+	now := time.Now()
+	u.UpdatedAt = now
+	u.DeletedAt = &now
+	u.IsDeleted = true
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WaveformResolutions are the numbers of pixels of the waveforms computed for every audio file
+var WaveformResolutions = []int{256, 1024, 4096}
+
+// Waveform is the peak data of an audio file mixed down to mono, computed at each of the WaveformResolutions.
+// Tracks sharing identical audio share its waveform
+type Waveform struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SourceFileID primitive.ObjectID `bson:"source_file_id" json:"source_file_id"` // Audio file the peaks were computed from
+	SampleRate   int                `bson:"sample_rate" json:"sample_rate"`       // Sample rate of the decoded audio in Hz
+	Levels       []WaveformLevel    `bson:"levels" json:"levels"`                 // Peak data, lowest resolution first
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`         // When the peaks were computed
+}
+
+// WaveformLevel is the peak data of an audio file at one resolution
+type WaveformLevel struct {
+	Resolution      int    `bson:"resolution" json:"resolution"`               // Maximum number of pixels requested
+	SamplesPerPixel int    `bson:"samples_per_pixel" json:"samples_per_pixel"` // Frames summarized by each pixel
+	Length          int    `bson:"length" json:"length"`                       // Number of pixels
+	Data            []int8 `bson:"data" json:"data"`                           // Minimum and maximum of every pixel as 8-bit values
+}
+
+// BeforeCreate sets default values before creating a new waveform
+func (w *Waveform) BeforeCreate() {
+	w.ID = primitive.NewObjectID()
+	w.CreatedAt = time.Now()
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyRoutes sets up the routes for the API key endpoints
+func APIKeyRoutes(router *gin.Engine, apiKeyController *controllers.APIKeyController, authMiddleware gin.HandlerFunc) {
+	// Group API key routes behind the auth middleware; keys are managed with a user session only
+	keys := router.Group("/api/keys", authMiddleware, middleware.RequireUserSession())
+	{
+		// Create a new API key
+		keys.POST("/", apiKeyController.CreateAPIKey)
+
+		// List the user's API keys
+		keys.GET("/", apiKeyController.ListAPIKeys)
+
+		// Revoke an API key
+		keys.DELETE("/:keyId", apiKeyController.RevokeAPIKey)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"music-library-management/api/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthRoutes sets up the routes for the authentication endpoints
+func AuthRoutes(router *gin.Engine, authController *controllers.AuthController, authMiddleware gin.HandlerFunc) {
+	// Group auth routes
+	auth := router.Group("/api/auth")
+	{
+		// Register a new user
+		auth.POST("/register", authController.Register)
+
+		// Log in with email and password
+		auth.POST("/login", authController.Login)
+
+		// Exchange a refresh token for a new token pair
+		auth.POST("/refresh", authController.Refresh)
+
+		// Retrieve the authenticated user
+		auth.GET("/me", authMiddleware, authController.Me)
+	}
+}
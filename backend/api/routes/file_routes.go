@@ -2,16 +2,21 @@ package routes
 
 import (
 	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // FileRoutes sets up the routes for the file-related endpoints
-func FileRoutes(router *gin.Engine, fileController *controllers.FileController) {
-	// Group file routes
-	files := router.Group("/api/files")
+func FileRoutes(router *gin.Engine, fileController *controllers.FileController, authMiddleware gin.HandlerFunc) {
+	// Group file routes behind the auth middleware
+	files := router.Group("/api/files", authMiddleware)
 	{
 		// List all files
-		files.GET("/", fileController.ListFiles)
+		files.GET("/", middleware.RequirePermission(models.PermissionFilesRead), fileController.ListFiles)
+
+		// Collect the files no live track uses, optionally as a dry run
+		files.POST("/gc", middleware.RequirePermission(models.PermissionFilesManage), fileController.CollectGarbage)
 	}
 }
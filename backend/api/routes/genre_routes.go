@@ -2,28 +2,30 @@ package routes
 
 import (
 	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GenreRoutes sets up the routes for the genre-related endpoints
-func GenreRoutes(router *gin.Engine, genreController *controllers.GenreController) {
-	// Group genre routes
-	genres := router.Group("/api/genres")
+func GenreRoutes(router *gin.Engine, genreController *controllers.GenreController, authMiddleware gin.HandlerFunc) {
+	// Group genre routes behind the auth middleware
+	genres := router.Group("/api/genres", authMiddleware)
 	{
 		// Add a new genre
-		genres.POST("/", genreController.AddGenre)
+		genres.POST("/", middleware.RequirePermission(models.PermissionGenresWrite), genreController.AddGenre)
 
 		// List all genres
-		genres.GET("/", genreController.ListGenres)
+		genres.GET("/", middleware.RequirePermission(models.PermissionGenresRead), genreController.ListGenres)
 
 		// Retrieve a genre by ID
-		genres.GET("/:genreId", genreController.GetGenre)
+		genres.GET("/:genreId", middleware.RequirePermission(models.PermissionGenresRead), genreController.GetGenre)
 
 		// Update a genre by ID
-		genres.PUT("/:genreId", genreController.UpdateGenre)
+		genres.PUT("/:genreId", middleware.RequirePermission(models.PermissionGenresWrite), genreController.UpdateGenre)
 
 		// Delete a genre by ID
-		genres.DELETE("/:genreId", genreController.DeleteGenre)
+		genres.DELETE("/:genreId", middleware.RequirePermission(models.PermissionGenresWrite), genreController.DeleteGenre)
 	}
 }
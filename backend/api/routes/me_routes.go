@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MeRoutes sets up the routes for the endpoints scoped to the authenticated user
+func MeRoutes(router *gin.Engine, playController *controllers.PlayController, authMiddleware gin.HandlerFunc) {
+	// Group the authenticated user's routes behind the auth middleware
+	me := router.Group("/api/me", authMiddleware)
+	{
+		// List the play history of the authenticated user with pagination and date-range filters
+		me.GET("/history", middleware.RequirePermission(models.PermissionTracksRead), playController.ListHistory)
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaybackRoutes sets up the routes for the playback-related endpoints
+func PlaybackRoutes(router *gin.Engine, playbackController *controllers.PlaybackController, authMiddleware gin.HandlerFunc) {
+	// Group playback routes behind the auth middleware
+	playback := router.Group("/api/playback", authMiddleware)
+	{
+		// Read the playback state of a device, or of the most recently used device
+		playback.GET("/", middleware.RequirePermission(models.PermissionTracksRead), playbackController.GetPlayback)
+	}
+}
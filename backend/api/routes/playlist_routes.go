@@ -2,34 +2,42 @@ package routes
 
 import (
 	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // PlaylistRoutes sets up the routes for the playlist-related endpoints
-func PlaylistRoutes(router *gin.Engine, playlistController *controllers.PlaylistController) {
-	// Group playlist routes
-	playlistRoutes := router.Group("/api/playlists")
+func PlaylistRoutes(router *gin.Engine, playlistController *controllers.PlaylistController, authMiddleware gin.HandlerFunc) {
+	// Group playlist routes behind the auth middleware
+	playlistRoutes := router.Group("/api/playlists", authMiddleware)
 	{
 		// Add a new playlist
-		playlistRoutes.POST("/", playlistController.AddPlaylist)
+		playlistRoutes.POST("/", middleware.RequirePermission(models.PermissionPlaylistsWrite), playlistController.AddPlaylist)
 
 		// View details of a specific playlist
-		playlistRoutes.GET("/:playlistId", playlistController.GetPlaylist)
+		playlistRoutes.GET("/:playlistId", middleware.RequirePermission(models.PermissionPlaylistsRead), playlistController.GetPlaylist)
 
 		// Update an existing playlist
-		playlistRoutes.PUT("/:playlistId", playlistController.UpdatePlaylist)
+		playlistRoutes.PUT("/:playlistId", middleware.RequirePermission(models.PermissionPlaylistsWrite), playlistController.UpdatePlaylist)
 
 		// Delete a playlist
-		playlistRoutes.DELETE("/:playlistId", playlistController.DeletePlaylist)
+		playlistRoutes.DELETE("/:playlistId", middleware.RequirePermission(models.PermissionPlaylistsWrite), playlistController.DeletePlaylist)
 
 		// List all playlists with pagination
-		playlistRoutes.GET("/", playlistController.ListPlaylists)
+		playlistRoutes.GET("/", middleware.RequirePermission(models.PermissionPlaylistsRead), playlistController.ListPlaylists)
 
-		// Add a track to a playlist
-		playlistRoutes.POST("/:playlistId/tracks/:trackId", playlistController.AddTrackToPlaylist)
+		// Reorder the tracks of a playlist
+		playlistRoutes.PUT("/:playlistId/tracks", middleware.RequirePermission(models.PermissionPlaylistsWrite), playlistController.ReorderPlaylistTracks)
 
-		// Remove a track from a playlist
-		playlistRoutes.DELETE("/:playlistId/tracks/:trackId", playlistController.RemoveTrackFromPlaylist)
+		// Move a track from one position of a playlist to another
+		playlistRoutes.POST("/:playlistId/tracks/move", middleware.RequirePermission(models.PermissionPlaylistsWrite), playlistController.MoveTrackInPlaylist)
+
+		// Add a track to a playlist, optionally at a position
+		playlistRoutes.POST("/:playlistId/tracks/:trackId", middleware.RequirePermission(models.PermissionPlaylistsWrite), playlistController.AddTrackToPlaylist)
+
+		// Remove a track from a playlist, optionally only at a position
+		playlistRoutes.DELETE("/:playlistId/tracks/:trackId", middleware.RequirePermission(models.PermissionPlaylistsWrite), playlistController.RemoveTrackFromPlaylist)
 	}
 }
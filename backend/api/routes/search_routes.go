@@ -2,19 +2,21 @@ package routes
 
 import (
 	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SearchRoutes sets up the routes for the search-related endpoints
-func SearchRoutes(router *gin.Engine, searchController *controllers.SearchController) {
-	// Group search routes
-	search := router.Group("/api/search")
+func SearchRoutes(router *gin.Engine, searchController *controllers.SearchController, authMiddleware gin.HandlerFunc) {
+	// Group search routes behind the auth middleware
+	search := router.Group("/api/search", authMiddleware)
 	{
 		// Search all tracks with pagination
-		search.GET("/tracks", searchController.SearchTracks)
+		search.GET("/tracks", middleware.RequirePermission(models.PermissionTracksRead), searchController.SearchTracks)
 
 		// Search all playlists with pagination
-		search.GET("/playlists", searchController.SearchPlaylists)
+		search.GET("/playlists", middleware.RequirePermission(models.PermissionPlaylistsRead), searchController.SearchPlaylists)
 	}
 }
@@ -2,31 +2,69 @@ package routes
 
 import (
 	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // TrackRoutes sets up the routes for the track-related endpoints
-func TrackRoutes(router *gin.Engine, trackController *controllers.TrackController) {
-	// Group track routes
-	trackRoutes := router.Group("/api/tracks")
+func TrackRoutes(router *gin.Engine, trackController *controllers.TrackController, authMiddleware, mediaAuthMiddleware gin.HandlerFunc) {
+	// Group track routes behind the auth middleware
+	trackRoutes := router.Group("/api/tracks", authMiddleware)
 	{
-		// Add a new music track with a cover image and MP3 file
-		trackRoutes.POST("/", trackController.AddTrack)
+		// Add a new music track with a cover image and audio file
+		trackRoutes.POST("/", middleware.RequirePermission(models.PermissionTracksWrite), trackController.AddTrack)
+
+		// List the most played music tracks with pagination
+		trackRoutes.GET("/most-played", middleware.RequirePermission(models.PermissionTracksRead), trackController.ListMostPlayedTracks)
+
+		// List the most recently played music tracks with pagination
+		trackRoutes.GET("/recently-played", middleware.RequirePermission(models.PermissionTracksRead), trackController.ListRecentlyPlayedTracks)
 
 		// View details of a specific music track
-		trackRoutes.GET("/:trackId", trackController.GetTrack)
+		trackRoutes.GET("/:trackId", middleware.RequirePermission(models.PermissionTracksRead), trackController.GetTrack)
 
 		// Update an existing music track, including the cover image
-		trackRoutes.PUT("/:trackId", trackController.UpdateTrack)
+		trackRoutes.PUT("/:trackId", middleware.RequirePermission(models.PermissionTracksWrite), trackController.UpdateTrack)
 
 		// Delete a music track
-		trackRoutes.DELETE("/:trackId", trackController.DeleteTrack)
+		trackRoutes.DELETE("/:trackId", middleware.RequirePermission(models.PermissionTracksWrite), trackController.DeleteTrack)
 
 		// List all music tracks with pagination
-		trackRoutes.GET("/", trackController.ListTracks)
+		trackRoutes.GET("/", middleware.RequirePermission(models.PermissionTracksRead), trackController.ListTracks)
+
+		// Issue a media token for the stream, cover and HLS endpoints of a music track
+		trackRoutes.POST("/:trackId/media-token", middleware.RequirePermission(models.PermissionTracksRead), trackController.IssueMediaToken)
+
+		// Retrieve the waveform peaks of a music track
+		trackRoutes.GET("/:trackId/waveform", middleware.RequirePermission(models.PermissionTracksRead), trackController.GetWaveform)
+
+		// Queue the loudness analysis of the music tracks never analyzed, or of every track
+		trackRoutes.POST("/loudness/analyze", middleware.RequirePermission(models.PermissionTracksManage), trackController.AnalyzeLoudness)
+
+		// List the transcoding jobs of the audio file of a music track
+		trackRoutes.GET("/:trackId/transcodes", middleware.RequirePermission(models.PermissionTracksRead), trackController.ListTranscodeJobs)
+
+		// List the files owned by a music track
+		trackRoutes.GET("/:trackId/files", middleware.RequirePermission(models.PermissionFilesRead), trackController.ListTrackFiles)
+
+		// Perform a playback action (play, pause, seek, stop, next, previous) on a music track
+		trackRoutes.POST("/:trackId/play", middleware.RequirePermission(models.PermissionTracksRead), trackController.PlayPauseTrack)
+	}
+
+	// Group the media routes behind the media auth middleware, which also accepts a media token in the URL
+	mediaRoutes := router.Group("/api/tracks", mediaAuthMiddleware)
+	{
+		// Stream the audio file of a music track or one of its renditions, with support for range requests
+		mediaRoutes.GET("/:trackId/stream", middleware.RequirePermission(models.PermissionTracksRead), trackController.StreamTrack)
+
+		// Retrieve the HLS master playlist of a music track, and the media playlists and segments of its variants
+		mediaRoutes.GET("/:trackId/hls/master.m3u8", middleware.RequirePermission(models.PermissionTracksRead), trackController.GetHLSMasterPlaylist)
+		mediaRoutes.GET("/:trackId/hls/:variant/index.m3u8", middleware.RequirePermission(models.PermissionTracksRead), trackController.GetHLSMediaPlaylist)
+		mediaRoutes.GET("/:trackId/hls/:variant/:segment", middleware.RequirePermission(models.PermissionTracksRead), trackController.GetHLSSegment)
 
-		// Play/Pause an MP3 file of a music track
-		trackRoutes.POST("/:trackId/play", trackController.PlayPauseTrack)
+		// Retrieve the cover image of a music track
+		mediaRoutes.GET("/:trackId/cover", middleware.RequirePermission(models.PermissionTracksRead), trackController.GetCoverImage)
 	}
 }
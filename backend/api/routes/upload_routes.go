@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadRoutes sets up the routes for the resumable upload endpoints, which follow the tus protocol
+func UploadRoutes(router *gin.Engine, uploadController *controllers.UploadController, authMiddleware gin.HandlerFunc) {
+	// Report the supported tus version, extensions and maximum size; clients discover these before authenticating
+	router.OPTIONS("/api/uploads", uploadController.GetOptions)
+	router.OPTIONS("/api/uploads/:uploadId", uploadController.GetOptions)
+
+	// Group upload routes behind the auth middleware and the tus version check
+	uploads := router.Group("/api/uploads", authMiddleware, uploadController.RequireTusResumable)
+	{
+		// Create a resumable upload
+		uploads.POST("/", middleware.RequirePermission(models.PermissionTracksWrite), uploadController.CreateUpload)
+
+		// Get the offset to resume an upload from
+		uploads.HEAD("/:uploadId", middleware.RequirePermission(models.PermissionTracksWrite), uploadController.GetUploadOffset)
+
+		// Append a chunk to an upload at its current offset
+		uploads.PATCH("/:uploadId", middleware.RequirePermission(models.PermissionTracksWrite), uploadController.PatchUpload)
+
+		// Terminate an upload
+		uploads.DELETE("/:uploadId", middleware.RequirePermission(models.PermissionTracksWrite), uploadController.DeleteUpload)
+	}
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
+	"music-library-management/api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserRoutes sets up the routes for the user administration endpoints
+func UserRoutes(router *gin.Engine, userController *controllers.UserController, authMiddleware gin.HandlerFunc) {
+	// Group user routes behind the auth middleware, restricted to user managers
+	users := router.Group("/api/users", authMiddleware, middleware.RequirePermission(models.PermissionUsersManage))
+	{
+		// List all users
+		users.GET("/", userController.ListUsers)
+
+		// Assign a role to a user
+		users.PUT("/:userId/role", userController.UpdateUserRole)
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"log"
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyService handles operations related to API keys
+type APIKeyService struct {
+	collection  *mongo.Collection // MongoDB collection for API keys
+	userService *UserService      // UserService to resolve the user a key acts for
+	config      *config.Config    // Application configuration holding the key lifetimes
+}
+
+// NewAPIKeyService creates a new APIKeyService
+func NewAPIKeyService(client *mongo.Client, cfg *config.Config, userService *UserService) *APIKeyService {
+	return &APIKeyService{
+		collection:  utils.GetDBCollection(client, cfg, "api_keys"),
+		userService: userService,
+		config:      cfg,
+	}
+}
+
+// CreateAPIKey creates a key for the given user and returns it together with the plain-text key, which is not stored
+func (s *APIKeyService) CreateAPIKey(userId, name string, scopes []string, ttl time.Duration) (*models.APIKey, string, error) {
+	user, err := s.userService.GetUser(userId) // Retrieve the user the key acts for
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A key can only be granted permissions its user holds
+	for _, scope := range scopes {
+		if !models.IsValidPermission(scope) {
+			return nil, "", errors.ErrInvalidInput
+		}
+		if !models.HasPermission(user.EffectiveRole(), scope) {
+			return nil, "", errors.ErrForbidden
+		}
+	}
+
+	// Apply the default lifetime and cap it at the maximum
+	if ttl <= 0 {
+		ttl = s.config.APIKeyDefaultTTL
+	}
+	if ttl > s.config.APIKeyMaxTTL {
+		return nil, "", errors.ErrInvalidInput
+	}
+
+	plainKey, err := utils.GenerateAPIKey() // Generate the secret key
+	if err != nil {
+		return nil, "", errors.ErrInternalServer
+	}
+
+	key := &models.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    plainKey[:len(utils.APIKeyPrefix)+6], // Keep enough characters to tell keys apart
+		KeyHash:   utils.HashAPIKey(plainKey),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	key.BeforeCreate() // Set default values before creating the key
+
+	_, err = s.collection.InsertOne(context.Background(), key) // Insert the key into the database
+	if err != nil {
+		return nil, "", errors.ErrDatabaseOperation
+	}
+
+	return key, plainKey, nil
+}
+
+// ListAPIKeys lists the non-revoked keys of the given user
+func (s *APIKeyService) ListAPIKeys(userId string) ([]*models.APIKey, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by created_at in descending order
+
+	cursor, err := s.collection.Find(context.Background(), bson.M{"user_id": userObjectID, "is_deleted": false}, findOptions) // Find the user's keys
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	keys := []*models.APIKey{}
+	err = cursor.All(context.Background(), &keys) // Decode all keys
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey soft deletes a key belonging to the given user
+func (s *APIKeyService) RevokeAPIKey(userId, keyId string) error {
+	objectID, err := primitive.ObjectIDFromHex(keyId) // Convert string ID to ObjectID
+	if err != nil {
+		return errors.ErrInvalidObjectID
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return errors.ErrInvalidObjectID
+	}
+
+	filter := bson.M{"_id": objectID, "user_id": userObjectID, "is_deleted": false} // Only the owner may revoke a key
+
+	var key models.APIKey
+	err = s.collection.FindOne(context.Background(), filter).Decode(&key) // Find the key
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.ErrAPIKeyNotFound
+		}
+		return errors.ErrDatabaseOperation
+	}
+
+	key.SoftDelete() // Apply soft delete to the key
+
+	update := bson.M{
+		"$set": bson.M{
+			"is_deleted": key.IsDeleted,
+			"deleted_at": key.DeletedAt,
+			"updated_at": key.UpdatedAt,
+		},
+	}
+
+	result := s.collection.FindOneAndUpdate(context.Background(), filter, update, nil) // Update the key to revoke it
+	if result.Err() != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// Authenticate validates a plain-text key and returns it together with the user it acts for
+func (s *APIKeyService) Authenticate(plainKey string) (*models.APIKey, *models.User, error) {
+	var key models.APIKey
+	err := s.collection.FindOne(context.Background(), bson.M{"key_hash": utils.HashAPIKey(plainKey), "is_deleted": false}).Decode(&key) // Find the key by hash
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, errors.ErrInvalidToken
+		}
+		return nil, nil, errors.ErrDatabaseOperation
+	}
+
+	if key.IsExpired() {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	user, err := s.userService.GetUser(key.UserID.Hex()) // Make sure the user still exists
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			return nil, nil, errors.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+
+	// Record when the key was last used, at most once per interval; failing to record it does not reject the key
+	now := time.Now()
+	if key.NeedsUsageRecorded(now) {
+		_, err = s.collection.UpdateOne(context.Background(), bson.M{"_id": key.ID}, bson.M{"$set": bson.M{"last_used_at": now}})
+		if err != nil {
+			log.Printf("Error recording the use of API key %s: %v", key.ID.Hex(), err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return &key, user, nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+	"time"
+)
+
+// AuthService handles registration, login and token issuance
+type AuthService struct {
+	userService *UserService   // UserService to handle user-related operations
+	config      *config.Config // Application configuration holding the JWT settings
+}
+
+// TokenPair holds a freshly issued access token and refresh token
+type TokenPair struct {
+	AccessToken           string    // Short-lived token used to call the API
+	AccessTokenExpiresAt  time.Time // When the access token expires
+	RefreshToken          string    // Long-lived token used to obtain a new pair
+	RefreshTokenExpiresAt time.Time // When the refresh token expires
+}
+
+// NewAuthService creates a new AuthService
+func NewAuthService(userService *UserService, cfg *config.Config) *AuthService {
+	return &AuthService{
+		userService: userService, // Initialize userService for user-related operations
+		config:      cfg,         // Keep the configuration for signing tokens
+	}
+}
+
+// Register creates a new user account and issues a token pair for it
+func (s *AuthService) Register(user *models.User, password string) (*models.User, *TokenPair, error) {
+	createdUser, err := s.userService.CreateUser(user, password) // Create the user
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.issueTokens(createdUser) // Issue tokens for the new user
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return createdUser, tokens, nil
+}
+
+// Login verifies the credentials and issues a token pair
+func (s *AuthService) Login(email, password string) (*models.User, *TokenPair, error) {
+	user, err := s.userService.GetUserByEmail(email) // Look up the user by email
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			return nil, nil, errors.ErrInvalidCredentials // Do not reveal whether the email exists
+		}
+		return nil, nil, err
+	}
+
+	if !utils.CheckPassword(user.PasswordHash, password) { // Compare the password with the stored hash
+		return nil, nil, errors.ErrInvalidCredentials
+	}
+
+	tokens, err := s.issueTokens(user) // Issue tokens for the user
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// Refresh validates a refresh token and issues a new token pair
+func (s *AuthService) Refresh(refreshToken string) (*models.User, *TokenPair, error) {
+	claims, err := utils.ParseToken(refreshToken, utils.RefreshTokenType, s.config.JWTSecret) // Validate the refresh token
+	if err != nil {
+		return nil, nil, errors.ErrInvalidToken
+	}
+
+	user, err := s.userService.GetUser(claims.Subject) // Make sure the user still exists
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			return nil, nil, errors.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+
+	tokens, err := s.issueTokens(user) // Issue a new token pair
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// Authenticate validates an access token and returns the user it belongs to
+func (s *AuthService) Authenticate(accessToken string) (*models.User, error) {
+	claims, err := utils.ParseToken(accessToken, utils.AccessTokenType, s.config.JWTSecret) // Validate the access token
+	if err != nil {
+		return nil, errors.ErrInvalidToken
+	}
+
+	user, err := s.userService.GetUser(claims.Subject) // Make sure the user still exists
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// IssueMediaToken signs a token the user can pass in the URLs of the stream, cover and HLS endpoints of a track,
+// for media elements and players that cannot send headers
+func (s *AuthService) IssueMediaToken(userId, trackId string) (string, time.Time, error) {
+	token, expiresAt, err := utils.GenerateMediaToken(userId, trackId, s.config.JWTSecret, s.config.MediaTokenTTL)
+	if err != nil {
+		return "", time.Time{}, errors.ErrInternalServer
+	}
+	return token, expiresAt, nil
+}
+
+// AuthenticateMedia validates a media token for the given track and returns the user it belongs to
+func (s *AuthService) AuthenticateMedia(mediaToken, trackId string) (*models.User, error) {
+	claims, err := utils.ParseToken(mediaToken, utils.MediaTokenType, s.config.JWTSecret) // Validate the media token
+	if err != nil || claims.TrackID != trackId {
+		return nil, errors.ErrInvalidToken // Reject tokens issued for another track
+	}
+
+	user, err := s.userService.GetUser(claims.Subject) // Make sure the user still exists
+	if err != nil {
+		if err == errors.ErrUserNotFound {
+			return nil, errors.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// issueTokens signs a new access token and refresh token for the user
+func (s *AuthService) issueTokens(user *models.User) (*TokenPair, error) {
+	userId := user.ID.Hex()
+
+	accessToken, accessExpiresAt, err := utils.GenerateToken(userId, utils.AccessTokenType, s.config.JWTSecret, s.config.AccessTokenTTL)
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+
+	refreshToken, refreshExpiresAt, err := utils.GenerateToken(userId, utils.RefreshTokenType, s.config.JWTSecret, s.config.RefreshTokenTTL)
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+
+	return &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt,
+	}, nil
+}
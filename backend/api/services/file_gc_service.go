@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"log"
+	"path"
+	"time"
+
+	"music-library-management/api/models"
+	"music-library-management/api/storage"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FileGCService collects the garbage left in the file storage: files no live track uses and stored content without a file record
+type FileGCService struct {
+	collection    *mongo.Collection // MongoDB collection for files
+	config        *config.Config    // Application configuration holding the sweep interval and grace period
+	storage       storage.Storage   // Storage holding the content of the files
+	trackService  *TrackService     // Service reporting the files used by tracks
+	uploadService *UploadService    // Service reporting the files and chunks held by resumable uploads
+}
+
+// NewFileGCService creates a new FileGCService
+func NewFileGCService(client *mongo.Client, cfg *config.Config, store storage.Storage, trackService *TrackService, uploadService *UploadService) *FileGCService {
+	return &FileGCService{
+		collection:    utils.GetDBCollection(client, cfg, "files"),
+		config:        cfg,
+		storage:       store,
+		trackService:  trackService,
+		uploadService: uploadService,
+	}
+}
+
+// FileReferences is the set of files in use, by file ID and, for entities added before file IDs were stored, by filename,
+// along with stored content in use that has no file record, such as the chunks of resumable uploads
+type FileReferences struct {
+	ids       map[primitive.ObjectID]bool
+	filenames map[string]bool
+	keys      map[string]bool
+}
+
+// NewFileReferences creates an empty set of file references
+func NewFileReferences() *FileReferences {
+	return &FileReferences{
+		ids:       make(map[primitive.ObjectID]bool),
+		filenames: make(map[string]bool),
+		keys:      make(map[string]bool),
+	}
+}
+
+// AddID marks the file with the given ID as used; a zero ID is ignored
+func (r *FileReferences) AddID(id primitive.ObjectID) {
+	if !id.IsZero() {
+		r.ids[id] = true
+	}
+}
+
+// AddURL marks the file behind a stored file URL as used; an empty URL is ignored
+func (r *FileReferences) AddURL(fileUrl string) {
+	if fileUrl != "" {
+		r.filenames[path.Base(fileUrl)] = true
+	}
+}
+
+// AddKey marks the stored content with the given key as used although it has no file record
+func (r *FileReferences) AddKey(key string) {
+	r.keys[key] = true
+}
+
+// Contains reports whether the file is used, directly or through a used file it was derived from
+func (r *FileReferences) Contains(file *models.File) bool {
+	if r.ids[file.ID] || r.filenames[file.Filename] {
+		return true
+	}
+	for _, owner := range file.Owners {
+		if owner.Type == models.FileOwnerFile && r.ids[owner.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+// GCEntry describes a file or stored object handled by a garbage collection
+type GCEntry struct {
+	FileID   string // ID of the file record, empty for stored content without a record
+	Filename string // Name the content is stored under
+	Size     int64  // Size of the content in bytes
+}
+
+// GCReport reports what a garbage collection did, or would have done in a dry run
+type GCReport struct {
+	DryRun         bool      // Whether nothing was changed
+	StartedAt      time.Time // When the collection started
+	Cutoff         time.Time // Files unused since before this time were collected
+	Restored       []GCEntry // Soft-deleted files that are used again and were restored
+	SoftDeleted    []GCEntry // Unused files that were soft-deleted
+	Purged         []GCEntry // Soft-deleted files that were removed with their content
+	StrayObjects   []GCEntry // Stored content without a file record that was removed
+	ReclaimedBytes int64     // Bytes of content removed from the storage
+}
+
+// CollectGarbage soft-deletes the files no live track uses once they were untouched for the grace period,
+// purges files that stayed soft-deleted for the grace period, and removes stored content without a file record
+// that is older than the grace period. In a dry run, it only reports what it would do
+func (s *FileGCService) CollectGarbage(dryRun bool) (*GCReport, error) {
+	now := time.Now()
+	report := &GCReport{
+		DryRun:       dryRun,
+		StartedAt:    now,
+		Cutoff:       now.Add(-s.config.FileGCGracePeriod),
+		Restored:     []GCEntry{},
+		SoftDeleted:  []GCEntry{},
+		Purged:       []GCEntry{},
+		StrayObjects: []GCEntry{},
+	}
+
+	// Collect the files in use before looking at the files, so files added meanwhile are recent enough to be kept
+	refs := NewFileReferences()
+	if err := s.trackService.CollectFileReferences(refs); err != nil {
+		return nil, err
+	}
+	if err := s.uploadService.CollectFileReferences(refs); err != nil {
+		return nil, err
+	}
+	if err := s.resolveFilenames(refs); err != nil {
+		return nil, err
+	}
+
+	recorded, err := s.collectFiles(refs, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.collectStrayObjects(recorded, refs, report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// resolveFilenames adds the IDs of the files used by filename, so the files derived from them are used too
+func (s *FileGCService) resolveFilenames(refs *FileReferences) error {
+	if len(refs.filenames) == 0 {
+		return nil
+	}
+
+	filenames := make([]string, 0, len(refs.filenames))
+	for filename := range refs.filenames {
+		filenames = append(filenames, filename)
+	}
+
+	projection := bson.M{"_id": 1}
+	cursor, err := s.collection.Find(context.Background(), bson.M{"filename": bson.M{"$in": filenames}}, options.Find().SetProjection(projection))
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var file models.File
+		if err := cursor.Decode(&file); err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		refs.AddID(file.ID)
+	}
+	if err := cursor.Err(); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// collectFiles restores, soft-deletes and purges file records and returns the names of all recorded files
+func (s *FileGCService) collectFiles(refs *FileReferences, report *GCReport) (map[string]bool, error) {
+	cursor, err := s.collection.Find(context.Background(), bson.M{}) // Find all files, including soft-deleted ones
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	defer cursor.Close(context.Background())
+
+	recorded := make(map[string]bool)
+	for cursor.Next(context.Background()) {
+		var file models.File
+		if err := cursor.Decode(&file); err != nil {
+			return nil, errors.ErrDatabaseOperation
+		}
+		recorded[file.Filename] = true
+		entry := GCEntry{FileID: file.ID.Hex(), Filename: file.Filename, Size: file.Size}
+
+		switch {
+		case refs.Contains(&file):
+			// A file in use is never collected, and restored if it was soft-deleted
+			if !file.IsDeleted {
+				continue
+			}
+			if !report.DryRun {
+				_, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": file.ID}, bson.M{
+					"$set": bson.M{"is_deleted": false, "deleted_at": nil, "updated_at": time.Now()},
+				})
+				if err != nil {
+					return nil, errors.ErrDatabaseOperation
+				}
+			}
+			report.Restored = append(report.Restored, entry)
+
+		case !file.IsDeleted && file.UpdatedAt.Before(report.Cutoff):
+			// An unused file untouched for the grace period is soft-deleted, unless a new upload of its content
+			// touched it meanwhile; uploads touch the file before the track using it is saved
+			if !report.DryRun {
+				filter := bson.M{"_id": file.ID, "is_deleted": false, "updated_at": file.UpdatedAt}
+				file.SoftDelete()
+				result, err := s.collection.UpdateOne(context.Background(), filter, bson.M{
+					"$set": bson.M{"is_deleted": true, "deleted_at": file.DeletedAt, "updated_at": file.UpdatedAt},
+				})
+				if err != nil {
+					return nil, errors.ErrDatabaseOperation
+				}
+				if result.ModifiedCount == 0 {
+					continue
+				}
+			}
+			report.SoftDeleted = append(report.SoftDeleted, entry)
+
+		case file.IsDeleted && file.DeletedAt != nil && file.DeletedAt.Before(report.Cutoff):
+			// A file soft-deleted for the grace period is purged, unless it was restored by a new upload of its content
+			if !report.DryRun {
+				purged, err := s.purgeFile(&file)
+				if err != nil {
+					return nil, err
+				}
+				if !purged {
+					continue
+				}
+			}
+			report.Purged = append(report.Purged, entry)
+			report.ReclaimedBytes += file.Size
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return recorded, nil
+}
+
+// purgeFile removes a soft-deleted file record and its content, reporting whether the record was still soft-deleted
+func (s *FileGCService) purgeFile(file *models.File) (bool, error) {
+	result, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": file.ID, "is_deleted": true})
+	if err != nil {
+		return false, errors.ErrDatabaseOperation
+	}
+	if result.DeletedCount == 0 {
+		return false, nil
+	}
+
+	// Keep the content if a new upload of it recorded it again meanwhile
+	count, err := s.collection.CountDocuments(context.Background(), bson.M{"filename": file.Filename})
+	if err != nil {
+		return false, errors.ErrDatabaseOperation
+	}
+	if count == 0 {
+		if err := s.storage.Delete(context.Background(), file.Filename); err != nil {
+			return false, errors.ErrInternalServer
+		}
+	}
+
+	return true, nil
+}
+
+// collectStrayObjects removes the stored content that has no file record, is not in use and is older than the grace period
+func (s *FileGCService) collectStrayObjects(recorded map[string]bool, refs *FileReferences, report *GCReport) error {
+	err := s.storage.List(context.Background(), func(info storage.ObjectInfo) error {
+		if recorded[info.Key] || refs.keys[info.Key] || !info.ModTime.Before(report.Cutoff) {
+			return nil
+		}
+
+		if !report.DryRun {
+			if err := s.storage.Delete(context.Background(), info.Key); err != nil {
+				return err
+			}
+		}
+		report.StrayObjects = append(report.StrayObjects, GCEntry{Filename: info.Key, Size: info.Size})
+		report.ReclaimedBytes += info.Size
+		return nil
+	})
+	if err != nil {
+		return errors.ErrInternalServer
+	}
+
+	return nil
+}
+
+// StartSweeper collects garbage in the background every sweep interval; a zero interval disables the sweeper
+func (s *FileGCService) StartSweeper() {
+	if s.config.FileGCInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.config.FileGCInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := s.CollectGarbage(false)
+			if err != nil {
+				log.Printf("Error collecting file garbage: %v", err)
+				continue
+			}
+			log.Printf("Collected file garbage: %d restored, %d soft-deleted, %d purged, %d stray objects removed, %d bytes reclaimed",
+				len(report.Restored), len(report.SoftDeleted), len(report.Purged), len(report.StrayObjects), report.ReclaimedBytes)
+		}
+	}()
+}
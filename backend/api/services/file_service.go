@@ -1,9 +1,19 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime/multipart"
+	"regexp"
+	"strings"
+	"time"
 
+	"music-library-management/api/audio"
 	"music-library-management/api/models"
+	"music-library-management/api/storage"
 	"music-library-management/api/utils"
 	"music-library-management/config"
 	"music-library-management/errors"
@@ -11,57 +21,515 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// errDuplicateContent is returned by SaveFileMetadata when a record for the same content already exists
+var errDuplicateContent = errors.NewError("duplicate file content")
+
 // FileService handles file management operations
 type FileService struct {
 	collection *mongo.Collection
 	config     *config.Config
+	storage    storage.Storage // Storage holding the content of the files
 }
 
-// NewFileService creates a new FileService
-func NewFileService(client *mongo.Client, cfg *config.Config) *FileService {
+// NewFileService creates a new FileService storing file content in the given storage
+func NewFileService(client *mongo.Client, cfg *config.Config, store storage.Storage) *FileService {
 	return &FileService{
 		collection: utils.GetDBCollection(client, cfg, "files"),
 		config:     cfg,
+		storage:    store,
+	}
+}
+
+// SaveUploadedFile stores a file uploaded in a multipart form for its owner and saves its metadata,
+// reusing the record of identical content; the client's filename and content type are ignored
+func (s *FileService) SaveUploadedFile(c *gin.Context, userId string, header *multipart.FileHeader, owner models.FileOwner) (*models.File, error) {
+	if err := s.checkSize(owner.Role, header.Size); err != nil {
+		return nil, err // Refuse oversized files before hashing them
+	}
+
+	content, err := header.Open() // Open the uploaded file
+	if err != nil {
+		return nil, errors.ErrInvalidInput
+	}
+	defer content.Close()
+
+	return s.StoreFile(c, userId, content, owner)
+}
+
+// StoreFile stores content for its owner under its SHA-256 hash and saves its metadata; when identical content
+// is already stored, its record is reused and the owner added to it
+func (s *FileService) StoreFile(c *gin.Context, userId string, content io.ReadSeeker, owner models.FileOwner) (*models.File, error) {
+	// Hash the content to find out whether it is already stored
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, content)
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	return s.StoreHashedFile(c, userId, hash, size, func() (io.ReadCloser, error) {
+		_, err := content.Seek(0, io.SeekStart) // Read the content again to store it
+		return io.NopCloser(content), err
+	}, owner)
+}
+
+// StoreHashedFile stores content whose SHA-256 hash and size are already known like StoreFile. The type of the
+// content is sniffed from its magic bytes and must suit the owner's role; the content is stored with the
+// extension of that type
+func (s *FileService) StoreHashedFile(c *gin.Context, userId, hash string, size int64, open func() (io.ReadCloser, error), owner models.FileOwner) (*models.File, error) {
+	content, err := open()
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+	defer content.Close()
+
+	// Sniff the type of the content instead of trusting the client
+	head := make([]byte, utils.SniffLen)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, errors.ErrInternalServer
+	}
+	head = head[:n]
+	mimeType := utils.SniffContentType(head)
+	if err := s.CheckContent(owner.Role, mimeType, size); err != nil {
+		return nil, err
+	}
+
+	file, err := s.acquireFile(hash, owner) // Reuse the record of identical content
+	if err != errors.ErrFileNotFound {
+		return file, err
+	}
+
+	// Store the content under its hash; a concurrent upload of the same content writes the same object
+	filename := hash + utils.ExtensionForType(mimeType)
+	err = s.storage.Put(context.Background(), filename, io.MultiReader(bytes.NewReader(head), content), size, mimeType)
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+
+	file, err = s.SaveFileMetadata(c, userId, filename, hash, size, mimeType, owner)
+	if err == errDuplicateContent {
+		// Lost a race against a concurrent upload of the same content, so share its record instead
+		file, err = s.acquireFile(hash, owner)
+		if err == nil && file.Filename != filename {
+			s.storage.Delete(context.Background(), filename) // Remove the copy stored under another extension
+		}
+		return file, err
+	}
+	if err != nil {
+		s.storage.Delete(context.Background(), filename) // Remove the stored content
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// RemoveFile removes an owner from a file, undoing StoreFile; the content and the record are removed
+// once no owner is left. A nil file is ignored
+func (s *FileService) RemoveFile(file *models.File, owner models.FileOwner) error {
+	if file == nil {
+		return nil
+	}
+
+	// Remove the owner and read the remaining reference count
+	var released models.File
+	err := s.collection.FindOneAndUpdate(
+		context.Background(),
+		bson.M{"_id": file.ID, "owners": bson.M{"$elemMatch": ownerFilter(owner)}},
+		bson.M{
+			"$pull": bson.M{"owners": ownerFilter(owner)},
+			"$inc":  bson.M{"ref_count": -1},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&released)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil // The owner does not own the file
+		}
+		return errors.ErrDatabaseOperation
+	}
+	if released.RefCount > 0 {
+		return nil // The content is still shared by another owner
+	}
+
+	// Remove the record, then the content; records without references are never acquired again
+	_, err = s.collection.DeleteOne(context.Background(), bson.M{"_id": file.ID})
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	// Keep the content if a new upload of it recorded it again meanwhile, as it is stored under the same key
+	count, err := s.collection.CountDocuments(context.Background(), bson.M{"filename": released.Filename})
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if count == 0 {
+		if err := s.storage.Delete(context.Background(), released.Filename); err != nil { // Remove the stored content
+			return errors.ErrInternalServer
+		}
 	}
+
+	return s.releaseDerivedFiles(file.ID) // Release the files derived from it, such as thumbnails
+}
+
+// releaseDerivedFiles removes a removed file as the owner of the files derived from it
+func (s *FileService) releaseDerivedFiles(parentID primitive.ObjectID) error {
+	filter := bson.M{"owners": bson.M{"$elemMatch": bson.M{"owner_type": models.FileOwnerFile, "owner_id": parentID}}}
+	cursor, err := s.collection.Find(context.Background(), filter)
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	var files []models.File
+	if err := cursor.All(context.Background(), &files); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	for i := range files {
+		for _, owner := range files[i].Owners {
+			if owner.Type != models.FileOwnerFile || owner.ID != parentID {
+				continue
+			}
+			if err := s.RemoveFile(&files[i], owner); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddFileOwner adds an owner to a stored file, e.g. to share the file of a finished upload with a track;
+// the type of the file must suit the owner's role
+func (s *FileService) AddFileOwner(file *models.File, owner models.FileOwner) (*models.File, error) {
+	if err := s.CheckContent(owner.Role, file.MimeType, file.Size); err != nil {
+		return nil, err
+	}
+
+	return s.addOwner(bson.M{"_id": file.ID}, owner)
+}
+
+// CheckContent checks that content of the sniffed MIME type and size may play the role: audio files must be
+// audio, covers must be images and uploads either, each within the size limit of its kind. HLS segments, which
+// are only packaged from stored audio files, must be MPEG transport streams
+func (s *FileService) CheckContent(role, mimeType string, size int64) error {
+	isAudio, isImage := utils.IsAudioType(mimeType), utils.IsImageType(mimeType)
+	switch {
+	case models.IsHLSSegmentRole(role) && mimeType != utils.ContentTypeMPEGTS:
+		return errors.ErrUnsupportedFileType
+	case models.IsHLSSegmentRole(role):
+		return nil // Segments are smaller than the audio file they were cut from
+	case role == models.FileRoleAudio && !isAudio:
+		return errors.ErrUnsupportedAudioType
+	case role == models.FileRoleCover && !isImage:
+		return errors.ErrUnsupportedImageType
+	case !isAudio && !isImage:
+		return errors.ErrUnsupportedFileType
+	case isAudio && size > s.config.AudioMaxSize, isImage && size > s.config.ImageMaxSize:
+		return errors.ErrFileTooLarge
+	}
+
+	return nil
+}
+
+// checkSize checks the size of content for a role before its type is known, against the largest limit the role allows
+func (s *FileService) checkSize(role string, size int64) error {
+	limit := max(s.config.AudioMaxSize, s.config.ImageMaxSize)
+	switch role {
+	case models.FileRoleAudio:
+		limit = s.config.AudioMaxSize
+	case models.FileRoleCover:
+		limit = s.config.ImageMaxSize
+	}
+
+	if size > limit {
+		return errors.ErrFileTooLarge
+	}
+	return nil
+}
+
+// acquireFile adds an owner to the file storing the content with the given hash
+func (s *FileService) acquireFile(hash string, owner models.FileOwner) (*models.File, error) {
+	return s.addOwner(bson.M{"hash": hash}, owner)
+}
+
+// addOwner adds an owner to the file matching the filter, incrementing its reference count unless the owner
+// already owns it; files without references are being removed and cannot gain owners
+func (s *FileService) addOwner(filter bson.M, owner models.FileOwner) (*models.File, error) {
+	filter["ref_count"] = bson.M{"$gt": 0}
+
+	var file models.File
+	err := s.collection.FindOneAndUpdate(
+		context.Background(),
+		bson.M{"$and": bson.A{filter, bson.M{"owners": bson.M{"$not": bson.M{"$elemMatch": ownerFilter(owner)}}}}},
+		bson.M{
+			"$push": bson.M{"owners": owner},
+			"$inc":  bson.M{"ref_count": 1},
+			"$set":  bson.M{"is_deleted": false, "deleted_at": nil, "updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&file)
+	if err == mongo.ErrNoDocuments {
+		// The owner may already own the content, e.g. when uploading the same cover image again
+		err = s.collection.FindOne(context.Background(), filter).Decode(&file)
+	}
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrFileNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &file, nil
+}
+
+// StoreDerivedFile stores content derived from a parent file, e.g. a thumbnail of a cover image, owned by the
+// parent in the given role; width and height describe images and are zero otherwise
+func (s *FileService) StoreDerivedFile(c *gin.Context, parent *models.File, role string, content io.ReadSeeker, width, height int) (*models.File, error) {
+	owner := models.FileOwner{Type: models.FileOwnerFile, ID: parent.ID, Role: role}
+	file, err := s.StoreFile(c, parent.UploadedBy.Hex(), content, owner)
+	if err != nil {
+		return nil, err
+	}
+	if !file.ParentID.IsZero() {
+		return file, nil // Identical content was already derived from a file
+	}
+
+	file.ParentID = parent.ID
+	file.Width = width
+	file.Height = height
+	update := bson.M{"$set": bson.M{"parent_id": file.ParentID, "width": file.Width, "height": file.Height}}
+
+	_, err = s.collection.UpdateOne(context.Background(), bson.M{"_id": file.ID, "parent_id": bson.M{"$exists": false}}, update) // Link the file to its parent
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return file, nil
 }
 
-// GetUploadPath returns the upload path from the config
-func (s *FileService) GetUploadPath() string {
-	return s.config.UploadPath
+// GetDerivedFile retrieves the file derived from a parent file in the given role
+func (s *FileService) GetDerivedFile(parent *models.File, role string) (*models.File, error) {
+	owner := models.FileOwner{Type: models.FileOwnerFile, ID: parent.ID, Role: role}
+	filter := bson.M{"owners": bson.M{"$elemMatch": ownerFilter(owner)}, "is_deleted": false}
+
+	var file models.File
+	err := s.collection.FindOne(context.Background(), filter).Decode(&file) // Find the derived file by its owner
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrFileNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &file, nil
 }
 
-// SaveFileMetadata saves metadata for an uploaded file
-func (s *FileService) SaveFileMetadata(c *gin.Context, filename string) (*models.File, error) {
-	fileUrl := utils.GetScheme(c) + "://" + c.Request.Host + "/" + s.GetUploadPath() + "/" + filename
+// SaveFileMetadata saves metadata for content uploaded by the given user under its hash, owned by a single owner;
+// the request context is nil for content stored outside a request
+func (s *FileService) SaveFileMetadata(c *gin.Context, userId, filename, hash string, size int64, mimeType string, owner models.FileOwner) (*models.File, error) {
+	uploadedBy, err := primitive.ObjectIDFromHex(userId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	// Files stored by background jobs have no request to take the host from, so their URL is relative
+	fileUrl := "/" + s.config.UploadPath + "/" + filename
+	if c != nil {
+		fileUrl = utils.GetScheme(c) + "://" + c.Request.Host + fileUrl
+	}
 
 	file := &models.File{
-		Filename: filename,
-		Filepath: s.GetUploadPath() + "/" + filename,
-		FileUrl:  fileUrl,
+		Filename:   filename,
+		Filepath:   s.storage.Location(filename),
+		FileUrl:    fileUrl,
+		Hash:       hash,
+		Size:       size,
+		MimeType:   mimeType,
+		Owners:     []models.FileOwner{owner},
+		RefCount:   1,
+		UploadedBy: uploadedBy,
 	}
 	file.BeforeCreate() // Set default values before creating the file record
 
-	_, err := s.collection.InsertOne(context.Background(), file)
+	_, err = s.collection.InsertOne(context.Background(), file)
 	if err != nil {
-		return nil, err
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errDuplicateContent // The content was stored by a concurrent upload
+		}
+		return nil, errors.ErrDatabaseOperation
 	}
 
 	return file, nil
 }
 
-// ListFiles lists all files metadata with pagination
-func (s *FileService) ListFiles(page, limit int) ([]models.File, int64, error) {
+// GetFile retrieves the metadata of a file that is not deleted by its ID
+func (s *FileService) GetFile(fileId string) (*models.File, error) {
+	objectID, err := primitive.ObjectIDFromHex(fileId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	var file models.File
+	err = s.collection.FindOne(context.Background(), bson.M{"_id": objectID, "is_deleted": false}).Decode(&file) // Find file by ID
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrFileNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &file, nil
+}
+
+// GetFileByFilename retrieves the metadata of a file that is not deleted by its stored filename
+func (s *FileService) GetFileByFilename(filename string) (*models.File, error) {
+	var file models.File
+	err := s.collection.FindOne(context.Background(), bson.M{"filename": filename, "is_deleted": false}).Decode(&file) // Find file by filename
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrFileNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &file, nil
+}
+
+// OpenFile opens the content of a file for reading; the caller must close it
+func (s *FileService) OpenFile(file *models.File) (storage.Object, *storage.ObjectInfo, error) {
+	content, info, err := s.storage.Get(context.Background(), file.Filename) // Open the stored content
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, nil, errors.ErrFileNotFound
+		}
+		return nil, nil, errors.ErrInternalServer
+	}
+
+	return content, info, nil
+}
+
+// RedirectURL returns a signed URL clients can fetch the content of a file from directly,
+// or an empty string if the content is served through the API
+func (s *FileService) RedirectURL(file *models.File) (string, error) {
+	if !s.config.StorageRedirect {
+		return "", nil
+	}
+
+	signedUrl, err := s.storage.SignedURL(context.Background(), file.Filename, s.config.SignedURLTTL) // Sign a URL of the stored content
+	if err != nil {
+		if err == storage.ErrUnsupported {
+			return "", nil
+		}
+		return "", errors.ErrInternalServer
+	}
+
+	return signedUrl, nil
+}
+
+// audioContainers maps the detected types of audio files to the containers their streams and tags are read from
+var audioContainers = map[string]string{
+	utils.ContentTypeMP3:  audio.ContainerMPEG,
+	utils.ContentTypeAAC:  audio.ContainerADTS,
+	utils.ContentTypeMP4:  audio.ContainerMP4,
+	utils.ContentTypeFLAC: audio.ContainerFLAC,
+	utils.ContentTypeOgg:  audio.ContainerOgg,
+	utils.ContentTypeOpus: audio.ContainerOgg,
+	utils.ContentTypeWAV:  audio.ContainerWAV,
+}
+
+// audioContainer returns the container of a stored audio file; files stored before their type was detected are MP3 files
+func audioContainer(file *models.File) string {
+	if container, ok := audioContainers[file.MimeType]; ok {
+		return container
+	}
+	return audio.ContainerMPEG
+}
+
+// ExtractTags reads the tags of a stored audio file, ID3 tags, Vorbis comments or MP4 metadata atoms depending on
+// its container, and records their raw fields on the file record; it returns nil tags if the file has none
+func (s *FileService) ExtractTags(file *models.File) (*audio.Tags, error) {
+	content, _, err := s.OpenFile(file) // Open the file content
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	tags, err := audio.ReadTags(content, audioContainer(file)) // Parse the tags native to the container
+	if err != nil {
+		if err == audio.ErrNoTags || err == audio.ErrInvalidStream {
+			return nil, nil // Invalid streams are rejected when their properties are read
+		}
+		return nil, errors.ErrInternalServer
+	}
+
+	file.TagFormat = tags.Format
+	file.Tags = tags.Frames
+	file.BeforeUpdate() // Set updated values before updating the file record
+
+	update := bson.M{
+		"$set": bson.M{
+			"tag_format": file.TagFormat,
+			"tags":       file.Tags,
+			"updated_at": file.UpdatedAt,
+		},
+	}
+
+	_, err = s.collection.UpdateOne(context.Background(), bson.M{"_id": file.ID}, update) // Store the tags on the file record
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return tags, nil
+}
+
+// ReadStreamInfo computes the duration and stream properties of a stored audio file from the headers of its container
+func (s *FileService) ReadStreamInfo(file *models.File) (*audio.StreamInfo, error) {
+	content, _, err := s.OpenFile(file) // Open the file content
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	info, err := audio.ReadStreamInfo(content, audioContainer(file)) // Read the stream properties
+	if err != nil {
+		if err == audio.ErrNotMPEG || err == audio.ErrInvalidStream {
+			return nil, errors.ErrInvalidAudioFile
+		}
+		return nil, errors.ErrInternalServer
+	}
+
+	return info, nil
+}
+
+// FileFilter narrows down the files listed by ListFiles; empty fields match every file
+type FileFilter struct {
+	OwnerType string // Type of an entity owning the file, e.g. "track"
+	OwnerID   string // ID of an entity owning the file
+	Role      string // Role of the file for its owner, e.g. "cover"
+	Type      string // MIME type of the file, or only its top-level type, e.g. "image"
+}
+
+// ListFiles lists the metadata of the files matching the filter with pagination
+func (s *FileService) ListFiles(filter FileFilter, page, limit int) ([]models.File, int64, error) {
+	query, err := filter.query()
+	if err != nil {
+		return nil, 0, err
+	}
+
 	skip := (page - 1) * limit
 	findOptions := options.Find()
 	findOptions.SetSkip(int64(skip))                            // Set the number of documents to skip
 	findOptions.SetLimit(int64(limit))                          // Set the number of documents to return
 	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by created_at in descending order
 
-	cursor, err := s.collection.Find(context.Background(), bson.M{"is_deleted": false}, findOptions) // Find files
+	cursor, err := s.collection.Find(context.Background(), query, findOptions) // Find files
 	if err != nil {
 		return nil, 0, errors.ErrDatabaseOperation
 	}
@@ -71,11 +539,70 @@ func (s *FileService) ListFiles(page, limit int) ([]models.File, int64, error) {
 		return nil, 0, errors.ErrDatabaseOperation
 	}
 
-	// Count total files excluding soft-deleted files
-	total, err := s.collection.CountDocuments(context.Background(), bson.M{"is_deleted": false})
+	// Count total matching files excluding soft-deleted files
+	total, err := s.collection.CountDocuments(context.Background(), query)
 	if err != nil {
 		return nil, 0, errors.ErrDatabaseOperation
 	}
 
 	return files, total, nil
 }
+
+// GetFilesByOwner retrieves the metadata of all files owned by an entity, oldest first
+func (s *FileService) GetFilesByOwner(ownerType, ownerId string) ([]models.File, error) {
+	query, err := FileFilter{OwnerType: ownerType, OwnerID: ownerId}.query()
+	if err != nil {
+		return nil, err
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}) // Sort by created_at in ascending order
+	cursor, err := s.collection.Find(context.Background(), query, findOptions)   // Find the owner's files
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	files := []models.File{}
+	if err := cursor.All(context.Background(), &files); err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return files, nil
+}
+
+// query builds the MongoDB filter matching the files that are not deleted and match the filter
+func (f FileFilter) query() (bson.M, error) {
+	query := bson.M{"is_deleted": false}
+
+	// The owner fields must match the same owner of the file
+	owner := bson.M{}
+	if f.OwnerType != "" {
+		owner["owner_type"] = f.OwnerType
+	}
+	if f.OwnerID != "" {
+		ownerID, err := primitive.ObjectIDFromHex(f.OwnerID) // Convert string ID to ObjectID
+		if err != nil {
+			return nil, errors.ErrInvalidObjectID
+		}
+		owner["owner_id"] = ownerID
+	}
+	if f.Role != "" {
+		owner["role"] = f.Role
+	}
+	if len(owner) > 0 {
+		query["owners"] = bson.M{"$elemMatch": owner}
+	}
+
+	// A full MIME type matches exactly, a top-level type matches all its subtypes
+	if strings.Contains(f.Type, "/") {
+		query["mime_type"] = f.Type
+	} else if f.Type != "" {
+		query["mime_type"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.Type) + "/"}
+	}
+
+	return query, nil
+}
+
+// ownerFilter matches an owner entry of a file
+func ownerFilter(owner models.FileOwner) bson.M {
+	return bson.M{"owner_type": owner.Type, "owner_id": owner.ID, "role": owner.Role}
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"music-library-management/api/models"
+	"music-library-management/api/storage"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+
+	"github.com/gin-gonic/gin"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newTestFileService creates a FileService on the mock deployment of mt, storing content in a temporary directory
+func newTestFileService(mt *mtest.T) (*FileService, storage.Storage) {
+	store, err := storage.NewLocalStorage(mt.TempDir())
+	if err != nil {
+		mt.Fatalf("NewLocalStorage: %v", err)
+	}
+	cfg := &config.Config{UploadPath: "uploads", AudioMaxSize: 1 << 20, ImageMaxSize: 1 << 10}
+	return &FileService{collection: mt.Coll, config: cfg, storage: store}, store
+}
+
+// newTestContext creates a request context for a file upload
+func newTestContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/files", nil)
+	return c
+}
+
+// contentHash returns the hex SHA-256 of the content
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileResponse is the reply to a findAndModify command returning the file, or no document if it is nil
+func fileResponse(file *models.File) bson.D {
+	if file == nil {
+		return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})
+	}
+	return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: file})
+}
+
+// findResponse is the reply to a find command returning the documents
+func findResponse(mt *mtest.T, documents ...interface{}) bson.D {
+	batch := make([]bson.D, len(documents))
+	for i, document := range documents {
+		data, err := bson.Marshal(document)
+		if err != nil {
+			mt.Fatalf("Marshal: %v", err)
+		}
+		if err := bson.Unmarshal(data, &batch[i]); err != nil {
+			mt.Fatalf("Unmarshal: %v", err)
+		}
+	}
+	return mtest.CreateCursorResponse(0, "test.files", mtest.FirstBatch, batch...)
+}
+
+// countResponse is the reply to the aggregation run by CountDocuments
+func countResponse(count int) bson.D {
+	if count == 0 {
+		return mtest.CreateCursorResponse(0, "test.files", mtest.FirstBatch)
+	}
+	return mtest.CreateCursorResponse(0, "test.files", mtest.FirstBatch, bson.D{{Key: "n", Value: count}})
+}
+
+// commandNames lists the commands sent to the mock deployment
+func commandNames(mt *mtest.T) []string {
+	var names []string
+	for _, event := range mt.GetAllStartedEvents() {
+		names = append(names, event.CommandName)
+	}
+	return names
+}
+
+// stored reports whether an object is stored under the key
+func stored(store storage.Storage, key string) bool {
+	_, err := store.Stat(context.Background(), key)
+	return err == nil
+}
+
+func TestStoreFile(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	content := []byte("ID3 audio content")
+	hash := contentHash(content)
+	userID := primitive.NewObjectID()
+	owner := models.FileOwner{Type: models.FileOwnerTrack, ID: primitive.NewObjectID(), Role: models.FileRoleAudio}
+
+	mt.Run("new content is stored under its hash", func(mt *mtest.T) {
+		service, store := newTestFileService(mt)
+		mt.AddMockResponses(fileResponse(nil), findResponse(mt), mtest.CreateSuccessResponse())
+
+		file, err := service.StoreFile(newTestContext(), userID.Hex(), bytes.NewReader(content), owner)
+		if err != nil {
+			mt.Fatalf("StoreFile: %v", err)
+		}
+		if file.Filename != hash+".mp3" || file.Hash != hash || file.Size != int64(len(content)) || file.RefCount != 1 ||
+			len(file.Owners) != 1 || file.Owners[0] != owner {
+			mt.Errorf("file = %+v, want %s.mp3 owned by a single owner", file, hash)
+		}
+		if !stored(store, hash+".mp3") {
+			mt.Error("content was not stored")
+		}
+		if names := commandNames(mt); !reflect.DeepEqual(names, []string{"findAndModify", "find", "insert"}) {
+			mt.Errorf("commands = %v, want two lookups and an insert", names)
+		}
+	})
+
+	mt.Run("identical content reuses the record", func(mt *mtest.T) {
+		service, store := newTestFileService(mt)
+		existing := &models.File{ID: primitive.NewObjectID(), Filename: hash + ".mp3", Hash: hash, RefCount: 2}
+		mt.AddMockResponses(fileResponse(existing))
+
+		file, err := service.StoreFile(newTestContext(), userID.Hex(), bytes.NewReader(content), owner)
+		if err != nil {
+			mt.Fatalf("StoreFile: %v", err)
+		}
+		if file.ID != existing.ID || file.RefCount != 2 {
+			mt.Errorf("file = %+v, want the existing record with its incremented count", file)
+		}
+		if stored(store, hash+".mp3") {
+			mt.Error("content was stored again")
+		}
+	})
+
+	mt.Run("an owner storing content it already owns gets the record unchanged", func(mt *mtest.T) {
+		service, _ := newTestFileService(mt)
+		existing := &models.File{ID: primitive.NewObjectID(), Filename: hash + ".mp3", Hash: hash, RefCount: 1, Owners: []models.FileOwner{owner}}
+		mt.AddMockResponses(fileResponse(nil), findResponse(mt, existing))
+
+		file, err := service.StoreFile(newTestContext(), userID.Hex(), bytes.NewReader(content), owner)
+		if err != nil {
+			mt.Fatalf("StoreFile: %v", err)
+		}
+		if file.ID != existing.ID || file.RefCount != 1 {
+			mt.Errorf("file = %+v, want the existing record with its count unchanged", file)
+		}
+	})
+
+	mt.Run("losing the duplicate key race shares the concurrent record", func(mt *mtest.T) {
+		service, store := newTestFileService(mt)
+		concurrent := &models.File{ID: primitive.NewObjectID(), Filename: hash + ".mpeg", Hash: hash, RefCount: 2} // Named by an older upload
+		mt.AddMockResponses(
+			fileResponse(nil),
+			findResponse(mt),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 11000, Message: "E11000 duplicate key error"}),
+			fileResponse(concurrent),
+		)
+
+		file, err := service.StoreFile(newTestContext(), userID.Hex(), bytes.NewReader(content), owner)
+		if err != nil {
+			mt.Fatalf("StoreFile: %v", err)
+		}
+		if file.ID != concurrent.ID {
+			mt.Errorf("file = %+v, want the concurrent record", file)
+		}
+		if stored(store, hash+".mp3") {
+			mt.Error("the copy stored under another extension was kept")
+		}
+	})
+
+	mt.Run("a failed insert removes the content", func(mt *mtest.T) {
+		service, store := newTestFileService(mt)
+		mt.AddMockResponses(fileResponse(nil), findResponse(mt), mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "failed"}))
+
+		_, err := service.StoreFile(newTestContext(), userID.Hex(), bytes.NewReader(content), owner)
+		if err != errors.ErrDatabaseOperation {
+			mt.Errorf("StoreFile error = %v, want ErrDatabaseOperation", err)
+		}
+		if stored(store, hash+".mp3") {
+			mt.Error("content of the failed insert was kept")
+		}
+	})
+}
+
+func TestStoreHashedFile(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	audioOwner := models.FileOwner{Type: models.FileOwnerTrack, ID: primitive.NewObjectID(), Role: models.FileRoleAudio}
+	coverOwner := models.FileOwner{Type: models.FileOwnerTrack, ID: primitive.NewObjectID(), Role: models.FileRoleCover}
+
+	tests := []struct {
+		name    string
+		content []byte
+		owner   models.FileOwner
+		err     error
+		key     string // Key the content is stored under, empty if it is refused
+	}{
+		{"audio is stored with the extension of its sniffed type", []byte("fLaC\x00\x00\x00\x22"), audioOwner, nil, ".flac"},
+		{"an image is stored as a cover", []byte("\x89PNG\r\n\x1a\n\x00\x00"), coverOwner, nil, ".png"},
+		{"an image is refused as audio", []byte("\x89PNG\r\n\x1a\n\x00\x00"), audioOwner, errors.ErrUnsupportedAudioType, ""},
+		{"audio is refused as a cover", []byte("fLaC\x00\x00\x00\x22"), coverOwner, errors.ErrUnsupportedImageType, ""},
+		{"an image over its limit is refused", append([]byte("\xff\xd8\xff"), make([]byte, 1<<10)...), coverOwner, errors.ErrFileTooLarge, ""},
+	}
+
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			service, store := newTestFileService(mt)
+			mt.AddMockResponses(fileResponse(nil), findResponse(mt), mtest.CreateSuccessResponse())
+
+			hash := contentHash(test.content)
+			open := func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(test.content)), nil
+			}
+			file, err := service.StoreHashedFile(newTestContext(), primitive.NewObjectID().Hex(), hash, int64(len(test.content)), open, test.owner)
+			if err != test.err {
+				mt.Fatalf("StoreHashedFile error = %v, want %v", err, test.err)
+			}
+			if test.err != nil {
+				if names := commandNames(mt); len(names) != 0 {
+					mt.Errorf("commands = %v, want the content refused before any lookup", names)
+				}
+				return
+			}
+
+			object, _, err := store.Get(context.Background(), hash+test.key)
+			if err != nil {
+				mt.Fatalf("Get: %v", err)
+			}
+			defer object.Close()
+			if content, _ := io.ReadAll(object); !bytes.Equal(content, test.content) || file.Filename != hash+test.key {
+				mt.Errorf("stored %q as %s, want %q as %s", content, file.Filename, test.content, hash+test.key)
+			}
+		})
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	service := &FileService{config: &config.Config{AudioMaxSize: 100, ImageMaxSize: 10}}
+
+	tests := []struct {
+		role     string
+		mimeType string
+		size     int64
+		err      error
+	}{
+		{models.FileRoleAudio, utils.ContentTypeMP3, 100, nil},
+		{models.FileRoleAudio, utils.ContentTypeOpus, 101, errors.ErrFileTooLarge},
+		{models.FileRoleAudio, utils.ContentTypeJPEG, 1, errors.ErrUnsupportedAudioType},
+		{models.FileRoleCover, utils.ContentTypeWebP, 10, nil},
+		{models.FileRoleCover, utils.ContentTypePNG, 11, errors.ErrFileTooLarge},
+		{models.FileRoleCover, utils.ContentTypeFLAC, 1, errors.ErrUnsupportedImageType},
+		{models.FileRoleContent, utils.ContentTypeWAV, 100, nil},
+		{models.FileRoleContent, utils.ContentTypeJPEG, 11, errors.ErrFileTooLarge},
+		{models.FileRoleContent, utils.ContentTypeUnknown, 1, errors.ErrUnsupportedFileType},
+	}
+
+	for _, test := range tests {
+		if err := service.CheckContent(test.role, test.mimeType, test.size); err != test.err {
+			t.Errorf("CheckContent(%s, %s, %d) = %v, want %v", test.role, test.mimeType, test.size, err, test.err)
+		}
+	}
+}
+
+func TestRemoveFile(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	content := []byte("ID3 audio content")
+	hash := contentHash(content)
+	owner := models.FileOwner{Type: models.FileOwnerTrack, ID: primitive.NewObjectID(), Role: models.FileRoleAudio}
+
+	tests := []struct {
+		name      string
+		remaining *models.File // Record after the owner was removed, nil if the owner does not own it
+		responses []bson.D     // Replies to the commands following the decrement
+		commands  []string
+		kept      bool // Whether the content is kept
+	}{
+		{
+			name:      "content shared with another owner is kept",
+			remaining: &models.File{Filename: hash + ".mp3", RefCount: 1},
+			commands:  []string{"findAndModify"},
+			kept:      true,
+		},
+		{
+			name:      "the last reference removes the record and the content",
+			remaining: &models.File{Filename: hash + ".mp3", RefCount: 0},
+			responses: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}), countResponse(0), findResponse(mt)},
+			commands:  []string{"findAndModify", "delete", "aggregate", "find"},
+			kept:      false,
+		},
+		{
+			name:      "content recorded again meanwhile is kept",
+			remaining: &models.File{Filename: hash + ".mp3", RefCount: 0},
+			responses: []bson.D{mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}), countResponse(1), findResponse(mt)},
+			commands:  []string{"findAndModify", "delete", "aggregate", "find"},
+			kept:      true,
+		},
+		{
+			name:     "a file the owner does not own is ignored",
+			commands: []string{"findAndModify"},
+			kept:     true,
+		},
+	}
+
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			service, store := newTestFileService(mt)
+			if err := store.Put(context.Background(), hash+".mp3", bytes.NewReader(content), int64(len(content)), "audio/mpeg"); err != nil {
+				mt.Fatalf("Put: %v", err)
+			}
+			mt.AddMockResponses(append([]bson.D{fileResponse(test.remaining)}, test.responses...)...)
+
+			file := &models.File{ID: primitive.NewObjectID(), Filename: hash + ".mp3"}
+			if err := service.RemoveFile(file, owner); err != nil {
+				mt.Fatalf("RemoveFile: %v", err)
+			}
+			if names := commandNames(mt); !reflect.DeepEqual(names, test.commands) {
+				mt.Errorf("commands = %v, want %v", names, test.commands)
+			}
+			if kept := stored(store, hash+".mp3"); kept != test.kept {
+				mt.Errorf("content kept = %v, want %v", kept, test.kept)
+			}
+		})
+	}
+
+	mt.Run("the files derived from a removed file are released", func(mt *mtest.T) {
+		service, store := newTestFileService(mt)
+		cover := &models.File{ID: primitive.NewObjectID(), Filename: hash + ".jpg"}
+		thumbnailOwner := models.FileOwner{Type: models.FileOwnerFile, ID: cover.ID, Role: models.ThumbnailRole(300)}
+		thumbnail := &models.File{ID: primitive.NewObjectID(), Filename: "thumbnail.jpg", Owners: []models.FileOwner{thumbnailOwner}}
+		for _, key := range []string{cover.Filename, thumbnail.Filename} {
+			if err := store.Put(context.Background(), key, bytes.NewReader(content), int64(len(content)), ""); err != nil {
+				mt.Fatalf("Put: %v", err)
+			}
+		}
+		mt.AddMockResponses(
+			fileResponse(&models.File{Filename: cover.Filename}),                      // Release the cover
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}), countResponse(0), // Remove it
+			findResponse(mt, thumbnail),                                               // Find its thumbnail
+			fileResponse(&models.File{Filename: thumbnail.Filename}),                  // Release the thumbnail
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}), countResponse(0), // Remove it
+			findResponse(mt), // Nothing derives from it
+		)
+
+		if err := service.RemoveFile(cover, owner); err != nil {
+			mt.Fatalf("RemoveFile: %v", err)
+		}
+		if keys := storedKeys(store, cover.Filename, thumbnail.Filename); keys != nil {
+			mt.Errorf("stored %v, want the cover and its thumbnail removed", keys)
+		}
+	})
+
+	mt.Run("a nil file is ignored", func(mt *mtest.T) {
+		service, _ := newTestFileService(mt)
+		if err := service.RemoveFile(nil, models.FileOwner{}); err != nil {
+			mt.Errorf("RemoveFile(nil) = %v", err)
+		}
+	})
+}
@@ -25,11 +25,17 @@ func NewGenreService(client *mongo.Client, cfg *config.Config) *GenreService {
 	}
 }
 
-// AddGenre adds a new genre to the database
-func (s *GenreService) AddGenre(genre *models.Genre) (*models.Genre, error) {
+// AddGenre adds a new genre to the database on behalf of the given user
+func (s *GenreService) AddGenre(userId string, genre *models.Genre) (*models.Genre, error) {
+	createdBy, err := primitive.ObjectIDFromHex(userId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
 	genre.BeforeCreate() // Set default values before creating a genre
+	genre.CreatedBy = createdBy
 
-	_, err := s.collection.InsertOne(context.Background(), genre) // Insert genre into the database
+	_, err = s.collection.InsertOne(context.Background(), genre) // Insert genre into the database
 	if err != nil {
 		return nil, errors.ErrDatabaseOperation
 	}
@@ -74,6 +80,7 @@ func (s *GenreService) UpdateGenre(genreId string, updatedGenre *models.Genre) (
 		updatedGenre.Name = existingGenre.Name
 	}
 	updatedGenre.ID = existingGenre.ID
+	updatedGenre.CreatedBy = existingGenre.CreatedBy
 	updatedGenre.CreatedAt = existingGenre.CreatedAt
 	updatedGenre.BeforeUpdate() // Set updated values before updating the genre
 
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PlayService handles operations related to the play history
+type PlayService struct {
+	collection   *mongo.Collection // MongoDB collection for plays
+	trackService *TrackService     // TrackService to aggregate play statistics onto tracks
+}
+
+// NewPlayService creates a new PlayService
+func NewPlayService(client *mongo.Client, cfg *config.Config, trackService *TrackService) *PlayService {
+	return &PlayService{
+		collection:   utils.GetDBCollection(client, cfg, "plays"),
+		trackService: trackService,
+	}
+}
+
+// StartPlay records that a user started playing a track and counts the play on the track
+func (s *PlayService) StartPlay(userID, trackID primitive.ObjectID, deviceId string, startedAt time.Time) (*models.Play, error) {
+	play := &models.Play{
+		UserID:    userID,
+		TrackID:   trackID,
+		DeviceID:  deviceId,
+		StartedAt: startedAt,
+	}
+	play.BeforeCreate() // Set default values before creating the play
+
+	_, err := s.collection.InsertOne(context.Background(), play) // Insert the play into the database
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	err = s.trackService.RecordPlay(trackID, startedAt) // Aggregate the play onto the track
+	if err != nil {
+		return nil, err
+	}
+
+	return play, nil
+}
+
+// UpdatePlay records how long a play has been listened to; a completed play stays completed
+func (s *PlayService) UpdatePlay(playID primitive.ObjectID, listenedDuration float64, completed bool) error {
+	set := bson.M{
+		"listened_duration": listenedDuration,
+		"updated_at":        time.Now(),
+	}
+	if completed {
+		set["completed"] = true
+	}
+
+	_, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": playID}, bson.M{"$set": set}) // Update the play
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// ListHistory lists the plays of a user, most recent first, optionally limited to plays started
+// within the given time range, with pagination
+func (s *PlayService) ListHistory(userId string, from, to time.Time, page, limit int) ([]*models.Play, int64, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, 0, errors.ErrInvalidObjectID
+	}
+
+	// Limit the plays to the date range when one is given
+	filter := bson.M{"user_id": userObjectID}
+	startedAt := bson.M{}
+	if !from.IsZero() {
+		startedAt["$gte"] = from
+	}
+	if !to.IsZero() {
+		startedAt["$lt"] = to
+	}
+	if len(startedAt) > 0 {
+		filter["started_at"] = startedAt
+	}
+
+	skip := (page - 1) * limit // Calculate the number of documents to skip
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))                            // Set the number of documents to skip
+	findOptions.SetLimit(int64(limit))                          // Set the number of documents to return
+	findOptions.SetSort(bson.D{{Key: "started_at", Value: -1}}) // Sort by started_at in descending order
+
+	cursor, err := s.collection.Find(context.Background(), filter, findOptions) // Find the user's plays
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	var plays []*models.Play
+	err = cursor.All(context.Background(), &plays) // Decode all plays
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	// Count total matching documents to get the total number of plays
+	total, err := s.collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	return plays, total, nil // Return found plays and total count
+}
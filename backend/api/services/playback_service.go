@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultDeviceID is the device used when a client does not identify its device
+const DefaultDeviceID = "default"
+
+// restartThreshold is how far into a track, in seconds, previous restarts the track instead of going back
+const restartThreshold = 3
+
+// PlaybackCommand represents a playback action sent by a device
+type PlaybackCommand struct {
+	DeviceID string   // Device the action is performed on
+	Action   string   // One of the playback actions
+	Position *float64 // Position in seconds to play or seek to
+	Queue    []string // Track IDs that next and previous move through, replacing the current queue
+}
+
+// PlaybackService handles operations related to playback sessions
+type PlaybackService struct {
+	collection   *mongo.Collection // MongoDB collection for playback sessions
+	trackService *TrackService     // TrackService to resolve the tracks being played
+	playService  *PlayService      // PlayService to record the plays of the sessions
+}
+
+// NewPlaybackService creates a new PlaybackService
+func NewPlaybackService(client *mongo.Client, cfg *config.Config, trackService *TrackService, playService *PlayService) *PlaybackService {
+	return &PlaybackService{
+		collection:   utils.GetDBCollection(client, cfg, "playback_sessions"),
+		trackService: trackService,
+		playService:  playService,
+	}
+}
+
+// PlayPauseTrack performs a playback action on the given track for the user's device and returns the
+// updated session together with the track it now holds
+func (s *PlaybackService) PlayPauseTrack(userId, trackId string, command PlaybackCommand) (*models.PlaybackSession, *models.Track, error) {
+	if !models.IsValidPlaybackAction(command.Action) { // Validate action
+		return nil, nil, errors.ErrBadRequest
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, nil, errors.ErrInvalidObjectID
+	}
+
+	track, err := s.trackService.GetTrack(trackId) // Retrieve the track the action is performed on
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if command.DeviceID == "" {
+		command.DeviceID = DefaultDeviceID
+	}
+
+	session, err := s.findSession(bson.M{"user_id": userObjectID, "device_id": command.DeviceID}) // Retrieve the device's session
+	if err != nil && err != errors.ErrNoPlaybackSession {
+		return nil, nil, err
+	}
+
+	// Every action other than play applies to the track the device is currently playing
+	if command.Action != models.PlaybackActionPlay && (session == nil || session.TrackID != track.ID) {
+		return nil, nil, errors.ErrNoPlaybackSession
+	}
+
+	now := time.Now()
+
+	// Measure how long the current track has played since the state last changed, before the action changes it
+	var playID primitive.ObjectID
+	var startedAt *time.Time
+	var reachedEnd bool
+	if session != nil && !session.PlayID.IsZero() {
+		playID, startedAt = session.PlayID, session.StartedAt
+		reachedEnd, err = s.measure(session, track, now)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch command.Action {
+	case models.PlaybackActionPlay:
+		if session == nil {
+			session = &models.PlaybackSession{UserID: userObjectID, DeviceID: command.DeviceID}
+			session.BeforeCreate() // Set default values before creating the session
+		}
+		err = s.play(session, track, command, now)
+	case models.PlaybackActionPause:
+		session.Position = session.CurrentPosition(now, track.Duration)
+		session.State = models.PlaybackStatePaused
+	case models.PlaybackActionSeek:
+		if command.Position == nil || !isValidPosition(*command.Position, track) {
+			return nil, nil, errors.ErrInvalidInput
+		}
+		session.Position = *command.Position
+		if session.State == models.PlaybackStateStopped {
+			session.State = models.PlaybackStatePaused // Seeking a stopped track loads it without playing
+		}
+	case models.PlaybackActionStop:
+		session.Position = 0
+		session.State = models.PlaybackStateStopped
+		session.StartedAt = nil
+	case models.PlaybackActionNext:
+		track, err = s.skip(session, track, 1, now)
+	case models.PlaybackActionPrevious:
+		if session.CurrentPosition(now, track.Duration) > restartThreshold || session.QueueIndex == 0 {
+			s.load(session, track, now) // Restart the current track
+		} else {
+			track, err = s.skip(session, track, -1, now)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Record the listened time on the current play, then start a new play whenever a track starts from the beginning
+	if !playID.IsZero() {
+		err = s.playService.UpdatePlay(playID, session.Listened, reachedEnd)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if session.StartedAt != startedAt {
+		session.PlayID = primitive.NilObjectID
+		session.Listened = 0
+		if session.StartedAt != nil {
+			play, err := s.playService.StartPlay(session.UserID, session.TrackID, session.DeviceID, *session.StartedAt)
+			if err != nil {
+				return nil, nil, err
+			}
+			session.PlayID = play.ID
+		}
+	}
+
+	session.UpdatedAt = now // The position is measured from the last state change
+
+	// Store the session, creating it on the device's first play
+	filter := bson.M{"user_id": session.UserID, "device_id": session.DeviceID}
+	_, err = s.collection.ReplaceOne(context.Background(), filter, session, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, nil, errors.ErrDatabaseOperation
+	}
+
+	return session, track, nil
+}
+
+// GetPlayback retrieves the playback session of the given device, or the user's most recently updated
+// session when no device is given, together with its track; the track is nil if it has been deleted
+func (s *PlaybackService) GetPlayback(userId, deviceId string) (*models.PlaybackSession, *models.Track, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, nil, errors.ErrInvalidObjectID
+	}
+
+	filter := bson.M{"user_id": userObjectID}
+	if deviceId != "" {
+		filter["device_id"] = deviceId
+	}
+
+	session, err := s.findSession(filter) // Retrieve the session
+	if err != nil {
+		return nil, nil, err
+	}
+
+	track, err := s.trackService.GetTrack(session.TrackID.Hex()) // Retrieve the track of the session
+	if err != nil {
+		if err == errors.ErrTrackNotFound {
+			return session, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return session, track, nil
+}
+
+// findSession retrieves the most recently updated session matching the filter
+func (s *PlaybackService) findSession(filter bson.M) (*models.PlaybackSession, error) {
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "updated_at", Value: -1}}) // Sort by updated_at in descending order
+
+	var session models.PlaybackSession
+	err := s.collection.FindOne(context.Background(), filter, findOptions).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrNoPlaybackSession
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &session, nil
+}
+
+// measure adds the time played since the state last changed to the session's listened time and reports
+// whether playback has reached the end of the current track
+func (s *PlaybackService) measure(session *models.PlaybackSession, track *models.Track, now time.Time) (bool, error) {
+	// The session may hold a different track when another track is played
+	duration := track.Duration
+	if session.TrackID != track.ID {
+		current, err := s.trackService.GetTrack(session.TrackID.Hex())
+		if err != nil && err != errors.ErrTrackNotFound {
+			return false, err
+		}
+		duration = 0
+		if current != nil {
+			duration = current.Duration
+		}
+	}
+
+	position := session.CurrentPosition(now, duration)
+	if session.State == models.PlaybackStatePlaying {
+		session.Listened += position - session.Position
+	}
+
+	return duration > 0 && position >= float64(duration), nil
+}
+
+// play starts or resumes the track on the session, replacing the queue when one is given
+func (s *PlaybackService) play(session *models.PlaybackSession, track *models.Track, command PlaybackCommand, now time.Time) error {
+	// Replace the queue when one is given; the track must be part of it
+	if command.Queue != nil {
+		queue := make([]primitive.ObjectID, len(command.Queue))
+		for i, id := range command.Queue {
+			objectID, err := primitive.ObjectIDFromHex(id) // Convert string ID to ObjectID
+			if err != nil {
+				return errors.ErrInvalidObjectID
+			}
+			queue[i] = objectID
+		}
+		session.Queue = queue
+	}
+	index := queueIndex(session.Queue, track.ID)
+	if index < 0 {
+		if command.Queue != nil {
+			return errors.ErrInvalidInput
+		}
+		session.Queue = []primitive.ObjectID{track.ID} // Play a track outside the queue on its own
+		index = 0
+	}
+	session.QueueIndex = index
+
+	// Pick the position: the requested one, else resume this device, else pick up where another device left off
+	var position float64
+	if command.Position != nil {
+		if !isValidPosition(*command.Position, track) {
+			return errors.ErrInvalidInput
+		}
+		position = *command.Position
+	} else if session.TrackID == track.ID && session.State != models.PlaybackStateStopped {
+		position = session.CurrentPosition(now, track.Duration)
+	} else if latest, err := s.findSession(bson.M{"user_id": session.UserID}); err == nil && latest.TrackID == track.ID && latest.State != models.PlaybackStateStopped {
+		position = latest.CurrentPosition(now, track.Duration)
+	}
+	if track.Duration > 0 && position >= float64(track.Duration) {
+		position = 0 // Start a finished track over
+	}
+
+	if session.TrackID != track.ID || session.StartedAt == nil {
+		session.StartedAt = &now
+	}
+	session.TrackID = track.ID
+	session.State = models.PlaybackStatePlaying
+	session.Position = position
+
+	return nil
+}
+
+// skip moves the session by step through its queue, passing over deleted tracks, and returns the track it
+// lands on; moving past the end of the queue stops playback on the current track
+func (s *PlaybackService) skip(session *models.PlaybackSession, current *models.Track, step int, now time.Time) (*models.Track, error) {
+	for index := session.QueueIndex + step; index >= 0 && index < len(session.Queue); index += step {
+		track, err := s.trackService.GetTrack(session.Queue[index].Hex()) // Retrieve the queued track
+		if err == errors.ErrTrackNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		session.QueueIndex = index
+		s.load(session, track, now)
+		return track, nil
+	}
+
+	session.Position = 0
+	session.State = models.PlaybackStateStopped
+	session.StartedAt = nil
+	return current, nil
+}
+
+// load starts the track on the session from the beginning
+func (s *PlaybackService) load(session *models.PlaybackSession, track *models.Track, now time.Time) {
+	session.TrackID = track.ID
+	session.State = models.PlaybackStatePlaying
+	session.Position = 0
+	session.StartedAt = &now
+}
+
+// queueIndex returns the index of the track in the queue, or -1 if it is not queued
+func queueIndex(queue []primitive.ObjectID, trackID primitive.ObjectID) int {
+	for i, id := range queue {
+		if id == trackID {
+			return i
+		}
+	}
+	return -1
+}
+
+// isValidPosition reports whether the position lies within the track
+func isValidPosition(position float64, track *models.Track) bool {
+	return position >= 0 && (track.Duration <= 0 || position <= float64(track.Duration))
+}
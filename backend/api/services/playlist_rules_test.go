@@ -0,0 +1,157 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"music-library-management/api/models"
+	"music-library-management/errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCompilePlaylistRules(t *testing.T) {
+	rules := &models.PlaylistRules{
+		Match: &models.PlaylistRule{Operator: models.PlaylistRuleAnd, Rules: []models.PlaylistRule{
+			{Operator: models.PlaylistRuleEq, Field: "genre", Value: "Jazz"},
+			{Operator: models.PlaylistRuleBetween, Field: "release_year", Value: []interface{}{1950.0, 1969.0}},
+			{Operator: models.PlaylistRuleOr, Rules: []models.PlaylistRule{
+				{Operator: models.PlaylistRuleStartsWith, Field: "artist", Value: "Miles (Davis)"},
+				{Operator: models.PlaylistRuleNotIn, Field: "album", Value: primitive.A{"Kind of Blue"}},
+			}},
+			{Operator: models.PlaylistRuleGte, Field: "created_at", Value: "2024-01-02"},
+		}},
+		SortBy:    "play_count",
+		SortOrder: models.PlaylistSortDesc,
+	}
+
+	filter, sort, err := compilePlaylistRules(rules)
+	if err != nil {
+		t.Fatalf("compilePlaylistRules: %v", err)
+	}
+	want := bson.M{
+		"is_deleted": false,
+		"$and": bson.A{bson.M{"$and": bson.A{
+			bson.M{"genre": bson.M{"$eq": "Jazz"}},
+			bson.M{"release_year": bson.M{"$gte": 1950.0, "$lte": 1969.0}},
+			bson.M{"$or": bson.A{
+				bson.M{"artist": bson.M{"$regex": `^Miles \(Davis\)`, "$options": "i"}},
+				bson.M{"album": bson.M{"$nin": bson.A{"Kind of Blue"}}},
+			}},
+			bson.M{"created_at": bson.M{"$gte": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		}}},
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("filter = %v, want %v", filter, want)
+	}
+	if wantSort := (bson.D{{Key: "play_count", Value: -1}, {Key: "_id", Value: -1}}); !reflect.DeepEqual(sort, wantSort) {
+		t.Errorf("sort = %v, want %v", sort, wantSort)
+	}
+}
+
+func TestCompilePlaylistRulesDefaults(t *testing.T) {
+	filter, sort, err := compilePlaylistRules(&models.PlaylistRules{})
+	if err != nil {
+		t.Fatalf("compilePlaylistRules: %v", err)
+	}
+	if want := (bson.M{"is_deleted": false}); !reflect.DeepEqual(filter, want) {
+		t.Errorf("filter = %v, want %v", filter, want)
+	}
+	if want := (bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}); !reflect.DeepEqual(sort, want) {
+		t.Errorf("sort = %v, want %v", sort, want)
+	}
+}
+
+func TestCompilePlaylistRulesStored(t *testing.T) {
+	// Rules store their value even when it is zero or absent, and compile the same after a round trip through the database
+	tests := []struct {
+		rule models.PlaylistRule
+		want bson.M
+	}{
+		{models.PlaylistRule{Operator: models.PlaylistRuleEq, Field: "play_count", Value: 0.0}, bson.M{"play_count": bson.M{"$eq": 0.0}}},
+		{models.PlaylistRule{Operator: models.PlaylistRuleGt, Field: "release_year", Value: int32(0)}, bson.M{"release_year": bson.M{"$gt": 0.0}}},
+		{models.PlaylistRule{Operator: models.PlaylistRuleEq, Field: "genre", Value: ""}, bson.M{"genre": bson.M{"$eq": ""}}},
+		{
+			models.PlaylistRule{Operator: models.PlaylistRuleOr, Rules: []models.PlaylistRule{{Operator: models.PlaylistRuleLte, Field: "duration", Value: 0.0}}},
+			bson.M{"$or": bson.A{bson.M{"duration": bson.M{"$lte": 0.0}}}},
+		},
+	}
+
+	for _, test := range tests {
+		data, err := bson.Marshal(models.PlaylistRules{Match: &test.rule})
+		if err != nil {
+			t.Fatalf("bson.Marshal(%+v): %v", test.rule, err)
+		}
+		if _, err := bson.Raw(data).LookupErr("match", "value"); err != nil {
+			t.Errorf("stored rule %s has no value", bson.Raw(data))
+		}
+		var stored models.PlaylistRules
+		if err := bson.Unmarshal(data, &stored); err != nil {
+			t.Fatalf("bson.Unmarshal(%+v): %v", test.rule, err)
+		}
+
+		filter, _, err := compilePlaylistRules(&stored)
+		if err != nil {
+			t.Errorf("compilePlaylistRules(%+v) of the stored rule: %v", test.rule, err)
+			continue
+		}
+		if want := (bson.M{"is_deleted": false, "$and": bson.A{test.want}}); !reflect.DeepEqual(filter, want) {
+			t.Errorf("filter = %v, want %v", filter, want)
+		}
+	}
+}
+
+func TestCompilePlaylistRulesInLast(t *testing.T) {
+	rules := &models.PlaylistRules{Match: &models.PlaylistRule{Operator: models.PlaylistRuleInLast, Field: "last_played_at", Value: 7.0}}
+	before := time.Now().Add(-7 * 24 * time.Hour)
+	filter, _, err := compilePlaylistRules(rules)
+	if err != nil {
+		t.Fatalf("compilePlaylistRules: %v", err)
+	}
+
+	condition := filter["$and"].(bson.A)[0].(bson.M)["last_played_at"].(bson.M)
+	since := condition["$gte"].(time.Time)
+	if since.Before(before) || since.After(time.Now().Add(-7*24*time.Hour)) {
+		t.Errorf("since = %s, want seven days ago", since)
+	}
+}
+
+func TestCompilePlaylistRulesInvalid(t *testing.T) {
+	condition := func(operator, field string, value interface{}) *models.PlaylistRule {
+		return &models.PlaylistRule{Operator: operator, Field: field, Value: value}
+	}
+	nested := condition(models.PlaylistRuleEq, "genre", "Jazz")
+	for i := 0; i < maxPlaylistRuleDepth; i++ {
+		nested = &models.PlaylistRule{Operator: models.PlaylistRuleAnd, Rules: []models.PlaylistRule{*nested}}
+	}
+
+	tests := map[string]*models.PlaylistRules{
+		"unknown field":        {Match: condition(models.PlaylistRuleEq, "password_hash", "x")},
+		"unknown operator":     {Match: condition("regex", "title", "x")},
+		"text for a number":    {Match: condition(models.PlaylistRuleGt, "duration", "long")},
+		"number for a text":    {Match: condition(models.PlaylistRuleEq, "title", 1.0)},
+		"invalid date":         {Match: condition(models.PlaylistRuleLt, "created_at", "yesterday")},
+		"between one bound":    {Match: condition(models.PlaylistRuleBetween, "duration", []interface{}{60.0})},
+		"in without list":      {Match: condition(models.PlaylistRuleIn, "genre", "Jazz")},
+		"in empty list":        {Match: condition(models.PlaylistRuleIn, "genre", []interface{}{})},
+		"contains on a number": {Match: condition(models.PlaylistRuleContains, "duration", "1")},
+		"contains empty text":  {Match: condition(models.PlaylistRuleContains, "title", "")},
+		"in_last on a text":    {Match: condition(models.PlaylistRuleInLast, "title", 7.0)},
+		"in_last without days": {Match: condition(models.PlaylistRuleInLast, "created_at", 0.0)},
+		"empty group":          {Match: &models.PlaylistRule{Operator: models.PlaylistRuleOr}},
+		"group with a field":   {Match: &models.PlaylistRule{Operator: models.PlaylistRuleAnd, Field: "title", Rules: []models.PlaylistRule{*nested}}},
+		"condition with rules": {Match: &models.PlaylistRule{Operator: models.PlaylistRuleEq, Field: "title", Value: "x", Rules: []models.PlaylistRule{*nested}}},
+		"too deep":             {Match: nested},
+		"unknown sort field":   {SortBy: "password_hash"},
+		"unknown sort order":   {SortOrder: "random"},
+		"negative limit":       {Limit: -1},
+	}
+
+	for name, rules := range tests {
+		if _, _, err := compilePlaylistRules(rules); err != errors.ErrInvalidPlaylistRules {
+			t.Errorf("%s: err = %v, want ErrInvalidPlaylistRules", name, err)
+		}
+	}
+}
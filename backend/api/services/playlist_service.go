@@ -6,6 +6,8 @@ import (
 	"music-library-management/api/utils"
 	"music-library-management/config"
 	"music-library-management/errors"
+	"regexp"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,23 +17,38 @@ import (
 
 // PlaylistService handles operations related to playlists
 type PlaylistService struct {
-	collection   *mongo.Collection // MongoDB collection for playlists
-	trackService *TrackService     // TrackService to handle track-related operations
+	collection      *mongo.Collection // MongoDB collection for playlists
+	trackCollection *mongo.Collection // MongoDB collection for tracks, which smart playlists are resolved from
+	trackService    *TrackService     // TrackService to handle track-related operations
 }
 
 // NewPlaylistService creates a new instance of PlaylistService
 func NewPlaylistService(client *mongo.Client, cfg *config.Config, trackService *TrackService) *PlaylistService {
 	return &PlaylistService{
-		collection:   utils.GetDBCollection(client, cfg, "playlists"), // Get the playlists collection
-		trackService: trackService,                                    // Initialize trackService for track-related operations
+		collection:      utils.GetDBCollection(client, cfg, "playlists"), // Get the playlists collection
+		trackCollection: utils.GetDBCollection(client, cfg, "tracks"),    // Get the tracks collection
+		trackService:    trackService,                                    // Initialize trackService for track-related operations
 	}
 }
 
-// AddPlaylist adds a new playlist to the database
-func (s *PlaylistService) AddPlaylist(playlist *models.Playlist) (*models.Playlist, error) {
+// AddPlaylist adds a new playlist owned by the given user to the database
+func (s *PlaylistService) AddPlaylist(userId string, playlist *models.Playlist) (*models.Playlist, error) {
+	ownerID, err := primitive.ObjectIDFromHex(userId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	// Check that the rules of a smart playlist compile
+	if playlist.IsSmart() {
+		if _, _, err := compilePlaylistRules(playlist.Rules); err != nil {
+			return nil, err
+		}
+	}
+
 	playlist.BeforeCreate() // Set default values before creating a playlist
+	playlist.OwnerID = ownerID
 
-	_, err := s.collection.InsertOne(context.Background(), playlist) // Insert playlist into the database
+	_, err = s.collection.InsertOne(context.Background(), playlist) // Insert playlist into the database
 	if err != nil {
 		return nil, errors.ErrDatabaseOperation
 	}
@@ -39,13 +56,18 @@ func (s *PlaylistService) AddPlaylist(playlist *models.Playlist) (*models.Playli
 	return playlist, nil
 }
 
-// GetPlaylist retrieves a playlist by its ID
-func (s *PlaylistService) GetPlaylist(playlistId string) (*models.Playlist, error) {
+// GetPlaylist retrieves a playlist by its ID if it is visible to the given user
+func (s *PlaylistService) GetPlaylist(userId, playlistId string) (*models.Playlist, error) {
 	objectID, err := primitive.ObjectIDFromHex(playlistId) // Convert string ID to ObjectID
 	if err != nil {
 		return nil, errors.ErrInvalidObjectID
 	}
 
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
 	var playlist models.Playlist
 	err = s.collection.FindOne(context.Background(), bson.M{"_id": objectID, "is_deleted": false}).Decode(&playlist) // Find playlist by ID and check if it's not deleted
 	if err != nil {
@@ -55,18 +77,221 @@ func (s *PlaylistService) GetPlaylist(playlistId string) (*models.Playlist, erro
 		return nil, errors.ErrDatabaseOperation
 	}
 
+	// Hide private playlists of other users as if they did not exist
+	if !playlist.IsVisibleTo(userObjectID) {
+		return nil, errors.ErrPlaylistNotFound
+	}
+
 	return &playlist, nil
 }
 
+// PlaylistDetail is a playlist along with a page of its entries, their tracks resolved in order, and totals over
+// all its entries
+type PlaylistDetail struct {
+	models.Playlist `bson:",inline"`
+	Entries         []PlaylistEntry `bson:"entries"`        // Entries of the requested page
+	TrackCount      int             `bson:"track_count"`    // Number of entries in the playlist, including unavailable tracks
+	TotalDuration   int             `bson:"total_duration"` // Duration in seconds of the available tracks of the playlist
+}
+
+// PlaylistEntry is a track at a position of a playlist
+type PlaylistEntry struct {
+	Position int                `bson:"position"` // Zero-based position in the playlist
+	TrackID  primitive.ObjectID `bson:"track_id"` // Track at the position
+	Track    *models.Track      `bson:"track"`    // The track, nil if it no longer exists
+}
+
+// IsAvailable reports whether the track of the entry can still be played, i.e. it exists and is not deleted
+func (e *PlaylistEntry) IsAvailable() bool {
+	return e.Track != nil && !e.Track.IsDeleted
+}
+
+// GetPlaylistDetail retrieves a playlist visible to the given user along with a page of its entries, resolving
+// their tracks and the totals of the playlist in a single aggregation. The entries of smart playlists are the
+// tracks their rules currently match
+func (s *PlaylistService) GetPlaylistDetail(userId, playlistId string, page, limit int) (*PlaylistDetail, error) {
+	objectID, err := primitive.ObjectIDFromHex(playlistId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	skip := (page - 1) * limit // Calculate the number of entries to skip
+	tracks := bson.M{"$ifNull": bson.A{"$tracks", bson.A{}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": objectID, "is_deleted": false}}}, // Find playlist by ID and check if it's not deleted
+		{{Key: "$set", Value: bson.M{"page_tracks": bson.M{"$slice": bson.A{tracks, skip, limit}}}}},
+		// Resolve the durations of every available track for the totals, and the tracks of the page in full
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "tracks",
+			"localField":   "tracks",
+			"foreignField": "_id",
+			"pipeline":     bson.A{bson.M{"$match": bson.M{"is_deleted": false}}, bson.M{"$project": bson.M{"duration": 1}}},
+			"as":           "available_tracks",
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "tracks",
+			"localField":   "page_tracks",
+			"foreignField": "_id",
+			"as":           "page_documents",
+		}}},
+		// Lay the resolved tracks out in the order of the playlist, repeating tracks the playlist holds more than once
+		{{Key: "$set", Value: bson.M{
+			"track_count": bson.M{"$size": tracks},
+			"total_duration": bson.M{"$sum": bson.M{"$map": bson.M{
+				"input": tracks,
+				"as":    "id",
+				"in": bson.M{"$let": bson.M{
+					"vars": bson.M{"index": bson.M{"$indexOfArray": bson.A{"$available_tracks._id", "$$id"}}},
+					"in":   bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$$index", 0}}, bson.M{"$getField": bson.M{"field": "duration", "input": bson.M{"$arrayElemAt": bson.A{"$available_tracks", "$$index"}}}}, 0}},
+				}},
+			}}},
+			"entries": bson.M{"$map": bson.M{
+				"input": bson.M{"$range": bson.A{0, bson.M{"$size": "$page_tracks"}}},
+				"as":    "i",
+				"in": bson.M{
+					"position": bson.M{"$add": bson.A{skip, "$$i"}},
+					"track_id": bson.M{"$arrayElemAt": bson.A{"$page_tracks", "$$i"}},
+					"track": bson.M{"$first": bson.M{"$filter": bson.M{
+						"input": "$page_documents",
+						"cond":  bson.M{"$eq": bson.A{"$$this._id", bson.M{"$arrayElemAt": bson.A{"$page_tracks", "$$i"}}}},
+					}}},
+				},
+			}},
+		}}},
+		{{Key: "$unset", Value: bson.A{"page_tracks", "available_tracks", "page_documents"}}},
+	}
+
+	cursor, err := s.collection.Aggregate(context.Background(), pipeline) // Resolve the playlist and its tracks
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	var details []PlaylistDetail
+	if err := cursor.All(context.Background(), &details); err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+	if len(details) == 0 {
+		return nil, errors.ErrPlaylistNotFound
+	}
+
+	// Hide private playlists of other users as if they did not exist
+	if !details[0].IsVisibleTo(userObjectID) {
+		return nil, errors.ErrPlaylistNotFound
+	}
+
+	if details[0].IsSmart() {
+		if err := s.resolveSmartPlaylist(&details[0], page, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	return &details[0], nil
+}
+
+// resolveSmartPlaylist fills the detail of a smart playlist with a page of the tracks its rules match and their
+// totals, evaluating the rules against the catalog as it is now
+func (s *PlaylistService) resolveSmartPlaylist(detail *PlaylistDetail, page, limit int) error {
+	filter, sort, err := compilePlaylistRules(detail.Rules)
+	if err != nil {
+		return err
+	}
+
+	skip := (page - 1) * limit // Calculate the number of tracks to skip
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: sort}},
+	}
+	if detail.Rules.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: detail.Rules.Limit}}) // Keep the first tracks only
+	}
+	// Count the matching tracks and return the page of them in one go
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+		"totals": bson.A{bson.M{"$group": bson.M{
+			"_id":            nil,
+			"track_count":    bson.M{"$sum": 1},
+			"total_duration": bson.M{"$sum": "$duration"},
+		}}},
+		"tracks": bson.A{bson.M{"$skip": skip}, bson.M{"$limit": limit}},
+	}}})
+
+	cursor, err := s.trackCollection.Aggregate(context.Background(), pipeline, options.Aggregate().SetAllowDiskUse(true)) // Sorting the whole catalog may exceed the memory limit
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	var results []struct {
+		Totals []struct {
+			TrackCount    int `bson:"track_count"`
+			TotalDuration int `bson:"total_duration"`
+		} `bson:"totals"`
+		Tracks []models.Track `bson:"tracks"`
+	}
+	if err := cursor.All(context.Background(), &results); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	if len(results[0].Totals) > 0 {
+		detail.TrackCount = results[0].Totals[0].TrackCount
+		detail.TotalDuration = results[0].Totals[0].TotalDuration
+	}
+	detail.Entries = make([]PlaylistEntry, len(results[0].Tracks))
+	for i := range results[0].Tracks {
+		track := &results[0].Tracks[i]
+		detail.Entries[i] = PlaylistEntry{Position: skip + i, TrackID: track.ID, Track: track}
+	}
+
+	return nil
+}
+
+// getOwnedPlaylist retrieves a playlist by its ID and ensures the given user owns it
+func (s *PlaylistService) getOwnedPlaylist(userId, playlistId string) (*models.Playlist, error) {
+	playlist, err := s.GetPlaylist(userId, playlistId) // Retrieve the playlist if the user can see it
+	if err != nil {
+		return nil, err
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	// Only the owner may change a playlist
+	if !playlist.IsOwnedBy(userObjectID) {
+		return nil, errors.ErrForbidden
+	}
+
+	return playlist, nil
+}
+
+// visiblePlaylistsFilter builds a filter matching the non-deleted playlists the given user may list
+func visiblePlaylistsFilter(userObjectID primitive.ObjectID) bson.M {
+	return bson.M{
+		"is_deleted": false, // Filter out deleted playlists
+		"$or": []bson.M{
+			{"owner_id": userObjectID},                      // The user's own playlists
+			{"visibility": models.PlaylistVisibilityPublic}, // Public playlists of anyone
+			{"visibility": bson.M{"$exists": false}},        // Legacy playlists created before visibility existed
+		},
+	}
+}
+
 // UpdatePlaylist updates an existing playlist
-func (s *PlaylistService) UpdatePlaylist(playlistId string, updatedPlaylist *models.Playlist) (*models.Playlist, error) {
+func (s *PlaylistService) UpdatePlaylist(userId, playlistId string, updatedPlaylist *models.Playlist) (*models.Playlist, error) {
 	objectID, err := primitive.ObjectIDFromHex(playlistId) // Convert string ID to ObjectID
 	if err != nil {
 		return nil, errors.ErrInvalidObjectID
 	}
 
-	// Retrieve the existing playlist to preserve the old values
-	existingPlaylist, err := s.GetPlaylist(playlistId)
+	// Retrieve the existing playlist to preserve the old values and check ownership
+	existingPlaylist, err := s.getOwnedPlaylist(userId, playlistId)
 	if err != nil {
 		return nil, err
 	}
@@ -75,17 +300,46 @@ func (s *PlaylistService) UpdatePlaylist(playlistId string, updatedPlaylist *mod
 	if updatedPlaylist.Name == "" {
 		updatedPlaylist.Name = existingPlaylist.Name
 	}
-	updatedPlaylist.ID = existingPlaylist.ID
-	updatedPlaylist.CreatedAt = existingPlaylist.CreatedAt
+	if updatedPlaylist.Visibility == "" {
+		updatedPlaylist.Visibility = existingPlaylist.Visibility
+	}
 	updatedPlaylist.BeforeUpdate() // Set updated values before updating the playlist
 
 	filter := bson.M{"_id": objectID, "is_deleted": false} // Filter to find the playlist by ID and check if it's not deleted
-	update := bson.M{
-		"$set": updatedPlaylist, // Set updated playlist values
+	set := bson.M{
+		// Set the updated settings only, leaving the tracks to concurrent edits
+		"name":             updatedPlaylist.Name,
+		"visibility":       updatedPlaylist.Visibility,
+		"allow_duplicates": updatedPlaylist.AllowDuplicates,
+		"updated_at":       updatedPlaylist.UpdatedAt,
+	}
+	update := bson.M{"$set": set}
+
+	// Duplicates can only be disallowed in a playlist without any, checked against the tracks as they were read
+	if !updatedPlaylist.AllowDuplicates && existingPlaylist.AllowDuplicates {
+		if existingPlaylist.HasDuplicateTracks() {
+			return nil, errors.ErrPlaylistHasDuplicates
+		}
+		filter["version"] = versionFilter(existingPlaylist.Version)
+	}
+
+	// Replace the rules of a smart playlist, which changes its tracks
+	if updatedPlaylist.IsSmart() {
+		if !existingPlaylist.IsSmart() {
+			return nil, errors.ErrNotSmartPlaylist
+		}
+		if _, _, err := compilePlaylistRules(updatedPlaylist.Rules); err != nil {
+			return nil, err
+		}
+		set["rules"] = updatedPlaylist.Rules
+		update["$inc"] = bson.M{"version": 1}
 	}
 
 	result := s.collection.FindOneAndUpdate(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)) // Update playlist in the database and return the updated document
 	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return nil, errors.ErrPlaylistConflict // The tracks changed since they were checked for duplicates
+		}
 		return nil, errors.ErrDatabaseOperation
 	}
 
@@ -99,14 +353,14 @@ func (s *PlaylistService) UpdatePlaylist(playlistId string, updatedPlaylist *mod
 }
 
 // DeletePlaylist soft deletes a playlist by setting is_deleted to true
-func (s *PlaylistService) DeletePlaylist(playlistId string) error {
+func (s *PlaylistService) DeletePlaylist(userId, playlistId string) error {
 	objectID, err := primitive.ObjectIDFromHex(playlistId) // Convert string ID to ObjectID
 	if err != nil {
 		return errors.ErrInvalidObjectID
 	}
 
-	// Retrieve the existing playlist
-	playlist, err := s.GetPlaylist(playlistId)
+	// Retrieve the existing playlist and check ownership
+	playlist, err := s.getOwnedPlaylist(userId, playlistId)
 	if err != nil {
 		return err
 	}
@@ -130,8 +384,14 @@ func (s *PlaylistService) DeletePlaylist(playlistId string) error {
 	return nil
 }
 
-// ListPlaylists lists all playlists with pagination
-func (s *PlaylistService) ListPlaylists(page, limit int) ([]*models.Playlist, int64, error) {
+// ListPlaylists lists the playlists visible to the given user with pagination
+func (s *PlaylistService) ListPlaylists(userId string, page, limit int) ([]*models.Playlist, int64, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, 0, errors.ErrInvalidObjectID
+	}
+	filter := visiblePlaylistsFilter(userObjectID) // Only the user's own playlists and public ones
+
 	skip := (page - 1) * limit // Calculate the number of documents to skip
 	findOptions := options.Find()
 	findOptions.SetSkip(int64(skip))                            // Set the number of documents to skip
@@ -139,7 +399,7 @@ func (s *PlaylistService) ListPlaylists(page, limit int) ([]*models.Playlist, in
 	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by created_at in descending order
 
 	// Execute the find query
-	cursor, err := s.collection.Find(context.Background(), bson.M{"is_deleted": false}, findOptions) // Find playlists that are not deleted
+	cursor, err := s.collection.Find(context.Background(), filter, findOptions) // Find visible playlists that are not deleted
 	if err != nil {
 		return nil, 0, errors.ErrDatabaseOperation
 	}
@@ -151,7 +411,7 @@ func (s *PlaylistService) ListPlaylists(page, limit int) ([]*models.Playlist, in
 	}
 
 	// Count total matching documents
-	total, err := s.collection.CountDocuments(context.Background(), bson.M{"is_deleted": false}) // Get the total number of playlists that are not deleted
+	total, err := s.collection.CountDocuments(context.Background(), filter) // Get the total number of visible playlists that are not deleted
 	if err != nil {
 		return nil, 0, errors.ErrDatabaseOperation
 	}
@@ -159,85 +419,407 @@ func (s *PlaylistService) ListPlaylists(page, limit int) ([]*models.Playlist, in
 	return playlists, total, nil // Return found playlists and total count
 }
 
-// AddTrackToPlaylist adds a track to a playlist
-func (s *PlaylistService) AddTrackToPlaylist(playlistId, trackId string) error {
+// AddTrackToPlaylist inserts a track into a playlist at the given position, or appends it when position is nil.
+// A track already in the playlist is rejected unless the playlist allows duplicates
+func (s *PlaylistService) AddTrackToPlaylist(userId, playlistId, trackId string, position *int) (*models.Playlist, error) {
 	playlistObjectID, err := primitive.ObjectIDFromHex(playlistId) // Convert playlist ID to ObjectID
 	if err != nil {
-		return errors.ErrInvalidObjectID
+		return nil, errors.ErrInvalidObjectID
 	}
 
 	// Check if the track exists
 	track, err := s.trackService.GetTrack(trackId) // Get the track by ID
 	if err != nil {
-		return errors.ErrTrackNotFound
+		return nil, errors.ErrTrackNotFound
 	}
 
-	// Retrieve the existing playlist
-	playlist, err := s.GetPlaylist(playlistId)
+	// Retrieve the existing playlist and check ownership
+	playlist, err := s.getOwnedPlaylist(userId, playlistId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	if playlist.IsSmart() {
+		return nil, errors.ErrSmartPlaylistTracks // The tracks of smart playlists follow their rules
+	}
 	// Check if the track already exists in the playlist
-	for _, t := range playlist.Tracks {
-		if t == track.ID {
-			return errors.ErrTrackAlreadyInPlaylist
-		}
+	if !playlist.AllowDuplicates && playlist.TrackIndex(track.ID) >= 0 {
+		return nil, errors.ErrTrackAlreadyInPlaylist
 	}
 
 	filter := bson.M{"_id": playlistObjectID, "is_deleted": false} // Filter to find the playlist by ID and check if it's not deleted
+	push := bson.M{"$each": bson.A{track.ID}}
+	if position != nil {
+		if *position < 0 || *position > len(playlist.Tracks) {
+			return nil, errors.ErrInvalidPosition
+		}
+		push["$position"] = *position
+		filter["version"] = versionFilter(playlist.Version) // Positions refer to the tracks as they were read
+	}
+	if !playlist.AllowDuplicates {
+		filter["tracks"] = bson.M{"$ne": track.ID} // The track may have been added meanwhile
+	}
 	update := bson.M{
-		"$addToSet": bson.M{"tracks": track.ID}, // Add track ID to the tracks array in the playlist
+		"$push": bson.M{"tracks": push}, // Insert the track ID into the tracks array in the playlist
+		"$inc":  bson.M{"version": 1},
+		"$set":  bson.M{"updated_at": time.Now()},
 	}
 
-	result := s.collection.FindOneAndUpdate(context.Background(), filter, update, nil) // Update the playlist with the new track
-	if result.Err() != nil {
-		return errors.ErrDatabaseOperation
+	return s.updateTracks(filter, update) // Update the playlist with the new track
+}
+
+// MoveTrackInPlaylist moves the track at one position of a playlist to another, shifting the tracks in between.
+// When a version is given, the move is rejected if the playlist changed since that version
+func (s *PlaylistService) MoveTrackInPlaylist(userId, playlistId string, from, to int, version *int64) (*models.Playlist, error) {
+	// Retrieve the existing playlist and check ownership
+	playlist, err := s.getOwnedPlaylist(userId, playlistId)
+	if err != nil {
+		return nil, err
+	}
+	if playlist.IsSmart() {
+		return nil, errors.ErrSmartPlaylistTracks // The tracks of smart playlists follow their rules
+	}
+	if version != nil && *version != playlist.Version {
+		return nil, errors.ErrPlaylistConflict
+	}
+	if from < 0 || from >= len(playlist.Tracks) || to < 0 || to >= len(playlist.Tracks) {
+		return nil, errors.ErrInvalidPosition
 	}
 
-	return nil
+	tracks := make([]primitive.ObjectID, 0, len(playlist.Tracks))
+	tracks = append(tracks, playlist.Tracks[:from]...)
+	tracks = append(tracks, playlist.Tracks[from+1:]...)
+	tracks = append(tracks[:to], append([]primitive.ObjectID{playlist.Tracks[from]}, tracks[to:]...)...)
+
+	return s.setTracks(playlist, tracks)
 }
 
-// RemoveTrackFromPlaylist removes a track from a playlist
-func (s *PlaylistService) RemoveTrackFromPlaylist(playlistId, trackId string) error {
+// ReorderPlaylistTracks replaces the tracks of a playlist with a reordering of them, as long as the playlist did not
+// change since the given version. The list must hold the same tracks as the playlist, as many times each
+func (s *PlaylistService) ReorderPlaylistTracks(userId, playlistId string, trackIds []string, version int64) (*models.Playlist, error) {
+	tracks := make([]primitive.ObjectID, len(trackIds))
+	for i, trackId := range trackIds {
+		trackID, err := primitive.ObjectIDFromHex(trackId) // Convert track ID to ObjectID
+		if err != nil {
+			return nil, errors.ErrInvalidObjectID
+		}
+		tracks[i] = trackID
+	}
+
+	// Retrieve the existing playlist and check ownership
+	playlist, err := s.getOwnedPlaylist(userId, playlistId)
+	if err != nil {
+		return nil, err
+	}
+	if playlist.IsSmart() {
+		return nil, errors.ErrSmartPlaylistTracks // The tracks of smart playlists follow their rules
+	}
+	if version != playlist.Version {
+		return nil, errors.ErrPlaylistConflict
+	}
+
+	// Check that the list holds every entry of the playlist and nothing else
+	if len(tracks) != len(playlist.Tracks) {
+		return nil, errors.ErrInvalidTrackOrder
+	}
+	counts := make(map[primitive.ObjectID]int, len(tracks))
+	for _, trackID := range playlist.Tracks {
+		counts[trackID]++
+	}
+	for _, trackID := range tracks {
+		if counts[trackID] == 0 {
+			return nil, errors.ErrInvalidTrackOrder
+		}
+		counts[trackID]--
+	}
+
+	return s.setTracks(playlist, tracks)
+}
+
+// RemoveTrackFromPlaylist removes a track from a playlist: the entry at the given position, or every occurrence
+// of the track when position is nil
+func (s *PlaylistService) RemoveTrackFromPlaylist(userId, playlistId, trackId string, position *int) (*models.Playlist, error) {
 	playlistObjectID, err := primitive.ObjectIDFromHex(playlistId) // Convert playlist ID to ObjectID
 	if err != nil {
-		return errors.ErrInvalidObjectID
+		return nil, errors.ErrInvalidObjectID
 	}
 
-	// Check if the track exists
-	track, err := s.trackService.GetTrack(trackId) // Get the track by ID
+	// Tracks deleted since they were added can be removed too
+	trackID, err := primitive.ObjectIDFromHex(trackId) // Convert track ID to ObjectID
 	if err != nil {
-		return errors.ErrTrackNotFound
+		return nil, errors.ErrInvalidObjectID
 	}
 
-	// Retrieve the existing playlist
-	playlist, err := s.GetPlaylist(playlistId)
+	// Retrieve the existing playlist and check ownership
+	playlist, err := s.getOwnedPlaylist(userId, playlistId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Check if the track does not exist in the playlist
-	found := false
-	for _, t := range playlist.Tracks {
-		if t == track.ID {
-			found = true
-			break
+	if playlist.IsSmart() {
+		return nil, errors.ErrSmartPlaylistTracks // The tracks of smart playlists follow their rules
+	}
+	if position != nil {
+		// Check if the entry at the position is the track
+		if *position < 0 || *position >= len(playlist.Tracks) || playlist.Tracks[*position] != trackID {
+			return nil, errors.ErrTrackNotInPlaylist
 		}
+
+		tracks := make([]primitive.ObjectID, 0, len(playlist.Tracks)-1)
+		tracks = append(tracks, playlist.Tracks[:*position]...)
+		tracks = append(tracks, playlist.Tracks[*position+1:]...)
+		return s.setTracks(playlist, tracks)
 	}
-	if !found {
-		return errors.ErrTrackNotInPlaylist
+
+	// Check if the track does not exist in the playlist
+	if playlist.TrackIndex(trackID) < 0 {
+		return nil, errors.ErrTrackNotInPlaylist
 	}
 
 	filter := bson.M{"_id": playlistObjectID, "is_deleted": false} // Filter to find the playlist by ID and check if it's not deleted
 	update := bson.M{
-		"$pull": bson.M{"tracks": track.ID}, // Remove track ID from the tracks array in the playlist
+		"$pull": bson.M{"tracks": trackID}, // Remove track ID from the tracks array in the playlist
+		"$inc":  bson.M{"version": 1},
+		"$set":  bson.M{"updated_at": time.Now()},
 	}
 
-	result := s.collection.FindOneAndUpdate(context.Background(), filter, update, nil) // Update the playlist by removing the track
-	if result.Err() != nil {
-		return errors.ErrDatabaseOperation
+	return s.updateTracks(filter, update) // Update the playlist by removing the track
+}
+
+// setTracks replaces the tracks of a playlist, as long as they did not change since the playlist was read
+func (s *PlaylistService) setTracks(playlist *models.Playlist, tracks []primitive.ObjectID) (*models.Playlist, error) {
+	filter := bson.M{"_id": playlist.ID, "is_deleted": false, "version": versionFilter(playlist.Version)}
+	update := bson.M{
+		"$set": bson.M{"tracks": tracks, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
 	}
 
-	return nil
+	return s.updateTracks(filter, update)
+}
+
+// updateTracks applies an update of the tracks of the playlist matching the filter and returns the updated playlist;
+// the filter guards the update against concurrent edits, which are reported as ErrPlaylistConflict
+func (s *PlaylistService) updateTracks(filter bson.M, update bson.M) (*models.Playlist, error) {
+	var playlist models.Playlist
+	err := s.collection.FindOneAndUpdate(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&playlist)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrPlaylistConflict
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &playlist, nil
+}
+
+// versionFilter matches playlists at the given version; playlists created before versions existed are at version 0
+func versionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+// Kinds of the track fields the rules of smart playlists compare
+const (
+	ruleFieldText   = iota // Strings
+	ruleFieldNumber        // Integers and floats
+	ruleFieldDate          // Timestamps, given as RFC 3339 timestamps or dates
+)
+
+// maxPlaylistRuleDepth is the deepest nesting of groups the rule tree of a smart playlist may have
+const maxPlaylistRuleDepth = 8
+
+// playlistRuleFields maps the track fields smart playlists can be filtered and sorted by to their kind
+var playlistRuleFields = map[string]int{
+	"title":          ruleFieldText,
+	"artist":         ruleFieldText,
+	"album":          ruleFieldText,
+	"genre":          ruleFieldText,
+	"release_year":   ruleFieldNumber,
+	"duration":       ruleFieldNumber,
+	"play_count":     ruleFieldNumber,
+	"created_at":     ruleFieldDate,
+	"last_played_at": ruleFieldDate,
+}
+
+// compilePlaylistRules compiles the rules of a smart playlist to the filter and sort of the tracks they match,
+// reporting rules that cannot be compiled as ErrInvalidPlaylistRules
+func compilePlaylistRules(rules *models.PlaylistRules) (bson.M, bson.D, error) {
+	filter := bson.M{"is_deleted": false} // Deleted tracks leave smart playlists
+	if rules.Match != nil {
+		match, err := compilePlaylistRule(rules.Match, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		filter["$and"] = bson.A{match}
+	}
+
+	sortBy := rules.SortBy
+	if sortBy == "" {
+		sortBy = "created_at" // Tracks in the order they were added
+	}
+	if _, ok := playlistRuleFields[sortBy]; !ok {
+		return nil, nil, errors.ErrInvalidPlaylistRules
+	}
+	order := 1
+	switch rules.SortOrder {
+	case "", models.PlaylistSortAsc:
+	case models.PlaylistSortDesc:
+		order = -1
+	default:
+		return nil, nil, errors.ErrInvalidPlaylistRules
+	}
+	if rules.Limit < 0 {
+		return nil, nil, errors.ErrInvalidPlaylistRules
+	}
+	sort := bson.D{{Key: sortBy, Value: order}, {Key: "_id", Value: order}} // Break ties so pages do not overlap
+
+	return filter, sort, nil
+}
+
+// compilePlaylistRule compiles a node of the rule tree of a smart playlist, at the given depth, to a filter
+func compilePlaylistRule(rule *models.PlaylistRule, depth int) (bson.M, error) {
+	if depth > maxPlaylistRuleDepth {
+		return nil, errors.ErrInvalidPlaylistRules
+	}
+
+	// Groups combine the filters of their sub-rules
+	if rule.Operator == models.PlaylistRuleAnd || rule.Operator == models.PlaylistRuleOr {
+		if len(rule.Rules) == 0 || rule.Field != "" {
+			return nil, errors.ErrInvalidPlaylistRules
+		}
+		clauses := make(bson.A, len(rule.Rules))
+		for i := range rule.Rules {
+			clause, err := compilePlaylistRule(&rule.Rules[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			clauses[i] = clause
+		}
+		return bson.M{"$" + rule.Operator: clauses}, nil
+	}
+
+	// Conditions compare a field of the tracks with their value
+	kind, ok := playlistRuleFields[rule.Field]
+	if !ok || len(rule.Rules) > 0 {
+		return nil, errors.ErrInvalidPlaylistRules
+	}
+	switch rule.Operator {
+	case models.PlaylistRuleEq, models.PlaylistRuleNe, models.PlaylistRuleGt, models.PlaylistRuleGte, models.PlaylistRuleLt, models.PlaylistRuleLte:
+		value, err := playlistRuleValue(kind, rule.Value)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{rule.Field: bson.M{"$" + rule.Operator: value}}, nil
+
+	case models.PlaylistRuleBetween:
+		bounds, err := playlistRuleValues(kind, rule.Value)
+		if err != nil || len(bounds) != 2 {
+			return nil, errors.ErrInvalidPlaylistRules
+		}
+		return bson.M{rule.Field: bson.M{"$gte": bounds[0], "$lte": bounds[1]}}, nil
+
+	case models.PlaylistRuleIn, models.PlaylistRuleNotIn:
+		values, err := playlistRuleValues(kind, rule.Value)
+		if err != nil || len(values) == 0 {
+			return nil, errors.ErrInvalidPlaylistRules
+		}
+		if rule.Operator == models.PlaylistRuleNotIn {
+			return bson.M{rule.Field: bson.M{"$nin": values}}, nil
+		}
+		return bson.M{rule.Field: bson.M{"$in": values}}, nil
+
+	case models.PlaylistRuleContains, models.PlaylistRuleStartsWith:
+		text, ok := rule.Value.(string)
+		if kind != ruleFieldText || !ok || text == "" {
+			return nil, errors.ErrInvalidPlaylistRules
+		}
+		pattern := regexp.QuoteMeta(text) // Match the text literally
+		if rule.Operator == models.PlaylistRuleStartsWith {
+			pattern = "^" + pattern
+		}
+		return bson.M{rule.Field: bson.M{"$regex": pattern, "$options": "i"}}, nil
+
+	case models.PlaylistRuleInLast:
+		days, ok := playlistRuleNumber(rule.Value)
+		if kind != ruleFieldDate || !ok || days <= 0 {
+			return nil, errors.ErrInvalidPlaylistRules
+		}
+		// Relative to the time the playlist is read, so the tracks keep following the catalog
+		since := time.Now().Add(-time.Duration(days * float64(24*time.Hour)))
+		return bson.M{rule.Field: bson.M{"$gte": since}}, nil
+	}
+
+	return nil, errors.ErrInvalidPlaylistRules
+}
+
+// playlistRuleValues converts the list a condition compares a field with, as decoded from JSON or BSON, to values
+// of the kind of the field
+func playlistRuleValues(kind int, value interface{}) (bson.A, error) {
+	var list []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		list = v
+	case primitive.A:
+		list = v
+	default:
+		return nil, errors.ErrInvalidPlaylistRules
+	}
+
+	values := make(bson.A, len(list))
+	for i, item := range list {
+		converted, err := playlistRuleValue(kind, item)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = converted
+	}
+	return values, nil
+}
+
+// playlistRuleValue converts the value a condition compares a field with, as decoded from JSON or BSON, to a value
+// of the kind of the field
+func playlistRuleValue(kind int, value interface{}) (interface{}, error) {
+	switch kind {
+	case ruleFieldText:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+	case ruleFieldNumber:
+		if number, ok := playlistRuleNumber(value); ok {
+			return number, nil
+		}
+	case ruleFieldDate:
+		switch v := value.(type) {
+		case string:
+			// Dates are stored as they were given, and parsed whenever the rules are compiled
+			if date, err := time.Parse(time.RFC3339, v); err == nil {
+				return date, nil
+			}
+			if date, err := time.Parse(time.DateOnly, v); err == nil {
+				return date, nil
+			}
+		case time.Time:
+			return v, nil
+		case primitive.DateTime:
+			return v.Time(), nil
+		}
+	}
+	return nil, errors.ErrInvalidPlaylistRules
+}
+
+// playlistRuleNumber converts a number decoded from JSON or BSON to a float
+func playlistRuleNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
 }
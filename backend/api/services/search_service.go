@@ -8,6 +8,7 @@ import (
 	"music-library-management/errors"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -68,18 +69,23 @@ func (s *SearchService) SearchTracks(query string, page, limit int) ([]*models.T
 	return tracks, total, nil // Return found tracks and total count
 }
 
-// SearchPlaylists searches for playlists by name
-func (s *SearchService) SearchPlaylists(query string, page, limit int) ([]*models.Playlist, int64, error) {
+// SearchPlaylists searches the playlists visible to the given user by name
+func (s *SearchService) SearchPlaylists(userId, query string, page, limit int) ([]*models.Playlist, int64, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userId) // Convert user ID to ObjectID
+	if err != nil {
+		return nil, 0, errors.ErrInvalidObjectID
+	}
+
 	skip := (page - 1) * limit // Calculate the number of documents to skip
 	findOptions := options.Find()
 	findOptions.SetSkip(int64(skip))                            // Set the number of documents to skip
 	findOptions.SetLimit(int64(limit))                          // Set the number of documents to return
 	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by created_at in descending order
 
-	// Create a filter for case-insensitive substring search by name and not deleted
+	// Create a filter for case-insensitive substring search by name among visible, not deleted playlists
 	filter := bson.M{
 		"$and": []bson.M{
-			{"is_deleted": false}, // Filter out deleted playlists
+			visiblePlaylistsFilter(userObjectID), // Only the user's own playlists and public ones
 			{"name": bson.M{"$regex": query, "$options": "i"}},
 		},
 	}
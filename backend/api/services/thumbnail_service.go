@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"io"
+
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+	"music-library-management/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bounds of the thumbnail sizes that can be requested, and the step sizes are rounded up to
+const (
+	MinThumbnailSize  = 16
+	MaxThumbnailSize  = 2048
+	thumbnailSizeStep = 16
+)
+
+// maxImagePixels is the largest number of pixels of a cover image that is decoded to scale it down
+const maxImagePixels = 50_000_000
+
+// ThumbnailService generates the thumbnails of cover images and stores them as files derived from the cover image
+type ThumbnailService struct {
+	fileService *FileService // Service storing the thumbnails
+}
+
+// NewThumbnailService creates a new ThumbnailService
+func NewThumbnailService(fileService *FileService) *ThumbnailService {
+	return &ThumbnailService{
+		fileService: fileService,
+	}
+}
+
+// ThumbnailSize validates a requested thumbnail size and rounds it up to a multiple of the size step,
+// so that only a bounded number of thumbnails is stored for each cover image
+func ThumbnailSize(size int) (int, error) {
+	if size < MinThumbnailSize || size > MaxThumbnailSize {
+		return 0, errors.ErrInvalidInput
+	}
+	return (size + thumbnailSizeStep - 1) / thumbnailSizeStep * thumbnailSizeStep, nil
+}
+
+// GenerateThumbnails makes sure the cover image has a thumbnail in each of the standard ThumbnailSizes and
+// returns them; thumbnails that already exist, e.g. of identical content uploaded before, are reused
+func (s *ThumbnailService) GenerateThumbnails(c *gin.Context, cover *models.File) ([]models.Thumbnail, error) {
+	var img image.Image // Cover image, decoded when the first missing thumbnail is generated
+	thumbnails := make([]models.Thumbnail, 0, len(models.ThumbnailSizes))
+	for _, size := range models.ThumbnailSizes {
+		file, err := s.getThumbnail(c, cover, size, &img)
+		if err != nil {
+			return nil, err
+		}
+		thumbnails = append(thumbnails, models.Thumbnail{Size: size, FileID: file.ID})
+	}
+
+	return thumbnails, nil
+}
+
+// GetThumbnail retrieves the thumbnail of the cover image fitting the given size, generating and storing it on
+// first request
+func (s *ThumbnailService) GetThumbnail(c *gin.Context, cover *models.File, size int) (*models.File, error) {
+	var img image.Image
+	return s.getThumbnail(c, cover, size, &img)
+}
+
+// getThumbnail retrieves or generates a thumbnail, decoding the cover image into img unless it already was
+func (s *ThumbnailService) getThumbnail(c *gin.Context, cover *models.File, size int, img *image.Image) (*models.File, error) {
+	thumbnail, err := s.fileService.GetDerivedFile(cover, models.ThumbnailRole(size))
+	if err != errors.ErrFileNotFound {
+		return thumbnail, err
+	}
+
+	if *img == nil {
+		*img, err = s.decodeCover(cover)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, width, height, err := utils.EncodeThumbnail(*img, size) // Scale the cover image down
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+
+	return s.fileService.StoreDerivedFile(c, cover, models.ThumbnailRole(size), bytes.NewReader(data), width, height)
+}
+
+// decodeCover decodes the stored cover image, refusing images too large to be decoded safely
+func (s *ThumbnailService) decodeCover(cover *models.File) (image.Image, error) {
+	content, _, err := s.fileService.OpenFile(cover) // Open the cover image
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	// Check the dimensions before decoding the pixels
+	config, _, err := image.DecodeConfig(content)
+	if err != nil {
+		return nil, errors.ErrInvalidImageFile
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, errors.ErrFileTooLarge
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.ErrInternalServer
+	}
+
+	img, err := utils.DecodeImage(content)
+	if err != nil {
+		return nil, errors.ErrInvalidImageFile
+	}
+
+	return img, nil
+}
@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"math"
+	"music-library-management/api/audio"
 	"music-library-management/api/models"
 	"music-library-management/api/utils"
 	"music-library-management/config"
 	"music-library-management/errors"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,20 +19,61 @@ import (
 // TrackService handles operations related to tracks
 type TrackService struct {
 	collection *mongo.Collection // MongoDB collection for tracks
+	config     *config.Config    // Application configuration holding the duration policy
 }
 
 // NewTrackService creates a new TrackService
 func NewTrackService(client *mongo.Client, cfg *config.Config) *TrackService {
 	return &TrackService{
 		collection: utils.GetDBCollection(client, cfg, "tracks"),
+		config:     cfg,
 	}
 }
 
-// AddTrack adds a new track to the database
-func (s *TrackService) AddTrack(track *models.Track) (*models.Track, error) {
+// ApplyStreamInfo sets the duration and stream properties computed from a track's audio file,
+// checking a client-supplied duration against the computed one
+func (s *TrackService) ApplyStreamInfo(track *models.Track, info *audio.StreamInfo, clientDuration int) error {
+	duration, err := s.CheckDuration(int(math.Round(info.Duration)), clientDuration)
+	if err != nil {
+		return err
+	}
+
+	track.Duration = duration
+	track.Audio.Container = info.Container
+	track.Audio.Codec = info.Codec
+	track.Audio.BitDepth = info.BitDepth
+	track.Audio.Bitrate = info.Bitrate
+	track.Audio.SampleRate = info.SampleRate
+	track.Audio.Channels = info.Channels
+	track.Audio.ChannelMode = info.ChannelMode
+	return nil
+}
+
+// CheckDuration returns the duration to store for a track given its computed duration and a client-supplied one:
+// the computed duration, unless the two differ by more than the tolerance and the policy is to reject
+func (s *TrackService) CheckDuration(computedDuration, clientDuration int) (int, error) {
+	difference := computedDuration - clientDuration
+	if difference < 0 {
+		difference = -difference
+	}
+
+	if clientDuration != 0 && difference > s.config.DurationTolerance && s.config.DurationMismatchPolicy == config.DurationPolicyReject {
+		return 0, errors.ErrDurationMismatch
+	}
+	return computedDuration, nil
+}
+
+// AddTrack adds a new track to the database on behalf of the given user
+func (s *TrackService) AddTrack(userId string, track *models.Track) (*models.Track, error) {
+	createdBy, err := primitive.ObjectIDFromHex(userId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
 	track.BeforeCreate() // Set default values before creating a new track
+	track.CreatedBy = createdBy
 
-	_, err := s.collection.InsertOne(context.Background(), track) // Insert the track into the database
+	_, err = s.collection.InsertOne(context.Background(), track) // Insert the track into the database
 	if err != nil {
 		return nil, errors.ErrDatabaseOperation
 	}
@@ -90,16 +134,37 @@ func (s *TrackService) UpdateTrack(trackId string, updatedTrack *models.Track) (
 	if updatedTrack.Duration == 0 {
 		updatedTrack.Duration = existingTrack.Duration
 	}
-	if updatedTrack.Mp3FileUrl == "" {
-		updatedTrack.Mp3FileUrl = existingTrack.Mp3FileUrl
+	if updatedTrack.Audio.FileUrl == "" {
+		updatedTrack.Audio = existingTrack.Audio
 	}
-	updatedTrack.ID = existingTrack.ID
-	updatedTrack.CreatedAt = existingTrack.CreatedAt
 	updatedTrack.BeforeUpdate() // Set updated values before updating the track
 
+	// Set only the fields a client edits; play statistics, renditions and loudness are updated concurrently by
+	// plays and background jobs, so writing back the values read above could undo their updates
 	filter := bson.M{"_id": objectID, "is_deleted": false} // Filter to find the track by ID and ensure it's not deleted
+	fields := bson.M{
+		"title":           updatedTrack.Title,
+		"cover_image_url": updatedTrack.CoverImageUrl,
+		"artist":          updatedTrack.Artist,
+		"album":           updatedTrack.Album,
+		"genre":           updatedTrack.Genre,
+		"release_year":    updatedTrack.ReleaseYear,
+		"duration":        updatedTrack.Duration,
+		"audio":           updatedTrack.Audio,
+		"updated_at":      updatedTrack.UpdatedAt,
+	}
+	if !updatedTrack.CoverFileID.IsZero() {
+		fields["cover_file_id"] = updatedTrack.CoverFileID
+	}
+	if updatedTrack.Thumbnails != nil {
+		fields["thumbnails"] = updatedTrack.Thumbnails // Only a new cover image comes with thumbnails
+	}
 	update := bson.M{
-		"$set": updatedTrack, // Update the track with new values
+		"$set": fields, // Update the track with new values
+	}
+	audioReplaced := updatedTrack.Audio.FileUrl != existingTrack.Audio.FileUrl
+	if audioReplaced {
+		update["$unset"] = bson.M{"loudness": ""} // The loudness of the previous audio file no longer applies
 	}
 
 	result := s.collection.FindOneAndUpdate(context.Background(), filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)) // Update the track in the database
@@ -113,6 +178,16 @@ func (s *TrackService) UpdateTrack(trackId string, updatedTrack *models.Track) (
 		return nil, errors.ErrDatabaseOperation
 	}
 
+	// Moving the track between albums, or replacing its audio, changes the loudness of the albums
+	if audioReplaced || track.Album != existingTrack.Album {
+		if err := s.UpdateAlbumLoudness(existingTrack.Album); err != nil {
+			return nil, err
+		}
+		if err := s.UpdateAlbumLoudness(track.Album); err != nil {
+			return nil, err
+		}
+	}
+
 	return &track, nil
 }
 
@@ -147,19 +222,36 @@ func (s *TrackService) DeleteTrack(trackId string) error {
 		return errors.ErrDatabaseOperation
 	}
 
-	return nil
+	return s.UpdateAlbumLoudness(track.Album) // The album loudness no longer includes the track
 }
 
 // ListTracks lists all tracks with pagination
 func (s *TrackService) ListTracks(page, limit int) ([]*models.Track, int64, error) {
+	return s.findTracks(bson.M{"is_deleted": false}, bson.D{{Key: "created_at", Value: -1}}, page, limit) // Sort by created_at in descending order
+}
+
+// ListMostPlayedTracks lists the tracks that have been played, most played first, with pagination
+func (s *TrackService) ListMostPlayedTracks(page, limit int) ([]*models.Track, int64, error) {
+	filter := bson.M{"is_deleted": false, "play_count": bson.M{"$gt": 0}}
+	return s.findTracks(filter, bson.D{{Key: "play_count", Value: -1}, {Key: "last_played_at", Value: -1}}, page, limit) // Break ties by the latest play
+}
+
+// ListRecentlyPlayedTracks lists the tracks that have been played, most recently played first, with pagination
+func (s *TrackService) ListRecentlyPlayedTracks(page, limit int) ([]*models.Track, int64, error) {
+	filter := bson.M{"is_deleted": false, "last_played_at": bson.M{"$ne": nil}}
+	return s.findTracks(filter, bson.D{{Key: "last_played_at", Value: -1}}, page, limit) // Sort by last_played_at in descending order
+}
+
+// findTracks lists the tracks matching the filter in the given order with pagination
+func (s *TrackService) findTracks(filter bson.M, sort bson.D, page, limit int) ([]*models.Track, int64, error) {
 	skip := (page - 1) * limit // Calculate the number of documents to skip
 	findOptions := options.Find()
-	findOptions.SetSkip(int64(skip))                            // Set the number of documents to skip
-	findOptions.SetLimit(int64(limit))                          // Set the number of documents to return
-	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by created_at in descending order
+	findOptions.SetSkip(int64(skip))   // Set the number of documents to skip
+	findOptions.SetLimit(int64(limit)) // Set the number of documents to return
+	findOptions.SetSort(sort)          // Set the order of the documents
 
-	// Execute the find query to get tracks that are not deleted
-	cursor, err := s.collection.Find(context.Background(), bson.M{"is_deleted": false}, findOptions)
+	// Execute the find query to get the matching tracks
+	cursor, err := s.collection.Find(context.Background(), filter, findOptions)
 	if err != nil {
 		return nil, 0, errors.ErrDatabaseOperation
 	}
@@ -170,8 +262,8 @@ func (s *TrackService) ListTracks(page, limit int) ([]*models.Track, int64, erro
 		return nil, 0, errors.ErrDatabaseOperation
 	}
 
-	// Count total matching documents to get the total number of tracks that are not deleted
-	total, err := s.collection.CountDocuments(context.Background(), bson.M{"is_deleted": false})
+	// Count total matching documents to get the total number of tracks
+	total, err := s.collection.CountDocuments(context.Background(), filter)
 	if err != nil {
 		return nil, 0, errors.ErrDatabaseOperation
 	}
@@ -179,10 +271,191 @@ func (s *TrackService) ListTracks(page, limit int) ([]*models.Track, int64, erro
 	return tracks, total, nil // Return found tracks and total count
 }
 
-// PlayPauseTrack plays or pauses a track based on the action provided
-func (s *TrackService) PlayPauseTrack(trackId string, action string) error {
-	if action != "play" && action != "pause" { // Validate action
-		return errors.ErrBadRequest
+// GetTracksByIDs retrieves the tracks with the given IDs that are not deleted, keyed by ID
+func (s *TrackService) GetTracksByIDs(ids []primitive.ObjectID) (map[primitive.ObjectID]*models.Track, error) {
+	cursor, err := s.collection.Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}, "is_deleted": false}) // Find the tracks by ID
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	var tracks []*models.Track
+	err = cursor.All(context.Background(), &tracks) // Decode all tracks
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	tracksByID := make(map[primitive.ObjectID]*models.Track, len(tracks))
+	for _, track := range tracks {
+		tracksByID[track.ID] = track
+	}
+
+	return tracksByID, nil
+}
+
+// RecordPlay increments the play count of a track and sets when it was last played
+func (s *TrackService) RecordPlay(trackID primitive.ObjectID, playedAt time.Time) error {
+	update := bson.M{
+		"$inc": bson.M{"play_count": 1},
+		"$max": bson.M{"last_played_at": playedAt}, // Plays may be recorded out of order across devices
+	}
+
+	_, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": trackID}, update) // Update the track's play statistics
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// SetRendition links a rendition to a track, replacing the rendition of the same name, as long as the track is not
+// deleted and still plays the audio file the rendition was transcoded from. It returns the rendition replaced, if any,
+// or ErrTrackNotFound if the rendition no longer belongs to the track
+func (s *TrackService) SetRendition(trackID, sourceFileID primitive.ObjectID, rendition models.Rendition) (*models.Rendition, error) {
+	filter := bson.M{"_id": trackID, "is_deleted": false, "audio.file_id": sourceFileID}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"renditions": bson.M{"$concatArrays": bson.A{
+				bson.M{"$filter": bson.M{
+					"input": bson.M{"$ifNull": bson.A{"$renditions", bson.A{}}},
+					"cond":  bson.M{"$ne": bson.A{"$$this.name", rendition.Name}},
+				}},
+				bson.A{rendition},
+			}},
+			"updated_at": time.Now(),
+		}}},
+	}
+
+	// Swap the rendition in a single update, returning the track as it was to find the rendition replaced
+	var track models.Track
+	err := s.collection.FindOneAndUpdate(context.Background(), filter, pipeline).Decode(&track)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrTrackNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	for i := range track.Renditions {
+		if track.Renditions[i].Name == rendition.Name {
+			return &track.Renditions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ClearRenditions unlinks all renditions from a track, e.g. when its audio file is replaced
+func (s *TrackService) ClearRenditions(trackID primitive.ObjectID) error {
+	update := bson.M{"$unset": bson.M{"renditions": ""}}
+	_, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": trackID}, update) // Remove the renditions of the track
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// SetLoudness stores the loudness measured from a track's audio file, as long as the track is not deleted and still
+// plays that audio file, and updates the album gain of the track's album. It returns ErrTrackNotFound if the
+// measured audio file no longer belongs to the track
+func (s *TrackService) SetLoudness(trackID, sourceFileID primitive.ObjectID, loudness models.Loudness) error {
+	filter := bson.M{"_id": trackID, "is_deleted": false, "audio.file_id": sourceFileID}
+	update := bson.M{"$set": bson.M{"loudness": loudness, "updated_at": time.Now()}}
+
+	var track models.Track
+	err := s.collection.FindOneAndUpdate(context.Background(), filter, update).Decode(&track) // Store the loudness on the track
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.ErrTrackNotFound
+		}
+		return errors.ErrDatabaseOperation
+	}
+
+	return s.UpdateAlbumLoudness(track.Album)
+}
+
+// UpdateAlbumLoudness computes the loudness of the analyzed tracks of an album as a whole and stores the resulting
+// album gain and peak on each of them; tracks without an album get no album gain
+func (s *TrackService) UpdateAlbumLoudness(album string) error {
+	if album == "" {
+		return nil
+	}
+
+	filter := bson.M{"album": album, "is_deleted": false, "loudness": bson.M{"$exists": true}}
+	cursor, err := s.collection.Find(context.Background(), filter, options.Find().SetProjection(bson.M{"loudness": 1})) // Find the album's analyzed tracks
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	var tracks []models.Track
+	if err := cursor.All(context.Background(), &tracks); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	measures := make([]audio.Loudness, len(tracks))
+	peak := math.Inf(-1)
+	for i, track := range tracks {
+		measures[i] = audio.Loudness{Integrated: track.Loudness.Integrated, TruePeak: track.Loudness.TruePeak, GatedBlocks: track.Loudness.GatedBlocks}
+		peak = max(peak, track.Loudness.TruePeak)
+	}
+	gain := audio.Gain(audio.AlbumLoudness(measures))
+
+	update := bson.M{"$set": bson.M{"loudness.album_gain": gain, "loudness.album_peak": peak}}
+	if _, err := s.collection.UpdateMany(context.Background(), filter, update); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// ListTracksToAnalyze lists the tracks whose audio file has not been analyzed for loudness yet, or every track
+// when all is true; tracks added before files were linked to tracks cannot be analyzed
+func (s *TrackService) ListTracksToAnalyze(all bool) ([]models.Track, error) {
+	filter := bson.M{"is_deleted": false, "audio.file_id": bson.M{"$exists": true}}
+	if !all {
+		filter["loudness"] = bson.M{"$exists": false}
+	}
+
+	cursor, err := s.collection.Find(context.Background(), filter, options.Find().SetProjection(bson.M{"audio": 1})) // Find the tracks to analyze
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	tracks := []models.Track{}
+	if err := cursor.All(context.Background(), &tracks); err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return tracks, nil
+}
+
+// CollectFileReferences adds the files used by tracks that are not deleted to the references
+func (s *TrackService) CollectFileReferences(refs *FileReferences) error {
+	projection := bson.M{"cover_file_id": 1, "cover_image_url": 1, "audio.file_id": 1, "audio.file_url": 1, "renditions.file_id": 1}
+	cursor, err := s.collection.Find(context.Background(), bson.M{"is_deleted": false}, options.Find().SetProjection(projection)) // Find the live tracks
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	defer cursor.Close(context.Background())
+
+	// Tracks added before file IDs were stored on tracks only reference their files by URL
+	for cursor.Next(context.Background()) {
+		var track models.Track
+		if err := cursor.Decode(&track); err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		refs.AddID(track.CoverFileID)
+		refs.AddID(track.Audio.FileID)
+		refs.AddURL(track.CoverImageUrl)
+		refs.AddURL(track.Audio.FileUrl)
+		for _, rendition := range track.Renditions {
+			refs.AddID(rendition.FileID)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return errors.ErrDatabaseOperation
 	}
 
 	return nil
@@ -0,0 +1,765 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"music-library-management/api/audio"
+	"music-library-management/api/hls"
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Limits of the transcoding workers
+const (
+	transcodeMaxAttempts  = 3                // Number of times a job is started before it fails
+	transcodePollInterval = 30 * time.Second // How often idle workers look for jobs queued by other instances
+	transcodeMaxErrorLen  = 1000             // Bytes of ffmpeg's error output kept on a failed job
+)
+
+// Outcomes of transcoding attempts that are not ffmpeg errors
+var (
+	errTranscodeCanceled  = errors.NewError("the track no longer plays the transcoded audio file") // The track was deleted or plays another audio file
+	errTranscodeAbandoned = errors.NewError("the worker stopped during the last attempt")          // The job was left running by a worker that stopped
+)
+
+// transcodeEncoder is the ffmpeg encoder and output format of a rendition codec
+type transcodeEncoder struct {
+	encoder string // Name of the ffmpeg encoder
+	format  string // Name of the ffmpeg muxer
+}
+
+// transcodeEncoders maps the rendition codecs to the encoders and formats they are transcoded with
+var transcodeEncoders = map[string]transcodeEncoder{
+	config.RenditionCodecMP3:  {encoder: "libmp3lame", format: "mp3"},
+	config.RenditionCodecOpus: {encoder: "libopus", format: "opus"}, // Opus in an Ogg file
+	config.RenditionCodecAAC:  {encoder: "aac", format: "adts"},     // Bare AAC frames, which need no seekable output
+}
+
+// TranscodeService transcodes the audio files of tracks into the configured renditions with a local ffmpeg binary
+// and computes their waveforms. Jobs are queued in MongoDB and run by background workers, so they survive restarts
+// and can be listed per track
+type TranscodeService struct {
+	collection   *mongo.Collection // MongoDB collection for transcoding jobs
+	packages     *mongo.Collection // MongoDB collection for the HLS packages of audio files
+	waveforms    *mongo.Collection // MongoDB collection for the waveforms of audio files
+	config       *config.Config    // Application configuration holding the renditions and the ffmpeg settings
+	fileService  *FileService      // Service storing the renditions
+	trackService *TrackService     // Service linking the renditions to their tracks
+	wake         chan struct{}     // Signals idle workers that jobs were queued
+	ffmpeg       bool              // Whether the ffmpeg binary was found; without it only native decoding is available
+}
+
+// NewTranscodeService creates a new TranscodeService
+func NewTranscodeService(client *mongo.Client, cfg *config.Config, fileService *FileService, trackService *TrackService) *TranscodeService {
+	return &TranscodeService{
+		collection:   utils.GetDBCollection(client, cfg, "transcode_jobs"),
+		packages:     utils.GetDBCollection(client, cfg, "hls_packages"),
+		waveforms:    utils.GetDBCollection(client, cfg, "waveforms"),
+		config:       cfg,
+		fileService:  fileService,
+		trackService: trackService,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// EnqueueTranscodes queues a job for each configured rendition of a track's audio file, one packaging it for HLS,
+// one computing its waveform and one measuring its loudness, after canceling the jobs of the audio file it replaced and releasing the
+// renditions transcoded from it. Renditions that would not be smaller than a lossy audio file, e.g. a 320k MP3
+// of a 256k AAC file, are skipped; streaming falls back to the original
+func (s *TranscodeService) EnqueueTranscodes(track *models.Track) error {
+	if s.config.TranscodeWorkers <= 0 {
+		return nil // Transcoding is disabled
+	}
+
+	// Cancel the jobs left for the previous audio file; running ones are not linked once they finish
+	now := time.Now()
+	filter := bson.M{"track_id": track.ID, "status": bson.M{"$in": bson.A{models.TranscodeStatusQueued, models.TranscodeStatusRunning}}}
+	update := bson.M{"$set": bson.M{"status": models.TranscodeStatusCanceled, "finished_at": now, "updated_at": now}}
+	if _, err := s.collection.UpdateMany(context.Background(), filter, update); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	// Unlink the renditions of the previous audio file
+	if len(track.Renditions) > 0 {
+		if err := s.trackService.ClearRenditions(track.ID); err != nil {
+			return err
+		}
+		for _, rendition := range track.Renditions {
+			s.releaseRendition(track.ID, rendition)
+		}
+		track.Renditions = nil
+	}
+
+	var jobs []interface{}
+	for _, rendition := range s.config.Renditions {
+		if !audio.IsLossless(track.Audio.Codec) && track.Audio.Bitrate > 0 && track.Audio.Bitrate <= rendition.Bitrate {
+			continue // The rendition would not save any bandwidth
+		}
+
+		job := &models.TranscodeJob{
+			TrackID:      track.ID,
+			SourceFileID: track.Audio.FileID,
+			Kind:         models.TranscodeKindRendition,
+			Rendition:    rendition.Name,
+			Codec:        rendition.Codec,
+			Bitrate:      rendition.Bitrate,
+		}
+		job.BeforeCreate() // Set default values before queuing the job
+		jobs = append(jobs, job)
+	}
+	if len(s.config.HLSBitrates) > 0 {
+		job := &models.TranscodeJob{
+			TrackID:      track.ID,
+			SourceFileID: track.Audio.FileID,
+			Kind:         models.TranscodeKindHLS,
+			Rendition:    models.TranscodeKindHLS,
+			Codec:        config.RenditionCodecAAC,
+		}
+		job.BeforeCreate() // Set default values before queuing the job
+		jobs = append(jobs, job)
+	}
+	for _, kind := range []string{models.TranscodeKindWaveform, models.TranscodeKindLoudness} {
+		job := &models.TranscodeJob{
+			TrackID:      track.ID,
+			SourceFileID: track.Audio.FileID,
+			Kind:         kind,
+			Rendition:    kind,
+		}
+		job.BeforeCreate() // Set default values before queuing the job
+		jobs = append(jobs, job)
+	}
+
+	if _, err := s.collection.InsertMany(context.Background(), jobs); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	s.signal() // Wake a worker to pick the jobs up
+	return nil
+}
+
+// EnqueueLoudnessAnalysis queues a job measuring the loudness of every track whose audio file has not been analyzed,
+// such as the tracks added before loudness was measured, or of every track when all is true. Tracks already waiting
+// for the analysis are skipped. It returns the number of jobs queued
+func (s *TranscodeService) EnqueueLoudnessAnalysis(all bool) (int, error) {
+	if s.config.TranscodeWorkers <= 0 {
+		return 0, errors.ErrTranscodingDisabled
+	}
+
+	tracks, err := s.trackService.ListTracksToAnalyze(all)
+	if err != nil {
+		return 0, err
+	}
+
+	filter := bson.M{"kind": models.TranscodeKindLoudness, "status": bson.M{"$in": bson.A{models.TranscodeStatusQueued, models.TranscodeStatusRunning}}}
+	pending, err := s.collection.Distinct(context.Background(), "track_id", filter) // Find the tracks waiting for the analysis
+	if err != nil {
+		return 0, errors.ErrDatabaseOperation
+	}
+	waiting := make(map[primitive.ObjectID]bool, len(pending))
+	for _, id := range pending {
+		if id, ok := id.(primitive.ObjectID); ok {
+			waiting[id] = true
+		}
+	}
+
+	var jobs []interface{}
+	for _, track := range tracks {
+		if waiting[track.ID] {
+			continue
+		}
+		job := &models.TranscodeJob{
+			TrackID:      track.ID,
+			SourceFileID: track.Audio.FileID,
+			Kind:         models.TranscodeKindLoudness,
+			Rendition:    models.TranscodeKindLoudness,
+		}
+		job.BeforeCreate() // Set default values before queuing the job
+		jobs = append(jobs, job)
+	}
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+
+	if _, err := s.collection.InsertMany(context.Background(), jobs); err != nil {
+		return 0, errors.ErrDatabaseOperation
+	}
+
+	s.signal() // Wake a worker to pick the jobs up
+	return len(jobs), nil
+}
+
+// ListTranscodeJobs lists the transcoding jobs of a track's current audio file, oldest first
+func (s *TranscodeService) ListTranscodeJobs(track *models.Track) ([]models.TranscodeJob, error) {
+	filter := bson.M{"track_id": track.ID, "source_file_id": track.Audio.FileID}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}) // Sort by created_at in ascending order
+
+	cursor, err := s.collection.Find(context.Background(), filter, findOptions) // Find the track's jobs
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	jobs := []models.TranscodeJob{}
+	if err := cursor.All(context.Background(), &jobs); err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return jobs, nil
+}
+
+// StartWorkers runs the configured number of transcoding workers in the background. When the ffmpeg binary cannot
+// be found, the workers only analyze audio files decoded natively, and other jobs wait for a restart
+func (s *TranscodeService) StartWorkers() {
+	if s.config.TranscodeWorkers <= 0 {
+		return
+	}
+	if _, err := exec.LookPath(s.config.FFmpegPath); err != nil {
+		log.Printf("Transcoding disabled, ffmpeg not found: %v; only MP3 and WAV files are analyzed", err)
+	} else {
+		s.ffmpeg = true
+	}
+
+	for i := 0; i < s.config.TranscodeWorkers; i++ {
+		go s.work()
+	}
+}
+
+// signal wakes an idle worker without blocking when every worker is busy
+func (s *TranscodeService) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work runs queued jobs until none is left, then waits to be woken or for the next poll
+func (s *TranscodeService) work() {
+	ticker := time.NewTicker(transcodePollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			job, err := s.claimJob()
+			if err != nil {
+				if err != mongo.ErrNoDocuments {
+					log.Printf("Error claiming a transcoding job: %v", err)
+				}
+				break
+			}
+			s.signal() // Let another worker claim the next job meanwhile
+			s.runJob(job)
+		}
+
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimJob marks the oldest queued job as running and returns it; jobs left running by a worker that stopped
+// are claimed again once they ran for twice the timeout
+func (s *TranscodeService) claimJob() (*models.TranscodeJob, error) {
+	now := time.Now()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.TranscodeStatusQueued},
+		bson.M{"status": models.TranscodeStatusRunning, "started_at": bson.M{"$lt": now.Add(-2 * s.config.TranscodeTimeout)}},
+	}}
+	if !s.ffmpeg {
+		filter["kind"] = bson.M{"$in": bson.A{models.TranscodeKindWaveform, models.TranscodeKindLoudness}} // Only analyses can run without ffmpeg
+	}
+	update := bson.M{
+		"$set": bson.M{"status": models.TranscodeStatusRunning, "started_at": now, "updated_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}). // Run jobs in the order they were queued
+		SetReturnDocument(options.After)
+
+	var job models.TranscodeJob
+	if err := s.collection.FindOneAndUpdate(context.Background(), filter, update, findOptions).Decode(&job); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// runJob runs a claimed job and records the outcome; failed attempts are queued again
+// until the job has been started transcodeMaxAttempts times
+func (s *TranscodeService) runJob(job *models.TranscodeJob) {
+	var fileID primitive.ObjectID
+	err := errTranscodeAbandoned
+	if job.Attempts <= transcodeMaxAttempts {
+		fileID, err = s.transcode(job)
+	}
+
+	status, message := models.TranscodeStatusDone, ""
+	switch {
+	case err == errTranscodeCanceled:
+		status = models.TranscodeStatusCanceled
+	case err != nil && job.Attempts < transcodeMaxAttempts:
+		status, message = models.TranscodeStatusQueued, err.Error()
+	case err != nil:
+		status, message = models.TranscodeStatusFailed, err.Error()
+		log.Printf("Error transcoding track %s to %s: %v", job.TrackID.Hex(), job.Rendition, err)
+	}
+
+	now := time.Now()
+	set := bson.M{"status": status, "error": message, "updated_at": now}
+	if status != models.TranscodeStatusQueued {
+		set["finished_at"] = now
+	}
+	if !fileID.IsZero() {
+		set["file_id"] = fileID
+	}
+
+	// Jobs canceled meanwhile stay canceled
+	_, err = s.collection.UpdateOne(context.Background(), bson.M{"_id": job.ID, "status": models.TranscodeStatusRunning}, bson.M{"$set": set})
+	if err != nil {
+		log.Printf("Error recording transcoding job %s: %v", job.ID.Hex(), err)
+	}
+}
+
+// transcode runs a job on the track's audio file: it transcodes a rendition, stored as a file owned by the track
+// and linked to the track in place of the previous rendition of the same name, packages the audio file for HLS,
+// computes its waveform or measures its loudness. It returns the ID of the stored rendition
+func (s *TranscodeService) transcode(job *models.TranscodeJob) (primitive.ObjectID, error) {
+	track, err := s.trackService.GetTrack(job.TrackID.Hex())
+	if err == errors.ErrTrackNotFound || err == nil && track.Audio.FileID != job.SourceFileID {
+		return primitive.NilObjectID, errTranscodeCanceled
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	source, err := s.fileService.GetFile(job.SourceFileID.Hex())
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	switch job.Kind {
+	case models.TranscodeKindHLS:
+		if _, err := s.GetHLSPackage(source.ID); err != errors.ErrHLSPackageNotFound {
+			return primitive.NilObjectID, err // Identical audio was already packaged, e.g. for another track
+		}
+	case models.TranscodeKindWaveform:
+		return primitive.NilObjectID, s.computeWaveform(track, source)
+	case models.TranscodeKindLoudness:
+		return primitive.NilObjectID, s.analyzeLoudness(track, source)
+	}
+
+	// ffmpeg reads and writes local files, so the audio file is copied out of the storage first
+	dir, err := os.MkdirTemp("", "transcode-*")
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "source"+filepath.Ext(source.Filename))
+	if err := s.copySource(source, input); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if job.Kind == models.TranscodeKindHLS {
+		return primitive.NilObjectID, s.packageHLS(track, source, input, dir)
+	}
+	return s.transcodeRendition(job, source, input, dir)
+}
+
+// transcodeRendition transcodes the first audio stream of the input file to the rendition of a job and links
+// the stored rendition to the track
+func (s *TranscodeService) transcodeRendition(job *models.TranscodeJob, source *models.File, input, dir string) (primitive.ObjectID, error) {
+	encoder, ok := transcodeEncoders[job.Codec]
+	if !ok {
+		return primitive.NilObjectID, fmt.Errorf("unsupported rendition codec %q", job.Codec)
+	}
+
+	output := filepath.Join(dir, "rendition")
+	err := s.runFFmpeg(
+		"-i", input,
+		"-map", "0:a:0", "-vn", // Drop embedded cover art, which is served from the cover endpoint
+		"-c:a", encoder.encoder, "-b:a", strconv.Itoa(job.Bitrate)+"k",
+		"-f", encoder.format, output,
+	)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	content, err := os.Open(output)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	defer content.Close()
+
+	owner := models.FileOwner{Type: models.FileOwnerTrack, ID: job.TrackID, Role: models.RenditionRole(job.Rendition)}
+	file, err := s.fileService.StoreFile(nil, source.UploadedBy.Hex(), content, owner) // Store the rendition and its metadata
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	rendition := models.Rendition{
+		Name:     job.Rendition,
+		Codec:    job.Codec,
+		Bitrate:  job.Bitrate,
+		MimeType: file.MimeType,
+		FileID:   file.ID,
+	}
+	previous, err := s.trackService.SetRendition(job.TrackID, job.SourceFileID, rendition)
+	if err != nil {
+		s.fileService.RemoveFile(file, owner) // Remove the rendition nobody links to
+		if err == errors.ErrTrackNotFound {
+			return primitive.NilObjectID, errTranscodeCanceled // The audio file was replaced while transcoding
+		}
+		return primitive.NilObjectID, err
+	}
+	if previous != nil && previous.FileID != file.ID {
+		s.releaseRendition(job.TrackID, *previous)
+	}
+
+	return file.ID, nil
+}
+
+// packageHLS cuts the input file into AAC variants of the configured bitrates with ffmpeg, stores their MPEG-TS
+// segments as files derived from the audio file, so they live as long as it does, and saves the package
+func (s *TranscodeService) packageHLS(track *models.Track, source *models.File, input, dir string) error {
+	bitrates := hlsBitrates(track.Audio, s.config.HLSBitrates)
+
+	// Encode every variant in a single run, numbering the outputs of each variant with %v
+	args := []string{"-i", input}
+	streamMap := make([]string, len(bitrates))
+	for i := range bitrates {
+		args = append(args, "-map", "0:a:0")
+		streamMap[i] = "a:" + strconv.Itoa(i)
+	}
+	args = append(args, "-c:a", "aac")
+	for i, bitrate := range bitrates {
+		args = append(args, "-b:a:"+strconv.Itoa(i), strconv.Itoa(bitrate)+"k")
+	}
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.FormatFloat(s.config.HLSSegmentDuration.Seconds(), 'f', -1, 64),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "mpegts",
+		"-hls_segment_filename", filepath.Join(dir, "v%v_%d.ts"),
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(dir, "v%v.m3u8"),
+	)
+	if err := s.runFFmpeg(args...); err != nil {
+		return err
+	}
+
+	hlsPackage := &models.HLSPackage{SourceFileID: source.ID}
+	for i, bitrate := range bitrates {
+		variant, err := s.storeHLSVariant(source, filepath.Join(dir, "v"+strconv.Itoa(i)+".m3u8"), bitrate)
+		if err != nil {
+			return err
+		}
+		hlsPackage.Variants = append(hlsPackage.Variants, *variant)
+	}
+	hlsPackage.BeforeCreate() // Set default values before creating the package
+
+	_, err := s.packages.InsertOne(context.Background(), hlsPackage)
+	if err != nil && !mongo.IsDuplicateKeyError(err) { // Identical audio may have been packaged meanwhile
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// storeHLSVariant stores the segments listed in the media playlist ffmpeg wrote for a variant and measures its bandwidth
+func (s *TranscodeService) storeHLSVariant(source *models.File, playlistPath string, bitrate int) (*models.HLSVariant, error) {
+	playlist, err := os.Open(playlistPath)
+	if err != nil {
+		return nil, err
+	}
+	defer playlist.Close()
+
+	segments, err := hls.ReadMediaPlaylist(playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	variant := &models.HLSVariant{
+		Name:    fmt.Sprintf("aac-%dk", bitrate),
+		Bitrate: bitrate,
+		Codecs:  hls.CodecAACLC,
+	}
+	var totalSize int64
+	var totalDuration float64
+	for i, segment := range segments {
+		file, err := s.storeHLSSegment(source, models.HLSSegmentRole(variant.Name, i), filepath.Join(filepath.Dir(playlistPath), segment.URI))
+		if err != nil {
+			return nil, err
+		}
+		variant.Segments = append(variant.Segments, models.HLSSegment{Duration: segment.Duration, Size: file.Size, FileID: file.ID})
+
+		// The peak bandwidth is that of the segment with the highest bitrate
+		if segment.Duration > 0 {
+			variant.Bandwidth = max(variant.Bandwidth, int(float64(file.Size)*8/segment.Duration))
+		}
+		totalSize += file.Size
+		totalDuration += segment.Duration
+	}
+	if totalDuration > 0 {
+		variant.AverageBandwidth = int(float64(totalSize) * 8 / totalDuration)
+	}
+
+	return variant, nil
+}
+
+// storeHLSSegment stores a segment file written by ffmpeg as a file derived from the packaged audio file
+func (s *TranscodeService) storeHLSSegment(source *models.File, role, path string) (*models.File, error) {
+	content, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	return s.fileService.StoreDerivedFile(nil, source, role, content, 0, 0)
+}
+
+// GetHLSPackage retrieves the HLS package of an audio file
+func (s *TranscodeService) GetHLSPackage(sourceFileID primitive.ObjectID) (*models.HLSPackage, error) {
+	var hlsPackage models.HLSPackage
+	err := s.packages.FindOne(context.Background(), bson.M{"source_file_id": sourceFileID}).Decode(&hlsPackage) // Find the package by its audio file
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrHLSPackageNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &hlsPackage, nil
+}
+
+// computeWaveform decodes the audio file and saves its peaks at each of the WaveformResolutions
+func (s *TranscodeService) computeWaveform(track *models.Track, source *models.File) error {
+	if _, err := s.GetWaveform(source.ID); err != errors.ErrWaveformNotFound {
+		return err // Identical audio was already analyzed, e.g. for another track
+	}
+
+	var peaks *audio.Peaks
+	err := s.decodeAudio(track, source, func(decoder audio.Decoder) error {
+		var err error
+		peaks, err = audio.ReadPeaks(decoder)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	waveform := &models.Waveform{SourceFileID: source.ID}
+	for _, resolution := range models.WaveformResolutions {
+		level := peaks.Waveform(resolution)
+		waveform.SampleRate = level.SampleRate
+		waveform.Levels = append(waveform.Levels, models.WaveformLevel{
+			Resolution:      resolution,
+			SamplesPerPixel: level.SamplesPerPixel,
+			Length:          level.Length(),
+			Data:            level.Data,
+		})
+	}
+	waveform.BeforeCreate() // Set default values before creating the waveform
+
+	_, err = s.waveforms.InsertOne(context.Background(), waveform)
+	if err != nil && !mongo.IsDuplicateKeyError(err) { // Identical audio may have been analyzed meanwhile
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// GetWaveform retrieves the waveform of an audio file
+func (s *TranscodeService) GetWaveform(sourceFileID primitive.ObjectID) (*models.Waveform, error) {
+	var waveform models.Waveform
+	err := s.waveforms.FindOne(context.Background(), bson.M{"source_file_id": sourceFileID}).Decode(&waveform) // Find the waveform by its audio file
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrWaveformNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &waveform, nil
+}
+
+// analyzeLoudness decodes the audio file, measures its loudness and stores it on the track along with its gain
+func (s *TranscodeService) analyzeLoudness(track *models.Track, source *models.File) error {
+	var measured *audio.Loudness
+	err := s.decodeAudio(track, source, func(decoder audio.Decoder) error {
+		var err error
+		measured, err = audio.MeasureLoudness(decoder)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	loudness := models.Loudness{
+		Integrated:  measured.Integrated,
+		TruePeak:    measured.TruePeak,
+		GatedBlocks: measured.GatedBlocks,
+		TrackGain:   audio.Gain(measured.Integrated),
+		AnalyzedAt:  time.Now(),
+	}
+	if err := s.trackService.SetLoudness(track.ID, source.ID, loudness); err != nil {
+		if err == errors.ErrTrackNotFound {
+			return errTranscodeCanceled // The audio file was replaced while it was analyzed
+		}
+		return err
+	}
+
+	return nil
+}
+
+// decodeAudio calls consume with a decoder of the audio file. MP3 and PCM WAV files are decoded natively, straight
+// from the storage; other files are decoded by ffmpeg to float samples at the sample rate and channels of the track
+func (s *TranscodeService) decodeAudio(track *models.Track, source *models.File, consume func(audio.Decoder) error) error {
+	content, _, err := s.fileService.OpenFile(source) // Open the file content
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	decoder, err := audio.NewDecoder(content, audioContainer(source))
+	if err != audio.ErrUnsupportedCodec {
+		if err != nil {
+			return err
+		}
+		return consume(decoder)
+	}
+	if !s.ffmpeg {
+		return fmt.Errorf("%v without ffmpeg", err)
+	}
+
+	dir, err := os.MkdirTemp("", "decode-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "source"+filepath.Ext(source.Filename))
+	if err := s.copySource(source, input); err != nil {
+		return err
+	}
+
+	sampleRate, channels := track.Audio.SampleRate, track.Audio.Channels
+	if sampleRate == 0 || channels == 0 {
+		sampleRate, channels = 44100, 2 // The stream properties of the track are unknown
+	}
+	return s.pipeFFmpeg(func(stdout io.Reader) error {
+		return consume(audio.NewPCMDecoder(stdout, sampleRate, channels))
+	},
+		"-i", input,
+		"-map", "0:a:0",
+		"-ar", strconv.Itoa(sampleRate), "-ac", strconv.Itoa(channels),
+		"-f", "f32le", "pipe:1",
+	)
+}
+
+// hlsBitrates returns the bitrates of the HLS variants an audio file is packaged in: the configured ones below the
+// bitrate of a lossy audio file, and at least the lowest one
+func hlsBitrates(asset models.AudioAsset, configured []int) []int {
+	if audio.IsLossless(asset.Codec) || asset.Bitrate == 0 {
+		return configured
+	}
+
+	bitrates := []int{configured[0]}
+	for _, bitrate := range configured[1:] {
+		if bitrate < asset.Bitrate {
+			bitrates = append(bitrates, bitrate)
+		}
+	}
+	return bitrates
+}
+
+// copySource writes the content of a stored file to a local file
+func (s *TranscodeService) copySource(source *models.File, path string) error {
+	content, _, err := s.fileService.OpenFile(source) // Open the file content
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runFFmpeg runs ffmpeg with the given input and output arguments, stopping it once the transcoding timeout is reached
+func (s *TranscodeService) runFFmpeg(args ...string) error {
+	return s.pipeFFmpeg(nil, args...)
+}
+
+// pipeFFmpeg runs ffmpeg like runFFmpeg, calling read with its standard output unless read is nil
+func (s *TranscodeService) pipeFFmpeg(read func(stdout io.Reader) error, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.TranscodeTimeout)
+	defer cancel()
+
+	args = append([]string{"-nostdin", "-hide_banner", "-loglevel", "error", "-y"}, args...)
+	cmd := exec.CommandContext(ctx, s.config.FFmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	var stdout io.Reader
+	if read != nil {
+		pipe, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		stdout = pipe
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if read != nil {
+		if err := read(stdout); err != nil {
+			cancel() // Kill ffmpeg, which would block writing the output nobody reads
+			cmd.Wait()
+			return err
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("ffmpeg timed out after %s", s.config.TranscodeTimeout)
+		}
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > transcodeMaxErrorLen {
+			message = message[:transcodeMaxErrorLen]
+		}
+		return fmt.Errorf("ffmpeg failed: %v: %s", err, message)
+	}
+
+	return nil
+}
+
+// releaseRendition removes the track as the owner of a rendition it no longer links to
+func (s *TranscodeService) releaseRendition(trackID primitive.ObjectID, rendition models.Rendition) {
+	file, err := s.fileService.GetFile(rendition.FileID.Hex())
+	if err != nil {
+		return // Nothing to release
+	}
+	owner := models.FileOwner{Type: models.FileOwnerTrack, ID: trackID, Role: models.RenditionRole(rendition.Name)}
+	s.fileService.RemoveFile(file, owner)
+}
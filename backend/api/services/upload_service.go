@@ -0,0 +1,400 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"music-library-management/api/models"
+	"music-library-management/api/storage"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+
+	"github.com/gin-gonic/gin"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UploadService handles resumable uploads: it stores their chunks as they arrive and finishes them into files
+type UploadService struct {
+	collection  *mongo.Collection // MongoDB collection for uploads
+	config      *config.Config    // Application configuration holding the upload limits
+	storage     storage.Storage   // Storage holding the chunks
+	fileService *FileService      // Service storing finished uploads as files
+}
+
+// NewUploadService creates a new UploadService
+func NewUploadService(client *mongo.Client, cfg *config.Config, store storage.Storage, fileService *FileService) *UploadService {
+	return &UploadService{
+		collection:  utils.GetDBCollection(client, cfg, "uploads"),
+		config:      cfg,
+		storage:     store,
+		fileService: fileService,
+	}
+}
+
+// MaxSize returns the largest upload length accepted
+func (s *UploadService) MaxSize() int64 {
+	return s.config.UploadMaxSize
+}
+
+// CreateUpload creates a resumable upload of the given length for the user
+func (s *UploadService) CreateUpload(userId string, length int64, metadata map[string]string) (*models.Upload, error) {
+	userID, err := primitive.ObjectIDFromHex(userId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+	if length <= 0 {
+		return nil, errors.ErrInvalidInput
+	}
+	if length > s.config.UploadMaxSize {
+		return nil, errors.ErrUploadTooLarge
+	}
+
+	upload := &models.Upload{
+		UserID:   userID,
+		Length:   length,
+		Filename: metadata["filename"],
+		MimeType: metadata["filetype"],
+		Metadata: metadata,
+	}
+	upload.BeforeCreate() // Set default values before creating the upload
+	upload.ExpiresAt = upload.CreatedAt.Add(s.config.UploadExpiry)
+
+	_, err = s.collection.InsertOne(context.Background(), upload)
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return upload, nil
+}
+
+// GetUpload retrieves an upload of the user that has not expired
+func (s *UploadService) GetUpload(userId, uploadId string) (*models.Upload, error) {
+	userID, err := primitive.ObjectIDFromHex(userId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+	objectID, err := primitive.ObjectIDFromHex(uploadId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrUploadNotFound
+	}
+
+	// Expired uploads are removed by a TTL index, which may lag behind
+	filter := bson.M{"_id": objectID, "user_id": userID, "expires_at": bson.M{"$gt": time.Now()}}
+
+	var upload models.Upload
+	err = s.collection.FindOne(context.Background(), filter).Decode(&upload) // Find the user's upload
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrUploadNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &upload, nil
+}
+
+// WriteChunk stores the bytes read from body as the chunk of an upload starting at offset, which must be the
+// upload's current offset, and finishes the upload into a file once all of its content was received.
+// Writing no bytes at the end of an unfinished upload retries finishing it
+func (s *UploadService) WriteChunk(c *gin.Context, userId, uploadId string, offset int64, body io.Reader) (*models.Upload, error) {
+	upload, err := s.GetUpload(userId, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return nil, errors.ErrUploadOffsetMismatch
+	}
+	if upload.IsFinished() {
+		return upload, nil
+	}
+
+	// Resume hashing where the previous chunk left off
+	hasher := sha256.New()
+	if len(upload.HashState) > 0 {
+		if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(upload.HashState); err != nil {
+			return nil, errors.ErrInternalServer
+		}
+	}
+
+	// Refuse content that is neither audio nor an image with its first chunk rather than once all of it was received
+	if upload.Offset == 0 {
+		head := make([]byte, min(utils.SniffLen, upload.Length))
+		n, err := io.ReadFull(body, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, errors.ErrInvalidInput
+		}
+		if n == len(head) {
+			if err := s.fileService.CheckContent(models.FileRoleContent, utils.SniffContentType(head), upload.Length); err != nil {
+				return nil, err
+			}
+		}
+		body = io.MultiReader(bytes.NewReader(head[:n]), body)
+	}
+
+	if remaining := upload.Length - upload.Offset; remaining > 0 {
+		chunk, err := s.storeChunk(upload, io.TeeReader(io.LimitReader(body, remaining), hasher))
+		if err != nil {
+			return nil, err
+		}
+
+		// Refuse bytes beyond the declared length
+		if n, _ := body.Read(make([]byte, 1)); n > 0 {
+			s.storage.Delete(context.Background(), chunk.Key)
+			return nil, errors.ErrUploadTooLarge
+		}
+
+		if chunk.Size > 0 {
+			if err := s.appendChunk(upload, chunk, hasher); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if upload.Offset == upload.Length {
+		if err := s.finishUpload(c, upload, hex.EncodeToString(hasher.Sum(nil))); err != nil {
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+// storeChunk stores the content as a chunk of the upload at its current offset
+func (s *UploadService) storeChunk(upload *models.Upload, content io.Reader) (*models.UploadChunk, error) {
+	counter := &countingReader{reader: content}
+	key := fmt.Sprintf("upload-%s-%d", upload.ID.Hex(), upload.Offset) // Chunks are named after their upload and offset
+
+	err := s.storage.Put(context.Background(), key, counter, -1, "application/octet-stream")
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+	if counter.count == 0 {
+		s.storage.Delete(context.Background(), key) // Nothing was received
+	}
+
+	return &models.UploadChunk{Key: key, Offset: upload.Offset, Size: counter.count}, nil
+}
+
+// appendChunk records a stored chunk on the upload, advancing its offset and expiry, unless a concurrent
+// request advanced the offset first
+func (s *UploadService) appendChunk(upload *models.Upload, chunk *models.UploadChunk, hasher io.Writer) error {
+	hashState, err := hasher.(encoding.BinaryMarshaler).MarshalBinary() // Save the hash state for the next chunk
+	if err != nil {
+		s.storage.Delete(context.Background(), chunk.Key)
+		return errors.ErrInternalServer
+	}
+
+	previousOffset := upload.Offset
+	upload.Offset += chunk.Size
+	upload.Chunks = append(upload.Chunks, *chunk)
+	upload.HashState = hashState
+	upload.BeforeUpdate() // Set updated values before updating the upload
+	upload.ExpiresAt = upload.UpdatedAt.Add(s.config.UploadExpiry)
+
+	update := bson.M{
+		"$set": bson.M{
+			"offset":     upload.Offset,
+			"hash_state": upload.HashState,
+			"expires_at": upload.ExpiresAt,
+			"updated_at": upload.UpdatedAt,
+		},
+		"$push": bson.M{"chunks": chunk},
+	}
+
+	result, err := s.collection.UpdateOne(context.Background(), bson.M{"_id": upload.ID, "offset": previousOffset}, update)
+	if err != nil {
+		s.storage.Delete(context.Background(), chunk.Key)
+		return errors.ErrDatabaseOperation
+	}
+	if result.MatchedCount == 0 {
+		s.storage.Delete(context.Background(), chunk.Key)
+		return errors.ErrUploadOffsetMismatch // Lost a race against a concurrent chunk
+	}
+
+	return nil
+}
+
+// finishUpload stores the received content as a file owned by the upload and removes the chunks
+func (s *UploadService) finishUpload(c *gin.Context, upload *models.Upload, hash string) error {
+	owner := models.FileOwner{Type: models.FileOwnerUpload, ID: upload.ID, Role: models.FileRoleContent}
+	file, err := s.fileService.StoreHashedFile(c, upload.UserID.Hex(), hash, upload.Length, func() (io.ReadCloser, error) {
+		return &chunkReader{storage: s.storage, chunks: upload.Chunks}, nil
+	}, owner)
+	if err != nil {
+		return err
+	}
+
+	upload.FileID = file.ID
+	upload.BeforeUpdate() // Set updated values before updating the upload
+	upload.ExpiresAt = upload.UpdatedAt.Add(s.config.UploadExpiry)
+
+	update := bson.M{
+		"$set":   bson.M{"file_id": upload.FileID, "expires_at": upload.ExpiresAt, "updated_at": upload.UpdatedAt},
+		"$unset": bson.M{"chunks": "", "hash_state": ""},
+	}
+
+	_, err = s.collection.UpdateOne(context.Background(), bson.M{"_id": upload.ID}, update) // Record the file on the upload
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	// The chunks are no longer needed; leftovers are collected as stray objects
+	for _, chunk := range upload.Chunks {
+		s.storage.Delete(context.Background(), chunk.Key)
+	}
+	upload.Chunks = nil
+	upload.HashState = nil
+
+	return nil
+}
+
+// DeleteUpload terminates an upload of the user, removing its chunks or releasing its file
+func (s *UploadService) DeleteUpload(userId, uploadId string) error {
+	upload, err := s.GetUpload(userId, uploadId)
+	if err != nil {
+		return err
+	}
+
+	return s.ReleaseUpload(upload)
+}
+
+// ClaimUpload adds an owner to the file of a finished upload of the user, e.g. a track using it as its audio file;
+// the upload stays usable until it is released
+func (s *UploadService) ClaimUpload(userId, uploadId string, owner models.FileOwner) (*models.File, *models.Upload, error) {
+	upload, err := s.GetUpload(userId, uploadId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !upload.IsFinished() {
+		return nil, nil, errors.ErrUploadIncomplete
+	}
+
+	file, err := s.fileService.GetFile(upload.FileID.Hex())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err = s.fileService.AddFileOwner(file, owner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, upload, nil
+}
+
+// ReleaseUpload removes an upload once its file was claimed or it is no longer wanted; a nil upload is ignored
+func (s *UploadService) ReleaseUpload(upload *models.Upload) error {
+	if upload == nil {
+		return nil
+	}
+
+	_, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": upload.ID}) // Remove the upload
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	// Release the upload's hold on its file, or remove its chunks
+	if upload.IsFinished() {
+		owner := models.FileOwner{Type: models.FileOwnerUpload, ID: upload.ID, Role: models.FileRoleContent}
+		return s.fileService.RemoveFile(&models.File{ID: upload.FileID}, owner)
+	}
+	for _, chunk := range upload.Chunks {
+		s.storage.Delete(context.Background(), chunk.Key)
+	}
+
+	return nil
+}
+
+// CollectFileReferences marks the files of finished uploads and the chunks of unfinished uploads as used,
+// so the garbage collection keeps them until the uploads expire
+func (s *UploadService) CollectFileReferences(refs *FileReferences) error {
+	projection := bson.M{"file_id": 1, "chunks": 1}
+	cursor, err := s.collection.Find(context.Background(), bson.M{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return errors.ErrDatabaseOperation
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var upload models.Upload
+		if err := cursor.Decode(&upload); err != nil {
+			return errors.ErrDatabaseOperation
+		}
+		refs.AddID(upload.FileID)
+		for _, chunk := range upload.Chunks {
+			refs.AddKey(chunk.Key)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return errors.ErrDatabaseOperation
+	}
+
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+// Read reads from the underlying reader and counts the bytes read
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// chunkReader reads the chunks of an upload in order as a single stream
+type chunkReader struct {
+	storage storage.Storage      // Storage holding the chunks
+	chunks  []models.UploadChunk // Chunks left to read
+	current storage.Object       // Chunk being read
+}
+
+// Read reads from the current chunk, opening the next chunk when it is exhausted
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			object, _, err := r.storage.Get(context.Background(), r.chunks[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			r.current = object
+			r.chunks = r.chunks[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the chunk being read
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"music-library-management/api/models"
+	"music-library-management/api/storage"
+	"music-library-management/config"
+	"music-library-management/errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newTestUploadService creates an UploadService on the mock deployment of mt accepting uploads of up to 100 bytes,
+// storing chunks and files in a temporary directory
+func newTestUploadService(mt *mtest.T) (*UploadService, storage.Storage) {
+	fileService, store := newTestFileService(mt)
+	cfg := &config.Config{UploadPath: "uploads", UploadMaxSize: 100, UploadExpiry: time.Hour}
+	return &UploadService{collection: mt.Coll, config: cfg, storage: store, fileService: fileService}, store
+}
+
+// putChunks stores the contents as the chunks of an upload
+func putChunks(t testing.TB, store storage.Storage, contents ...string) []models.UploadChunk {
+	var chunks []models.UploadChunk
+	var offset int64
+	for i, content := range contents {
+		chunk := models.UploadChunk{Key: "upload-test-" + string(rune('a'+i)), Offset: offset, Size: int64(len(content))}
+		if err := store.Put(context.Background(), chunk.Key, strings.NewReader(content), chunk.Size, ""); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		chunks = append(chunks, chunk)
+		offset += chunk.Size
+	}
+	return chunks
+}
+
+// hashState returns the SHA-256 state after hashing the content, as stored on an upload
+func hashState(t testing.TB, content string) []byte {
+	hasher := sha256.New()
+	io.WriteString(hasher, content)
+	state, err := hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	return state
+}
+
+// storedKeys lists the keys under which objects are stored
+func storedKeys(store storage.Storage, keys ...string) []string {
+	var found []string
+	for _, key := range keys {
+		if stored(store, key) {
+			found = append(found, key)
+		}
+	}
+	return found
+}
+
+func TestChunkReader(t *testing.T) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	chunks := putChunks(t, store, "first ", "s", "econd ", "third")
+
+	// Read in single bytes to cross every chunk boundary, then check the reader as a whole
+	content, err := io.ReadAll(iotest.OneByteReader(&chunkReader{storage: store, chunks: chunks}))
+	if err != nil || string(content) != "first second third" {
+		t.Errorf("ReadAll = (%q, %v), want the chunks in order", content, err)
+	}
+	if err := iotest.TestReader(&chunkReader{storage: store, chunks: chunks}, []byte("first second third")); err != nil {
+		t.Error(err)
+	}
+
+	// An empty upload reads as empty content
+	if n, err := (&chunkReader{storage: store}).Read(make([]byte, 8)); n != 0 || err != io.EOF {
+		t.Errorf("Read of no chunks = (%d, %v), want EOF", n, err)
+	}
+
+	// A missing chunk fails the read instead of truncating the content
+	missing := append(chunks[:1:1], models.UploadChunk{Key: "upload-test-missing", Offset: 6, Size: 4})
+	reader := &chunkReader{storage: store, chunks: missing}
+	if _, err := io.ReadAll(reader); err != storage.ErrNotFound {
+		t.Errorf("ReadAll with a missing chunk error = %v, want ErrNotFound", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestCreateUploadLength(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	for _, test := range []struct {
+		length int64
+		err    error
+	}{
+		{0, errors.ErrInvalidInput},
+		{-1, errors.ErrInvalidInput},
+		{101, errors.ErrUploadTooLarge},
+	} {
+		mt.Run("", func(mt *mtest.T) {
+			service, _ := newTestUploadService(mt)
+			if _, err := service.CreateUpload(primitive.NewObjectID().Hex(), test.length, nil); err != test.err {
+				mt.Errorf("CreateUpload(%d) error = %v, want %v", test.length, err, test.err)
+			}
+			if names := commandNames(mt); len(names) != 0 {
+				mt.Errorf("commands = %v, want none", names)
+			}
+		})
+	}
+}
+
+func TestWriteChunk(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	userID := primitive.NewObjectID()
+
+	// newUpload returns an unfinished upload of the user that received offset of its length bytes
+	newUpload := func(length, offset int64) *models.Upload {
+		upload := &models.Upload{UserID: userID, Length: length, Offset: offset, ExpiresAt: time.Now().Add(time.Hour)}
+		upload.BeforeCreate()
+		return upload
+	}
+
+	mt.Run("a chunk at another offset is rejected", func(mt *mtest.T) {
+		service, store := newTestUploadService(mt)
+		upload := newUpload(10, 4)
+		mt.AddMockResponses(findResponse(mt, upload))
+
+		_, err := service.WriteChunk(newTestContext(), userID.Hex(), upload.ID.Hex(), 0, strings.NewReader("data"))
+		if err != errors.ErrUploadOffsetMismatch {
+			mt.Errorf("WriteChunk error = %v, want ErrUploadOffsetMismatch", err)
+		}
+		if keys := storedKeys(store, "upload-"+upload.ID.Hex()+"-4", "upload-"+upload.ID.Hex()+"-0"); keys != nil {
+			mt.Errorf("stored %v, want no chunk", keys)
+		}
+	})
+
+	mt.Run("bytes beyond the declared length are rejected", func(mt *mtest.T) {
+		service, store := newTestUploadService(mt)
+		upload := newUpload(10, 4)
+		mt.AddMockResponses(findResponse(mt, upload))
+
+		_, err := service.WriteChunk(newTestContext(), userID.Hex(), upload.ID.Hex(), 4, strings.NewReader("seven b"))
+		if err != errors.ErrUploadTooLarge {
+			mt.Errorf("WriteChunk error = %v, want ErrUploadTooLarge", err)
+		}
+		if keys := storedKeys(store, "upload-"+upload.ID.Hex()+"-4"); keys != nil {
+			mt.Errorf("stored %v, want the rejected chunk removed", keys)
+		}
+		if names := commandNames(mt); !reflect.DeepEqual(names, []string{"find"}) {
+			mt.Errorf("commands = %v, want only the lookup of the upload", names)
+		}
+	})
+
+	mt.Run("a first chunk that is neither audio nor an image is rejected", func(mt *mtest.T) {
+		service, store := newTestUploadService(mt)
+		upload := newUpload(10, 0)
+		mt.AddMockResponses(findResponse(mt, upload))
+
+		_, err := service.WriteChunk(newTestContext(), userID.Hex(), upload.ID.Hex(), 0, strings.NewReader("plain text"))
+		if err != errors.ErrUnsupportedFileType {
+			mt.Errorf("WriteChunk error = %v, want ErrUnsupportedFileType", err)
+		}
+		if keys := storedKeys(store, "upload-"+upload.ID.Hex()+"-0"); keys != nil {
+			mt.Errorf("stored %v, want no chunk", keys)
+		}
+	})
+
+	mt.Run("a chunk racing a concurrent chunk is rejected", func(mt *mtest.T) {
+		service, store := newTestUploadService(mt)
+		upload := newUpload(10, 0)
+		mt.AddMockResponses(findResponse(mt, upload), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}))
+
+		_, err := service.WriteChunk(newTestContext(), userID.Hex(), upload.ID.Hex(), 0, strings.NewReader("part"))
+		if err != errors.ErrUploadOffsetMismatch {
+			mt.Errorf("WriteChunk error = %v, want ErrUploadOffsetMismatch", err)
+		}
+		if keys := storedKeys(store, "upload-"+upload.ID.Hex()+"-0"); keys != nil {
+			mt.Errorf("stored %v, want the losing chunk removed", keys)
+		}
+	})
+
+	mt.Run("a partial chunk advances the offset", func(mt *mtest.T) {
+		service, store := newTestUploadService(mt)
+		upload := newUpload(10, 0)
+		mt.AddMockResponses(findResponse(mt, upload), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		written, err := service.WriteChunk(newTestContext(), userID.Hex(), upload.ID.Hex(), 0, strings.NewReader("part"))
+		if err != nil {
+			mt.Fatalf("WriteChunk: %v", err)
+		}
+		if written.Offset != 4 || len(written.Chunks) != 1 || len(written.HashState) == 0 || written.IsFinished() {
+			mt.Fatalf("upload = %+v, want an unfinished upload at offset 4 with one chunk and the hash state", written)
+		}
+		if !stored(store, written.Chunks[0].Key) {
+			mt.Error("chunk was not stored")
+		}
+	})
+
+	mt.Run("the last chunk finishes the upload into a file", func(mt *mtest.T) {
+		service, store := newTestUploadService(mt)
+		first := putChunks(mt, store, "ID3 ")
+		upload := newUpload(10, 4)
+		upload.Chunks = first
+		upload.HashState = hashState(mt, "ID3 ") // Resume hashing after the first chunk
+		mt.AddMockResponses(
+			findResponse(mt, upload),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}), // Record the chunk
+			fileResponse(nil), findResponse(mt),                     // No file stores the content yet
+			mtest.CreateSuccessResponse(),                           // Insert the file
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}), // Record the file on the upload
+		)
+
+		written, err := service.WriteChunk(newTestContext(), userID.Hex(), upload.ID.Hex(), 4, strings.NewReader("audio!"))
+		if err != nil {
+			mt.Fatalf("WriteChunk: %v", err)
+		}
+		if !written.IsFinished() || written.Chunks != nil || written.HashState != nil {
+			mt.Errorf("upload = %+v, want a finished upload without chunks", written)
+		}
+
+		object, _, err := store.Get(context.Background(), contentHash([]byte("ID3 audio!"))+".mp3")
+		if err != nil {
+			mt.Fatalf("Get of the stored file: %v", err)
+		}
+		defer object.Close()
+		if content, _ := io.ReadAll(object); !bytes.Equal(content, []byte("ID3 audio!")) {
+			mt.Errorf("stored content = %q, want the chunks in order", content)
+		}
+		if keys := storedKeys(store, first[0].Key, "upload-"+upload.ID.Hex()+"-4"); keys != nil {
+			mt.Errorf("stored %v, want the chunks removed", keys)
+		}
+	})
+}
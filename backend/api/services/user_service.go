@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"music-library-management/api/models"
+	"music-library-management/api/utils"
+	"music-library-management/config"
+	"music-library-management/errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserService handles operations related to user accounts
+type UserService struct {
+	collection *mongo.Collection // MongoDB collection for users
+}
+
+// NewUserService creates a new UserService
+func NewUserService(client *mongo.Client, cfg *config.Config) *UserService {
+	return &UserService{
+		collection: utils.GetDBCollection(client, cfg, "users"),
+	}
+}
+
+// CreateUser hashes the password and stores a new user
+func (s *UserService) CreateUser(user *models.User, password string) (*models.User, error) {
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email)) // Normalize the email so lookups are case-insensitive
+
+	// Check if the email is already registered
+	_, err := s.GetUserByEmail(user.Email)
+	if err == nil {
+		return nil, errors.ErrEmailAlreadyExists
+	}
+	if err != errors.ErrUserNotFound {
+		return nil, err
+	}
+
+	hash, err := utils.HashPassword(password) // Hash the password before storing it
+	if err != nil {
+		return nil, errors.ErrInternalServer
+	}
+	user.PasswordHash = hash
+	user.BeforeCreate() // Set default values before creating a new user
+
+	_, err = s.collection.InsertOne(context.Background(), user) // Insert the user into the database
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.ErrEmailAlreadyExists // Lost a race against a concurrent registration
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return user, nil
+}
+
+// GetUser retrieves a user by their ID
+func (s *UserService) GetUser(userId string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(userId) // Convert string ID to ObjectID
+	if err != nil {
+		return nil, errors.ErrInvalidObjectID
+	}
+
+	var user models.User
+	err = s.collection.FindOne(context.Background(), bson.M{"_id": objectID, "is_deleted": false}).Decode(&user) // Find user by ID and ensure it's not deleted
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmail retrieves a user by their email address
+func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email)) // Normalize the email
+
+	var user models.User
+	err := s.collection.FindOne(context.Background(), bson.M{"email": email, "is_deleted": false}).Decode(&user) // Find user by email and ensure it's not deleted
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &user, nil
+}
+
+// ListUsers lists all users with pagination
+func (s *UserService) ListUsers(page, limit int) ([]*models.User, int64, error) {
+	skip := (page - 1) * limit // Calculate the number of documents to skip
+	findOptions := options.Find()
+	findOptions.SetSkip(int64(skip))                            // Set the number of documents to skip
+	findOptions.SetLimit(int64(limit))                          // Set the number of documents to return
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by created_at in descending order
+
+	// Execute the find query to get users that are not deleted
+	cursor, err := s.collection.Find(context.Background(), bson.M{"is_deleted": false}, findOptions)
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	var users []*models.User
+	err = cursor.All(context.Background(), &users) // Decode all users
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	// Count total matching documents to get the total number of users that are not deleted
+	total, err := s.collection.CountDocuments(context.Background(), bson.M{"is_deleted": false})
+	if err != nil {
+		return nil, 0, errors.ErrDatabaseOperation
+	}
+
+	return users, total, nil // Return found users and total count
+}
+
+// UpdateUserRole assigns a role to a user on behalf of the acting admin
+func (s *UserService) UpdateUserRole(actingUserId, userId, role string) (*models.User, error) {
+	if !models.IsValidRole(role) { // Validate the role against the permission matrix
+		return nil, errors.ErrInvalidInput
+	}
+
+	// Admins cannot change their own role, so the last admin cannot lock everyone out
+	if actingUserId == userId {
+		return nil, errors.ErrForbidden
+	}
+
+	user, err := s.GetUser(userId) // Retrieve the existing user
+	if err != nil {
+		return nil, err
+	}
+
+	user.Role = role
+	user.BeforeUpdate() // Set updated values before updating the user
+
+	update := bson.M{
+		"$set": bson.M{
+			"role":       user.Role,
+			"updated_at": user.UpdatedAt,
+		},
+	}
+
+	result := s.collection.FindOneAndUpdate(context.Background(), bson.M{"_id": user.ID, "is_deleted": false}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)) // Update the role in the database
+	if result.Err() != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	var updatedUser models.User
+	err = result.Decode(&updatedUser) // Decode the updated user
+	if err != nil {
+		return nil, errors.ErrDatabaseOperation
+	}
+
+	return &updatedUser, nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalStorage stores objects as files in a directory on the local disk
+type LocalStorage struct {
+	root string // Directory the objects are stored in
+}
+
+// NewLocalStorage creates a LocalStorage in the given directory, creating the directory if needed
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("creating upload directory: %w", err)
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+// Put writes the content to a temporary file and renames it into place, so readers never see a partial object
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.root, ".upload-*") // Create the temporary file next to the object
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Remove the temporary file if it was not renamed
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil { // Temporary files are only readable by their owner
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get opens the file of the object
+func (s *LocalStorage) Get(ctx context.Context, key string) (Object, *ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(path) // Open the file on disk
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	stat, err := f.Stat() // Read the size and modification time
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return f, newLocalObjectInfo(key, stat), nil
+}
+
+// Stat reads the size and modification time of the file of the object
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return newLocalObjectInfo(key, stat), nil
+}
+
+// Delete removes the file of the object
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL is not supported by the local disk; its objects are served through the API
+func (s *LocalStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "", ErrUnsupported
+}
+
+// List reads the storage directory; temporary files of interrupted writes are listed too so they can be cleaned up
+func (s *LocalStorage) List(ctx context.Context, fn func(info ObjectInfo) error) error {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue // Skip directories, e.g. the uploads of another environment
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Removed since the directory was read
+			}
+			return err
+		}
+		if err := fn(*newLocalObjectInfo(entry.Name(), stat)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Location returns the path of the file of the object
+func (s *LocalStorage) Location(key string) string {
+	return s.root + "/" + key
+}
+
+// path returns the path of the file of the object, refusing keys that would leave the storage directory
+func (s *LocalStorage) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.root, key), nil
+}
+
+// newLocalObjectInfo describes the object stored in a file, deriving its content type from the extension
+func newLocalObjectInfo(key string, stat os.FileInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:         key,
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+	}
+}
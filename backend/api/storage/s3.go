@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"music-library-management/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage stores objects in a bucket of an S3-compatible object store such as MinIO
+type S3Storage struct {
+	client *minio.Client // Client of the object store
+	bucket string        // Bucket the objects are stored in
+}
+
+// NewS3Storage connects to the object store configured in the config, creating the bucket if it does not exist
+func NewS3Storage(cfg *config.Config) (*S3Storage, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating S3 client: %w", err)
+	}
+
+	// Create a context with a timeout of 10 seconds for the bucket check
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("checking S3 bucket %q: %w", cfg.S3Bucket, err)
+	}
+	if !exists {
+		err = client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region})
+		if err != nil {
+			return nil, fmt.Errorf("creating S3 bucket %q: %w", cfg.S3Bucket, err)
+		}
+	}
+
+	return &S3Storage{client: client, bucket: cfg.S3Bucket}, nil
+}
+
+// Put uploads the content as an object, using a multipart upload when the size is unknown or large
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Get opens the object for reading; reads and seeks are served with ranged requests to the object store
+func (s *S3Storage) Get(ctx context.Context, key string) (Object, *ObjectInfo, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s.mapError(err)
+	}
+
+	stat, err := object.Stat() // Fetch the object's metadata; this is where a missing object is reported
+	if err != nil {
+		object.Close()
+		return nil, nil, s.mapError(err)
+	}
+
+	return object, newS3ObjectInfo(stat), nil
+}
+
+// Stat fetches the metadata of the object
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	stat, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return newS3ObjectInfo(stat), nil
+}
+
+// Delete removes the object; the object store does not report missing objects
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// SignedURL presigns a GET request for the object
+func (s *S3Storage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	signedUrl, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, url.Values{})
+	if err != nil {
+		return "", s.mapError(err)
+	}
+	return signedUrl.String(), nil
+}
+
+// List lists the objects of the bucket page by page
+func (s *S3Storage) List(ctx context.Context, fn func(info ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx) // Stop the listing when fn fails
+	defer cancel()
+
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if err := fn(*newS3ObjectInfo(object)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Location returns the s3:// URL of the object
+func (s *S3Storage) Location(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+// mapError converts the object store's "no such key" error to ErrNotFound
+func (s *S3Storage) mapError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
+
+// newS3ObjectInfo converts the metadata of an object to an ObjectInfo
+func newS3ObjectInfo(stat minio.ObjectInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:         stat.Key,
+		Size:        stat.Size,
+		ModTime:     stat.LastModified,
+		ContentType: stat.ContentType,
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"music-library-management/config"
+)
+
+// Errors returned by the storage drivers
+var (
+	ErrNotFound    = errors.New("object not found")                   // No object is stored under the key
+	ErrUnsupported = errors.New("operation not supported by storage") // The driver cannot perform the operation
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key         string    // Key the object is stored under
+	Size        int64     // Size of the object in bytes
+	ModTime     time.Time // When the object was last written
+	ContentType string    // MIME type of the object, if known
+}
+
+// Object is the content of a stored object; it supports seeking so it can answer range requests
+type Object interface {
+	io.ReadSeekCloser
+}
+
+// Storage stores the content of uploaded files under flat keys
+type Storage interface {
+	// Put stores the content read from r under the key, replacing any object stored under it;
+	// size is the length of the content, or -1 if it is unknown
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get opens the object stored under the key for reading; the caller must close it
+	Get(ctx context.Context, key string) (Object, *ObjectInfo, error)
+
+	// Stat describes the object stored under the key
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// Delete removes the object stored under the key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+
+	// SignedURL returns a URL that grants read access to the object for the given duration,
+	// or ErrUnsupported if the driver cannot issue one
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+
+	// List calls fn with every stored object, in no particular order, stopping at the first error fn returns
+	List(ctx context.Context, fn func(info ObjectInfo) error) error
+
+	// Location describes where the object stored under the key lives, e.g. a path or an s3:// URL
+	Location(key string) string
+}
+
+// NewStorage creates the storage driver selected in the config
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case config.StorageDriverLocal:
+		return NewLocalStorage(cfg.UploadPath)
+	case config.StorageDriverS3:
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
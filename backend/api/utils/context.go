@@ -0,0 +1,30 @@
+package utils
+
+import "github.com/gin-gonic/gin"
+
+// Keys under which the auth middleware stores the authenticated user in the gin context
+const (
+	ContextUserIDKey = "userId" // The authenticated user's ID
+	ContextRoleKey   = "role"   // The authenticated user's role
+	ContextScopesKey = "scopes" // The scopes of the API key, only set for API key requests
+)
+
+// GetUserID returns the authenticated user's ID set by the auth middleware
+func GetUserID(c *gin.Context) string {
+	return c.GetString(ContextUserIDKey)
+}
+
+// GetRole returns the authenticated user's role set by the auth middleware
+func GetRole(c *gin.Context) string {
+	return c.GetString(ContextRoleKey)
+}
+
+// GetScopes returns the scopes of the API key used for the request, and false if the request did not use an API key
+func GetScopes(c *gin.Context) ([]string, bool) {
+	value, exists := c.Get(ContextScopesKey)
+	if !exists {
+		return nil, false
+	}
+	scopes, ok := value.([]string)
+	return scopes, ok
+}
@@ -58,7 +58,7 @@ func GetDatabase(client *mongo.Client, cfg *config.Config) *mongo.Database {
 // InitializeCollections ensures that the required collections exist
 func InitializeCollections(db *mongo.Database) error {
 	// Define a list of required collections
-	collections := []string{"tracks", "playlists", "genres", "files"}
+	collections := []string{"tracks", "playlists", "genres", "files", "users", "api_keys", "playback_sessions", "plays", "uploads", "transcode_jobs", "hls_packages", "waveforms"}
 
 	// Iterate over each collection name
 	for _, collection := range collections {
@@ -80,6 +80,58 @@ func InitializeCollections(db *mongo.Database) error {
 	return nil // Return nil if all collections are initialized successfully
 }
 
+// InitializeIndexes ensures that the required indexes exist
+func InitializeIndexes(db *mongo.Database) error {
+	// Define the indexes required by each collection
+	indexes := map[string][]mongo.IndexModel{
+		"users": {
+			{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)}, // Emails must be unique
+		},
+		"api_keys": {
+			{Keys: bson.D{{Key: "key_hash", Value: 1}}, Options: options.Index().SetUnique(true)}, // Keys are looked up by hash
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},                                            // Keys are listed per user
+		},
+		"files": {
+			{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"hash": bson.M{"$exists": true}})}, // Content is stored once; files uploaded before hashing have no hash
+			{Keys: bson.D{{Key: "owners.owner_id", Value: 1}, {Key: "owners.role", Value: 1}}},                                                                    // Files are listed per owner
+		},
+		"plays": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "started_at", Value: -1}}}, // History is listed per user, most recent first
+		},
+		"tracks": {
+			{Keys: bson.D{{Key: "play_count", Value: -1}}},     // Most played listing
+			{Keys: bson.D{{Key: "last_played_at", Value: -1}}}, // Recently played listing
+		},
+		"playback_sessions": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "device_id", Value: 1}}, Options: options.Index().SetUnique(true)}, // One session per user and device
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}}},                                         // The latest session is picked up by other devices
+		},
+		"uploads": {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}, // Expired uploads are removed by MongoDB
+		},
+		"transcode_jobs": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},    // Workers claim the oldest queued job
+			{Keys: bson.D{{Key: "track_id", Value: 1}, {Key: "created_at", Value: -1}}}, // Jobs are listed per track
+		},
+		"hls_packages": {
+			{Keys: bson.D{{Key: "source_file_id", Value: 1}}, Options: options.Index().SetUnique(true)}, // Audio files are packaged once
+		},
+		"waveforms": {
+			{Keys: bson.D{{Key: "source_file_id", Value: 1}}, Options: options.Index().SetUnique(true)}, // Peaks are computed once per audio file
+		},
+	}
+
+	// Create the indexes for each collection; creating an existing index is a no-op
+	for collection, models := range indexes {
+		_, err := db.Collection(collection).Indexes().CreateMany(context.Background(), models)
+		if err != nil {
+			// Return an error if the index creation fails
+			return fmt.Errorf("failed to create indexes on %s: %v", collection, err)
+		}
+	}
+	return nil // Return nil if all indexes are created successfully
+}
+
 // collectionExists checks if a collection exists in the database
 func collectionExists(db *mongo.Database, collectionName string) (bool, error) {
 	// List all collection names in the database that match the given collection name
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"music-library-management/api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrateAudioAssets moves the MP3 file fields of tracks stored before tracks had a format-neutral audio asset
+// into the asset. Those tracks only ever held MP3 files, so their container is MPEG; tracks added before their
+// stream was analysed keep an asset without stream properties
+func MigrateAudioAssets(db *mongo.Database) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"audio": bson.M{
+				"file_id":      "$audio_file_id", // Missing for tracks added before files were linked
+				"file_url":     bson.M{"$ifNull": bson.A{"$mp3_file_url", ""}},
+				"mime_type":    "audio/mpeg",
+				"container":    "mpeg",
+				"codec":        "$codec",
+				"bitrate":      "$bitrate",
+				"sample_rate":  "$sample_rate",
+				"channel_mode": "$channel_mode",
+				"channels": bson.M{"$switch": bson.M{
+					"branches": bson.A{
+						bson.M{"case": bson.M{"$eq": bson.A{"$channel_mode", "mono"}}, "then": 1},
+						bson.M{"case": bson.M{"$gt": bson.A{"$channel_mode", nil}}, "then": 2},
+					},
+					"default": "$$REMOVE",
+				}},
+			},
+		}}},
+		{{Key: "$unset", Value: bson.A{"audio_file_id", "mp3_file_url", "codec", "bitrate", "sample_rate", "channel_mode"}}},
+	}
+
+	result, err := db.Collection("tracks").UpdateMany(context.Background(), bson.M{"audio": bson.M{"$exists": false}}, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to migrate the audio of tracks: %v", err)
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Migrated the audio of %d tracks", result.ModifiedCount) // Log the number of tracks migrated
+	}
+	return nil
+}
+
+// MigratePlaylistOwners assigns the playlists created before playlists had owners to the earliest admin, so they
+// can still be modified and deleted. Their visibility is left unset, so they stay public. Without an admin account
+// the playlists are left alone until one exists at a later startup
+func MigratePlaylistOwners(db *mongo.Database) error {
+	filter := bson.M{"owner_id": bson.M{"$in": bson.A{nil, primitive.NilObjectID}}} // Missing, null or zero owners
+	count, err := db.Collection("playlists").CountDocuments(context.Background(), filter)
+	if err != nil {
+		return fmt.Errorf("failed to find playlists without an owner: %v", err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	// Find the earliest admin account to hand the playlists to
+	var admin models.User
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	err = db.Collection("users").FindOne(context.Background(), bson.M{"role": models.RoleAdmin}, findOptions).Decode(&admin)
+	if err == mongo.ErrNoDocuments {
+		log.Printf("%d playlists have no owner and no admin account exists to assign them to", count)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find an admin account: %v", err)
+	}
+
+	result, err := db.Collection("playlists").UpdateMany(context.Background(), filter, bson.M{"$set": bson.M{"owner_id": admin.ID}})
+	if err != nil {
+		return fmt.Errorf("failed to assign playlists to an owner: %v", err)
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Assigned %d playlists without an owner to %s", result.ModifiedCount, admin.Email) // Log the number of playlists migrated
+	}
+	return nil
+}
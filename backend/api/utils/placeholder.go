@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bytes"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// placeholderSize is the width and height of generated placeholder covers in pixels
+const placeholderSize = 512
+
+// GeneratePlaceholderCover renders a PNG cover with a diagonal gradient whose colors are derived from the seed,
+// so tracks of the same album get the same placeholder
+func GeneratePlaceholderCover(seed string) ([]byte, error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(seed))
+	sum := hash.Sum32()
+
+	// Pick two hues a third of the color wheel apart
+	hue := float64(sum%360) / 360
+	from := hslToRGB(hue, 0.55, 0.45)
+	to := hslToRGB(hue+1.0/3, 0.55, 0.25)
+
+	img := image.NewRGBA(image.Rect(0, 0, placeholderSize, placeholderSize))
+	for y := 0; y < placeholderSize; y++ {
+		for x := 0; x < placeholderSize; x++ {
+			t := float64(x+y) / float64(2*placeholderSize-2) // Position along the diagonal
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(float64(from.R) + t*(float64(to.R)-float64(from.R))),
+				G: uint8(float64(from.G) + t*(float64(to.G)-float64(from.G))),
+				B: uint8(float64(from.B) + t*(float64(to.B)-float64(from.B))),
+				A: 0xFF,
+			})
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// hslToRGB converts a color from hue, saturation and lightness, each between 0 and 1, to RGB
+func hslToRGB(h, s, l float64) color.RGBA {
+	hueToChannel := func(p, q, t float64) float64 {
+		for t < 0 {
+			t++
+		}
+		for t > 1 {
+			t--
+		}
+		switch {
+		case t < 1.0/6:
+			return p + (q-p)*6*t
+		case t < 1.0/2:
+			return q
+		case t < 2.0/3:
+			return p + (q-p)*(2.0/3-t)*6
+		}
+		return p
+	}
+
+	q := l * (1 + s)
+	if l >= 0.5 {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+
+	return color.RGBA{
+		R: uint8(hueToChannel(p, q, h+1.0/3) * 255),
+		G: uint8(hueToChannel(p, q, h) * 255),
+		B: uint8(hueToChannel(p, q, h-1.0/3) * 255),
+		A: 0xFF,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"music-library-management/api/models"
 	"music-library-management/config"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -50,3 +51,39 @@ func SeedGenres(client *mongo.Client, cfg *config.Config) {
 		}
 	}
 }
+
+// SeedAdmin creates the admin account configured through ADMIN_EMAIL and ADMIN_PASSWORD if it does not exist yet
+func SeedAdmin(client *mongo.Client, cfg *config.Config) {
+	if cfg.AdminEmail == "" || cfg.AdminPassword == "" {
+		return // Nothing to seed when no admin account is configured
+	}
+
+	// Get the users collection from the database
+	collection := GetDBCollection(client, cfg, "users")
+	email := strings.ToLower(strings.TrimSpace(cfg.AdminEmail)) // Normalize the email like registration does
+
+	// Check if the admin account already exists
+	var existingUser models.User
+	err := collection.FindOne(context.Background(), bson.M{"email": email}).Decode(&existingUser)
+	if err != mongo.ErrNoDocuments {
+		return // The account exists already (or the lookup failed); never overwrite it
+	}
+
+	hash, err := HashPassword(cfg.AdminPassword) // Hash the configured password
+	if err != nil {
+		log.Printf("Error seeding admin: %v", err)
+		return
+	}
+
+	admin := models.User{Email: email, Name: "Administrator", PasswordHash: hash, Role: models.RoleAdmin}
+	admin.BeforeCreate() // Set default values before creating the admin
+
+	_, err = collection.InsertOne(context.Background(), admin) // Insert the admin into the collection
+	if err != nil {
+		// Log an error if the insert fails
+		log.Printf("Error seeding admin: %v", err)
+	} else {
+		// Log the seeded admin
+		log.Printf("Seeded admin account %s", email)
+	}
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// SniffLen is the number of leading bytes SniffContentType looks at
+const SniffLen = 512
+
+// Content types recognized by SniffContentType
+const (
+	ContentTypeMP3     = "audio/mpeg"
+	ContentTypeAAC     = "audio/aac"
+	ContentTypeMP4     = "audio/mp4"
+	ContentTypeFLAC    = "audio/flac"
+	ContentTypeOgg     = "audio/ogg"
+	ContentTypeOpus    = "audio/opus"
+	ContentTypeWAV     = "audio/wav"
+	ContentTypeJPEG    = "image/jpeg"
+	ContentTypePNG     = "image/png"
+	ContentTypeWebP    = "image/webp"
+	ContentTypeMPEGTS  = "video/mp2t"
+	ContentTypeUnknown = "application/octet-stream"
+)
+
+// contentTypeExtensions maps the recognized content types to the extension files of that type are stored with
+var contentTypeExtensions = map[string]string{
+	ContentTypeMP3:  ".mp3",
+	ContentTypeAAC:  ".aac",
+	ContentTypeMP4:  ".m4a",
+	ContentTypeFLAC: ".flac",
+	ContentTypeOgg:  ".ogg",
+	ContentTypeOpus: ".opus",
+	ContentTypeWAV:  ".wav",
+	ContentTypeJPEG: ".jpg",
+	ContentTypePNG:  ".png",
+	ContentTypeWebP: ".webp",
+
+	// Segments of HLS streams packaged from audio files
+	ContentTypeMPEGTS: ".ts",
+}
+
+// Transport stream packets are of a fixed size and start with a sync byte
+const (
+	mpegTSPacketSize = 188
+	mpegTSSyncByte   = 0x47
+)
+
+// mp4AudioBrands are the ftyp brands of MP4 files holding audio only
+var mp4AudioBrands = map[string]bool{"M4A ": true, "M4B ": true, "M4P ": true, "F4A ": true}
+
+// SniffContentType detects the type of content from its magic bytes, looking at up to SniffLen leading bytes.
+// Unlike http.DetectContentType it only recognizes the audio containers and image formats accepted for uploads,
+// including MP3 and AAC streams without tags, and the HLS segments packaged from audio files, and returns
+// ContentTypeUnknown for anything else
+func SniffContentType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return ContentTypeMP3 // ID3v2 tags precede the MPEG frames
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return ContentTypeFLAC
+	case bytes.HasPrefix(head, []byte("OggS")):
+		// The first page of an Ogg stream holds the identification header of its codec
+		if bytes.Contains(head[:min(len(head), 64)], []byte("OpusHead")) {
+			return ContentTypeOpus
+		}
+		if bytes.Contains(head[:min(len(head), 64)], []byte("\x01vorbis")) {
+			return ContentTypeOgg
+		}
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")):
+		switch string(head[8:12]) {
+		case "WAVE":
+			return ContentTypeWAV
+		case "WEBP":
+			return ContentTypeWebP
+		}
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		if mp4AudioBrands[string(head[8:12])] {
+			return ContentTypeMP4
+		}
+	case len(head) > mpegTSPacketSize && head[0] == mpegTSSyncByte && head[mpegTSPacketSize] == mpegTSSyncByte:
+		return ContentTypeMPEGTS // Consecutive transport stream packets start with a sync byte
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return ContentTypeJPEG
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return ContentTypePNG
+	case len(head) >= 4 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return sniffFrameSync(binary.BigEndian.Uint32(head))
+	}
+
+	return ContentTypeUnknown
+}
+
+// sniffFrameSync tells MPEG audio frames from AAC ADTS frames by the header starting with a frame sync
+func sniffFrameSync(header uint32) string {
+	version := header >> 19 & 0x3
+	layer := header >> 17 & 0x3
+	if layer == 0 {
+		// ADTS headers have a 12-bit sync word, layer 0 and a valid sampling frequency index
+		if header>>20 == 0xFFF && header>>10&0xF < 13 {
+			return ContentTypeAAC
+		}
+		return ContentTypeUnknown
+	}
+
+	// MPEG audio frames have a valid version, bitrate and sample rate
+	bitrate := header >> 12 & 0xF
+	sampleRate := header >> 10 & 0x3
+	if version == 1 || bitrate == 0 || bitrate == 15 || sampleRate == 3 {
+		return ContentTypeUnknown
+	}
+	return ContentTypeMP3
+}
+
+// IsAudioType reports whether the content type is one of the recognized audio containers
+func IsAudioType(contentType string) bool {
+	switch contentType {
+	case ContentTypeMP3, ContentTypeAAC, ContentTypeMP4, ContentTypeFLAC, ContentTypeOgg, ContentTypeOpus, ContentTypeWAV:
+		return true
+	}
+	return false
+}
+
+// IsImageType reports whether the content type is one of the image formats accepted for covers
+func IsImageType(contentType string) bool {
+	switch contentType {
+	case ContentTypeJPEG, ContentTypePNG, ContentTypeWebP:
+		return true
+	}
+	return false
+}
+
+// ExtensionForType returns the extension files of a recognized content type are stored with, or an empty string
+func ExtensionForType(contentType string) string {
+	return contentTypeExtensions[contentType]
+}
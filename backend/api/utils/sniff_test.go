@@ -0,0 +1,80 @@
+package utils
+
+import "testing"
+
+func TestSniffContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		head        string
+		contentType string
+	}{
+		{"ID3v2 tag", "ID3\x04\x00\x00\x00\x00\x00\x00", ContentTypeMP3},
+		{"MPEG-1 layer III frame", "\xff\xfb\x90\x00", ContentTypeMP3},
+		{"MPEG-2 layer III frame", "\xff\xf3\x84\xc0", ContentTypeMP3},
+		{"MPEG frame with a free bitrate", "\xff\xfb\x00\x00", ContentTypeUnknown},
+		{"MPEG frame with a reserved sample rate", "\xff\xfb\x9c\x00", ContentTypeUnknown},
+		{"MPEG frame with a reserved version", "\xff\xeb\x90\x00", ContentTypeUnknown},
+		{"ADTS frame", "\xff\xf1\x50\x80\x02\x1f\xfc", ContentTypeAAC},
+		{"ADTS frame with a reserved sampling frequency", "\xff\xf1\x74\x80", ContentTypeUnknown},
+		{"FLAC stream", "fLaC\x00\x00\x00\x22", ContentTypeFLAC},
+		{"Ogg Vorbis", "OggS\x00\x02" + string(make([]byte, 22)) + "\x01vorbis", ContentTypeOgg},
+		{"Ogg Opus", "OggS\x00\x02" + string(make([]byte, 22)) + "OpusHead", ContentTypeOpus},
+		{"Ogg of another codec", "OggS\x00\x02" + string(make([]byte, 22)) + "\x80theora", ContentTypeUnknown},
+		{"MP4 audio", "\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00", ContentTypeMP4},
+		{"MP4 audiobook", "\x00\x00\x00\x20ftypM4B \x00\x00\x00\x00", ContentTypeMP4},
+		{"MP4 video", "\x00\x00\x00\x20ftypisom\x00\x00\x02\x00", ContentTypeUnknown},
+		{"RIFF WAVE", "RIFF\x24\x00\x00\x00WAVEfmt ", ContentTypeWAV},
+		{"RIFF WebP", "RIFF\x24\x00\x00\x00WEBPVP8 ", ContentTypeWebP},
+		{"RIFF of another form", "RIFF\x24\x00\x00\x00AVI LIST", ContentTypeUnknown},
+		{"MPEG transport stream", "\x47" + string(make([]byte, 187)) + "\x47", ContentTypeMPEGTS},
+		{"single transport stream packet", "\x47" + string(make([]byte, 187)), ContentTypeUnknown},
+		{"JPEG", "\xff\xd8\xff\xe0\x00\x10JFIF", ContentTypeJPEG},
+		{"PNG", "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR", ContentTypePNG},
+		{"RIFF too short for its form", "RIFF\x24\x00\x00\x00WA", ContentTypeUnknown},
+		{"frame sync too short for a header", "\xff\xfb", ContentTypeUnknown},
+		{"empty", "", ContentTypeUnknown},
+		{"text", "plain text", ContentTypeUnknown},
+		{"HTML", "<!DOCTYPE html>", ContentTypeUnknown},
+	}
+
+	for _, test := range tests {
+		if contentType := SniffContentType([]byte(test.head)); contentType != test.contentType {
+			t.Errorf("SniffContentType(%s) = %s, want %s", test.name, contentType, test.contentType)
+		}
+	}
+}
+
+func TestContentTypeClasses(t *testing.T) {
+	tests := []struct {
+		contentType string
+		extension   string
+		audio       bool
+		image       bool
+	}{
+		{ContentTypeMP3, ".mp3", true, false},
+		{ContentTypeAAC, ".aac", true, false},
+		{ContentTypeMP4, ".m4a", true, false},
+		{ContentTypeFLAC, ".flac", true, false},
+		{ContentTypeOgg, ".ogg", true, false},
+		{ContentTypeOpus, ".opus", true, false},
+		{ContentTypeWAV, ".wav", true, false},
+		{ContentTypeJPEG, ".jpg", false, true},
+		{ContentTypePNG, ".png", false, true},
+		{ContentTypeWebP, ".webp", false, true},
+		{ContentTypeMPEGTS, ".ts", false, false},
+		{ContentTypeUnknown, "", false, false},
+		{"text/plain", "", false, false},
+	}
+
+	for _, test := range tests {
+		if extension := ExtensionForType(test.contentType); extension != test.extension {
+			t.Errorf("ExtensionForType(%s) = %q, want %q", test.contentType, extension, test.extension)
+		}
+		if audio := IsAudioType(test.contentType); audio != test.audio {
+			t.Errorf("IsAudioType(%s) = %v, want %v", test.contentType, audio, test.audio)
+		}
+		if image := IsImageType(test.contentType); image != test.image {
+			t.Errorf("IsImageType(%s) = %v, want %v", test.contentType, image, test.image)
+		}
+	}
+}
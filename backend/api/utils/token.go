@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token types carried in the "typ" claim so a refresh token cannot be used as an access token
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+	MediaTokenType   = "media" // Passed in media URLs and only valid for the media endpoints of one track
+)
+
+// TokenClaims defines the claims stored in the JSON Web Tokens issued by the API
+type TokenClaims struct {
+	TokenType string `json:"typ"`           // One of the token types
+	TrackID   string `json:"trk,omitempty"` // The track a media token is limited to
+	jwt.RegisteredClaims
+}
+
+// GenerateToken creates a signed token for the given user ID
+func GenerateToken(userId, tokenType, secret string, ttl time.Duration) (string, time.Time, error) {
+	return signToken(TokenClaims{TokenType: tokenType}, userId, secret, ttl)
+}
+
+// GenerateMediaToken creates a signed media token for the given user ID, limited to the given track
+func GenerateMediaToken(userId, trackId, secret string, ttl time.Duration) (string, time.Time, error) {
+	return signToken(TokenClaims{TokenType: MediaTokenType, TrackID: trackId}, userId, secret, ttl)
+}
+
+// signToken completes the registered claims for the given user ID and signs them
+func signToken(claims TokenClaims, userId, secret string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl) // Compute the expiry time
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   userId,                        // The user the token belongs to
+		IssuedAt:  jwt.NewNumericDate(now),       // When the token was issued
+		ExpiresAt: jwt.NewNumericDate(expiresAt), // When the token expires
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims) // Sign with HMAC-SHA256
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+// ParseToken validates a signed token and checks that it has the expected type
+func ParseToken(tokenString, expectedType, secret string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil // Use the shared secret to verify the signature
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != expectedType {
+		return nil, fmt.Errorf("unexpected token type %q", claims.TokenType) // Reject tokens of the wrong type
+	}
+
+	return claims, nil
+}
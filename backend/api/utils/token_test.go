@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMediaTokenIsLimitedToItsTrack(t *testing.T) {
+	token, _, err := GenerateMediaToken("user", "track", "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateMediaToken: %v", err)
+	}
+
+	claims, err := ParseToken(token, MediaTokenType, "secret")
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims.Subject != "user" || claims.TrackID != "track" {
+		t.Errorf("claims = (%q, %q), want (user, track)", claims.Subject, claims.TrackID)
+	}
+
+	// A media token must not pass as an access token, nor an access token as a media token
+	if _, err := ParseToken(token, AccessTokenType, "secret"); err == nil {
+		t.Error("media token accepted as an access token")
+	}
+	access, _, err := GenerateToken("user", AccessTokenType, "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := ParseToken(access, MediaTokenType, "secret"); err == nil {
+		t.Error("access token accepted as a media token")
+	}
+}
+
+func TestMediaTokenExpires(t *testing.T) {
+	token, _, err := GenerateMediaToken("user", "track", "secret", -time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateMediaToken: %v", err)
+	}
+	if _, err := ParseToken(token, MediaTokenType, "secret"); err == nil {
+		t.Error("expired media token accepted")
+	}
+}
@@ -3,10 +3,40 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Policies for a client-supplied track duration that differs from the duration computed from the audio file
+const (
+	DurationPolicyReject   = "reject"   // Reject the request
+	DurationPolicyOverride = "override" // Replace the client-supplied duration with the computed one
+)
+
+// Drivers storing the content of uploaded files
+const (
+	StorageDriverLocal = "local" // Files on the local disk under UploadPath
+	StorageDriverS3    = "s3"    // Objects in a bucket of an S3-compatible object store
+)
+
+// Codecs renditions can be transcoded to
+const (
+	RenditionCodecMP3  = "mp3"
+	RenditionCodecOpus = "opus"
+	RenditionCodecAAC  = "aac"
+)
+
+// Rendition describes a version of every track's audio transcoded for streaming
+type Rendition struct {
+	Name    string // Name of the rendition, e.g. "mp3-128k"
+	Codec   string // One of the rendition codecs
+	Bitrate int    // Target bitrate in kbit/s
+}
+
 // Config struct to hold all configuration values
 type Config struct {
 	MongoHost  string // MongoDB host address
@@ -14,6 +44,48 @@ type Config struct {
 	MongoDB    string // MongoDB database name
 	Port       string // Application server port
 	UploadPath string // Path for uploaded files
+
+	StorageDriver   string        // Driver storing uploaded files: "local" or "s3"
+	S3Endpoint      string        // Host and port of the S3-compatible object store
+	S3AccessKey     string        // Access key of the object store
+	S3SecretKey     string        // Secret key of the object store
+	S3Bucket        string        // Bucket uploaded files are stored in
+	S3Region        string        // Region of the bucket
+	S3UseSSL        bool          // Whether the object store is reached over HTTPS
+	StorageRedirect bool          // Whether stream and cover requests are redirected to signed URLs when the driver supports them
+	SignedURLTTL    time.Duration // Lifetime of signed URLs
+
+	FileGCInterval    time.Duration // How often unused files are collected in the background; zero disables the sweeper
+	FileGCGracePeriod time.Duration // How long unused files are kept before being soft-deleted, and soft-deleted files before being purged
+
+	UploadMaxSize int64         // Largest resumable upload accepted, in bytes
+	UploadExpiry  time.Duration // How long a resumable upload is kept after its last chunk
+	AudioMaxSize  int64         // Largest audio file accepted, in bytes
+	ImageMaxSize  int64         // Largest cover image accepted, in bytes
+
+	FFmpegPath       string        // Path of the ffmpeg binary renditions are transcoded with
+	Renditions       []Rendition   // Renditions transcoded from every audio file for streaming
+	TranscodeWorkers int           // Number of transcoding jobs run at once; zero disables transcoding
+	TranscodeTimeout time.Duration // Longest time a transcoding job may run
+
+	HLSBitrates        []int         // Bitrates in kbit/s of the AAC variants every audio file is packaged in for HLS; empty disables HLS
+	HLSSegmentDuration time.Duration // Target duration of the HLS segments
+
+	ServeUploads bool // Whether the uploads directory is also served as static files under /uploads
+
+	DurationMismatchPolicy string // What to do when a client-supplied duration differs from the computed one: "reject" or "override"
+	DurationTolerance      int    // Seconds a client-supplied duration may differ from the computed one
+
+	JWTSecret       string        // Secret used to sign JSON Web Tokens
+	AccessTokenTTL  time.Duration // Lifetime of access tokens
+	RefreshTokenTTL time.Duration // Lifetime of refresh tokens
+	MediaTokenTTL   time.Duration // Lifetime of the track-scoped tokens carried in media URLs
+
+	APIKeyDefaultTTL time.Duration // Lifetime of API keys created without an explicit expiry
+	APIKeyMaxTTL     time.Duration // Longest lifetime an API key may be created with
+
+	AdminEmail    string // Email of the admin account created at startup, if set
+	AdminPassword string // Password of the admin account created at startup
 }
 
 // LoadConfig loads configuration from environment variables
@@ -48,11 +120,132 @@ func LoadConfig() (*Config, error) {
 		MongoDB:    getEnv("MONGO_DB", ""),    // Get the value of MONGO_DB or use the default value
 		Port:       getEnv("PORT", ""),        // Get the value of PORT or use the default value
 		UploadPath: getEnv("UPLOAD_PATH", ""), // Get the value of UPLOAD_PATH or use the default value
+
+		StorageDriver:   getEnv("STORAGE_DRIVER", StorageDriverLocal),     // Get the value of STORAGE_DRIVER or use the default value
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),                        // Get the value of S3_ENDPOINT or use the default value
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),                      // Get the value of S3_ACCESS_KEY or use the default value
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),                      // Get the value of S3_SECRET_KEY or use the default value
+		S3Bucket:        getEnv("S3_BUCKET", ""),                          // Get the value of S3_BUCKET or use the default value
+		S3Region:        getEnv("S3_REGION", ""),                          // Get the value of S3_REGION or use the default value
+		S3UseSSL:        getEnv("S3_USE_SSL", "false") == "true",          // Get the value of S3_USE_SSL or use the default value
+		StorageRedirect: getEnv("STORAGE_REDIRECT", "false") == "true",    // Get the value of STORAGE_REDIRECT or use the default value
+		SignedURLTTL:    getEnvDuration("SIGNED_URL_TTL", 15*time.Minute), // Get the value of SIGNED_URL_TTL or use the default value
+
+		FileGCInterval:    getEnvDuration("FILE_GC_INTERVAL", time.Hour),        // Get the value of FILE_GC_INTERVAL or use the default value
+		FileGCGracePeriod: getEnvDuration("FILE_GC_GRACE_PERIOD", 24*time.Hour), // Get the value of FILE_GC_GRACE_PERIOD or use the default value
+
+		UploadMaxSize: int64(getEnvInt("UPLOAD_MAX_SIZE", 2<<30)),    // Get the value of UPLOAD_MAX_SIZE or use the default value
+		UploadExpiry:  getEnvDuration("UPLOAD_EXPIRY", 24*time.Hour), // Get the value of UPLOAD_EXPIRY or use the default value
+		AudioMaxSize:  int64(getEnvInt("AUDIO_MAX_SIZE", 500<<20)),   // Get the value of AUDIO_MAX_SIZE or use the default value
+		ImageMaxSize:  int64(getEnvInt("IMAGE_MAX_SIZE", 10<<20)),    // Get the value of IMAGE_MAX_SIZE or use the default value
+
+		FFmpegPath:       getEnv("FFMPEG_PATH", "ffmpeg"),                     // Get the value of FFMPEG_PATH or use the default value
+		TranscodeWorkers: getEnvInt("TRANSCODE_WORKERS", 2),                   // Get the value of TRANSCODE_WORKERS or use the default value
+		TranscodeTimeout: getEnvDuration("TRANSCODE_TIMEOUT", 10*time.Minute), // Get the value of TRANSCODE_TIMEOUT or use the default value
+
+		HLSSegmentDuration: getEnvDuration("HLS_SEGMENT_DURATION", 6*time.Second), // Get the value of HLS_SEGMENT_DURATION or use the default value
+
+		ServeUploads: getEnv("SERVE_UPLOADS", "false") == "true", // Get the value of SERVE_UPLOADS or use the default value
+
+		DurationMismatchPolicy: getEnv("DURATION_MISMATCH_POLICY", DurationPolicyOverride), // Get the value of DURATION_MISMATCH_POLICY or use the default value
+		DurationTolerance:      getEnvInt("DURATION_TOLERANCE", 2),                         // Get the value of DURATION_TOLERANCE or use the default value
+
+		JWTSecret:       getEnv("JWT_SECRET", ""),                          // Get the value of JWT_SECRET or use the default value
+		AccessTokenTTL:  getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute),  // Get the value of JWT_ACCESS_TTL or use the default value
+		RefreshTokenTTL: getEnvDuration("JWT_REFRESH_TTL", 7*24*time.Hour), // Get the value of JWT_REFRESH_TTL or use the default value
+		MediaTokenTTL:   getEnvDuration("MEDIA_TOKEN_TTL", time.Hour),      // Get the value of MEDIA_TOKEN_TTL or use the default value
+
+		APIKeyDefaultTTL: getEnvDuration("API_KEY_DEFAULT_TTL", 90*24*time.Hour), // Get the value of API_KEY_DEFAULT_TTL or use the default value
+		APIKeyMaxTTL:     getEnvDuration("API_KEY_MAX_TTL", 365*24*time.Hour),    // Get the value of API_KEY_MAX_TTL or use the default value
+
+		AdminEmail:    getEnv("ADMIN_EMAIL", ""),    // Get the value of ADMIN_EMAIL or use the default value
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""), // Get the value of ADMIN_PASSWORD or use the default value
+	}
+
+	// Refuse to start without a signing secret, otherwise every token could be forged
+	if config.JWTSecret == "" {
+		return nil, fmt.Errorf("JWT_SECRET must be set")
+	}
+
+	// Refuse to start with an unknown duration policy
+	if config.DurationMismatchPolicy != DurationPolicyReject && config.DurationMismatchPolicy != DurationPolicyOverride {
+		return nil, fmt.Errorf("DURATION_MISMATCH_POLICY must be %q or %q", DurationPolicyReject, DurationPolicyOverride)
+	}
+
+	// Refuse to start with renditions that cannot be transcoded
+	config.Renditions, err = parseRenditions(getEnv("RENDITIONS", "mp3-128k,mp3-320k,opus-96k"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to start with HLS variants that cannot be packaged
+	config.HLSBitrates, err = parseBitrates(getEnv("HLS_BITRATES", "64,128,256"))
+	if err != nil {
+		return nil, err
+	}
+	if config.HLSSegmentDuration < time.Second {
+		return nil, fmt.Errorf("HLS_SEGMENT_DURATION must be at least 1s")
+	}
+
+	// Refuse to start with an unknown or incompletely configured storage driver
+	switch config.StorageDriver {
+	case StorageDriverLocal:
+	case StorageDriverS3:
+		if config.S3Endpoint == "" || config.S3Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET must be set when STORAGE_DRIVER is %q", StorageDriverS3)
+		}
+	default:
+		return nil, fmt.Errorf("STORAGE_DRIVER must be %q or %q", StorageDriverLocal, StorageDriverS3)
 	}
 
 	return config, nil // Return the loaded configuration
 }
 
+// parseRenditions parses a comma-separated list of renditions named after their codec and bitrate, e.g. "mp3-128k"
+func parseRenditions(value string) ([]Rendition, error) {
+	var renditions []Rendition
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		codec, bitrate, _ := strings.Cut(name, "-")
+		kbps, err := strconv.Atoi(strings.TrimSuffix(bitrate, "k"))
+		if err != nil || kbps <= 0 || !strings.HasSuffix(bitrate, "k") {
+			return nil, fmt.Errorf("RENDITIONS entry %q must be a codec and a bitrate, e.g. \"mp3-128k\"", name)
+		}
+		switch codec {
+		case RenditionCodecMP3, RenditionCodecOpus, RenditionCodecAAC:
+		default:
+			return nil, fmt.Errorf("RENDITIONS entry %q must use the codec %q, %q or %q", name, RenditionCodecMP3, RenditionCodecOpus, RenditionCodecAAC)
+		}
+
+		renditions = append(renditions, Rendition{Name: name, Codec: codec, Bitrate: kbps})
+	}
+	return renditions, nil
+}
+
+// parseBitrates parses a comma-separated list of bitrates in kbit/s, e.g. "64,128,256", in ascending order
+func parseBitrates(value string) ([]int, error) {
+	var bitrates []int
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kbps, err := strconv.Atoi(strings.TrimSuffix(entry, "k"))
+		if err != nil || kbps <= 0 {
+			return nil, fmt.Errorf("HLS_BITRATES entry %q must be a bitrate in kbit/s, e.g. \"128\"", entry)
+		}
+		bitrates = append(bitrates, kbps)
+	}
+
+	sort.Ints(bitrates)
+	return bitrates, nil
+}
+
 // getEnv gets the value of an environment variable or returns a default value if the variable is not set
 func getEnv(key, defaultValue string) string {
 	value, exists := os.LookupEnv(key) // Look up the environment variable
@@ -61,3 +254,33 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value // Return the value of the environment variable
 }
+
+// getEnvInt gets an integer from an environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key) // Look up the environment variable
+	if !exists {
+		return defaultValue // Return the default value if the variable is not set
+	}
+
+	number, err := strconv.Atoi(value) // Parse the integer string
+	if err != nil {
+		fmt.Printf("Invalid integer %q for %s, using default %d\n", value, key, defaultValue) // Print a message if the value cannot be parsed
+		return defaultValue
+	}
+	return number // Return the parsed integer
+}
+
+// getEnvDuration gets a duration (e.g. "15m", "24h") from an environment variable or returns a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key) // Look up the environment variable
+	if !exists {
+		return defaultValue // Return the default value if the variable is not set
+	}
+
+	duration, err := time.ParseDuration(value) // Parse the duration string
+	if err != nil {
+		fmt.Printf("Invalid duration %q for %s, using default %s\n", value, key, defaultValue) // Print a message if the value cannot be parsed
+		return defaultValue
+	}
+	return duration // Return the parsed duration
+}
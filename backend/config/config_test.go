@@ -0,0 +1,78 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRenditions(t *testing.T) {
+	renditions, err := parseRenditions(" mp3-128k, opus-96k,,aac-256k ")
+	if err != nil {
+		t.Fatalf("parseRenditions: %v", err)
+	}
+	want := []Rendition{
+		{Name: "mp3-128k", Codec: RenditionCodecMP3, Bitrate: 128},
+		{Name: "opus-96k", Codec: RenditionCodecOpus, Bitrate: 96},
+		{Name: "aac-256k", Codec: RenditionCodecAAC, Bitrate: 256},
+	}
+	if !reflect.DeepEqual(renditions, want) {
+		t.Errorf("renditions = %+v, want %+v", renditions, want)
+	}
+
+	if renditions, err := parseRenditions(""); err != nil || len(renditions) != 0 {
+		t.Errorf("parseRenditions(\"\") = %v, %v, want no renditions", renditions, err)
+	}
+
+	for _, value := range []string{"mp3", "mp3-128", "mp3-k", "mp3-0k", "mp3--128k", "flac-128k", "128k"} {
+		if _, err := parseRenditions(value); err == nil {
+			t.Errorf("parseRenditions(%q) succeeded", value)
+		}
+	}
+}
+
+func TestParseBitrates(t *testing.T) {
+	bitrates, err := parseBitrates("256, 64k,128")
+	if err != nil {
+		t.Fatalf("parseBitrates: %v", err)
+	}
+	if want := []int{64, 128, 256}; !reflect.DeepEqual(bitrates, want) {
+		t.Errorf("bitrates = %v, want %v", bitrates, want)
+	}
+
+	if bitrates, err := parseBitrates(""); err != nil || len(bitrates) != 0 {
+		t.Errorf("parseBitrates(\"\") = %v, %v, want no bitrates", bitrates, err)
+	}
+
+	for _, value := range []string{"0", "-64", "fast", "64,abc"} {
+		if _, err := parseBitrates(value); err == nil {
+			t.Errorf("parseBitrates(%q) succeeded", value)
+		}
+	}
+}
+
+func TestGetEnvParsers(t *testing.T) {
+	t.Setenv("TEST_INT", "42")
+	t.Setenv("TEST_BAD_INT", "many")
+	t.Setenv("TEST_DURATION", "90s")
+	t.Setenv("TEST_BAD_DURATION", "soon")
+
+	if got := getEnvInt("TEST_INT", 1); got != 42 {
+		t.Errorf("getEnvInt = %d, want 42", got)
+	}
+	if got := getEnvInt("TEST_BAD_INT", 1); got != 1 {
+		t.Errorf("getEnvInt of an invalid value = %d, want the default", got)
+	}
+	if got := getEnvInt("TEST_UNSET", 7); got != 7 {
+		t.Errorf("getEnvInt of an unset variable = %d, want the default", got)
+	}
+	if got := getEnvDuration("TEST_DURATION", time.Second); got != 90*time.Second {
+		t.Errorf("getEnvDuration = %s, want 1m30s", got)
+	}
+	if got := getEnvDuration("TEST_BAD_DURATION", time.Second); got != time.Second {
+		t.Errorf("getEnvDuration of an invalid value = %s, want the default", got)
+	}
+	if got := getEnv("TEST_UNSET", "default"); got != "default" {
+		t.Errorf("getEnv of an unset variable = %q, want the default", got)
+	}
+}
@@ -1,6 +1,8 @@
 package errors
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,6 +12,58 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+// statusCodes maps well-known errors to the HTTP status code they should be reported with
+var statusCodes = map[error]int{
+	ErrNotFound:               http.StatusNotFound,
+	ErrBadRequest:             http.StatusBadRequest,
+	ErrUnauthorized:           http.StatusUnauthorized,
+	ErrInvalidObjectID:        http.StatusBadRequest,
+	ErrTrackNotFound:          http.StatusNotFound,
+	ErrPlaylistNotFound:       http.StatusNotFound,
+	ErrGenreNotFound:          http.StatusNotFound,
+	ErrTrackAlreadyInPlaylist: http.StatusConflict,
+	ErrTrackNotInPlaylist:     http.StatusBadRequest,
+	ErrInvalidPosition:        http.StatusBadRequest,
+	ErrInvalidTrackOrder:      http.StatusBadRequest,
+	ErrPlaylistConflict:       http.StatusConflict,
+	ErrPlaylistHasDuplicates:  http.StatusConflict,
+	ErrInvalidPlaylistRules:   http.StatusBadRequest,
+	ErrSmartPlaylistTracks:    http.StatusConflict,
+	ErrNotSmartPlaylist:       http.StatusConflict,
+	ErrInvalidInput:           http.StatusBadRequest,
+	ErrUserNotFound:           http.StatusNotFound,
+	ErrEmailAlreadyExists:     http.StatusConflict,
+	ErrInvalidCredentials:     http.StatusUnauthorized,
+	ErrInvalidToken:           http.StatusUnauthorized,
+	ErrForbidden:              http.StatusForbidden,
+	ErrAPIKeyNotFound:         http.StatusNotFound,
+	ErrFileNotFound:           http.StatusNotFound,
+	ErrNoPlaybackSession:      http.StatusNotFound,
+	ErrInvalidAudioFile:       http.StatusBadRequest,
+	ErrDurationMismatch:       http.StatusUnprocessableEntity,
+	ErrUploadNotFound:         http.StatusNotFound,
+	ErrUploadOffsetMismatch:   http.StatusConflict,
+	ErrUploadTooLarge:         http.StatusRequestEntityTooLarge,
+	ErrUploadIncomplete:       http.StatusConflict,
+	ErrUnsupportedAudioType:   http.StatusUnsupportedMediaType,
+	ErrUnsupportedImageType:   http.StatusUnsupportedMediaType,
+	ErrUnsupportedFileType:    http.StatusUnsupportedMediaType,
+	ErrFileTooLarge:           http.StatusRequestEntityTooLarge,
+	ErrInvalidImageFile:       http.StatusBadRequest,
+	ErrRenditionNotFound:      http.StatusNotFound,
+	ErrHLSPackageNotFound:     http.StatusNotFound,
+	ErrWaveformNotFound:       http.StatusNotFound,
+	ErrTranscodingDisabled:    http.StatusServiceUnavailable,
+}
+
+// StatusCode returns the HTTP status code for a well-known error, or the fallback code for any other error
+func StatusCode(err error, fallback int) int {
+	if code, ok := statusCodes[err]; ok {
+		return code
+	}
+	return fallback
+}
+
 // HandleError is a utility function to handle errors in a standardized way
 func HandleError(c *gin.Context, code int, err error) {
 	c.JSON(code, ErrorResponse{
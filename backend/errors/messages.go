@@ -15,7 +15,37 @@ var (
 	ErrGenreNotFound          = errors.New("genre not found")                      // Error when a genre is not found
 	ErrTrackAlreadyInPlaylist = errors.New("track already exists in the playlist") // Error when a track is already in a playlist
 	ErrTrackNotInPlaylist     = errors.New("track does not exist in the playlist") // Error when a track is not in a playlist
+	ErrInvalidPosition        = errors.New("position is out of range")             // Error when a playlist position is past the end of its tracks
+	ErrInvalidTrackOrder      = errors.New("tracks must reorder the playlist")     // Error when a reordered list does not hold the playlist's tracks
+	ErrPlaylistConflict       = errors.New("playlist was changed meanwhile")       // Error when a playlist changed since the version an edit is based on
+	ErrPlaylistHasDuplicates  = errors.New("playlist contains duplicate tracks")   // Error when disallowing duplicates in a playlist that holds some
+	ErrInvalidPlaylistRules   = errors.New("invalid smart playlist rules")         // Error when the rules of a smart playlist do not compile
+	ErrSmartPlaylistTracks    = errors.New("smart playlists follow their rules")   // Error when editing the tracks of a smart playlist
+	ErrNotSmartPlaylist       = errors.New("playlist is not a smart playlist")     // Error when setting rules on a playlist of stored tracks
 	ErrInvalidInput           = errors.New("invalid input")                        // Error for invalid input
+	ErrUserNotFound           = errors.New("user not found")                       // Error when a user is not found
+	ErrEmailAlreadyExists     = errors.New("email is already registered")          // Error when registering an email that is taken
+	ErrInvalidCredentials     = errors.New("invalid email or password")            // Error when login credentials do not match
+	ErrInvalidToken           = errors.New("invalid or expired token")             // Error when a token fails validation
+	ErrForbidden              = errors.New("forbidden")                            // Error when the user may not perform the action
+	ErrAPIKeyNotFound         = errors.New("API key not found")                    // Error when an API key is not found
+	ErrFileNotFound           = errors.New("file not found")                       // Error when a file is not found
+	ErrNoPlaybackSession      = errors.New("no playback session for this track")   // Error when a device has no session for the track
+	ErrInvalidAudioFile       = errors.New("file is not a valid audio file")       // Error when no audio stream is found in an upload
+	ErrDurationMismatch       = errors.New("duration does not match the audio")    // Error when a supplied duration differs from the computed one
+	ErrUploadNotFound         = errors.New("upload not found")                     // Error when a resumable upload is not found or expired
+	ErrUploadOffsetMismatch   = errors.New("upload offset does not match")         // Error when a chunk is not written at the current offset
+	ErrUploadTooLarge         = errors.New("upload is too large")                  // Error when an upload is larger than allowed or declared
+	ErrUploadIncomplete       = errors.New("upload is not finished")               // Error when an unfinished upload is used for a track
+	ErrUnsupportedAudioType   = errors.New("audio format is not supported")        // Error when an audio upload is not a recognized audio container
+	ErrUnsupportedImageType   = errors.New("image must be JPEG, PNG or WebP")      // Error when a cover upload is not a recognized image format
+	ErrUnsupportedFileType    = errors.New("file type is not supported")           // Error when an upload is neither audio nor an image
+	ErrFileTooLarge           = errors.New("file exceeds the size limit")          // Error when an upload exceeds the size limit of its kind
+	ErrInvalidImageFile       = errors.New("file is not a valid image")            // Error when a cover image cannot be decoded
+	ErrRenditionNotFound      = errors.New("rendition not found")                  // Error when a track has no rendition of the requested quality
+	ErrHLSPackageNotFound     = errors.New("HLS stream not found")                 // Error when a track's audio is not packaged for HLS (yet)
+	ErrWaveformNotFound       = errors.New("waveform not found")                   // Error when the peaks of a track's audio are not computed (yet)
+	ErrTranscodingDisabled    = errors.New("audio processing is disabled")         // Error when no transcoding worker is configured to run queued jobs
 )
 
 // CustomError represents a custom error type
@@ -7,8 +7,10 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"music-library-management/api/controllers"
+	"music-library-management/api/middleware"
 	"music-library-management/api/routes"
 	"music-library-management/api/services"
+	"music-library-management/api/storage"
 	"music-library-management/api/utils"
 	"music-library-management/config"
 )
@@ -40,9 +42,30 @@ func main() {
 		log.Fatalf("Error initializing collections: %v", err) // Log and exit if there is an error initializing collections
 	}
 
+	// Create indexes
+	err = utils.InitializeIndexes(db) // Create the MongoDB indexes
+	if err != nil {
+		log.Fatalf("Error initializing indexes: %v", err) // Log and exit if there is an error creating indexes
+	}
+
+	// Migrate tracks stored before tracks had a format-neutral audio asset
+	err = utils.MigrateAudioAssets(db) // Move the MP3 file fields into the audio asset
+	if err != nil {
+		log.Fatalf("Error migrating tracks: %v", err) // Log and exit if the migration fails
+	}
+
 	// Seed genres collection with sample data
 	utils.SeedGenres(client, cfg) // Seed the genres collection with sample data
 
+	// Seed the admin account
+	utils.SeedAdmin(client, cfg) // Create the configured admin account if it does not exist
+
+	// Migrate playlists created before playlists had owners
+	err = utils.MigratePlaylistOwners(db) // Assign the playlists without an owner to the earliest admin
+	if err != nil {
+		log.Fatalf("Error migrating playlists: %v", err) // Log and exit if the migration fails
+	}
+
 	// Initialize Gin router
 	router := gin.Default() // Create a new Gin router with default settings
 
@@ -55,15 +78,43 @@ func main() {
 	// Middleware
 	// router.Use(middleware.CORSMiddleware()) // Uncomment this line to enable CORS middleware
 
-	// Serve static files from the uploads directory
-	router.Static("/uploads", "./uploads") // Serve static files from the "uploads" directory
+	// Serve static files from the uploads directory only when enabled; tracks are served through their stream and cover endpoints
+	if cfg.ServeUploads {
+		router.Static("/uploads", "./uploads") // Serve static files from the "uploads" directory
+	}
 
 	// Initialize services and controllers
-	fileService := services.NewFileService(client, cfg)          // Create a new FileService instance
-	fileController := controllers.NewFileController(fileService) // Create a new FileController instance
+	userService := services.NewUserService(client, cfg)                                // Create a new UserService instance
+	authService := services.NewAuthService(userService, cfg)                           // Create a new AuthService instance
+	authController := controllers.NewAuthController(authService, userService)          // Create a new AuthController instance
+	apiKeyService := services.NewAPIKeyService(client, cfg, userService)               // Create a new APIKeyService instance
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)                 // Create a new APIKeyController instance
+	authMiddleware := middleware.AuthMiddleware(authService, apiKeyService)            // Create the middleware guarding the API routes
+	mediaAuthMiddleware := middleware.MediaAuthMiddleware(authService, authMiddleware) // Create the middleware guarding the media routes
+	userController := controllers.NewUserController(userService)                       // Create a new UserController instance
+
+	// Initialize the storage driver selected in the configuration
+	store, err := storage.NewStorage(cfg) // Create the local disk or S3 storage
+	if err != nil {
+		log.Fatalf("Error initializing storage: %v", err) // Log and exit if the storage cannot be reached
+	}
+
+	trackService := services.NewTrackService(client, cfg)                       // Create a new TrackService instance
+	fileService := services.NewFileService(client, cfg, store)                  // Create a new FileService instance
+	uploadService := services.NewUploadService(client, cfg, store, fileService) // Create a new UploadService instance
+	uploadController := controllers.NewUploadController(uploadService)          // Create a new UploadController instance
+	thumbnailService := services.NewThumbnailService(fileService)               // Create a new ThumbnailService instance
+
+	fileGCService := services.NewFileGCService(client, cfg, store, trackService, uploadService) // Create a new FileGCService instance
+	fileController := controllers.NewFileController(fileService, fileGCService)                 // Create a new FileController instance
+
+	transcodeService := services.NewTranscodeService(client, cfg, fileService, trackService) // Create a new TranscodeService instance
 
-	trackService := services.NewTrackService(client, cfg)                        // Create a new TrackService instance
-	trackController := controllers.NewTrackController(trackService, fileService) // Create a new TrackController instance
+	playService := services.NewPlayService(client, cfg, trackService)                                                                                             // Create a new PlayService instance
+	playController := controllers.NewPlayController(playService, trackService)                                                                                    // Create a new PlayController instance
+	playbackService := services.NewPlaybackService(client, cfg, trackService, playService)                                                                        // Create a new PlaybackService instance
+	playbackController := controllers.NewPlaybackController(playbackService)                                                                                      // Create a new PlaybackController instance
+	trackController := controllers.NewTrackController(trackService, fileService, uploadService, thumbnailService, playbackService, transcodeService, authService) // Create a new TrackController instance
 
 	playlistService := services.NewPlaylistService(client, cfg, trackService) // Create a new PlaylistService instance
 	playlistController := controllers.NewPlaylistController(playlistService)  // Create a new PlaylistController instance
@@ -75,11 +126,21 @@ func main() {
 	searchController := controllers.NewSearchController(searchService) // Create a new SearchController instance
 
 	// Initialize routes
-	routes.FileRoutes(router, fileController)         // Initialize file routes
-	routes.TrackRoutes(router, trackController)       // Initialize track routes
-	routes.PlaylistRoutes(router, playlistController) // Initialize playlist routes
-	routes.GenreRoutes(router, genreController)       // Initialize genre routes
-	routes.SearchRoutes(router, searchController)     // Initialize search routes
+	routes.AuthRoutes(router, authController, authMiddleware)                        // Initialize auth routes
+	routes.UserRoutes(router, userController, authMiddleware)                        // Initialize user administration routes
+	routes.APIKeyRoutes(router, apiKeyController, authMiddleware)                    // Initialize API key routes
+	routes.FileRoutes(router, fileController, authMiddleware)                        // Initialize file routes
+	routes.UploadRoutes(router, uploadController, authMiddleware)                    // Initialize resumable upload routes
+	routes.TrackRoutes(router, trackController, authMiddleware, mediaAuthMiddleware) // Initialize track routes
+	routes.PlaybackRoutes(router, playbackController, authMiddleware)                // Initialize playback routes
+	routes.MeRoutes(router, playController, authMiddleware)                          // Initialize routes of the authenticated user
+	routes.PlaylistRoutes(router, playlistController, authMiddleware)                // Initialize playlist routes
+	routes.GenreRoutes(router, genreController, authMiddleware)                      // Initialize genre routes
+	routes.SearchRoutes(router, searchController, authMiddleware)                    // Initialize search routes
+
+	// Start the background jobs
+	fileGCService.StartSweeper()    // Collect unused files every FILE_GC_INTERVAL
+	transcodeService.StartWorkers() // Transcode the audio of tracks into the configured renditions
 
 	// Start the server
 	log.Fatal(router.Run("0.0.0.0:" + cfg.Port)) // Start the Gin server on all network interfaces and log any fatal errors